@@ -0,0 +1,489 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptyPlan is returned by Adapt when nothing is left to send, e.g. for a text of whitespace only.
+var ErrEmptyPlan = errors.New("nothing to send")
+
+// AdaptationKind identifies a change Adapt made to fit a message into channel limits.
+type AdaptationKind string
+
+const (
+	AdaptationTextSplit          AdaptationKind = "text_split"
+	AdaptationItemsSplit         AdaptationKind = "items_split"
+	AdaptationOrderAsText        AdaptationKind = "order_as_text"
+	AdaptationProductAsText      AdaptationKind = "product_as_text"
+	AdaptationSuggestionsDropped AdaptationKind = "suggestions_dropped"
+)
+
+// Adaptation describes a single change made by Adapt.
+type Adaptation struct {
+	Kind    AdaptationKind
+	Message string
+}
+
+// SendPlan is a sequence of messages that together deliver an adapted message.
+type SendPlan struct {
+	Messages    []SendMessageRequestBody
+	Adaptations []Adaptation
+}
+
+// Adapted reports whether the original message had to be changed.
+func (p SendPlan) Adapted() bool {
+	return len(p.Adaptations) > 0
+}
+
+// Execute sends the planned messages in order and stops at the first failure.
+// Responses of the messages sent before the failure are returned along with the error.
+func (p SendPlan) Execute(
+	ctx context.Context, client ClientWithResponsesInterface, reqEditors ...RequestEditorFn,
+) ([]SendMessageResponse, error) {
+	sent := make([]SendMessageResponse, 0, len(p.Messages))
+
+	for i, body := range p.Messages {
+		resp, err := client.SendMessageWithResponse(ctx, body, reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return sent, fmt.Errorf("send message %d of %d: %w", i+1, len(p.Messages), err)
+		}
+
+		if resp.JSON200 != nil {
+			sent = append(sent, *resp.JSON200)
+		}
+	}
+
+	return sent, nil
+}
+
+// Adapt turns a message into a plan of one or more messages that fit the channel settings:
+//   - order and product messages become formatted text when the channel cannot create them;
+//   - suggestions of types the channel does not support are dropped;
+//   - long text is split at paragraph, line, sentence or word boundaries to fit Text.MaxCharsCount;
+//   - attachments are split into batches of at most MaxItemsCount.
+//
+// The quoted message is kept on the first message of the plan and suggestions on the last one.
+// Violations that cannot be fixed by adaptation (e.g. unsupported quoting) are returned as Violations,
+// and ErrEmptyPlan is returned if the plan has no messages.
+// Private messages are not delivered to the channel and are returned unchanged.
+func Adapt(settings ChannelSettings, body SendMessageRequestBody) (SendPlan, error) {
+	if body.Scope == MessageScopePrivate {
+		return SendPlan{Messages: []SendMessageRequestBody{body}}, nil
+	}
+
+	var plan SendPlan
+
+	msgType := MessageTypeText
+	if body.Type != nil {
+		msgType = *body.Type
+	}
+
+	switch {
+	case msgType == MessageTypeOrder && !settings.Order.Creating.CanSend() && body.Order != nil:
+		body = asText(body, FormatOrder(*body.Order))
+		msgType = MessageTypeText
+		plan.adapted(AdaptationOrderAsText, "order message sent as text")
+	case msgType == MessageTypeProduct && !settings.Product.Creating.CanSend() && body.Product != nil:
+		body = asText(body, FormatProduct(*body.Product))
+		msgType = MessageTypeText
+		plan.adapted(AdaptationProductAsText, "product message sent as text")
+	}
+
+	if body.TransportAttachments != nil && len(body.TransportAttachments.Suggestions) > 0 {
+		kept := make([]Suggestion, 0, len(body.TransportAttachments.Suggestions))
+		for _, s := range body.TransportAttachments.Suggestions {
+			if suggestionFeature(settings.Suggestions, s.Type).CanSend() {
+				kept = append(kept, s)
+			}
+		}
+
+		if dropped := len(body.TransportAttachments.Suggestions) - len(kept); dropped > 0 {
+			attachments := *body.TransportAttachments
+			attachments.Suggestions = kept
+			body.TransportAttachments = &attachments
+			plan.adapted(AdaptationSuggestionsDropped, "%d unsupported suggestions dropped", dropped)
+		}
+	}
+
+	switch {
+	case msgType == MessageTypeText && body.Content != nil && settings.Text.MaxCharsCount != nil:
+		chunks := SplitText(*body.Content, int(*settings.Text.MaxCharsCount))
+		plan.Messages = make([]SendMessageRequestBody, 0, len(chunks))
+		for i := range chunks {
+			part := body
+			part.Content = &chunks[i]
+			plan.Messages = append(plan.Messages, part)
+		}
+		if len(chunks) > 1 {
+			plan.adapted(AdaptationTextSplit, "text split into %d messages", len(chunks))
+		}
+	case body.Items != nil:
+		maxItems, _ := itemLimits(settings, msgType)
+		batches := splitItems(*body.Items, maxItems)
+		plan.Messages = make([]SendMessageRequestBody, 0, len(batches))
+		for i := range batches {
+			part := body
+			part.Items = &batches[i]
+			if i > 0 {
+				part.Note = nil
+			}
+			plan.Messages = append(plan.Messages, part)
+		}
+		if len(batches) > 1 {
+			plan.adapted(AdaptationItemsSplit, "attachments split into %d messages", len(batches))
+		}
+	default:
+		plan.Messages = []SendMessageRequestBody{body}
+	}
+	if len(plan.Messages) == 0 {
+		return SendPlan{}, ErrEmptyPlan
+	}
+
+	for i := range plan.Messages {
+		if i > 0 {
+			plan.Messages[i].QuoteMessageID = 0
+		}
+		if i < len(plan.Messages)-1 {
+			plan.Messages[i].TransportAttachments = nil
+		}
+	}
+
+	var violations Violations
+	for _, msg := range plan.Messages {
+		violations = append(violations, CheckSend(settings, msg)...)
+	}
+
+	if err := violations.Err(); err != nil {
+		return SendPlan{}, err
+	}
+
+	return plan, nil
+}
+
+// Adapt resolves the settings of the message's channel and adapts the message to them.
+func (p *Preflight) Adapt(ctx context.Context, body SendMessageRequestBody) (SendPlan, error) {
+	if body.Scope == MessageScopePrivate {
+		return Adapt(ChannelSettings{}, body)
+	}
+
+	settings, err := p.ChannelSettings(ctx, body.ChatID)
+	if err != nil {
+		return SendPlan{}, err
+	}
+
+	return Adapt(settings, body)
+}
+
+func (p *SendPlan) adapted(kind AdaptationKind, format string, args ...interface{}) {
+	p.Adaptations = append(p.Adaptations, Adaptation{Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+func asText(body SendMessageRequestBody, text string) SendMessageRequestBody {
+	t := MessageTypeText
+	body.Type = &t
+	body.Content = &text
+	body.Order = nil
+	body.Product = nil
+
+	return body
+}
+
+func splitItems(items []MessageItem, limit *int) [][]MessageItem {
+	if limit == nil || *limit <= 0 || len(items) <= *limit {
+		return [][]MessageItem{items}
+	}
+
+	batches := make([][]MessageItem, 0, (len(items)+*limit-1) / *limit)
+	for start := 0; start < len(items); start += *limit {
+		end := min(start+*limit, len(items))
+		batches = append(batches, items[start:end:end])
+	}
+
+	return batches
+}
+
+// FormatOrder renders an order as plain text for channels that cannot display order messages.
+func FormatOrder(order MessageOrder) string {
+	var b strings.Builder
+
+	b.WriteString("Order")
+	if order.Number != "" {
+		b.WriteString(" #" + order.Number)
+	}
+	if order.Date != nil {
+		b.WriteString(" of " + order.Date.Format("2006-01-02"))
+	}
+	if order.Status != nil {
+		if status := firstNonEmpty(order.Status.Name, string(order.Status.Code)); status != "" {
+			b.WriteString(" (" + status + ")")
+		}
+	}
+
+	for _, item := range order.Items {
+		b.WriteString("\n- " + item.Name)
+		if item.Quantity.Value > 0 {
+			b.WriteString(" × " + formatQuantity(item.Quantity))
+		}
+		if item.Price != nil {
+			b.WriteString(" — " + formatCost(*item.Price))
+		}
+	}
+
+	if order.Delivery != nil {
+		delivery := order.Delivery
+		parts := make([]string, 0, 2)
+		for _, s := range []string{delivery.Name, delivery.Address} {
+			if s != "" {
+				parts = append(parts, s)
+			}
+		}
+		b.WriteString("\nDelivery: " + strings.Join(parts, ", "))
+		if delivery.Price != nil {
+			b.WriteString(" — " + formatCost(*delivery.Price))
+		}
+		if delivery.Comment != "" {
+			b.WriteString("\n" + delivery.Comment)
+		}
+	}
+
+	for _, payment := range order.Payments {
+		b.WriteString("\nPayment: " + payment.Name)
+		if payment.Amount != nil {
+			b.WriteString(" — " + formatCost(*payment.Amount))
+		}
+	}
+
+	if order.Discount != nil {
+		b.WriteString("\nDiscount: " + formatCost(*order.Discount))
+	}
+	if order.Cost != nil {
+		b.WriteString("\nTotal: " + formatCost(*order.Cost))
+	}
+	if order.Url != "" {
+		b.WriteString("\n" + order.Url)
+	}
+
+	return b.String()
+}
+
+// FormatProduct renders a product as plain text for channels that cannot display product messages.
+func FormatProduct(product MessageProduct) string {
+	var b strings.Builder
+
+	b.WriteString(product.Name)
+	if product.Article != "" {
+		b.WriteString("\n" + product.Article)
+	}
+	if product.Cost != nil {
+		b.WriteString("\nPrice: " + formatCost(*product.Cost))
+		if product.Unit != "" {
+			b.WriteString(" / " + product.Unit)
+		}
+	}
+	if product.Url != "" {
+		b.WriteString("\n" + product.Url)
+	}
+
+	return b.String()
+}
+
+func formatCost(c Cost) string {
+	return strings.TrimSpace(strconv.FormatFloat(c.Value, 'f', -1, 64) + " " + c.Currency)
+}
+
+func formatQuantity(q Quantity) string {
+	return strings.TrimSpace(strconv.FormatFloat(q.Value, 'f', -1, 64) + " " + q.Unit)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// Boundary ranks used by SplitText, from the least to the most preferred.
+const (
+	boundaryNone = iota
+	boundaryWord
+	boundarySentence
+	boundaryLine
+	boundaryParagraph
+)
+
+// SplitText splits text into chunks of at most limit characters as counted by TextLength.
+// Chunks end at paragraph, line, sentence or word boundaries when possible and never
+// break a grapheme cluster (combined characters, emoji sequences, flags).
+// Whitespace around the split points is dropped, so a text of whitespace only that exceeds the limit
+// yields no chunks.
+func SplitText(text string, limit int) []string {
+	if limit <= 0 || TextLength(text) <= limit {
+		return []string{text}
+	}
+
+	clusters := graphemes(text)
+	offsets := make([]int, len(clusters)+1)
+	for i, c := range clusters {
+		offsets[i+1] = offsets[i] + TextLength(c)
+	}
+
+	var chunks []string
+	for start := 0; start < len(clusters); {
+		for start < len(clusters) && isSpaceCluster(clusters[start]) {
+			start++
+		}
+		if start == len(clusters) {
+			break
+		}
+
+		end := start
+		for end < len(clusters) && offsets[end+1]-offsets[start] <= limit {
+			end++
+		}
+
+		switch {
+		case end == start:
+			// A single cluster longer than the limit cannot be split without breaking it.
+			end++
+		case end < len(clusters):
+			end = breakPoint(clusters, offsets, start, end, limit)
+		}
+
+		chunk := strings.TrimRightFunc(strings.Join(clusters[start:end], ""), unicode.IsSpace)
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		start = end
+	}
+
+	return chunks
+}
+
+// breakPoint picks where to end a chunk of clusters[start:end].
+// Paragraph, line and sentence boundaries are used when they keep at least half of the limit,
+// word boundaries anywhere; otherwise the chunk is cut at end.
+func breakPoint(clusters []string, offsets []int, start, end, limit int) int {
+	var best [boundaryParagraph + 1]int
+	for i := end; i > start; i-- {
+		if rank := boundaryRank(clusters, start, i); best[rank] == 0 {
+			best[rank] = i
+		}
+	}
+
+	for rank := boundaryParagraph; rank >= boundarySentence; rank-- {
+		if i := best[rank]; i > 0 && offsets[i]-offsets[start] >= limit/2 {
+			return i
+		}
+	}
+
+	if best[boundaryWord] > 0 {
+		return best[boundaryWord]
+	}
+
+	return end
+}
+
+// boundaryRank classifies the position between clusters[i-1] and clusters[i].
+func boundaryRank(clusters []string, start, i int) int {
+	prev := clusters[i-1]
+
+	switch {
+	case isNewlineCluster(prev) && i-2 >= start && isNewlineCluster(clusters[i-2]):
+		return boundaryParagraph
+	case isNewlineCluster(prev):
+		return boundaryLine
+	case isSpaceCluster(prev) && i-2 >= start && endsSentence(clusters[i-2]):
+		return boundarySentence
+	case isSpaceCluster(prev):
+		return boundaryWord
+	case i < len(clusters) && isSpaceCluster(clusters[i]):
+		if endsSentence(prev) {
+			return boundarySentence
+		}
+
+		return boundaryWord
+	}
+
+	return boundaryNone
+}
+
+func isSpaceCluster(c string) bool {
+	return strings.TrimSpace(c) == ""
+}
+
+func isNewlineCluster(c string) bool {
+	return c == "\n" || c == "\r\n" || c == "\r"
+}
+
+func endsSentence(c string) bool {
+	return strings.ContainsAny(c, ".!?…。！？")
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// graphemes splits text into approximate extended grapheme clusters.
+// It keeps together CR LF, combining marks, variation selectors, emoji modifiers,
+// zero-width joiner sequences, tag sequences and regional indicator pairs.
+func graphemes(s string) []string {
+	clusters := make([]string, 0, len(s))
+
+	var (
+		prev     rune = -1
+		riRun    int
+		clusterI int
+	)
+	for i, r := range s {
+		if prev >= 0 && !extendsCluster(prev, r, riRun) {
+			clusters = append(clusters, s[clusterI:i])
+			clusterI = i
+		}
+
+		if isRegionalIndicator(r) {
+			riRun++
+		} else {
+			riRun = 0
+		}
+		prev = r
+	}
+
+	if clusterI < len(s) {
+		clusters = append(clusters, s[clusterI:])
+	}
+
+	return clusters
+}
+
+func extendsCluster(prev, r rune, riRun int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == '\n' || prev == '\r':
+		return false
+	case r == zeroWidthJoiner || prev == zeroWidthJoiner:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF: // variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // emoji tag sequences
+		return true
+	case isRegionalIndicator(r) && isRegionalIndicator(prev):
+		return riRun%2 == 1
+	}
+
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
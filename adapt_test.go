@@ -0,0 +1,252 @@
+package bot_api_client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitText(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "fits",
+			text:     "short text",
+			limit:    20,
+			expected: []string{"short text"},
+		},
+		{
+			name:     "paragraph boundary",
+			text:     "First paragraph here.\n\nSecond one.",
+			limit:    30,
+			expected: []string{"First paragraph here.", "Second one."},
+		},
+		{
+			name:     "sentence boundary",
+			text:     "One two three. Four five six seven.",
+			limit:    25,
+			expected: []string{"One two three.", "Four five six seven."},
+		},
+		{
+			name:     "word boundary",
+			text:     "alpha beta gamma delta",
+			limit:    12,
+			expected: []string{"alpha beta", "gamma delta"},
+		},
+		{
+			name:     "hard split",
+			text:     "abcdefghij",
+			limit:    4,
+			expected: []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:     "surrogate pairs are not broken",
+			text:     "😀😀😀",
+			limit:    3,
+			expected: []string{"😀", "😀", "😀"},
+		},
+		{
+			name:     "zwj sequences and flags are kept whole",
+			text:     "👩‍👩‍👧🇩🇪🇫🇷",
+			limit:    8,
+			expected: []string{"👩‍👩‍👧", "🇩🇪🇫🇷"},
+		},
+		{
+			name:     "combining marks are kept with the base",
+			text:     "e\u0301e\u0301e\u0301",
+			limit:    3,
+			expected: []string{"e\u0301", "e\u0301", "e\u0301"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			chunks := SplitText(tc.text, tc.limit)
+			assert.Equal(t, tc.expected, chunks)
+			for _, chunk := range chunks {
+				assert.LessOrEqual(t, TextLength(chunk), tc.limit)
+			}
+		})
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	t.Parallel()
+
+	maxChars := uint16(20)
+	maxItems := 2
+	settings := ChannelSettings{
+		Text: TextMessageSetting{
+			Creating:      ChannelFeatureBoth,
+			Quoting:       ChannelFeatureBoth,
+			MaxCharsCount: &maxChars,
+		},
+		File: FileMessageSetting{
+			Creating:      ChannelFeatureBoth,
+			Quoting:       ChannelFeatureBoth,
+			MaxItemsCount: &maxItems,
+		},
+		Order:       OrderMessageSetting{Creating: ChannelFeatureNone},
+		Suggestions: Suggestions{Text: ChannelFeatureBoth},
+	}
+
+	t.Run("long text", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewText(1, "The parcel is ready. Pick it up tomorrow.").
+			Quote(7).
+			Suggestions(
+				Suggestion{Type: SuggestionTypeText, Title: "OK"},
+				Suggestion{Type: SuggestionTypePhone, Title: "Call"},
+			).
+			Build()
+		require.NoError(t, err)
+
+		plan, err := Adapt(settings, body)
+		require.NoError(t, err)
+		require.Len(t, plan.Messages, 2)
+		assert.True(t, plan.Adapted())
+
+		assert.Equal(t, "The parcel is ready.", *plan.Messages[0].Content)
+		assert.Equal(t, int64(7), plan.Messages[0].QuoteMessageID)
+		assert.Nil(t, plan.Messages[0].TransportAttachments)
+		assert.Equal(t, "Pick it up tomorrow.", *plan.Messages[1].Content)
+		assert.Zero(t, plan.Messages[1].QuoteMessageID)
+		require.NotNil(t, plan.Messages[1].TransportAttachments)
+		assert.Equal(t, []Suggestion{{Type: SuggestionTypeText, Title: "OK"}}, plan.Messages[1].TransportAttachments.Suggestions)
+
+		kinds := make([]AdaptationKind, 0, len(plan.Adaptations))
+		for _, a := range plan.Adaptations {
+			kinds = append(kinds, a.Kind)
+		}
+		assert.Equal(t, []AdaptationKind{AdaptationSuggestionsDropped, AdaptationTextSplit}, kinds)
+		assert.Equal(t, "The parcel is ready. Pick it up tomorrow.", *body.Content)
+	})
+
+	t.Run("file batches", func(t *testing.T) {
+		t.Parallel()
+
+		items := []MessageItem{
+			NewMessageItem(uuid.New(), "1"),
+			NewMessageItem(uuid.New(), "2"),
+			NewMessageItem(uuid.New(), "3"),
+		}
+		body, err := NewFiles(1, items...).Note("docs").Build()
+		require.NoError(t, err)
+
+		plan, err := Adapt(settings, body)
+		require.NoError(t, err)
+		require.Len(t, plan.Messages, 2)
+		assert.Equal(t, items[:2], *plan.Messages[0].Items)
+		assert.Equal(t, "docs", *plan.Messages[0].Note)
+		assert.Equal(t, items[2:], *plan.Messages[1].Items)
+		assert.Nil(t, plan.Messages[1].Note)
+	})
+
+	t.Run("order as text", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewOrder(1, MessageOrder{
+			Number: "A-1",
+			Items:  []MessageOrderItem{{Name: "Socks", Quantity: Quantity{Value: 2, Unit: "pcs"}}},
+			Cost:   &Cost{Currency: "USD", Value: 10.5},
+		}).Build()
+		require.NoError(t, err)
+
+		maxChars := uint16(1000)
+		textSettings := settings
+		textSettings.Text.MaxCharsCount = &maxChars
+
+		plan, err := Adapt(textSettings, body)
+		require.NoError(t, err)
+		require.Len(t, plan.Messages, 1)
+		assert.Equal(t, MessageTypeText, *plan.Messages[0].Type)
+		assert.Nil(t, plan.Messages[0].Order)
+		assert.Equal(t, "Order #A-1\n- Socks × 2 pcs\nTotal: 10.5 USD", *plan.Messages[0].Content)
+		assert.Equal(t, AdaptationOrderAsText, plan.Adaptations[0].Kind)
+	})
+
+	t.Run("whitespace only text", func(t *testing.T) {
+		t.Parallel()
+
+		body := SendMessageRequestBody{ChatID: 1, Scope: MessageScopePublic, Content: optional(strings.Repeat(" \n", 15))}
+
+		_, err := Adapt(settings, body)
+		require.ErrorIs(t, err, ErrEmptyPlan)
+	})
+
+	t.Run("unfixable violations", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewAudio(1, NewMessageItem(uuid.New(), "voice")).Build()
+		require.NoError(t, err)
+
+		_, err = Adapt(settings, body)
+		var violations Violations
+		require.ErrorAs(t, err, &violations)
+		assert.True(t, violations.Has(ViolationMessageTypeUnsupported))
+	})
+}
+
+func TestFormatProduct(t *testing.T) {
+	t.Parallel()
+
+	text := FormatProduct(MessageProduct{
+		Name:    "Sweater",
+		Article: "Wool, size M",
+		Cost:    &Cost{Currency: "EUR", Value: 40},
+		Unit:    "pcs",
+		Url:     "https://example.com/p/1",
+	})
+	assert.Equal(t, "Sweater\nWool, size M\nPrice: 40 EUR / pcs\nhttps://example.com/p/1", text)
+}
+
+func TestSendPlanExecute(t *testing.T) {
+	t.Parallel()
+
+	var contents []string
+	mockDoer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		contents = append(contents, string(body))
+
+		status, payload := http.StatusOK, `{"message_id": 1, "time": "2025-01-01T00:00:00Z"}`
+		if len(contents) == 2 {
+			status, payload = http.StatusBadRequest, `{"errors": ["chat is closed"]}`
+		}
+
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(payload)),
+		}, nil
+	})
+	client, err := NewClientWithResponses("https://example.com", WithHTTPClient(mockDoer))
+	require.NoError(t, err)
+
+	first, second, third := "one", "two", "three"
+	plan := SendPlan{Messages: []SendMessageRequestBody{
+		{ChatID: 1, Content: &first},
+		{ChatID: 1, Content: &second},
+		{ChatID: 1, Content: &third},
+	}}
+
+	sent, err := plan.Execute(context.Background(), client)
+	require.EqualError(t, err, "send message 2 of 3: chat is closed")
+	assert.Len(t, sent, 1)
+	assert.Len(t, contents, 2)
+}
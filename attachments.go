@@ -0,0 +1,455 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+const (
+	// DefaultAttachmentConcurrency is the number of attachments AttachmentPipeline processes at the same time.
+	DefaultAttachmentConcurrency = 4
+	// DefaultAttachmentAttempts is how many times a failed attachment step is attempted.
+	DefaultAttachmentAttempts = 3
+	// DefaultAttachmentBackoff is the delay before the second attempt; it grows linearly with each attempt.
+	DefaultAttachmentBackoff = time.Second
+)
+
+// Attachment is a file attached to an inbound message.
+type Attachment struct {
+	Message Message
+	File    MessageFile
+}
+
+// Transcriber turns the contents of an attachment into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, attachment Attachment, content io.Reader) (string, error)
+}
+
+// AttachmentProcessor inspects the contents of an attachment, e.g. to scan it for malware.
+type AttachmentProcessor interface {
+	Process(ctx context.Context, attachment Attachment, content io.Reader) error
+}
+
+// AttachmentProcessorFunc adapts a function to AttachmentProcessor.
+type AttachmentProcessorFunc func(ctx context.Context, attachment Attachment, content io.Reader) error
+
+func (f AttachmentProcessorFunc) Process(ctx context.Context, attachment Attachment, content io.Reader) error {
+	return f(ctx, attachment, content)
+}
+
+// AttachmentError describes a failed step of attachment processing.
+type AttachmentError struct {
+	Attachment Attachment
+	// Step is "download", "transcribe", "update metadata" or "process".
+	Step string
+	Err  error
+}
+
+func (e *AttachmentError) Error() string {
+	return fmt.Sprintf("attachment %s of message %d: %s: %v", e.Attachment.File.ID, e.Attachment.Message.ID, e.Step, e.Err)
+}
+
+func (e *AttachmentError) Unwrap() error {
+	return e.Err
+}
+
+// AttachmentPipeline downloads the files of new messages and runs a transcriber and processors on them.
+// Every file is downloaded once to a temporary file that the transcriber and the processors read.
+// Transcriptions are written back with UpdateFileMetadata: the status is set to in_progress before
+// the transcriber runs and to ready or error once it finishes. Failed steps are retried, and the number
+// of attachments processed at the same time is limited. AttachmentPipeline is safe for concurrent use.
+type AttachmentPipeline struct {
+	client      ClientWithResponsesInterface
+	downloader  *Downloader
+	transcriber Transcriber
+	transcribe  []FileType
+	processors  []attachmentProcessor
+	filter      func(Attachment) bool
+	senders     []ws.UserTypeSchema
+	onError     func(ctx context.Context, err error)
+	attempts    int
+	backoff     time.Duration
+
+	slots    chan struct{}
+	messages chan struct{}
+	wg       sync.WaitGroup
+}
+
+type attachmentProcessor struct {
+	processor AttachmentProcessor
+	kinds     []FileType
+}
+
+// AttachmentPipelineOption configures an AttachmentPipeline.
+type AttachmentPipelineOption func(*AttachmentPipeline)
+
+// WithTranscriber transcribes attachments of the given kinds, audio by default.
+// Attachments that already have a transcription are skipped.
+func WithTranscriber(t Transcriber, kinds ...FileType) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		if len(kinds) == 0 {
+			kinds = []FileType{FileTypeAudio}
+		}
+		p.transcriber, p.transcribe = t, kinds
+	}
+}
+
+// WithAttachmentProcessor runs the processor on attachments of the given kinds, or on audio,
+// image and file attachments if no kinds are given. Processors run in the order they are added.
+func WithAttachmentProcessor(processor AttachmentProcessor, kinds ...FileType) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		if len(kinds) == 0 {
+			kinds = []FileType{FileTypeAudio, FileTypeImage, FileTypeFile}
+		}
+		p.processors = append(p.processors, attachmentProcessor{processor: processor, kinds: kinds})
+	}
+}
+
+// WithAttachmentFilter skips attachments for which filter returns false.
+func WithAttachmentFilter(filter func(Attachment) bool) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		p.filter = filter
+	}
+}
+
+// WithAttachmentSenders makes the handler process messages from the given sender types instead of customers only.
+func WithAttachmentSenders(types ...ws.UserTypeSchema) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		p.senders = types
+	}
+}
+
+// WithAttachmentDownloader sets the downloader used to fetch attachments.
+func WithAttachmentDownloader(d *Downloader) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		p.downloader = d
+	}
+}
+
+// WithAttachmentConcurrency sets the number of attachments processed at the same time
+// and the number of messages the handler processes in the background.
+func WithAttachmentConcurrency(n int) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		p.slots = make(chan struct{}, max(n, 1))
+		p.messages = make(chan struct{}, max(n, 1))
+	}
+}
+
+// WithAttachmentRetries sets how many times a failed step is attempted and the delay before the second attempt.
+func WithAttachmentRetries(attempts int, backoff time.Duration) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		p.attempts, p.backoff = max(attempts, 1), backoff
+	}
+}
+
+// WithAttachmentErrorHandler sets the function called with errors of attachments processed in the background.
+func WithAttachmentErrorHandler(onError func(ctx context.Context, err error)) AttachmentPipelineOption {
+	return func(p *AttachmentPipeline) {
+		p.onError = onError
+	}
+}
+
+// NewAttachmentPipeline creates a pipeline that downloads files and updates their metadata with the given client.
+func NewAttachmentPipeline(client ClientWithResponsesInterface, opts ...AttachmentPipelineOption) *AttachmentPipeline {
+	p := &AttachmentPipeline{
+		client:   client,
+		attempts: DefaultAttachmentAttempts,
+		backoff:  DefaultAttachmentBackoff,
+		senders:  []ws.UserTypeSchema{ws.UserTypeCustomer},
+		slots:    make(chan struct{}, DefaultAttachmentConcurrency),
+		messages: make(chan struct{}, DefaultAttachmentConcurrency),
+		onError:  func(context.Context, error) {},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.downloader == nil {
+		p.downloader = NewDownloader(client)
+	}
+
+	return p
+}
+
+// Handler returns a WebSocket event handler that processes the attachments of new messages from customers
+// in the background and passes every event to next, which may be nil. Errors are reported to the error handler.
+// The handler blocks while as many messages as the concurrency allows are being processed.
+// Use Wait to wait for the background processing to finish.
+func (p *AttachmentPipeline) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		data, ok := msg.Payload.Data.(ws.MessageDataSchema)
+		if ok && msg.Payload.Type == ws.EventTypeMessageNew && len(data.Message.Items) > 0 &&
+			data.Message.From != nil && slices.Contains(p.senders, data.Message.From.Type) {
+			message := MessageFromWS(data.Message)
+			ctx := context.WithoutCancel(ctx)
+
+			p.messages <- struct{}{}
+			p.wg.Add(1)
+			go func() {
+				defer func() {
+					<-p.messages
+					p.wg.Done()
+				}()
+
+				if err := p.Process(ctx, message); err != nil {
+					p.onError(ctx, err)
+				}
+			}()
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// Wait blocks until the attachments passed to the handler have been processed.
+func (p *AttachmentPipeline) Wait() {
+	p.wg.Wait()
+}
+
+// Process processes the attachments of the message and returns the errors of all failed steps
+// as *AttachmentError values joined together.
+func (p *AttachmentPipeline) Process(ctx context.Context, message Message) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	for _, file := range message.Items {
+		attachment := Attachment{Message: message, File: file}
+		if p.filter != nil && !p.filter(attachment) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case p.slots <- struct{}{}:
+				defer func() { <-p.slots }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, &AttachmentError{Attachment: attachment, Step: "process", Err: ctx.Err()})
+				mu.Unlock()
+
+				return
+			}
+
+			if err := p.processAttachment(ctx, attachment); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+func (p *AttachmentPipeline) processAttachment(ctx context.Context, attachment Attachment) error {
+	var (
+		errs       []error
+		processors []AttachmentProcessor
+	)
+
+	transcribe := p.transcriber != nil && attachment.File.Transcription == "" &&
+		slices.Contains(p.transcribe, attachment.File.Kind)
+	if transcribe {
+		if err := p.updateMetadata(ctx, attachment, "", FileTranscriptionStatusInProgress); err != nil {
+			errs, transcribe = append(errs, err), false
+		}
+	}
+	for _, proc := range p.processors {
+		if slices.Contains(proc.kinds, attachment.File.Kind) {
+			processors = append(processors, proc.processor)
+		}
+	}
+	if !transcribe && len(processors) == 0 {
+		return errors.Join(errs...)
+	}
+
+	content, err := p.download(ctx, attachment)
+	if err != nil {
+		errs = append(errs, &AttachmentError{Attachment: attachment, Step: "download", Err: err})
+		if transcribe {
+			// Report the failure even if the context is done, so the file is not left in progress.
+			errs = append(errs, p.updateMetadata(context.WithoutCancel(ctx), attachment, "", FileTranscriptionStatusError))
+		}
+
+		return errors.Join(errs...)
+	}
+	defer content.Close()
+
+	if transcribe {
+		if err := p.transcribeAttachment(ctx, attachment, content); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, processor := range processors {
+		err := p.retry(ctx, func() error {
+			return processor.Process(ctx, attachment, content.reader())
+		})
+		if err != nil {
+			errs = append(errs, &AttachmentError{Attachment: attachment, Step: "process", Err: err})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// transcribeAttachment transcribes the content, whose transcription status is already in progress.
+func (p *AttachmentPipeline) transcribeAttachment(
+	ctx context.Context, attachment Attachment, content *attachmentContent,
+) error {
+	var text string
+	err := p.retry(ctx, func() error {
+		var err error
+		text, err = p.transcriber.Transcribe(ctx, attachment, content.reader())
+
+		return err
+	})
+	if err != nil {
+		// Report the failure even if the context is done, so the file is not left in progress.
+		return errors.Join(
+			&AttachmentError{Attachment: attachment, Step: "transcribe", Err: err},
+			p.updateMetadata(context.WithoutCancel(ctx), attachment, "", FileTranscriptionStatusError),
+		)
+	}
+
+	return p.updateMetadata(ctx, attachment, text, FileTranscriptionStatusReady)
+}
+
+func (p *AttachmentPipeline) updateMetadata(
+	ctx context.Context, attachment Attachment, transcription string, status FileTranscriptionStatus,
+) error {
+	err := p.retry(ctx, func() error {
+		resp, err := p.client.UpdateFileMetadataWithResponse(ctx, attachment.File.ID, UpdateFileMetadataJSONRequestBody{
+			Transcription:       transcription,
+			TranscriptionStatus: &status,
+		})
+
+		return ExtractError(resp, err)
+	})
+	if err != nil {
+		return &AttachmentError{Attachment: attachment, Step: "update metadata", Err: err}
+	}
+
+	return nil
+}
+
+// attachmentContent is a downloaded attachment kept in a temporary file.
+type attachmentContent struct {
+	file *os.File
+	size int64
+}
+
+// reader returns a reader of the whole content. Readers are independent of each other.
+func (c *attachmentContent) reader() io.Reader {
+	return io.NewSectionReader(c.file, 0, c.size)
+}
+
+// Close removes the temporary file.
+func (c *attachmentContent) Close() error {
+	return errors.Join(c.file.Close(), os.Remove(c.file.Name()))
+}
+
+// download saves the attachment to a temporary file, retrying failed downloads.
+func (p *AttachmentPipeline) download(ctx context.Context, attachment Attachment) (*attachmentContent, error) {
+	file, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, err
+	}
+
+	content := &attachmentContent{file: file}
+	err = p.retry(ctx, func() error {
+		body, _, err := p.downloader.DownloadFile(ctx, attachment.File.ID)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		// A retry overwrites the content of the failed attempt.
+		content.size, err = io.Copy(io.NewOffsetWriter(file, 0), body)
+
+		return err
+	})
+	if err != nil {
+		return nil, errors.Join(err, content.Close())
+	}
+
+	return content, nil
+}
+
+// retry calls fn until it succeeds, the attempts run out or the context is done.
+func (p *AttachmentPipeline) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= p.attempts {
+			return err
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt) * p.backoff):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+	}
+}
+
+// FakeTranscriber is a Transcriber for tests. It reads the whole attachment, fails the first Failures
+// calls with Err (or a generic error if Err is nil) and returns Text afterwards.
+// It records the attachments it was called with.
+type FakeTranscriber struct {
+	Text     string
+	Err      error
+	Failures int
+
+	mu    sync.Mutex
+	calls []Attachment
+}
+
+func (f *FakeTranscriber) Transcribe(_ context.Context, attachment Attachment, content io.Reader) (string, error) {
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, attachment)
+	if len(f.calls) <= f.Failures {
+		if f.Err != nil {
+			return "", f.Err
+		}
+
+		return "", errors.New("fake transcription failure")
+	}
+
+	return f.Text, nil
+}
+
+// Calls returns the attachments the transcriber was called with.
+func (f *FakeTranscriber) Calls() []Attachment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Attachment(nil), f.calls...)
+}
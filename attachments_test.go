@@ -0,0 +1,295 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type metadataUpdate struct {
+	fileID string
+	body   UpdateFileMetadataJSONRequestBody
+}
+
+type attachmentServer struct {
+	fakeAPI
+	updates   []metadataUpdate
+	downloads int
+	// failUpdates makes the first metadata updates fail.
+	failUpdates int
+}
+
+func (s *attachmentServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		switch {
+		case req.URL.Host == "storage.example.com":
+			s.downloads++
+
+			return http.StatusOK, "content of " + req.URL.Query().Get("id")
+		case req.Method == http.MethodGet && len(segments) == 2:
+			return http.StatusOK, fmt.Sprintf(
+				`{"id": %q, "size": %d, "type": "audio", "url": "https://storage.example.com/file?id=%s"}`,
+				segments[1], len("content of ")+len(segments[1]), segments[1],
+			)
+		case req.Method == http.MethodPut && len(segments) == 3:
+			if s.failUpdates > 0 {
+				s.failUpdates--
+
+				return http.StatusInternalServerError, `{"errors": ["temporary failure"]}`
+			}
+
+			var update UpdateFileMetadataJSONRequestBody
+			if !decodeRequest(t, req, &update) {
+				return http.StatusBadRequest, nil
+			}
+			s.updates = append(s.updates, metadataUpdate{fileID: segments[1], body: update})
+
+			return http.StatusOK, "{}"
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func (s *attachmentServer) statuses(fileID uuid.UUID) []FileTranscriptionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var statuses []FileTranscriptionStatus
+	for _, u := range s.updates {
+		if u.fileID == fileID.String() {
+			statuses = append(statuses, *u.body.TranscriptionStatus)
+		}
+	}
+
+	return statuses
+}
+
+func TestAttachmentPipeline(t *testing.T) {
+	t.Parallel()
+
+	t.Run("transcribes audio and runs processors", func(t *testing.T) {
+		t.Parallel()
+
+		audio, image := uuid.New(), uuid.New()
+
+		var (
+			server  attachmentServer
+			mu      sync.Mutex
+			scanned = map[uuid.UUID]string{}
+		)
+		transcriber := &FakeTranscriber{Text: "hello", Failures: 1}
+		scanner := AttachmentProcessorFunc(func(_ context.Context, a Attachment, content io.Reader) error {
+			data, err := io.ReadAll(content)
+			mu.Lock()
+			scanned[a.File.ID] = string(data)
+			mu.Unlock()
+
+			return err
+		})
+
+		p := NewAttachmentPipeline(server.client(t),
+			WithTranscriber(transcriber),
+			WithAttachmentProcessor(scanner, FileTypeImage),
+			WithAttachmentRetries(2, time.Millisecond),
+		)
+
+		err := p.Process(context.Background(), Message{ID: 1, Items: []MessageFile{
+			{ID: audio, Kind: FileTypeAudio},
+			{ID: image, Kind: FileTypeImage},
+			{ID: uuid.New(), Kind: FileTypeAudio, Transcription: "already done"},
+		}})
+		require.NoError(t, err)
+
+		assert.Len(t, transcriber.Calls(), 2)
+		assert.Equal(t, []FileTranscriptionStatus{
+			FileTranscriptionStatusInProgress, FileTranscriptionStatusReady,
+		}, server.statuses(audio))
+		assert.Equal(t, "hello", server.updates[len(server.updates)-1].body.Transcription)
+		assert.Empty(t, server.statuses(image))
+		assert.Equal(t, map[uuid.UUID]string{image: "content of " + image.String()}, scanned)
+		// Every file is downloaded once, even though the transcription was retried.
+		assert.Equal(t, 2, server.downloads)
+	})
+
+	t.Run("reports transcription failures", func(t *testing.T) {
+		t.Parallel()
+
+		audio := uuid.New()
+
+		var server attachmentServer
+		transcriber := &FakeTranscriber{Err: errors.New("unsupported codec"), Failures: 2}
+
+		p := NewAttachmentPipeline(server.client(t),
+			WithTranscriber(transcriber),
+			WithAttachmentRetries(2, time.Millisecond),
+		)
+
+		err := p.Process(context.Background(), Message{ID: 1, Items: []MessageFile{{ID: audio, Kind: FileTypeAudio}}})
+
+		var attErr *AttachmentError
+		require.ErrorAs(t, err, &attErr)
+		assert.Equal(t, "transcribe", attErr.Step)
+		assert.ErrorContains(t, err, "unsupported codec")
+		assert.Equal(t, []FileTranscriptionStatus{
+			FileTranscriptionStatusInProgress, FileTranscriptionStatusError,
+		}, server.statuses(audio))
+	})
+
+	t.Run("retries metadata updates", func(t *testing.T) {
+		t.Parallel()
+
+		audio := uuid.New()
+
+		server := attachmentServer{failUpdates: 1}
+		p := NewAttachmentPipeline(server.client(t),
+			WithTranscriber(&FakeTranscriber{Text: "hi"}),
+			WithAttachmentRetries(2, time.Millisecond),
+		)
+
+		require.NoError(t, p.Process(context.Background(), Message{Items: []MessageFile{{ID: audio, Kind: FileTypeAudio}}}))
+		assert.Equal(t, []FileTranscriptionStatus{
+			FileTranscriptionStatusInProgress, FileTranscriptionStatusReady,
+		}, server.statuses(audio))
+	})
+
+	t.Run("limits concurrency", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			server        attachmentServer
+			running, peak int
+			mu            sync.Mutex
+		)
+		processor := AttachmentProcessorFunc(func(context.Context, Attachment, io.Reader) error {
+			mu.Lock()
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+
+			return nil
+		})
+
+		p := NewAttachmentPipeline(server.client(t),
+			WithAttachmentProcessor(processor),
+			WithAttachmentConcurrency(2),
+		)
+
+		items := make([]MessageFile, 6)
+		for i := range items {
+			items[i] = MessageFile{ID: uuid.New(), Kind: FileTypeFile}
+		}
+		require.NoError(t, p.Process(context.Background(), Message{Items: items}))
+		assert.Equal(t, 2, peak)
+		assert.Equal(t, 6, server.downloads)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		audio := uuid.New()
+		audioEvent := func(id int64, from ws.UserTypeSchema) ws.EventMessageFromEventsChannel {
+			event := messageEvent(id, from, "")
+			data := event.Payload.Data.(ws.MessageDataSchema)
+			data.Message.Type = ws.MessageTypeAudio
+			data.Message.Items = []ws.MessageFileSchema{{Id: audio, Kind: ws.MessageFileKindAudio}}
+			event.Payload.Data = data
+
+			return event
+		}
+
+		var (
+			server attachmentServer
+			passed int
+		)
+		transcriber := &FakeTranscriber{Text: "hi"}
+		p := NewAttachmentPipeline(server.client(t), WithTranscriber(transcriber))
+
+		handler := p.Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+			passed++
+
+			return nil
+		})
+
+		require.NoError(t, handler(context.Background(), audioEvent(1, ws.UserTypeCustomer)))
+		require.NoError(t, handler(context.Background(), messageEvent(2, ws.UserTypeCustomer, "text")))
+		// Messages from operators are not processed by default.
+		require.NoError(t, handler(context.Background(), audioEvent(3, ws.UserTypeUser)))
+		p.Wait()
+
+		assert.Equal(t, 3, passed)
+		require.Len(t, transcriber.Calls(), 1)
+		assert.Equal(t, int64(1), transcriber.Calls()[0].Message.ID)
+		assert.Equal(t, []FileTranscriptionStatus{
+			FileTranscriptionStatusInProgress, FileTranscriptionStatusReady,
+		}, server.statuses(audio))
+
+		all := &FakeTranscriber{Text: "hi"}
+		p = NewAttachmentPipeline(server.client(t), WithTranscriber(all),
+			WithAttachmentSenders(ws.UserTypeCustomer, ws.UserTypeUser))
+		require.NoError(t, p.Handler(nil)(context.Background(), audioEvent(3, ws.UserTypeUser)))
+		p.Wait()
+		require.Len(t, all.Calls(), 1)
+		assert.Equal(t, int64(3), all.Calls()[0].Message.ID)
+	})
+
+	t.Run("handler limits messages in the background", func(t *testing.T) {
+		t.Parallel()
+
+		var server attachmentServer
+		release := make(chan struct{})
+		processor := AttachmentProcessorFunc(func(context.Context, Attachment, io.Reader) error {
+			<-release
+
+			return nil
+		})
+		p := NewAttachmentPipeline(server.client(t), WithAttachmentProcessor(processor), WithAttachmentConcurrency(1))
+		handler := p.Handler(nil)
+
+		fileEvent := func(id int64) ws.EventMessageFromEventsChannel {
+			event := messageEvent(id, ws.UserTypeCustomer, "")
+			data := event.Payload.Data.(ws.MessageDataSchema)
+			data.Message.Type = ws.MessageTypeFile
+			data.Message.Items = []ws.MessageFileSchema{{Id: uuid.New(), Kind: ws.MessageFileKindFile}}
+			event.Payload.Data = data
+
+			return event
+		}
+
+		require.NoError(t, handler(context.Background(), fileEvent(1)))
+
+		handled := make(chan error)
+		go func() {
+			handled <- handler(context.Background(), fileEvent(2))
+		}()
+		select {
+		case <-handled:
+			require.Fail(t, "the handler did not wait for the first message")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(release)
+		require.NoError(t, <-handled)
+		p.Wait()
+		assert.Equal(t, 2, server.downloads)
+	})
+}
@@ -0,0 +1,313 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// TimeRange is a range of the day, as offsets from midnight. End is exclusive and may be 24h.
+type TimeRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Contains reports whether the offset from midnight is within the range.
+func (r TimeRange) Contains(offset time.Duration) bool {
+	return offset >= r.Start && offset < r.End
+}
+
+// HolidayCalendar reports whether the day of t is a holiday. t is in the time zone of the schedule.
+type HolidayCalendar interface {
+	IsHoliday(t time.Time) bool
+}
+
+// HolidayCalendarFunc adapts a function to HolidayCalendar.
+type HolidayCalendarFunc func(t time.Time) bool
+
+func (f HolidayCalendarFunc) IsHoliday(t time.Time) bool {
+	return f(t)
+}
+
+// Holiday is a calendar date. A zero Year makes the holiday repeat every year.
+type Holiday struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// Holidays is a HolidayCalendar of fixed dates.
+type Holidays []Holiday
+
+func (h Holidays) IsHoliday(t time.Time) bool {
+	year, month, day := t.Date()
+	for _, holiday := range h {
+		if holiday.Month == month && holiday.Day == day && (holiday.Year == 0 || holiday.Year == year) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Schedule is a weekly schedule of business hours in a time zone. Business is closed on the days
+// without hours and on holidays.
+type Schedule struct {
+	// Location is the time zone of the hours and holidays. Nil means UTC.
+	Location *time.Location
+	Hours    map[time.Weekday][]TimeRange
+	Holidays []HolidayCalendar
+}
+
+// Open reports whether t is within the business hours.
+func (s Schedule) Open(t time.Time) bool {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	} else {
+		t = t.UTC()
+	}
+
+	for _, calendar := range s.Holidays {
+		if calendar.IsHoliday(t) {
+			return false
+		}
+	}
+
+	hour, minute, sec := t.Clock()
+	offset := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(sec)*time.Second
+	for _, r := range s.Hours[t.Weekday()] {
+		if r.Contains(offset) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ChannelOverride replaces the schedule and, if set, the reply of the auto-responder for a channel.
+type ChannelOverride struct {
+	Schedule Schedule
+	Reply    string
+}
+
+// AutoResponder replies to customers writing in out of business hours, once per dialog.
+// Messages from users, bots and channels are skipped. It is safe for concurrent use.
+type AutoResponder struct {
+	client    ClientWithResponsesInterface
+	schedule  Schedule
+	reply     string
+	note      string
+	overrides map[int64]ChannelOverride
+	cooldown  time.Duration
+	preflight *Preflight
+	now       func() time.Time
+	onError   func(ctx context.Context, err error)
+
+	mu        sync.Mutex
+	dialogs   map[int64]struct{}
+	customers map[int64]time.Time
+}
+
+// AutoResponderOption configures an AutoResponder.
+type AutoResponderOption func(*AutoResponder)
+
+// WithChannelOverride uses a different schedule and reply for the chats of the channel.
+func WithChannelOverride(channelID int64, override ChannelOverride) AutoResponderOption {
+	return func(r *AutoResponder) {
+		r.overrides[channelID] = override
+	}
+}
+
+// WithOperatorNote posts a private message with the text to the chat after every automatic reply.
+func WithOperatorNote(text string) AutoResponderOption {
+	return func(r *AutoResponder) {
+		r.note = text
+	}
+}
+
+// WithCustomerCooldown does not reply to a customer again within d, even in another dialog.
+func WithCustomerCooldown(d time.Duration) AutoResponderOption {
+	return func(r *AutoResponder) {
+		r.cooldown = d
+	}
+}
+
+// WithResponderPreflight resolves chat channels with the preflight, sharing its cache.
+func WithResponderPreflight(p *Preflight) AutoResponderOption {
+	return func(r *AutoResponder) {
+		r.preflight = p
+	}
+}
+
+// WithAutoResponderErrorHandler sets the function called with errors of messages handled by the handler.
+func WithAutoResponderErrorHandler(onError func(ctx context.Context, err error)) AutoResponderOption {
+	return func(r *AutoResponder) {
+		r.onError = onError
+	}
+}
+
+// NewAutoResponder creates an AutoResponder that sends reply out of the schedule's business hours.
+func NewAutoResponder(
+	client ClientWithResponsesInterface, schedule Schedule, reply string, opts ...AutoResponderOption,
+) *AutoResponder {
+	r := &AutoResponder{
+		client:    client,
+		schedule:  schedule,
+		reply:     reply,
+		overrides: make(map[int64]ChannelOverride),
+		now:       time.Now,
+		onError:   func(context.Context, error) {},
+		dialogs:   make(map[int64]struct{}),
+		customers: make(map[int64]time.Time),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.preflight == nil {
+		r.preflight = NewPreflight(client)
+	}
+
+	return r
+}
+
+// Handler returns a WebSocket event handler that responds to message_new events, forgets the dialogs
+// of dialog_closed events and passes every event to next, which may be nil. Errors are reported
+// to the error handler.
+func (r *AutoResponder) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		switch data := msg.Payload.Data.(type) {
+		case ws.MessageDataSchema:
+			if msg.Payload.Type == ws.EventTypeMessageNew {
+				if _, err := r.Respond(ctx, data.Message); err != nil {
+					r.onError(ctx, err)
+				}
+			}
+		case ws.DialogDataSchema:
+			if msg.Payload.Type == ws.EventTypeDialogClosed {
+				r.mu.Lock()
+				delete(r.dialogs, data.Dialog.Id)
+				r.mu.Unlock()
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// Respond replies to the customer message if it arrived out of business hours, the dialog has not been
+// replied to yet and the customer is not in cooldown. It reports whether a reply was sent.
+func (r *AutoResponder) Respond(ctx context.Context, message ws.MessagePropertyFromMessageDataSchema) (bool, error) {
+	if message.Dialog == nil || message.Dialog.Id == nil || message.From == nil ||
+		message.From.Type != ws.UserTypeCustomer {
+		return false, nil
+	}
+	dialogID, customerID := *message.Dialog.Id, message.From.Id
+
+	schedule, reply, err := r.channelSchedule(ctx, message)
+	if err != nil {
+		return false, fmt.Errorf("auto-respond to message %d: %w", message.Id, err)
+	}
+
+	now := r.now()
+	if schedule.Open(now) || !r.reserve(dialogID, customerID, now) {
+		return false, nil
+	}
+
+	if err = r.send(ctx, NewText(message.ChatId, reply)); err != nil {
+		r.release(dialogID, customerID)
+
+		return false, fmt.Errorf("auto-respond to message %d: %w", message.Id, err)
+	}
+
+	if r.note != "" {
+		if err = r.send(ctx, NewText(message.ChatId, r.note).Private()); err != nil {
+			return true, fmt.Errorf("post note for message %d: %w", message.Id, err)
+		}
+	}
+
+	return true, nil
+}
+
+func (r *AutoResponder) send(ctx context.Context, message *MessageBuilder) error {
+	body, err := message.Build()
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.SendMessageWithResponse(ctx, body)
+
+	return ExtractError(resp, err)
+}
+
+func (r *AutoResponder) channelSchedule(
+	ctx context.Context, message ws.MessagePropertyFromMessageDataSchema,
+) (Schedule, string, error) {
+	if len(r.overrides) == 0 {
+		return r.schedule, r.reply, nil
+	}
+
+	var channelID int64
+	if message.Chat != nil && message.Chat.Channel != nil {
+		channelID = message.Chat.Channel.Id
+	} else {
+		channel, err := r.preflight.Channel(ctx, message.ChatId)
+		if err != nil {
+			return Schedule{}, "", err
+		}
+		channelID = channel.ID
+	}
+
+	override, ok := r.overrides[channelID]
+	if !ok {
+		return r.schedule, r.reply, nil
+	}
+	if override.Reply == "" {
+		return override.Schedule, r.reply, nil
+	}
+
+	return override.Schedule, override.Reply, nil
+}
+
+// reserve marks the dialog as replied to and starts the customer cooldown, unless either is already set.
+func (r *AutoResponder) reserve(dialogID, customerID int64, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.dialogs[dialogID]; ok {
+		return false
+	}
+	if until, ok := r.customers[customerID]; ok && now.Before(until) {
+		return false
+	}
+
+	r.dialogs[dialogID] = struct{}{}
+	if r.cooldown > 0 {
+		for id, until := range r.customers {
+			if !now.Before(until) {
+				delete(r.customers, id)
+			}
+		}
+		r.customers[customerID] = now.Add(r.cooldown)
+	}
+
+	return true
+}
+
+func (r *AutoResponder) release(dialogID, customerID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.dialogs, dialogID)
+	delete(r.customers, customerID)
+}
@@ -0,0 +1,237 @@
+package bot_api_client
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type responderServer struct {
+	fakeAPI
+	messages []string
+	fail     bool
+}
+
+func (s *responderServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		if req.Method != http.MethodPost || req.URL.Path != "/messages" {
+			return unexpectedRequest(t, req)
+		}
+		if s.fail {
+			return http.StatusInternalServerError, `{"errors":["internal error"]}`
+		}
+
+		var message SendMessageRequestBody
+		if !decodeRequest(t, req, &message) {
+			return http.StatusBadRequest, nil
+		}
+		s.messages = append(s.messages, string(message.Scope)+":"+*message.Content)
+
+		return http.StatusOK, `{"message_id":1}`
+	})
+}
+
+func (s *responderServer) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := s.messages
+	s.messages = nil
+
+	return messages
+}
+
+func customerMessage(dialogID, customerID, channelID int64) ws.MessagePropertyFromMessageDataSchema {
+	content := "hello"
+
+	return ws.MessagePropertyFromMessageDataSchema{
+		Id:      1,
+		ChatId:  10,
+		Content: &content,
+		Dialog:  &ws.MessageDialogSchema{Id: &dialogID},
+		From:    &ws.UserRefSchema{Id: customerID, Type: ws.UserTypeCustomer},
+		Chat:    &ws.ChatSchema{Id: 10, Channel: &ws.ChannelSchema{Id: channelID}},
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	workdays := []TimeRange{{Start: 9 * time.Hour, End: 13 * time.Hour}, {Start: 14 * time.Hour, End: 18 * time.Hour}}
+	schedule := Schedule{
+		Location: berlin,
+		Hours: map[time.Weekday][]TimeRange{
+			time.Monday: workdays, time.Tuesday: workdays, time.Wednesday: workdays,
+			time.Thursday: workdays, time.Friday: workdays,
+		},
+		Holidays: []HolidayCalendar{
+			Holidays{{Month: time.December, Day: 25}, {Year: 2024, Month: time.May, Day: 9}},
+			HolidayCalendarFunc(func(t time.Time) bool { return t.Month() == time.August }),
+		},
+	}
+
+	for _, tt := range []struct {
+		at   string
+		open bool
+	}{
+		{at: "2024-05-06T07:30:00Z", open: true}, // Monday 09:30 in Berlin
+		{at: "2024-05-06T06:59:59Z", open: false},
+		{at: "2024-05-06T11:15:00Z", open: false}, // lunch break
+		{at: "2024-05-06T15:59:59Z", open: true},
+		{at: "2024-05-06T16:00:00Z", open: false},
+		{at: "2024-05-04T10:00:00Z", open: false}, // Saturday
+		{at: "2024-05-09T10:00:00Z", open: false}, // Ascension Day 2024
+		{at: "2025-05-09T10:00:00Z", open: true},
+		{at: "2025-12-25T10:00:00Z", open: false},
+		{at: "2024-08-05T10:00:00Z", open: false},
+	} {
+		at, err := time.Parse(time.RFC3339, tt.at)
+		require.NoError(t, err)
+		assert.Equal(t, tt.open, schedule.Open(at), tt.at)
+	}
+}
+
+func TestAutoResponder(t *testing.T) {
+	t.Parallel()
+
+	nineToFive := []TimeRange{{Start: 9 * time.Hour, End: 17 * time.Hour}}
+	schedule := Schedule{Hours: map[time.Weekday][]TimeRange{time.Wednesday: nineToFive}}
+	wednesday := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	t.Run("replies once per dialog out of hours", func(t *testing.T) {
+		t.Parallel()
+
+		server := responderServer{}
+		responder := NewAutoResponder(server.client(t), schedule, "We are closed", WithOperatorNote("Replied automatically"))
+		responder.now = func() time.Time { return wednesday }
+
+		replied, err := responder.Respond(context.Background(), customerMessage(1, 5, 3))
+		require.NoError(t, err)
+		assert.True(t, replied)
+		assert.Equal(t, []string{"public:We are closed", "private:Replied automatically"}, server.take())
+
+		replied, err = responder.Respond(context.Background(), customerMessage(1, 5, 3))
+		require.NoError(t, err)
+		assert.False(t, replied)
+
+		// Without a cooldown the same customer gets a reply in a new dialog.
+		replied, err = responder.Respond(context.Background(), customerMessage(2, 5, 3))
+		require.NoError(t, err)
+		assert.True(t, replied)
+		assert.Len(t, server.take(), 2)
+
+		responder.now = func() time.Time { return wednesday.Add(-8 * time.Hour) }
+		replied, err = responder.Respond(context.Background(), customerMessage(3, 6, 3))
+		require.NoError(t, err)
+		assert.False(t, replied)
+		assert.Empty(t, server.take())
+	})
+
+	t.Run("skips users and bots", func(t *testing.T) {
+		t.Parallel()
+
+		responder := NewAutoResponder(nil, schedule, "We are closed")
+		responder.now = func() time.Time { return wednesday }
+
+		for _, sender := range []ws.UserTypeSchema{ws.UserTypeUser, ws.UserTypeBot} {
+			message := customerMessage(1, 5, 3)
+			message.From.Type = sender
+			replied, err := responder.Respond(context.Background(), message)
+			require.NoError(t, err)
+			assert.False(t, replied)
+		}
+	})
+
+	t.Run("customer cooldown", func(t *testing.T) {
+		t.Parallel()
+
+		server := responderServer{}
+		clock := wednesday
+		responder := NewAutoResponder(server.client(t), schedule, "We are closed", WithCustomerCooldown(time.Hour))
+		responder.now = func() time.Time { return clock }
+
+		for _, dialogID := range []int64{1, 2} {
+			_, err := responder.Respond(context.Background(), customerMessage(dialogID, 5, 3))
+			require.NoError(t, err)
+		}
+		assert.Len(t, server.take(), 1)
+
+		clock = clock.Add(time.Hour)
+		replied, err := responder.Respond(context.Background(), customerMessage(3, 5, 3))
+		require.NoError(t, err)
+		assert.True(t, replied)
+	})
+
+	t.Run("channel override", func(t *testing.T) {
+		t.Parallel()
+
+		server := responderServer{}
+		alwaysClosed := ChannelOverride{Reply: "Email us instead"}
+		alwaysOpen := ChannelOverride{Schedule: Schedule{Hours: map[time.Weekday][]TimeRange{
+			time.Wednesday: {{Start: 0, End: 24 * time.Hour}},
+		}}}
+		responder := NewAutoResponder(server.client(t), schedule, "We are closed",
+			WithChannelOverride(4, alwaysClosed), WithChannelOverride(5, alwaysOpen))
+		responder.now = func() time.Time { return wednesday.Add(-8 * time.Hour) }
+
+		for dialogID, channelID := range map[int64]int64{1: 3, 2: 4, 3: 5} {
+			_, err := responder.Respond(context.Background(), customerMessage(dialogID, 5, channelID))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"public:Email us instead"}, server.take())
+	})
+
+	t.Run("failed reply is retried", func(t *testing.T) {
+		t.Parallel()
+
+		server := responderServer{fail: true}
+		responder := NewAutoResponder(server.client(t), schedule, "We are closed", WithCustomerCooldown(time.Hour))
+		responder.now = func() time.Time { return wednesday }
+
+		_, err := responder.Respond(context.Background(), customerMessage(1, 5, 3))
+		require.ErrorContains(t, err, "auto-respond to message 1")
+
+		server.fail = false
+		replied, err := responder.Respond(context.Background(), customerMessage(1, 5, 3))
+		require.NoError(t, err)
+		assert.True(t, replied)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		server := responderServer{}
+		responder := NewAutoResponder(server.client(t), schedule, "We are closed")
+		responder.now = func() time.Time { return wednesday }
+
+		var passed int
+		handler := responder.Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+			passed++
+
+			return nil
+		})
+		handle := func(eventType ws.EventTypeSchema, data any) {
+			require.NoError(t, handler(context.Background(), ws.EventMessageFromEventsChannel{
+				Payload: ws.EventSchema{Type: eventType, Data: data},
+			}))
+		}
+
+		handle(ws.EventTypeMessageNew, ws.MessageDataSchema{Message: customerMessage(1, 5, 3)})
+		handle(ws.EventTypeMessageNew, ws.MessageDataSchema{Message: customerMessage(1, 5, 3)})
+		assert.Len(t, server.take(), 1)
+
+		handle(ws.EventTypeDialogClosed, ws.DialogDataSchema{Dialog: ws.DialogEventSchema{Id: 1}})
+		handle(ws.EventTypeMessageNew, ws.MessageDataSchema{Message: customerMessage(1, 5, 3)})
+		assert.Len(t, server.take(), 1)
+		assert.Equal(t, 4, passed)
+	})
+}
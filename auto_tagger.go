@@ -0,0 +1,380 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// TagRule is a declarative auto-tagging rule. All conditions that are set must match the message;
+// a rule without conditions matches every message.
+type TagRule struct {
+	Name string `json:"name"`
+	// Pattern is a regular expression matched against the message content.
+	Pattern string `json:"pattern,omitempty"`
+	// Keywords match if the content contains any of them, ignoring case.
+	Keywords []string `json:"keywords,omitempty"`
+	// ChannelTypes match the type of the chat's channel.
+	ChannelTypes []ws.ChannelTypeSchema `json:"channel_types,omitempty"`
+	// Languages match the language of the customer.
+	Languages []string `json:"languages,omitempty"`
+	// AttachmentKinds match if the message has an attachment of any of the kinds.
+	AttachmentKinds []ws.MessageFileKindSchema `json:"attachment_kinds,omitempty"`
+	// HasOrder matches messages with an order.
+	HasOrder bool `json:"has_order,omitempty"`
+
+	// AddTags are added to the dialog of a matching message.
+	AddTags []Tag `json:"add_tags,omitempty"`
+	// RemoveTags are the names of the tags removed from the dialog of a matching message.
+	RemoveTags []string `json:"remove_tags,omitempty"`
+}
+
+// TagChange describes the tags changed in a dialog for a message.
+type TagChange struct {
+	DialogID  int64
+	MessageID int64
+	// Rules are the names of the matching rules.
+	Rules []string
+	// Add and Remove exclude the tags the dialog already has or does not have.
+	Add    []Tag
+	Remove []string
+	// DryRun is set if the change was not applied.
+	DryRun bool
+}
+
+// Empty reports whether the change neither adds nor removes tags.
+func (c TagChange) Empty() bool {
+	return len(c.Add) == 0 && len(c.Remove) == 0
+}
+
+// AutoTagger evaluates new messages against tag rules and adds or removes the tags of their dialogs.
+// By default only customer messages are evaluated. It is safe for concurrent use.
+type AutoTagger struct {
+	client    ClientWithResponsesInterface
+	rules     []tagRule
+	senders   []ws.UserTypeSchema
+	dryRun    bool
+	preflight *Preflight
+	ttl       time.Duration
+	now       func() time.Time
+	onChange  func(ctx context.Context, change TagChange)
+	onError   func(ctx context.Context, err error)
+
+	mu        sync.Mutex
+	languages map[int64]cached[string]
+}
+
+type tagRule struct {
+	TagRule
+	pattern  *regexp.Regexp
+	keywords []string
+}
+
+// AutoTaggerOption configures an AutoTagger.
+type AutoTaggerOption func(*AutoTagger)
+
+// WithTagDryRun computes tag changes and reports them to the change handler without applying them.
+func WithTagDryRun() AutoTaggerOption {
+	return func(a *AutoTagger) {
+		a.dryRun = true
+	}
+}
+
+// WithTaggedSenders evaluates messages from the given sender types instead of customers only.
+func WithTaggedSenders(types ...ws.UserTypeSchema) AutoTaggerOption {
+	return func(a *AutoTagger) {
+		a.senders = types
+	}
+}
+
+// WithTaggerPreflight resolves chat channels with the preflight, sharing its cache.
+func WithTaggerPreflight(p *Preflight) AutoTaggerOption {
+	return func(a *AutoTagger) {
+		a.preflight = p
+	}
+}
+
+// WithTagChangeHandler sets the function called with every non-empty tag change, applied or not.
+func WithTagChangeHandler(onChange func(ctx context.Context, change TagChange)) AutoTaggerOption {
+	return func(a *AutoTagger) {
+		a.onChange = onChange
+	}
+}
+
+// WithAutoTaggerErrorHandler sets the function called with errors of messages evaluated by the handler.
+func WithAutoTaggerErrorHandler(onError func(ctx context.Context, err error)) AutoTaggerOption {
+	return func(a *AutoTagger) {
+		a.onError = onError
+	}
+}
+
+// NewAutoTagger creates an AutoTagger with the rules, which are evaluated in order.
+// If rules add and remove the same tag, the last of them wins. It fails if a pattern does not compile.
+func NewAutoTagger(
+	client ClientWithResponsesInterface, rules []TagRule, opts ...AutoTaggerOption,
+) (*AutoTagger, error) {
+	a := &AutoTagger{
+		client:    client,
+		senders:   []ws.UserTypeSchema{ws.UserTypeCustomer},
+		ttl:       DefaultPreflightTTL,
+		now:       time.Now,
+		onChange:  func(context.Context, TagChange) {},
+		onError:   func(context.Context, error) {},
+		languages: make(map[int64]cached[string]),
+	}
+
+	for _, rule := range rules {
+		compiled := tagRule{TagRule: rule}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("tag rule %q: %w", rule.Name, err)
+			}
+			compiled.pattern = pattern
+		}
+		for _, k := range rule.Keywords {
+			compiled.keywords = append(compiled.keywords, strings.ToLower(k))
+		}
+		a.rules = append(a.rules, compiled)
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.preflight == nil {
+		a.preflight = NewPreflight(client)
+	}
+
+	return a, nil
+}
+
+// Handler returns a WebSocket event handler that evaluates message_new events and passes every event
+// to next, which may be nil. Errors are reported to the error handler.
+func (a *AutoTagger) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		if data, ok := msg.Payload.Data.(ws.MessageDataSchema); ok && msg.Payload.Type == ws.EventTypeMessageNew {
+			if _, err := a.Evaluate(ctx, data.Message); err != nil {
+				a.onError(ctx, err)
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// Evaluate matches the message against the rules and applies the resulting tag change to its dialog,
+// unless the tagger runs in dry-run mode. Messages outside a dialog or from other senders are skipped.
+func (a *AutoTagger) Evaluate(ctx context.Context, message ws.MessagePropertyFromMessageDataSchema) (TagChange, error) {
+	if message.Dialog == nil || message.Dialog.Id == nil || message.From == nil ||
+		!slices.Contains(a.senders, message.From.Type) {
+		return TagChange{}, nil
+	}
+
+	change := TagChange{DialogID: *message.Dialog.Id, MessageID: message.Id, DryRun: a.dryRun}
+	var (
+		add    []Tag
+		remove []string
+	)
+	for _, rule := range a.rules {
+		ok, err := a.matches(ctx, rule, message)
+		if err != nil {
+			return TagChange{}, fmt.Errorf("tag message %d: rule %q: %w", message.Id, rule.Name, err)
+		}
+		if !ok {
+			continue
+		}
+
+		change.Rules = append(change.Rules, rule.Name)
+		for _, tag := range rule.AddTags {
+			remove = slices.DeleteFunc(remove, func(name string) bool { return name == tag.Name })
+			add = append(slices.DeleteFunc(add, func(t Tag) bool { return t.Name == tag.Name }), tag)
+		}
+		for _, name := range rule.RemoveTags {
+			add = slices.DeleteFunc(add, func(t Tag) bool { return t.Name == name })
+			remove = append(slices.DeleteFunc(remove, func(n string) bool { return n == name }), name)
+		}
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return change, nil
+	}
+
+	current, err := a.dialogTags(ctx, change.DialogID)
+	if err != nil {
+		return TagChange{}, fmt.Errorf("tag message %d: %w", message.Id, err)
+	}
+	hasTag := func(name string) bool {
+		return slices.ContainsFunc(current, func(t Tag) bool { return t.Name == name })
+	}
+	for _, tag := range add {
+		if !hasTag(tag.Name) {
+			change.Add = append(change.Add, tag)
+		}
+	}
+	for _, name := range remove {
+		if hasTag(name) {
+			change.Remove = append(change.Remove, name)
+		}
+	}
+	if change.Empty() {
+		return change, nil
+	}
+
+	if !a.dryRun {
+		if err = a.apply(ctx, change); err != nil {
+			return TagChange{}, fmt.Errorf("tag dialog %d: %w", change.DialogID, err)
+		}
+	}
+	a.onChange(ctx, change)
+
+	return change, nil
+}
+
+func (a *AutoTagger) matches(
+	ctx context.Context, rule tagRule, message ws.MessagePropertyFromMessageDataSchema,
+) (bool, error) {
+	content := deref(message.Content)
+	if rule.pattern != nil && !rule.pattern.MatchString(content) {
+		return false, nil
+	}
+	if len(rule.keywords) > 0 {
+		lower := strings.ToLower(content)
+		if !slices.ContainsFunc(rule.keywords, func(k string) bool { return strings.Contains(lower, k) }) {
+			return false, nil
+		}
+	}
+	if rule.HasOrder && message.Order == nil {
+		return false, nil
+	}
+	if len(rule.AttachmentKinds) > 0 && !slices.ContainsFunc(message.Items, func(f ws.MessageFileSchema) bool {
+		return slices.Contains(rule.AttachmentKinds, f.Kind)
+	}) {
+		return false, nil
+	}
+
+	if len(rule.ChannelTypes) > 0 {
+		channelType, err := a.channelType(ctx, message)
+		if err != nil {
+			return false, err
+		}
+		if !slices.Contains(rule.ChannelTypes, channelType) {
+			return false, nil
+		}
+	}
+
+	if len(rule.Languages) > 0 {
+		language, err := a.customerLanguage(ctx, message.From.Id)
+		if err != nil {
+			return false, err
+		}
+		if !slices.Contains(rule.Languages, language) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (a *AutoTagger) channelType(
+	ctx context.Context, message ws.MessagePropertyFromMessageDataSchema,
+) (ws.ChannelTypeSchema, error) {
+	if message.Chat != nil && message.Chat.Channel != nil {
+		return message.Chat.Channel.Type, nil
+	}
+
+	channel, err := a.preflight.Channel(ctx, message.ChatId)
+	if err != nil {
+		return "", err
+	}
+
+	return ws.ChannelTypeSchema(channel.Type), nil
+}
+
+func (a *AutoTagger) customerLanguage(ctx context.Context, customerID int64) (string, error) {
+	a.mu.Lock()
+	entry, ok := a.languages[customerID]
+	a.mu.Unlock()
+	if ok && a.now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	id := int(customerID)
+	resp, err := a.client.ListCustomersWithResponse(ctx, &ListCustomersParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return "", fmt.Errorf("get customer %d: %w", customerID, err)
+	}
+
+	var language string
+	for _, c := range deref(resp.JSON200) {
+		if c.ID == customerID {
+			language = deref(c.Language)
+		}
+	}
+
+	a.mu.Lock()
+	a.languages[customerID] = cached[string]{value: language, expires: a.now().Add(a.ttl)}
+	a.mu.Unlock()
+
+	return language, nil
+}
+
+func (a *AutoTagger) dialogTags(ctx context.Context, dialogID int64) ([]Tag, error) {
+	id := int(dialogID)
+	resp, err := a.client.ListDialogsWithResponse(ctx, &ListDialogsParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return nil, fmt.Errorf("get dialog %d: %w", dialogID, err)
+	}
+
+	for _, d := range deref(resp.JSON200) {
+		if d.ID == dialogID {
+			return d.Tags, nil
+		}
+	}
+
+	return nil, fmt.Errorf("get dialog %d: not found", dialogID)
+}
+
+func (a *AutoTagger) apply(ctx context.Context, change TagChange) error {
+	if len(change.Add) > 0 {
+		var body DialogAddTagsJSONRequestBody
+		for _, tag := range change.Add {
+			body.Tags = append(body.Tags, struct {
+				ColorCode *ColorCode `binding:"omitempty,enum-valid" json:"color_code,omitempty"`
+				Name      string     `binding:"required,min=1,max=255" json:"name" mod:"trim,escape"`
+			}{ColorCode: optional(tag.ColorCode), Name: tag.Name})
+		}
+
+		resp, err := a.client.DialogAddTagsWithResponse(ctx, change.DialogID, body)
+		if err = ExtractError(resp, err); err != nil {
+			return fmt.Errorf("add tags: %w", err)
+		}
+	}
+
+	if len(change.Remove) > 0 {
+		var body DialogDeleteTagsJSONRequestBody
+		for _, name := range change.Remove {
+			body.Tags = append(body.Tags, struct {
+				Name string `binding:"required,min=1,max=255" json:"name" mod:"trim,escape"`
+			}{Name: name})
+		}
+
+		resp, err := a.client.DialogDeleteTagsWithResponse(ctx, change.DialogID, body)
+		if err = ExtractError(resp, err); err != nil {
+			return fmt.Errorf("remove tags: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package bot_api_client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type taggerServer struct {
+	fakeAPI
+	tags      []Tag
+	languages map[int64]string
+	lookups   int
+	requests  []string
+}
+
+func (s *taggerServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/dialogs":
+			dialogID, _ := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+
+			return http.StatusOK, []DialogListResponseItem{{ID: dialogID, Tags: s.tags}}
+		case req.Method == http.MethodGet && req.URL.Path == "/customers":
+			customerID, _ := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+			s.lookups++
+			language := s.languages[customerID]
+
+			return http.StatusOK, []Customer{{ID: customerID, Language: &language}}
+		case req.Method == http.MethodPatch && strings.HasPrefix(req.URL.Path, "/dialogs/"):
+			var tags struct {
+				Tags []Tag `json:"tags"`
+			}
+			if !decodeRequest(t, req, &tags) {
+				return http.StatusBadRequest, nil
+			}
+
+			names := make([]string, 0, len(tags.Tags))
+			for _, tag := range tags.Tags {
+				names = append(names, tag.Name)
+			}
+			s.requests = append(s.requests, req.URL.Path+" "+strings.Join(names, ","))
+
+			return http.StatusOK, map[string]any{}
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func (s *taggerServer) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := s.requests
+	s.requests = nil
+
+	return requests
+}
+
+func taggedMessage(content string) ws.MessagePropertyFromMessageDataSchema {
+	dialogID := int64(7)
+
+	return ws.MessagePropertyFromMessageDataSchema{
+		Id:      1,
+		ChatId:  10,
+		Content: &content,
+		Dialog:  &ws.MessageDialogSchema{Id: &dialogID},
+		From:    &ws.UserRefSchema{Id: 5, Type: ws.UserTypeCustomer},
+		Chat:    &ws.ChatSchema{Id: 10, Channel: &ws.ChannelSchema{Id: 3, Type: ws.ChannelTypeTelegram}},
+	}
+}
+
+func TestAutoTagger(t *testing.T) {
+	t.Parallel()
+
+	rules := []TagRule{
+		{Name: "refund", Pattern: `(?i)\brefund\b`, AddTags: []Tag{{Name: "refund", ColorCode: ColorCodeRed}}},
+		{Name: "delivery", Keywords: []string{"Courier", "delivery"}, AddTags: []Tag{{Name: "delivery"}}},
+		{
+			Name:         "telegram",
+			ChannelTypes: []ws.ChannelTypeSchema{ws.ChannelTypeTelegram},
+			Keywords:     []string{"hello"},
+			AddTags:      []Tag{{Name: "telegram"}},
+		},
+		{Name: "spanish", Languages: []string{"es"}, AddTags: []Tag{{Name: "es"}}},
+		{
+			Name:            "voice",
+			AttachmentKinds: []ws.MessageFileKindSchema{ws.MessageFileKindAudio},
+			AddTags:         []Tag{{Name: "voice"}},
+		},
+		{Name: "order", HasOrder: true, AddTags: []Tag{{Name: "order"}}, RemoveTags: []string{"lead"}},
+		{Name: "resolved", Keywords: []string{"thanks"}, RemoveTags: []string{"refund"}},
+	}
+
+	t.Run("matches rules and applies changes", func(t *testing.T) {
+		t.Parallel()
+
+		server := taggerServer{tags: []Tag{{Name: "delivery"}, {Name: "lead"}}, languages: map[int64]string{5: "es"}}
+
+		var changes []TagChange
+		tagger, err := NewAutoTagger(server.client(t), rules, WithTagChangeHandler(func(_ context.Context, c TagChange) {
+			changes = append(changes, c)
+		}))
+		require.NoError(t, err)
+
+		message := taggedMessage("Hello, where is my REFUND? The courier never came")
+		message.Order = &ws.MessageOrderSchema{}
+		message.Items = []ws.MessageFileSchema{{Kind: ws.MessageFileKindAudio}}
+
+		change, err := tagger.Evaluate(context.Background(), message)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"refund", "delivery", "telegram", "spanish", "voice", "order"}, change.Rules)
+		assert.Equal(t, []Tag{
+			{Name: "refund", ColorCode: ColorCodeRed}, {Name: "telegram"}, {Name: "es"}, {Name: "voice"}, {Name: "order"},
+		}, change.Add)
+		assert.Equal(t, []string{"lead"}, change.Remove)
+		assert.False(t, change.DryRun)
+		assert.Equal(t, []TagChange{change}, changes)
+		assert.Equal(t, []string{
+			"/dialogs/7/tags/add refund,telegram,es,voice,order",
+			"/dialogs/7/tags/delete lead",
+		}, server.take())
+
+		// The customer language is cached and tags the dialog already has are not added again.
+		server.tags = append(server.tags, change.Add...)
+		change, err = tagger.Evaluate(context.Background(), taggedMessage("refund please"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"refund", "spanish"}, change.Rules)
+		assert.True(t, change.Empty())
+		assert.Empty(t, server.take())
+		assert.Equal(t, 1, server.lookups)
+		assert.Len(t, changes, 1)
+	})
+
+	t.Run("last rule wins", func(t *testing.T) {
+		t.Parallel()
+
+		server := taggerServer{tags: []Tag{{Name: "refund"}}}
+		tagger, err := NewAutoTagger(server.client(t), rules)
+		require.NoError(t, err)
+
+		change, err := tagger.Evaluate(context.Background(), taggedMessage("refund received, thanks"))
+		require.NoError(t, err)
+		assert.Empty(t, change.Add)
+		assert.Equal(t, []string{"refund"}, change.Remove)
+		assert.Equal(t, []string{"/dialogs/7/tags/delete refund"}, server.take())
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		t.Parallel()
+
+		server := taggerServer{}
+
+		var changes []TagChange
+		tagger, err := NewAutoTagger(server.client(t), rules, WithTagDryRun(),
+			WithTagChangeHandler(func(_ context.Context, c TagChange) { changes = append(changes, c) }))
+		require.NoError(t, err)
+
+		change, err := tagger.Evaluate(context.Background(), taggedMessage("refund"))
+		require.NoError(t, err)
+		assert.True(t, change.DryRun)
+		assert.Equal(t, []Tag{{Name: "refund", ColorCode: ColorCodeRed}}, change.Add)
+		assert.Equal(t, []TagChange{change}, changes)
+		assert.Empty(t, server.take())
+	})
+
+	t.Run("skips other senders", func(t *testing.T) {
+		t.Parallel()
+
+		tagger, err := NewAutoTagger(nil, rules)
+		require.NoError(t, err)
+
+		message := taggedMessage("refund")
+		message.From.Type = ws.UserTypeUser
+		change, err := tagger.Evaluate(context.Background(), message)
+		require.NoError(t, err)
+		assert.Equal(t, TagChange{}, change)
+
+		message = taggedMessage("refund")
+		message.Dialog = nil
+		change, err = tagger.Evaluate(context.Background(), message)
+		require.NoError(t, err)
+		assert.Equal(t, TagChange{}, change)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewAutoTagger(nil, []TagRule{{Name: "broken", Pattern: "("}})
+		require.ErrorContains(t, err, `tag rule "broken"`)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		server := taggerServer{}
+		tagger, err := NewAutoTagger(server.client(t), rules)
+		require.NoError(t, err)
+
+		var passed int
+		handler := tagger.Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+			passed++
+
+			return nil
+		})
+
+		require.NoError(t, handler(context.Background(), ws.EventMessageFromEventsChannel{
+			Payload: ws.EventSchema{
+				Type: ws.EventTypeMessageNew,
+				Data: ws.MessageDataSchema{Message: taggedMessage("courier is late")},
+			},
+		}))
+		require.NoError(t, handler(context.Background(), messageEvent(2, ws.UserTypeCustomer, "refund")))
+		assert.Equal(t, []string{"/dialogs/7/tags/add delivery"}, server.take())
+		assert.Equal(t, 2, passed)
+	})
+}
@@ -0,0 +1,473 @@
+package bot_api_client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+const (
+	// DefaultCampaignRate is how many campaign messages per second are sent to the chats of a channel.
+	DefaultCampaignRate = 1.0
+	// DefaultCampaignBurst is how many campaign messages may be sent to a channel at once.
+	DefaultCampaignBurst = 1
+	// DefaultCampaignPauseTimeout is how long a campaign waits for a paused channel before skipping its chats.
+	DefaultCampaignPauseTimeout = 15 * time.Minute
+)
+
+// CampaignRecipients selects the chats of a campaign: the chats with the given IDs, or, if ChatIDs
+// is empty, the chats matching Filter, e.g. by channel or customer.
+type CampaignRecipients struct {
+	ChatIDs []int64
+	Filter  ListChatsParams
+}
+
+// CampaignRenderer builds the message for the chat. The message is marked as mass communication
+// by the campaign. Returning a nil builder skips the chat.
+type CampaignRenderer func(ctx context.Context, chat ChatsListResponseItem) (*MessageBuilder, error)
+
+// CampaignReport counts the campaign results.
+type CampaignReport struct {
+	Sent    int
+	Failed  int
+	Skipped int
+	// Errors counts the failed messages by error code. Sending errors returned by the API are counted
+	// as general, and requests that did not get a response as network_error.
+	Errors map[MessageErrorCode]int
+}
+
+// Campaign sends a mass communication message to many chats under per-channel rate limits and
+// records every result in its store, so an interrupted campaign resumes where it stopped.
+// A message sent right before a crash may be sent again, since its result was not recorded.
+type Campaign struct {
+	client     ClientWithResponsesInterface
+	store      CampaignStore
+	recipients CampaignRecipients
+	render     CampaignRenderer
+	rate       float64
+	burst      int
+	preflight  *Preflight
+	policy     *SendingPolicyEvaluator
+	gate       *ChannelGate
+	maxPause   time.Duration
+	now        func() time.Time
+	onResult   func(ctx context.Context, result CampaignResult)
+	onError    func(ctx context.Context, err error)
+
+	mu       sync.Mutex
+	limiters map[int64]RateLimiter
+	messages map[int64]CampaignResult
+}
+
+// CampaignOption configures a Campaign.
+type CampaignOption func(*Campaign)
+
+// WithCampaignRate sets the rate and burst of messages per channel.
+func WithCampaignRate(r float64, burst int) CampaignOption {
+	return func(c *Campaign) {
+		c.rate = r
+		c.burst = burst
+	}
+}
+
+// WithCampaignChannelLimiter limits the messages sent to the chats of the channel with l instead of
+// the campaign rate.
+func WithCampaignChannelLimiter(channelID int64, l RateLimiter) CampaignOption {
+	return func(c *Campaign) {
+		c.limiters[channelID] = l
+	}
+}
+
+// WithCampaignPreflight checks every message against the settings of its channel and skips the chats
+// whose channel does not support it.
+func WithCampaignPreflight(p *Preflight) CampaignOption {
+	return func(c *Campaign) {
+		c.preflight = p
+	}
+}
+
+// WithCampaignSendingPolicy skips the chats the sending policy of their channel does not allow
+// free-form messages to.
+func WithCampaignSendingPolicy(e *SendingPolicyEvaluator) CampaignOption {
+	return func(c *Campaign) {
+		c.policy = e
+	}
+}
+
+// WithCampaignChannelGate holds back the messages to channels paused in the gate until they are resumed.
+// A chat whose channel stays paused for longer than the pause timeout is skipped with the ChannelPausedError
+// as the reason, and so are the following chats of the channel while it stays paused.
+func WithCampaignChannelGate(gate *ChannelGate) CampaignOption {
+	return func(c *Campaign) {
+		c.gate = gate
+	}
+}
+
+// WithCampaignPauseTimeout sets how long to wait for a paused channel, DefaultCampaignPauseTimeout by default.
+func WithCampaignPauseTimeout(timeout time.Duration) CampaignOption {
+	return func(c *Campaign) {
+		c.maxPause = timeout
+	}
+}
+
+// WithCampaignResultHandler sets the function called with every recorded result.
+func WithCampaignResultHandler(onResult func(ctx context.Context, result CampaignResult)) CampaignOption {
+	return func(c *Campaign) {
+		c.onResult = onResult
+	}
+}
+
+// WithCampaignErrorHandler sets the function called with errors of events handled by the handler.
+func WithCampaignErrorHandler(onError func(ctx context.Context, err error)) CampaignOption {
+	return func(c *Campaign) {
+		c.onError = onError
+	}
+}
+
+// NewCampaign creates a Campaign sending the message rendered for every recipient.
+func NewCampaign(
+	client ClientWithResponsesInterface,
+	store CampaignStore,
+	recipients CampaignRecipients,
+	render CampaignRenderer,
+	opts ...CampaignOption,
+) *Campaign {
+	c := &Campaign{
+		client:     client,
+		store:      store,
+		recipients: recipients,
+		render:     render,
+		rate:       DefaultCampaignRate,
+		burst:      DefaultCampaignBurst,
+		maxPause:   DefaultCampaignPauseTimeout,
+		now:        time.Now,
+		onResult:   func(context.Context, CampaignResult) {},
+		onError:    func(context.Context, error) {},
+		limiters:   make(map[int64]RateLimiter),
+		messages:   make(map[int64]CampaignResult),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Run sends the campaign to the recipients without a recorded result. Channels are served concurrently,
+// each at its own rate. Run returns the report of all results once every recipient is done, or an error
+// if the recipients cannot be listed, a result cannot be recorded or ctx is done.
+func (c *Campaign) Run(ctx context.Context) (CampaignReport, error) {
+	results, err := c.store.Results(ctx)
+	if err != nil {
+		return CampaignReport{}, fmt.Errorf("run campaign: %w", err)
+	}
+
+	done := make(map[int64]bool, len(results))
+	c.mu.Lock()
+	for _, r := range results {
+		done[r.ChatID] = true
+		if r.Status == CampaignStatusSent {
+			c.messages[r.MessageID] = r
+		}
+	}
+	c.mu.Unlock()
+
+	chats, missing, err := c.chats(ctx)
+	if err != nil {
+		return CampaignReport{}, fmt.Errorf("run campaign: list chats: %w", err)
+	}
+
+	for _, chatID := range missing {
+		if !done[chatID] {
+			result := CampaignResult{ChatID: chatID, Status: CampaignStatusSkipped, Error: ErrChatNotFound.Error()}
+			if err = c.record(ctx, result); err != nil {
+				return CampaignReport{}, fmt.Errorf("run campaign: %w", err)
+			}
+		}
+	}
+
+	byChannel := make(map[int64][]ChatsListResponseItem)
+	for _, chat := range chats {
+		if done[chat.ID] {
+			continue
+		}
+		var channelID int64
+		if chat.Channel != nil {
+			channelID = chat.Channel.ID
+		}
+		byChannel[channelID] = append(byChannel[channelID], chat)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		fail error
+	)
+	for channelID, chats := range byChannel {
+		limiter := c.limiter(channelID)
+		wg.Go(func() {
+			var timedOut bool
+			for _, chat := range chats {
+				err := c.wait(ctx, channelID, limiter, timedOut)
+				var paused *ChannelPausedError
+				timedOut = errors.As(err, &paused)
+				switch {
+				case timedOut:
+					err = c.record(ctx, CampaignResult{
+						ChatID: chat.ID, ChannelID: channelID, Status: CampaignStatusSkipped, Error: paused.Error(),
+					})
+				case err == nil:
+					err = c.send(ctx, channelID, chat)
+				}
+				if err != nil {
+					once.Do(func() { fail = err })
+					cancel()
+
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	if fail != nil {
+		return CampaignReport{}, fmt.Errorf("run campaign: %w", fail)
+	}
+
+	return c.Report(ctx)
+}
+
+// Report counts the results recorded so far.
+func (c *Campaign) Report(ctx context.Context) (CampaignReport, error) {
+	results, err := c.store.Results(ctx)
+	if err != nil {
+		return CampaignReport{}, err
+	}
+
+	report := CampaignReport{Errors: make(map[MessageErrorCode]int)}
+	for _, r := range results {
+		switch r.Status {
+		case CampaignStatusSent:
+			report.Sent++
+		case CampaignStatusFailed:
+			report.Failed++
+			report.Errors[r.ErrorCode]++
+		case CampaignStatusSkipped:
+			report.Skipped++
+		}
+	}
+
+	return report, nil
+}
+
+// Handler returns a WebSocket event handler that records campaign messages failed after sending,
+// as reported by message_updated events, and passes every event to next, which may be nil.
+func (c *Campaign) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		data, ok := msg.Payload.Data.(ws.MessageDataSchema)
+		if ok && msg.Payload.Type == ws.EventTypeMessageUpdated && data.Message.Status == ws.MessageStatusFailed {
+			if err := c.deliveryFailed(ctx, data.Message); err != nil {
+				c.onError(ctx, fmt.Errorf("record campaign message %d: %w", data.Message.Id, err))
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// deliveryFailed records a campaign message that failed after it was sent.
+func (c *Campaign) deliveryFailed(ctx context.Context, message ws.MessagePropertyFromMessageDataSchema) error {
+	c.mu.Lock()
+	sent, ok := c.messages[message.Id]
+	delete(c.messages, message.Id)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	result := CampaignResult{
+		ChatID:    sent.ChatID,
+		ChannelID: sent.ChannelID,
+		Status:    CampaignStatusFailed,
+		MessageID: message.Id,
+		ErrorCode: MessageErrorCodeUnknown,
+	}
+	if e := message.Error; e != nil {
+		result.ErrorCode = cmp.Or(convertEnumValue[MessageErrorCode](e.Code), MessageErrorCodeUnknown)
+		result.Error = deref(e.Message)
+	}
+
+	return c.record(ctx, result)
+}
+
+// chats returns the recipient chats and the requested chat IDs that were not found.
+func (c *Campaign) chats(ctx context.Context) ([]ChatsListResponseItem, []int64, error) {
+	if len(c.recipients.ChatIDs) == 0 {
+		var chats []ChatsListResponseItem
+		params := c.recipients.Filter
+		params.Limit = optional(maxListLimit)
+		for {
+			resp, err := c.client.ListChatsWithResponse(ctx, &params)
+			if err = ExtractError(resp, err); err != nil {
+				return nil, nil, err
+			}
+
+			page := deref(resp.JSON200)
+			chats = append(chats, page...)
+			if len(page) < maxListLimit {
+				return chats, nil, nil
+			}
+			params.SinceID = &page[len(page)-1].ID
+		}
+	}
+
+	var (
+		chats   []ChatsListResponseItem
+		missing []int64
+	)
+	for _, chatID := range c.recipients.ChatIDs {
+		id := int(chatID)
+		resp, err := c.client.ListChatsWithResponse(ctx, &ListChatsParams{ID: &id})
+		if err = ExtractError(resp, err); err != nil {
+			return nil, nil, err
+		}
+		if page := deref(resp.JSON200); len(page) > 0 {
+			chats = append(chats, page[0])
+		} else {
+			missing = append(missing, chatID)
+		}
+	}
+
+	return chats, missing, nil
+}
+
+func (c *Campaign) limiter(channelID int64) RateLimiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.limiters[channelID]
+	if !ok {
+		l = NewDefaultLimiter(c.rate, c.burst)
+		c.limiters[channelID] = l
+	}
+
+	return l
+}
+
+// wait blocks until the channel is not paused and the limiter lets the next message through. It returns
+// a *ChannelPausedError if the channel is still paused after the pause timeout, or at once if timedOut is set
+// after the previous wait timed out.
+func (c *Campaign) wait(ctx context.Context, channelID int64, limiter RateLimiter, timedOut bool) error {
+	if c.gate != nil {
+		if timedOut {
+			if err := c.gate.Allow(channelID); err != nil {
+				return err
+			}
+		} else {
+			waitCtx, cancel := context.WithTimeout(ctx, c.maxPause)
+			err := c.gate.Wait(waitCtx, channelID)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				err = c.gate.Allow(channelID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return limiter.Wait(ctx)
+}
+
+// send renders and sends the message to the chat and records the result. It only fails if the result
+// cannot be recorded or ctx is done.
+func (c *Campaign) send(ctx context.Context, channelID int64, chat ChatsListResponseItem) error {
+	result := CampaignResult{ChatID: chat.ID, ChannelID: channelID}
+	skip := func(reason string) error {
+		result.Status, result.Error = CampaignStatusSkipped, reason
+
+		return c.record(ctx, result)
+	}
+
+	message, err := c.render(ctx, chat)
+	if err != nil {
+		return skip("render: " + err.Error())
+	}
+	if message == nil {
+		return skip("no message")
+	}
+
+	body, err := message.MassCommunication().Build()
+	if err != nil {
+		return skip(err.Error())
+	}
+	if c.preflight != nil {
+		violations, err := c.preflight.CheckSend(ctx, body)
+		if err == nil {
+			err = violations.Err()
+		}
+		if err != nil {
+			return skip(err.Error())
+		}
+	}
+	if c.policy != nil {
+		decision, err := c.policy.Evaluate(ctx, chat)
+		if err != nil {
+			return skip(err.Error())
+		}
+		if !decision.Allows(body) {
+			return skip(decision.Message)
+		}
+	}
+
+	resp, err := c.client.SendMessageWithResponse(ctx, body)
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// The campaign was stopped; the chat is sent to when it resumes.
+		return err
+	case err != nil:
+		result.Status, result.ErrorCode, result.Error = CampaignStatusFailed, MessageErrorCodeNetworkError, err.Error()
+	case resp.Error() != nil:
+		result.Status, result.ErrorCode, result.Error = CampaignStatusFailed, MessageErrorCodeGeneral, resp.Error().Error()
+	default:
+		result.Status = CampaignStatusSent
+		if resp.JSON200 != nil {
+			result.MessageID = resp.JSON200.MessageId
+		}
+	}
+
+	return c.record(ctx, result)
+}
+
+func (c *Campaign) record(ctx context.Context, result CampaignResult) error {
+	if result.At.IsZero() {
+		result.At = c.now()
+	}
+	if err := c.store.Record(ctx, result); err != nil {
+		return err
+	}
+
+	if result.Status == CampaignStatusSent && result.MessageID != 0 {
+		c.mu.Lock()
+		c.messages[result.MessageID] = result
+		c.mu.Unlock()
+	}
+	c.onResult(ctx, result)
+
+	return nil
+}
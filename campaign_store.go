@@ -0,0 +1,173 @@
+package bot_api_client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// CampaignStatus is the outcome of a campaign message for a recipient.
+type CampaignStatus string
+
+const (
+	CampaignStatusSent    CampaignStatus = "sent"
+	CampaignStatusFailed  CampaignStatus = "failed"
+	CampaignStatusSkipped CampaignStatus = "skipped"
+)
+
+// CampaignResult is the outcome of a campaign for a chat.
+type CampaignResult struct {
+	ChatID    int64          `json:"chat_id"`
+	ChannelID int64          `json:"channel_id,omitempty"`
+	Status    CampaignStatus `json:"status"`
+	MessageID int64          `json:"message_id,omitempty"`
+	// ErrorCode is set for failed messages.
+	ErrorCode MessageErrorCode `json:"error_code,omitempty"`
+	// Error describes why the message failed or was skipped.
+	Error string    `json:"error,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// CampaignStore keeps the progress of a campaign, so that an interrupted campaign resumes without sending
+// the message to the same chat twice. Implementations must be safe for concurrent use.
+type CampaignStore interface {
+	// Record saves the result, replacing an earlier result for the chat.
+	Record(ctx context.Context, result CampaignResult) error
+	// Results returns the latest result of every chat in the order they were first recorded.
+	Results(ctx context.Context) ([]CampaignResult, error)
+}
+
+// MemoryCampaignStore is a CampaignStore keeping results in memory.
+type MemoryCampaignStore struct {
+	mu      sync.RWMutex
+	order   []int64
+	results map[int64]CampaignResult
+}
+
+// NewMemoryCampaignStore creates an empty in-memory store.
+func NewMemoryCampaignStore() *MemoryCampaignStore {
+	return &MemoryCampaignStore{results: make(map[int64]CampaignResult)}
+}
+
+func (s *MemoryCampaignStore) Record(_ context.Context, result CampaignResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.results[result.ChatID]; !ok {
+		s.order = append(s.order, result.ChatID)
+	}
+	s.results[result.ChatID] = result
+
+	return nil
+}
+
+func (s *MemoryCampaignStore) Results(context.Context) ([]CampaignResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]CampaignResult, 0, len(s.order))
+	for _, chatID := range s.order {
+		results = append(results, s.results[chatID])
+	}
+
+	return results, nil
+}
+
+// FileCampaignStore is a CampaignStore persisted to a file, one JSON line per recorded result.
+// The file is replayed when the store is opened.
+type FileCampaignStore struct {
+	*MemoryCampaignStore
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileCampaignStore opens the store at path, creating the file if it does not exist.
+// A record cut short by a crash at the end of the file is discarded.
+func OpenFileCampaignStore(path string) (*FileCampaignStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileCampaignStore{MemoryCampaignStore: NewMemoryCampaignStore(), file: file}
+	if err = s.replay(); err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("open campaign store %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *FileCampaignStore) replay() error {
+	reader := bufio.NewReader(s.file)
+
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without the trailing newline was not written completely.
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var result CampaignResult
+		if err = json.Unmarshal(line, &result); err != nil {
+			return fmt.Errorf("record at offset %d: %w", valid, err)
+		}
+		_ = s.MemoryCampaignStore.Record(context.Background(), result)
+
+		valid += int64(len(line))
+	}
+
+	if err := s.file.Truncate(valid); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(valid, io.SeekStart)
+
+	return err
+}
+
+// Record appends the result to the file and then applies it to the memory store.
+func (s *FileCampaignStore) Record(ctx context.Context, result CampaignResult) error {
+	line, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return s.MemoryCampaignStore.Record(ctx, result)
+}
+
+// Close syncs and closes the file.
+func (s *FileCampaignStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := errors.Join(s.file.Sync(), s.file.Close())
+	s.file = nil
+
+	return err
+}
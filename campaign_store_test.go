@@ -0,0 +1,80 @@
+package bot_api_client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaignStore(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sent := CampaignResult{ChatID: 1, ChannelID: 3, Status: CampaignStatusSent, MessageID: 10, At: at}
+	skipped := CampaignResult{ChatID: 2, Status: CampaignStatusSkipped, Error: "no message", At: at}
+	failed := CampaignResult{
+		ChatID: 1, ChannelID: 3, Status: CampaignStatusFailed, MessageID: 10,
+		ErrorCode: MessageErrorCodeSpamSuspicion, At: at.Add(time.Minute),
+	}
+
+	t.Run("memory", func(t *testing.T) {
+		t.Parallel()
+
+		store := NewMemoryCampaignStore()
+		for _, r := range []CampaignResult{sent, skipped, failed} {
+			require.NoError(t, store.Record(context.Background(), r))
+		}
+
+		results, err := store.Results(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []CampaignResult{failed, skipped}, results)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "campaign.jsonl")
+		store, err := OpenFileCampaignStore(path)
+		require.NoError(t, err)
+		for _, r := range []CampaignResult{sent, skipped, failed} {
+			require.NoError(t, store.Record(context.Background(), r))
+		}
+		require.NoError(t, store.Close())
+		require.ErrorIs(t, store.Record(context.Background(), sent), os.ErrClosed)
+
+		// A record cut short by a crash is discarded.
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"chat_id":3,"sta`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		store, err = OpenFileCampaignStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+
+		results, err := store.Results(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []CampaignResult{failed, skipped}, results)
+
+		require.NoError(t, store.Record(context.Background(), CampaignResult{ChatID: 3, Status: CampaignStatusSent, At: at}))
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "\n"+`{"chat_id":3,"status":"sent"`)
+	})
+
+	t.Run("corrupt file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "campaign.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o600))
+
+		_, err := OpenFileCampaignStore(path)
+		require.ErrorContains(t, err, "record at offset 0")
+	})
+}
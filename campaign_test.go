@@ -0,0 +1,284 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type campaignServer struct {
+	fakeAPI
+	chats []ChatsListResponseItem
+	sent  []string
+	// rejected chats get an API error, unreachable chats a transport error.
+	rejected    map[int64]bool
+	unreachable map[int64]bool
+}
+
+func (s *campaignServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		if err := req.Context().Err(); err != nil {
+			return 0, err
+		}
+
+		query := req.URL.Query()
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/chats":
+			chats := []ChatsListResponseItem{}
+			for _, chat := range s.chats {
+				if (query.Has("id") && query.Get("id") != strconv.FormatInt(chat.ID, 10)) ||
+					(query.Has("channel_id") && query.Get("channel_id") != strconv.FormatInt(chat.Channel.ID, 10)) {
+					continue
+				}
+				chats = append(chats, chat)
+			}
+
+			return http.StatusOK, chats
+		case req.Method == http.MethodPost && req.URL.Path == "/messages":
+			var message SendMessageRequestBody
+			if !decodeRequest(t, req, &message) {
+				return http.StatusBadRequest, nil
+			}
+			assert.True(t, deref(message.MassCommunication))
+
+			switch {
+			case s.unreachable[message.ChatID]:
+				return 0, errors.New("connection reset by peer")
+			case s.rejected[message.ChatID]:
+				return http.StatusBadRequest, ErrorResponse{Errors: []string{"chat is blocked"}}
+			default:
+				s.sent = append(s.sent, fmt.Sprintf("%d:%s", message.ChatID, *message.Content))
+
+				return http.StatusOK, SendMessageResponse{MessageId: 100 + message.ChatID}
+			}
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func (s *campaignServer) sentMessages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := slices.Clone(s.sent)
+	slices.Sort(sent)
+
+	return sent
+}
+
+func campaignChats() []ChatsListResponseItem {
+	chat := func(id, channelID int64, name string) ChatsListResponseItem {
+		return ChatsListResponseItem{ID: id, Channel: &Channel{ID: channelID}, Customer: &Actor{Name: name}}
+	}
+
+	return []ChatsListResponseItem{
+		chat(11, 1, "Ann"), chat(12, 1, ""), chat(13, 1, "Bob"), chat(14, 2, "Carl"), chat(15, 2, "Dan"),
+	}
+}
+
+func greeting(_ context.Context, chat ChatsListResponseItem) (*MessageBuilder, error) {
+	if chat.Customer.Name == "" {
+		return nil, nil
+	}
+
+	return NewText(chat.ID, "Hi "+chat.Customer.Name), nil
+}
+
+type countingLimiter struct{ waits atomic.Int32 }
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.waits.Add(1)
+
+	return ctx.Err()
+}
+
+func TestCampaign(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends and reports", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{
+			chats:       campaignChats(),
+			rejected:    map[int64]bool{13: true},
+			unreachable: map[int64]bool{14: true},
+		}
+		var limiter countingLimiter
+		campaign := NewCampaign(server.client(t), NewMemoryCampaignStore(), CampaignRecipients{}, greeting,
+			WithCampaignRate(1000, 10), WithCampaignChannelLimiter(2, &limiter))
+
+		report, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, CampaignReport{
+			Sent:    2,
+			Failed:  2,
+			Skipped: 1,
+			Errors:  map[MessageErrorCode]int{MessageErrorCodeGeneral: 1, MessageErrorCodeNetworkError: 1},
+		}, report)
+		assert.Equal(t, []string{"11:Hi Ann", "15:Hi Dan"}, server.sentMessages())
+		assert.Equal(t, int32(2), limiter.waits.Load())
+
+		results, err := campaign.store.Results(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 5)
+		byChat := make(map[int64]CampaignResult)
+		for _, r := range results {
+			byChat[r.ChatID] = r
+		}
+		assert.Equal(t, int64(111), byChat[11].MessageID)
+		assert.Equal(t, "no message", byChat[12].Error)
+		assert.Equal(t, "chat is blocked", byChat[13].Error)
+		assert.Equal(t, int64(2), byChat[14].ChannelID)
+	})
+
+	t.Run("explicit chats", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{chats: campaignChats()}
+		campaign := NewCampaign(server.client(t), NewMemoryCampaignStore(), CampaignRecipients{ChatIDs: []int64{11, 99, 15}},
+			greeting, WithCampaignRate(1000, 10))
+
+		report, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, CampaignReport{Sent: 2, Skipped: 1, Errors: map[MessageErrorCode]int{}}, report)
+		assert.Equal(t, []string{"11:Hi Ann", "15:Hi Dan"}, server.sentMessages())
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{chats: campaignChats()}
+		recipients := CampaignRecipients{Filter: ListChatsParams{ChannelID: optional(2)}}
+		campaign := NewCampaign(server.client(t), NewMemoryCampaignStore(), recipients, greeting, WithCampaignRate(1000, 10))
+
+		report, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Sent)
+		assert.Equal(t, []string{"14:Hi Carl", "15:Hi Dan"}, server.sentMessages())
+	})
+
+	t.Run("resumes after interruption", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{chats: campaignChats()[:3]}
+		store := NewMemoryCampaignStore()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		interrupting := func(ctx context.Context, chat ChatsListResponseItem) (*MessageBuilder, error) {
+			if chat.ID == 12 {
+				cancel()
+			}
+
+			return NewText(chat.ID, "Sale"), nil
+		}
+		campaign := NewCampaign(server.client(t), store, CampaignRecipients{}, interrupting, WithCampaignRate(1000, 10))
+		_, err := campaign.Run(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, []string{"11:Sale"}, server.sentMessages())
+
+		campaign = NewCampaign(server.client(t), store, CampaignRecipients{}, interrupting, WithCampaignRate(1000, 10))
+		report, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Sent)
+		assert.Equal(t, []string{"11:Sale", "12:Sale", "13:Sale"}, server.sentMessages())
+	})
+
+	t.Run("channel gate", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{chats: campaignChats()}
+		gate := NewChannelGate()
+		gate.Pause(2, "flagged")
+		campaign := NewCampaign(server.client(t), NewMemoryCampaignStore(), CampaignRecipients{}, greeting,
+			WithCampaignRate(1000, 10), WithCampaignChannelGate(gate))
+
+		done := make(chan error)
+		go func() {
+			_, err := campaign.Run(context.Background())
+			done <- err
+		}()
+
+		require.Eventually(t, func() bool { return len(server.sentMessages()) == 2 }, time.Second, time.Millisecond)
+		assert.Equal(t, []string{"11:Hi Ann", "13:Hi Bob"}, server.sentMessages())
+
+		gate.Resume(2)
+		require.NoError(t, <-done)
+		assert.Equal(t, []string{"11:Hi Ann", "13:Hi Bob", "14:Hi Carl", "15:Hi Dan"}, server.sentMessages())
+	})
+
+	t.Run("channel paused for too long", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{chats: campaignChats()}
+		store := NewMemoryCampaignStore()
+		gate := NewChannelGate()
+		gate.Pause(2, "flagged")
+		campaign := NewCampaign(server.client(t), store, CampaignRecipients{}, greeting,
+			WithCampaignRate(1000, 10), WithCampaignChannelGate(gate), WithCampaignPauseTimeout(10*time.Millisecond))
+
+		report, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Sent)
+		assert.Equal(t, 3, report.Skipped)
+		assert.Equal(t, []string{"11:Hi Ann", "13:Hi Bob"}, server.sentMessages())
+
+		results, err := store.Results(context.Background())
+		require.NoError(t, err)
+		var paused []int64
+		for _, r := range results {
+			if r.Error == "channel 2 is paused: flagged" {
+				paused = append(paused, r.ChatID)
+			}
+		}
+		assert.ElementsMatch(t, []int64{14, 15}, paused)
+	})
+
+	t.Run("delivery failures", func(t *testing.T) {
+		t.Parallel()
+
+		server := campaignServer{chats: campaignChats()[:1]}
+		var results []CampaignResult
+		campaign := NewCampaign(server.client(t), NewMemoryCampaignStore(), CampaignRecipients{}, greeting,
+			WithCampaignRate(1000, 10),
+			WithCampaignResultHandler(func(_ context.Context, r CampaignResult) { results = append(results, r) }))
+
+		_, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+
+		var passed int
+		handler := campaign.Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+			passed++
+
+			return nil
+		})
+		spam := ws.MessageErrorCodeSpamSuspicion
+		for _, id := range []int64{111, 111, 5} {
+			require.NoError(t, handler(context.Background(), ws.EventMessageFromEventsChannel{Payload: ws.EventSchema{
+				Type: ws.EventTypeMessageUpdated,
+				Data: ws.MessageDataSchema{Message: ws.MessagePropertyFromMessageDataSchema{
+					Id: id, Status: ws.MessageStatusFailed, Error: &ws.MessageErrorSchema{Code: &spam},
+				}},
+			}}))
+		}
+		assert.Equal(t, 3, passed)
+
+		report, err := campaign.Report(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, CampaignReport{Failed: 1, Errors: map[MessageErrorCode]int{MessageErrorCodeSpamSuspicion: 1}}, report)
+		require.Len(t, results, 2)
+		assert.Equal(t, CampaignStatusFailed, results[1].Status)
+		assert.Equal(t, int64(111), results[1].MessageID)
+	})
+}
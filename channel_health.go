@@ -0,0 +1,504 @@
+package bot_api_client
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// DefaultChannelHealthInterval is how often ChannelHealthMonitor.Run lists the channels again.
+const DefaultChannelHealthInterval = 5 * time.Minute
+
+// ChannelHealthEventKind is the transition a channel health event reports.
+type ChannelHealthEventKind string
+
+const (
+	// ChannelHealthDeactivated fires when an active channel is deactivated.
+	ChannelHealthDeactivated ChannelHealthEventKind = "deactivated"
+	// ChannelHealthActivated fires when an inactive channel is activated again.
+	ChannelHealthActivated ChannelHealthEventKind = "activated"
+	// ChannelHealthQualityDropped fires when the WhatsApp quality rating goes down.
+	ChannelHealthQualityDropped ChannelHealthEventKind = "quality_dropped"
+	// ChannelHealthQualityRaised fires when the WhatsApp quality rating goes up.
+	ChannelHealthQualityRaised ChannelHealthEventKind = "quality_raised"
+	// ChannelHealthFlagged fires when the WhatsApp status becomes flagged.
+	ChannelHealthFlagged ChannelHealthEventKind = "flagged"
+	// ChannelHealthRestricted fires when the WhatsApp status becomes restricted.
+	ChannelHealthRestricted ChannelHealthEventKind = "restricted"
+	// ChannelHealthOffline fires when the WhatsApp status becomes offline.
+	ChannelHealthOffline ChannelHealthEventKind = "offline"
+	// ChannelHealthConnected fires when the WhatsApp status becomes connected.
+	ChannelHealthConnected ChannelHealthEventKind = "connected"
+	// ChannelHealthStatusChanged fires when the WhatsApp status changes to any other value.
+	ChannelHealthStatusChanged ChannelHealthEventKind = "status_changed"
+	// ChannelHealthTierChanged fires when the WhatsApp messaging tier changes.
+	ChannelHealthTierChanged ChannelHealthEventKind = "tier_changed"
+	// ChannelHealthUnhealthy fires when a channel becomes unhealthy, or is unhealthy when it is first seen.
+	ChannelHealthUnhealthy ChannelHealthEventKind = "unhealthy"
+	// ChannelHealthRecovered fires when an unhealthy channel becomes healthy.
+	ChannelHealthRecovered ChannelHealthEventKind = "recovered"
+)
+
+// ChannelHealth is the health of a channel as last seen by the monitor.
+type ChannelHealth struct {
+	ChannelID int64
+	Type      ChannelType
+	Name      string
+	Active    bool
+	// DeactivatedAt is when the channel was deactivated, as reported by the API or as seen in an event.
+	// It is zero for active channels.
+	DeactivatedAt time.Time
+	// Quality, Status and Tier are only reported for WhatsApp channels.
+	Quality WAChannelQuality
+	Status  WAChannelStatus
+	Tier    *int
+	// Healthy is the verdict of the health policy of the monitor.
+	Healthy bool
+	// UpdatedAt is when the monitor last updated the channel.
+	UpdatedAt time.Time
+}
+
+// DefaultChannelHealthPolicy considers a channel healthy if it is active and, for WhatsApp channels,
+// connected with a quality rating above low.
+func DefaultChannelHealthPolicy(h ChannelHealth) bool {
+	return h.Active &&
+		(h.Status == "" || h.Status == WAChannelStatusConnected) &&
+		h.Quality != WAChannelQualityLow
+}
+
+// ChannelHealthEvent describes a transition in the health of a channel.
+type ChannelHealthEvent struct {
+	Kind ChannelHealthEventKind
+	// Previous is the health before the transition. It is zero when the channel is first seen.
+	Previous ChannelHealth
+	Current  ChannelHealth
+	// At is the time the transition was detected.
+	At time.Time
+}
+
+// ChannelHealthHook is called for channel health events. Errors are reported to the error handler of the monitor.
+type ChannelHealthHook func(ctx context.Context, event ChannelHealthEvent) error
+
+// ChannelHealthMonitor keeps the health of every channel of the bot and fires hooks on transitions such as
+// a dropped WhatsApp quality rating, a flagged number or a deactivated channel. Channels are loaded by Refresh
+// and updated from channel_updated events; Run refreshes them periodically to catch missed events.
+// With a ChannelGate, traffic to unhealthy channels is paused until they recover.
+// ChannelHealthMonitor is safe for concurrent use.
+type ChannelHealthMonitor struct {
+	client     ClientWithResponsesInterface
+	hooks      []channelHealthHook
+	gate       *ChannelGate
+	healthy    func(ChannelHealth) bool
+	interval   time.Duration
+	now        func() time.Time
+	onError    func(ctx context.Context, err error)
+	reqEditors []RequestEditorFn
+
+	mu       sync.Mutex
+	channels map[int64]ChannelHealth
+}
+
+type channelHealthHook struct {
+	hook  ChannelHealthHook
+	kinds []ChannelHealthEventKind
+}
+
+// ChannelHealthOption configures a ChannelHealthMonitor.
+type ChannelHealthOption func(*ChannelHealthMonitor)
+
+// WithChannelHealthHook calls the hook for events of the given kinds, or of all kinds if none are given.
+// Hooks run in the order they are added.
+func WithChannelHealthHook(hook ChannelHealthHook, kinds ...ChannelHealthEventKind) ChannelHealthOption {
+	return func(m *ChannelHealthMonitor) {
+		m.hooks = append(m.hooks, channelHealthHook{hook: hook, kinds: kinds})
+	}
+}
+
+// WithChannelHealthGate pauses unhealthy channels in the gate and resumes them when they recover.
+func WithChannelHealthGate(gate *ChannelGate) ChannelHealthOption {
+	return func(m *ChannelHealthMonitor) {
+		m.gate = gate
+	}
+}
+
+// WithChannelHealthPolicy replaces DefaultChannelHealthPolicy.
+func WithChannelHealthPolicy(healthy func(ChannelHealth) bool) ChannelHealthOption {
+	return func(m *ChannelHealthMonitor) {
+		m.healthy = healthy
+	}
+}
+
+// WithChannelHealthInterval sets how often Run lists the channels.
+func WithChannelHealthInterval(interval time.Duration) ChannelHealthOption {
+	return func(m *ChannelHealthMonitor) {
+		m.interval = interval
+	}
+}
+
+// WithChannelHealthErrorHandler sets the function called with errors of hooks and of periodic refreshes.
+func WithChannelHealthErrorHandler(onError func(ctx context.Context, err error)) ChannelHealthOption {
+	return func(m *ChannelHealthMonitor) {
+		m.onError = onError
+	}
+}
+
+// WithChannelHealthRequestEditors sets the request editors applied to the list requests.
+func WithChannelHealthRequestEditors(reqEditors ...RequestEditorFn) ChannelHealthOption {
+	return func(m *ChannelHealthMonitor) {
+		m.reqEditors = reqEditors
+	}
+}
+
+// NewChannelHealthMonitor creates a monitor that lists channels with the given client.
+func NewChannelHealthMonitor(client ClientWithResponsesInterface, opts ...ChannelHealthOption) *ChannelHealthMonitor {
+	m := &ChannelHealthMonitor{
+		client:   client,
+		healthy:  DefaultChannelHealthPolicy,
+		interval: DefaultChannelHealthInterval,
+		now:      time.Now,
+		onError:  func(context.Context, error) {},
+		channels: make(map[int64]ChannelHealth),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Refresh lists all channels and updates their health, firing hooks for the transitions found.
+func (m *ChannelHealthMonitor) Refresh(ctx context.Context) error {
+	params := ListChannelsParams{Limit: optional(maxListLimit)}
+	for {
+		resp, err := m.client.ListChannelsWithResponse(ctx, &params, m.reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return fmt.Errorf("channel health refresh: %w", err)
+		}
+
+		page := deref(resp.JSON200)
+		for _, channel := range page {
+			m.Update(ctx, channel)
+		}
+		if len(page) < maxListLimit {
+			return nil
+		}
+		params.SinceID = &page[len(page)-1].ID
+	}
+}
+
+// Update sets the health of the channel from its REST model, firing hooks for the transitions.
+func (m *ChannelHealthMonitor) Update(ctx context.Context, channel ChannelListResponseItem) {
+	health := ChannelHealth{
+		ChannelID: channel.ID,
+		Type:      channel.Type,
+		Name:      deref(channel.Name),
+		Active:    channel.IsActive,
+	}
+	if channel.DeactivatedAt != nil && !channel.IsActive {
+		health.DeactivatedAt = channel.DeactivatedAt.Time
+	}
+	if wa := channel.Settings.Whatsapp; wa != nil {
+		health.Quality, health.Status, health.Tier = deref(wa.ChannelQuality), deref(wa.ChannelStatus), wa.Tier
+	}
+
+	m.set(ctx, channel.ID, func(ChannelHealth) ChannelHealth { return health })
+}
+
+// updateFromWS sets the health of the channel from a channel_updated event. Events do not carry the
+// deactivation time, so the time the deactivation is seen is used.
+func (m *ChannelHealthMonitor) updateFromWS(ctx context.Context, channel ws.ChannelSchema) {
+	m.set(ctx, channel.Id, func(health ChannelHealth) ChannelHealth {
+		return m.mergeWS(health, channel)
+	})
+}
+
+// mergeWS merges the channel of an event into its stored health.
+func (m *ChannelHealthMonitor) mergeWS(health ChannelHealth, channel ws.ChannelSchema) ChannelHealth {
+	health.ChannelID, health.Type, health.Active = channel.Id, ChannelType(channel.Type), channel.IsActive
+	health.Name = cmp.Or(deref(channel.Name), health.Name)
+	switch {
+	case channel.IsActive:
+		health.DeactivatedAt = time.Time{}
+	case health.DeactivatedAt.IsZero():
+		health.DeactivatedAt = m.now()
+	}
+	if channel.Settings != nil && channel.Settings.Whatsapp != nil {
+		wa := channel.Settings.Whatsapp
+		health.Quality = convertEnumValue[WAChannelQuality](wa.ChannelQuality)
+		health.Status = convertEnumValue[WAChannelStatus](wa.ChannelStatus)
+		health.Tier = wa.Tier
+	}
+
+	return health
+}
+
+// set stores the health merge returns for the stored health of the channel, or the zero value if the channel
+// has not been seen, and fires hooks for the transitions. The stored health is read and replaced under the lock,
+// so concurrent updates of the same channel are not lost.
+func (m *ChannelHealthMonitor) set(ctx context.Context, channelID int64, merge func(ChannelHealth) ChannelHealth) {
+	m.mu.Lock()
+	prev, seen := m.channels[channelID]
+	health := merge(prev)
+	now := m.now()
+	health.Healthy = m.healthy(health)
+	health.UpdatedAt = now
+	m.channels[channelID] = health
+	kinds := channelTransitions(prev, health, seen)
+	// The gate is updated under the lock, so that it follows the order in which the health is stored.
+	if m.gate != nil {
+		switch {
+		case !health.Healthy:
+			m.gate.Pause(health.ChannelID, unhealthyReason(health))
+		case slices.Contains(kinds, ChannelHealthRecovered):
+			m.gate.Resume(health.ChannelID)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, kind := range kinds {
+		event := ChannelHealthEvent{Kind: kind, Previous: prev, Current: health, At: now}
+		for _, h := range m.hooks {
+			if len(h.kinds) > 0 && !slices.Contains(h.kinds, kind) {
+				continue
+			}
+
+			if err := h.hook(ctx, event); err != nil {
+				m.onError(ctx, fmt.Errorf("channel health hook for channel %d (%s): %w", health.ChannelID, kind, err))
+			}
+		}
+	}
+}
+
+// Health returns the health of the channel if the monitor has seen it.
+func (m *ChannelHealthMonitor) Health(channelID int64) (ChannelHealth, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health, ok := m.channels[channelID]
+
+	return health, ok
+}
+
+// Channels returns the health of every channel the monitor has seen, ordered by channel ID.
+func (m *ChannelHealthMonitor) Channels() []ChannelHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	channels := make([]ChannelHealth, 0, len(m.channels))
+	for _, health := range m.channels {
+		channels = append(channels, health)
+	}
+	slices.SortFunc(channels, func(a, b ChannelHealth) int { return cmp.Compare(a.ChannelID, b.ChannelID) })
+
+	return channels
+}
+
+// Run refreshes the channels every interval until the context is done.
+// Refresh errors are reported to the error handler.
+func (m *ChannelHealthMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Refresh(ctx); err != nil && ctx.Err() == nil {
+			m.onError(ctx, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Handler returns a WebSocket event handler that updates the monitor from channel_updated events and passes
+// every event to next, which may be nil. Hooks run before next is called.
+func (m *ChannelHealthMonitor) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		if data, ok := msg.Payload.Data.(ws.ChannelUpdatedDataSchema); ok && data.Channel != nil {
+			m.updateFromWS(ctx, *data.Channel)
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// channelTransitions returns the kinds of the transitions from prev to cur. A channel seen for the first time
+// only reports that it is unhealthy.
+func channelTransitions(prev, cur ChannelHealth, seen bool) []ChannelHealthEventKind {
+	if !seen {
+		if !cur.Healthy {
+			return []ChannelHealthEventKind{ChannelHealthUnhealthy}
+		}
+
+		return nil
+	}
+
+	var kinds []ChannelHealthEventKind
+	switch {
+	case prev.Active && !cur.Active:
+		kinds = append(kinds, ChannelHealthDeactivated)
+	case !prev.Active && cur.Active:
+		kinds = append(kinds, ChannelHealthActivated)
+	}
+
+	if from, to := qualityRank(prev.Quality), qualityRank(cur.Quality); from > 0 && to > 0 {
+		switch {
+		case to < from:
+			kinds = append(kinds, ChannelHealthQualityDropped)
+		case to > from:
+			kinds = append(kinds, ChannelHealthQualityRaised)
+		}
+	}
+
+	if cur.Status != "" && cur.Status != prev.Status {
+		switch cur.Status {
+		case WAChannelStatusFlagged:
+			kinds = append(kinds, ChannelHealthFlagged)
+		case WAChannelStatusRestricted:
+			kinds = append(kinds, ChannelHealthRestricted)
+		case WAChannelStatusOffline:
+			kinds = append(kinds, ChannelHealthOffline)
+		case WAChannelStatusConnected:
+			kinds = append(kinds, ChannelHealthConnected)
+		default:
+			kinds = append(kinds, ChannelHealthStatusChanged)
+		}
+	}
+
+	if prev.Tier != nil && cur.Tier != nil && *prev.Tier != *cur.Tier {
+		kinds = append(kinds, ChannelHealthTierChanged)
+	}
+
+	switch {
+	case prev.Healthy && !cur.Healthy:
+		kinds = append(kinds, ChannelHealthUnhealthy)
+	case !prev.Healthy && cur.Healthy:
+		kinds = append(kinds, ChannelHealthRecovered)
+	}
+
+	return kinds
+}
+
+func qualityRank(q WAChannelQuality) int {
+	switch q {
+	case WAChannelQualityLow:
+		return 1
+	case WAChannelQualityMedium:
+		return 2
+	case WAChannelQualityHigh:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func unhealthyReason(h ChannelHealth) string {
+	switch {
+	case !h.Active:
+		return "channel is deactivated"
+	case h.Status != "" && h.Status != WAChannelStatusConnected:
+		return fmt.Sprintf("WhatsApp status is %s", h.Status)
+	case h.Quality == WAChannelQualityLow:
+		return "WhatsApp quality is low"
+	default:
+		return "channel is unhealthy"
+	}
+}
+
+// ChannelPausedError is returned for traffic to a channel paused in a ChannelGate.
+type ChannelPausedError struct {
+	ChannelID int64
+	Reason    string
+}
+
+func (e *ChannelPausedError) Error() string {
+	return fmt.Sprintf("channel %d is paused: %s", e.ChannelID, e.Reason)
+}
+
+// ChannelGate holds back traffic to paused channels. Senders call Wait to block until the channel is
+// resumed, or Allow to fail fast. The zero value is not usable; use NewChannelGate.
+// ChannelGate is safe for concurrent use.
+type ChannelGate struct {
+	mu      sync.Mutex
+	paused  map[int64]string
+	resumed chan struct{}
+}
+
+// NewChannelGate creates a gate with all channels open.
+func NewChannelGate() *ChannelGate {
+	return &ChannelGate{paused: make(map[int64]string), resumed: make(chan struct{})}
+}
+
+// Pause holds back traffic to the channel for the given reason. Pausing a paused channel updates the reason.
+func (g *ChannelGate) Pause(channelID int64, reason string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.paused[channelID] = reason
+}
+
+// Resume lets traffic to the channel through and wakes up the senders waiting for it.
+func (g *ChannelGate) Resume(channelID int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.paused[channelID]; !ok {
+		return
+	}
+	delete(g.paused, channelID)
+	close(g.resumed)
+	g.resumed = make(chan struct{})
+}
+
+// Paused returns the reason the channel is paused for, if it is.
+func (g *ChannelGate) Paused(channelID int64) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reason, ok := g.paused[channelID]
+
+	return reason, ok
+}
+
+// Allow returns a ChannelPausedError if the channel is paused.
+func (g *ChannelGate) Allow(channelID int64) error {
+	if reason, ok := g.Paused(channelID); ok {
+		return &ChannelPausedError{ChannelID: channelID, Reason: reason}
+	}
+
+	return nil
+}
+
+// Wait blocks until the channel is not paused or the context is done.
+func (g *ChannelGate) Wait(ctx context.Context, channelID int64) error {
+	for {
+		g.mu.Lock()
+		_, paused := g.paused[channelID]
+		resumed := g.resumed
+		g.mu.Unlock()
+
+		if !paused {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resumed:
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type channelServer struct {
+	fakeAPI
+	channels []ChannelListResponseItem
+}
+
+func (s *channelServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		if req.URL.Path != "/channels" {
+			return unexpectedRequest(t, req)
+		}
+		assert.Equal(t, "1000", req.URL.Query().Get("limit"))
+
+		return http.StatusOK, s.channels
+	})
+}
+
+func (s *channelServer) set(channels ...ChannelListResponseItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channels = channels
+}
+
+func whatsappChannel(id int64, quality WAChannelQuality, status WAChannelStatus, tier int) ChannelListResponseItem {
+	return ChannelListResponseItem{
+		ID:       id,
+		Type:     ChannelTypeWhatsapp,
+		IsActive: true,
+		Settings: ChannelSettings{Whatsapp: &WAChannelProperties{
+			ChannelQuality: &quality, ChannelStatus: &status, Tier: &tier,
+		}},
+	}
+}
+
+func channelUpdated(channel ws.ChannelSchema) ws.EventMessageFromEventsChannel {
+	return ws.EventMessageFromEventsChannel{Payload: ws.EventSchema{
+		Type: ws.EventTypeChannelUpdated,
+		Data: ws.ChannelUpdatedDataSchema{Channel: &channel},
+	}}
+}
+
+func TestChannelHealthMonitor(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	deactivatedAt := now.Add(-time.Hour)
+	telegram := ChannelListResponseItem{
+		ID: 2, Type: ChannelTypeTelegram, DeactivatedAt: &DateTimeRFC3339Micro{Time: deactivatedAt},
+	}
+
+	t.Run("transitions", func(t *testing.T) {
+		t.Parallel()
+
+		server := channelServer{}
+		server.set(whatsappChannel(1, WAChannelQualityHigh, WAChannelStatusConnected, 2), telegram)
+
+		var events []ChannelHealthEvent
+		gate := NewChannelGate()
+		monitor := NewChannelHealthMonitor(server.client(t),
+			WithChannelHealthGate(gate),
+			WithChannelHealthHook(func(_ context.Context, event ChannelHealthEvent) error {
+				events = append(events, event)
+
+				return nil
+			}))
+		monitor.now = func() time.Time { return now }
+		kinds := func() []ChannelHealthEventKind {
+			var kinds []ChannelHealthEventKind
+			for _, event := range events {
+				kinds = append(kinds, event.Kind)
+			}
+			events = nil
+
+			return kinds
+		}
+
+		require.NoError(t, monitor.Refresh(context.Background()))
+		assert.Equal(t, []ChannelHealthEventKind{ChannelHealthUnhealthy}, kinds())
+		health, ok := monitor.Health(2)
+		require.True(t, ok)
+		assert.Equal(t, deactivatedAt, health.DeactivatedAt)
+		assert.False(t, health.Healthy)
+		require.NoError(t, gate.Allow(1))
+		reason, paused := gate.Paused(2)
+		assert.True(t, paused)
+		assert.Equal(t, "channel is deactivated", reason)
+
+		server.set(whatsappChannel(1, WAChannelQualityLow, WAChannelStatusFlagged, 3), telegram)
+		require.NoError(t, monitor.Refresh(context.Background()))
+		assert.Equal(t, []ChannelHealthEventKind{
+			ChannelHealthQualityDropped, ChannelHealthFlagged, ChannelHealthTierChanged, ChannelHealthUnhealthy,
+		}, kinds())
+		var pausedErr *ChannelPausedError
+		require.True(t, errors.As(gate.Allow(1), &pausedErr))
+		assert.Equal(t, "channel 1 is paused: WhatsApp status is flagged", pausedErr.Error())
+
+		medium, connected := ws.WAChannelQualityMedium, ws.WAChannelStatusConnected
+		handler := monitor.Handler(nil)
+		require.NoError(t, handler(context.Background(), channelUpdated(ws.ChannelSchema{
+			Id: 1, IsActive: true, Type: ws.ChannelTypeSchema(ChannelTypeWhatsapp),
+			Settings: &ws.ChannelSettingsSchema{Whatsapp: &ws.WAChannelPropertiesSchema{
+				ChannelQuality: &medium, ChannelStatus: &connected, Tier: optional(3),
+			}},
+		})))
+		assert.Equal(t, []ChannelHealthEventKind{
+			ChannelHealthQualityRaised, ChannelHealthConnected, ChannelHealthRecovered,
+		}, kinds())
+		require.NoError(t, gate.Allow(1))
+
+		// Events without settings keep the WhatsApp properties.
+		require.NoError(t, handler(context.Background(), channelUpdated(ws.ChannelSchema{
+			Id: 1, Type: ws.ChannelTypeSchema(ChannelTypeWhatsapp),
+		})))
+		require.Len(t, events, 2)
+		assert.Equal(t, ChannelHealthDeactivated, events[0].Kind)
+		assert.Equal(t, ChannelHealthUnhealthy, events[1].Kind)
+		assert.Equal(t, now, events[1].Current.DeactivatedAt)
+		assert.Equal(t, WAChannelQualityMedium, events[1].Current.Quality)
+		assert.True(t, events[1].Previous.Healthy)
+
+		channels := monitor.Channels()
+		require.Len(t, channels, 2)
+		assert.Equal(t, int64(1), channels[0].ChannelID)
+		assert.Equal(t, int64(2), channels[1].ChannelID)
+	})
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		t.Parallel()
+
+		server := channelServer{}
+		gate := NewChannelGate()
+		monitor := NewChannelHealthMonitor(server.client(t), WithChannelHealthGate(gate))
+
+		var wg sync.WaitGroup
+		for i := range 1000 {
+			quality := WAChannelQualityHigh
+			if i%2 == 0 {
+				quality = WAChannelQualityLow
+			}
+			wg.Go(func() {
+				monitor.Update(context.Background(), whatsappChannel(1, quality, WAChannelStatusConnected, 1))
+			})
+		}
+		wg.Wait()
+
+		// The gate ends up in the state of the health stored last.
+		health, ok := monitor.Health(1)
+		require.True(t, ok)
+		_, paused := gate.Paused(1)
+		assert.Equal(t, !health.Healthy, paused)
+	})
+
+	t.Run("hook kinds and errors", func(t *testing.T) {
+		t.Parallel()
+
+		server := channelServer{}
+		server.set(whatsappChannel(1, WAChannelQualityHigh, WAChannelStatusConnected, 1))
+
+		var (
+			flagged []int64
+			errs    []error
+		)
+		monitor := NewChannelHealthMonitor(server.client(t),
+			WithChannelHealthHook(func(_ context.Context, event ChannelHealthEvent) error {
+				flagged = append(flagged, event.Current.ChannelID)
+
+				return nil
+			}, ChannelHealthFlagged),
+			WithChannelHealthHook(func(context.Context, ChannelHealthEvent) error {
+				return errors.New("pager is down")
+			}, ChannelHealthUnhealthy),
+			WithChannelHealthErrorHandler(func(_ context.Context, err error) { errs = append(errs, err) }),
+			WithChannelHealthPolicy(func(h ChannelHealth) bool { return h.Status != WAChannelStatusFlagged }))
+
+		require.NoError(t, monitor.Refresh(context.Background()))
+		server.set(whatsappChannel(1, WAChannelQualityLow, WAChannelStatusConnected, 1))
+		require.NoError(t, monitor.Refresh(context.Background()))
+		assert.Empty(t, errs, "low quality is healthy under the custom policy")
+
+		server.set(whatsappChannel(1, WAChannelQualityLow, WAChannelStatusFlagged, 1))
+		require.NoError(t, monitor.Refresh(context.Background()))
+		assert.Equal(t, []int64{1}, flagged)
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "channel health hook for channel 1 (unhealthy): pager is down")
+	})
+}
+
+func TestChannelGate(t *testing.T) {
+	t.Parallel()
+
+	gate := NewChannelGate()
+	require.NoError(t, gate.Wait(context.Background(), 1))
+
+	gate.Pause(1, "maintenance")
+	gate.Resume(2)
+	require.NoError(t, gate.Allow(2))
+	require.Error(t, gate.Allow(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, gate.Wait(ctx, 1), context.DeadlineExceeded)
+
+	done := make(chan error)
+	go func() { done <- gate.Wait(context.Background(), 1) }()
+	gate.Resume(1)
+	require.NoError(t, <-done)
+	_, paused := gate.Paused(1)
+	assert.False(t, paused)
+}
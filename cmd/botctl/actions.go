@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	bot_api_client "github.com/retailcrm/bot-api-client-go"
+)
+
+// result prints v as JSON, or the summary in table format.
+func (a *app) result(v any, format string, args ...any) error {
+	if a.format == formatJSON {
+		return printJSON(a.stdout, v)
+	}
+
+	_, err := fmt.Fprintf(a.stdout, format+"\n", args...)
+
+	return err
+}
+
+// requireFlag returns a usage error if a required numeric flag is not set.
+func requireFlag(name string, value int64) error {
+	if value == 0 {
+		return usageError{err: fmt.Errorf("-%s is required", name)}
+	}
+
+	return nil
+}
+
+// parseID parses a positional ID argument.
+func parseID(name, s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, usageError{err: fmt.Errorf("invalid %s ID %q", name, s)}
+	}
+
+	return id, nil
+}
+
+func sendText(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("send text", "<text>|-")
+	chatID := fs.Int64("chat", 0, "`ID` of the chat")
+	private := fs.Bool("private", false, "send a private note visible to operators only")
+	quote := fs.Int64("quote", 0, "`ID` of the message to reply to")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if err := requireFlag("chat", *chatID); err != nil {
+		return err
+	}
+
+	text := strings.Join(fs.Args(), " ")
+	if text == "-" {
+		data, err := io.ReadAll(a.stdin)
+		if err != nil {
+			return err
+		}
+		text = strings.TrimSuffix(string(data), "\n")
+	}
+
+	message := bot_api_client.NewText(*chatID, text)
+	if *private {
+		message.Private()
+	}
+	if *quote != 0 {
+		message.Quote(*quote)
+	}
+	body, err := message.Build()
+	if err != nil {
+		return err
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	resp, err := client.SendMessageWithResponse(ctx, body)
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(resp.JSON200, "sent message %d", resp.JSON200.MessageId)
+}
+
+func sendFile(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("send file", "<path>...")
+	chatID := fs.Int64("chat", 0, "`ID` of the chat")
+	note := fs.String("note", "", "`text` of the annotation of the first message")
+	quote := fs.Int64("quote", 0, "`ID` of the message to reply to")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if err := requireFlag("chat", *chatID); err != nil {
+		return err
+	}
+
+	files := make([]bot_api_client.OutgoingFile, fs.NArg())
+	for i, path := range fs.Args() {
+		files[i] = bot_api_client.OutgoingFile{Path: path}
+	}
+	var opts []bot_api_client.SendFilesOption
+	if *note != "" {
+		opts = append(opts, bot_api_client.WithSendFilesNote(*note))
+	}
+	if *quote != 0 {
+		opts = append(opts, bot_api_client.WithSendFilesQuote(*quote))
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	result, err := bot_api_client.SendFiles(ctx, client, *chatID, files, opts...)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, len(result.Sent))
+	for i, sent := range result.Sent {
+		ids[i] = strconv.FormatInt(sent.MessageId, 10)
+	}
+
+	return a.result(result, "uploaded %d files, sent messages %s", len(result.Uploaded), strings.Join(ids, ", "))
+}
+
+var dialogCommands = map[string]commandFunc{
+	"assign":   assignDialog,
+	"unassign": unassignDialog,
+	"close":    closeDialog,
+	"tag":      tagDialog,
+}
+
+func assignDialog(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("dialog assign", "<dialog>")
+	userID := fs.Int64("user", 0, "`ID` of the user to assign")
+	botID := fs.Int64("bot", 0, "`ID` of the bot to assign")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	if (*userID == 0) == (*botID == 0) {
+		return usageError{err: errors.New("exactly one of -user and -bot is required")}
+	}
+	dialogID, err := parseID("dialog", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	resp, err := client.AssignDialogResponsibleWithResponse(ctx, dialogID,
+		bot_api_client.AssignDialogResponsibleJSONRequestBody{UserID: *userID, BotID: *botID})
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	responsible := resp.JSON200.Responsible
+
+	return a.result(resp.JSON200, "assigned dialog %d to %s %d", dialogID, responsible.Type, responsible.ID)
+}
+
+func unassignDialog(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("dialog unassign", "<dialog>")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	dialogID, err := parseID("dialog", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	resp, err := client.UnassignDialogResponsibleWithResponse(ctx, dialogID)
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(resp.JSON200, "unassigned dialog %d", dialogID)
+}
+
+func closeDialog(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("dialog close", "<dialog>")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	dialogID, err := parseID("dialog", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	resp, err := client.CloseDialogWithResponse(ctx, dialogID)
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(resp.JSON200, "closed dialog %d", dialogID)
+}
+
+func tagDialog(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("dialog tag", "<dialog> <tag>...")
+	var color bot_api_client.ColorCode
+	colors := bot_api_client.ColorCode("").EnumValues()
+	fs.Func("color", "`color` of the added tags: "+strings.Join(colors, ", "), func(s string) error {
+		return parseValue(reflect.ValueOf(&color).Elem(), s)
+	})
+	remove := fs.Bool("remove", false, "remove the tags instead of adding them")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return usageError{err: errors.New("missing tags")}
+	}
+	dialogID, err := parseID("dialog", fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	names := fs.Args()[1:]
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+
+	if *remove {
+		var body bot_api_client.DialogDeleteTagsJSONRequestBody
+		for _, name := range names {
+			body.Tags = append(body.Tags, struct {
+				Name string `binding:"required,min=1,max=255" json:"name" mod:"trim,escape"`
+			}{Name: name})
+		}
+
+		resp, err := client.DialogDeleteTagsWithResponse(ctx, dialogID, body)
+		if err = bot_api_client.ExtractError(resp, err); err != nil {
+			return err
+		}
+
+		return a.result(resp.JSON200, "removed tags %s from dialog %d", strings.Join(names, ", "), dialogID)
+	}
+
+	var body bot_api_client.DialogAddTagsJSONRequestBody
+	for _, name := range names {
+		tag := struct {
+			ColorCode *bot_api_client.ColorCode `binding:"omitempty,enum-valid" json:"color_code,omitempty"`
+			Name      string                    `binding:"required,min=1,max=255" json:"name" mod:"trim,escape"`
+		}{Name: name}
+		if color != "" {
+			tag.ColorCode = &color
+		}
+		body.Tags = append(body.Tags, tag)
+	}
+
+	resp, err := client.DialogAddTagsWithResponse(ctx, dialogID, body)
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(resp.JSON200, "added tags %s to dialog %d", strings.Join(names, ", "), dialogID)
+}
+
+func setCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("command set", "<name> <description>")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return err
+	}
+
+	var body bot_api_client.CreateOrUpdateCommandJSONRequestBody
+	err := body.FromCreateCommandRequestBody(bot_api_client.CreateCommandRequestBody{
+		Name:        fs.Arg(0),
+		Description: fs.Arg(1),
+	})
+	if err != nil {
+		return err
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	resp, err := client.CreateOrUpdateCommandWithResponse(ctx, fs.Arg(0), body)
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(resp.JSON200, "saved command %s", fs.Arg(0))
+}
+
+func deleteCommand(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("command delete", "<name>")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	resp, err := client.DeleteCommandWithResponse(ctx, fs.Arg(0))
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(resp.JSON200, "deleted command %s", fs.Arg(0))
+}
+
+// updateBot changes the profile of the bot. Fields without a flag keep their current values.
+func updateBot(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("bot update", "")
+	name := fs.String("name", "", "new `name` of the bot")
+	avatarURL := fs.String("avatar-url", "", "`URL` of the new avatar")
+	var roles []bot_api_client.Role
+	roleValues := bot_api_client.Role("").EnumValues()
+	fs.Func("roles", "comma-separated `roles`: "+strings.Join(roleValues, ", "), func(s string) error {
+		return parseValue(reflect.ValueOf(&roles).Elem(), s)
+	})
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" && *avatarURL == "" && roles == nil {
+		return usageError{err: errors.New("nothing to update: set -name, -avatar-url or -roles")}
+	}
+
+	client, err := a.api()
+	if err != nil {
+		return err
+	}
+	self := bot_api_client.BooleanTrue
+	bots, err := client.ListBotsWithResponse(ctx, &bot_api_client.ListBotsParams{Self: &self})
+	if err = bot_api_client.ExtractError(bots, err); err != nil {
+		return fmt.Errorf("get bot: %w", err)
+	}
+	if len(deref(bots.JSON200)) == 0 {
+		return errors.New("get bot: not found")
+	}
+	current := (*bots.JSON200)[0]
+
+	body := bot_api_client.UpdateBotJSONRequestBody{
+		Name: current.Name, AvatarUrl: current.AvatarUrl, Roles: current.Roles,
+	}
+	if *name != "" {
+		body.Name = *name
+	}
+	if *avatarURL != "" {
+		body.AvatarUrl = avatarURL
+	}
+	if roles != nil {
+		body.Roles = roles
+	}
+
+	resp, err := client.UpdateBotWithResponse(ctx, body)
+	if err = bot_api_client.ExtractError(resp, err); err != nil {
+		return err
+	}
+
+	return a.result(body, "updated bot %d", current.ID)
+}
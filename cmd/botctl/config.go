@@ -0,0 +1,69 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	envConfig   = "BOTCTL_CONFIG"
+	envEndpoint = "BOTCTL_ENDPOINT"
+	envToken    = "BOTCTL_TOKEN"
+)
+
+// config is the connection settings of botctl, read from a JSON file:
+//
+//	{"endpoint": "https://mg-s1.retailcrm.pro/api/bot/v1/", "token": "BOT_TOKEN"}
+type config struct {
+	Endpoint string `json:"endpoint"`
+	Token    string `json:"token"`
+}
+
+// defaultConfigPath returns botctl/config.json in the user configuration directory.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "botctl", "config.json")
+}
+
+// loadConfig reads the config file and overrides it with the environment and then with the flags.
+// The file is path, or $BOTCTL_CONFIG, or the default path; only a missing default file is not an error.
+func loadConfig(path string, getenv func(string) string, flags config) (config, error) {
+	path = cmp.Or(path, getenv(envConfig))
+	explicit := path != ""
+	path = cmp.Or(path, defaultConfigPath())
+
+	var cfg config
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && !explicit:
+		case err != nil:
+			return config{}, err
+		default:
+			if err = json.Unmarshal(data, &cfg); err != nil {
+				return config{}, fmt.Errorf("config %s: %w", path, err)
+			}
+		}
+	}
+
+	cfg.Endpoint = cmp.Or(flags.Endpoint, getenv(envEndpoint), cfg.Endpoint)
+	cfg.Token = cmp.Or(flags.Token, getenv(envToken), cfg.Token)
+
+	switch {
+	case cfg.Endpoint == "":
+		return config{}, fmt.Errorf("no endpoint: set -endpoint, $%s or the config file", envEndpoint)
+	case cfg.Token == "":
+		return config{}, fmt.Errorf("no token: set -token, $%s or the config file", envToken)
+	}
+
+	return cfg, nil
+}
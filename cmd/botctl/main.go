@@ -0,0 +1,244 @@
+// Command botctl is a command-line tool for the Bot API: it lists and inspects bots, channels, chats, dialogs,
+// customers, users, members, messages and commands, sends messages, manages dialogs, commands and the bot profile,
+// and prints, records and replays WebSocket events.
+//
+// The endpoint and token are read from the -endpoint and -token flags, the BOTCTL_ENDPOINT and BOTCTL_TOKEN
+// environment variables, or a JSON config file, in that order of precedence. Run botctl -h for usage.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	bot_api_client "github.com/retailcrm/bot-api-client-go"
+)
+
+const usage = `Usage: botctl [flags] <command> [command flags] [arguments]
+
+Commands:
+  list <resource> [filters]             list bots, channels, chats, dialogs, customers, users, members,
+                                        messages or commands; botctl list <resource> -h shows the filters
+  get <resource> <id>                   show a single item
+  send text -chat ID [flags] <text>     send a text message
+  send file -chat ID [flags] <path>...  upload and send files
+  dialog assign (-user ID | -bot ID) <dialog>
+  dialog unassign <dialog>
+  dialog close <dialog>
+  dialog tag [-color CODE] [-remove] <dialog> <tag>...
+  command set <name> <description>      create or update a bot command
+  command delete <name>
+  bot update [-name NAME] [-avatar-url URL] [-roles ROLES]
+  tail [flags]                          print WebSocket events until interrupted, record or replay them
+
+Flags:
+`
+
+// usageError is an error in the command line. It is reported with the usage of the command.
+type usageError struct {
+	err error
+	// reported is set if the flag package has already printed the error.
+	reported bool
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+// app runs a botctl command line.
+type app struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+	// doer replaces the HTTP client in tests.
+	doer bot_api_client.HttpRequestDoer
+
+	configPath string
+	flags      config
+	format     string
+	client     *bot_api_client.ClientWithResponses
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := (&app{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}).run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// run runs the command line and returns the exit code: 0 on success, 1 on errors and 2 on usage errors.
+func (a *app) run(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("botctl", flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprint(a.stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&a.configPath, "config", "", "config `file` (default $"+envConfig+" or "+defaultConfigPath()+")")
+	fs.StringVar(&a.flags.Endpoint, "endpoint", "", "Bot API `URL` (default $"+envEndpoint+")")
+	fs.StringVar(&a.flags.Token, "token", "", "bot `token` (default $"+envToken+")")
+	fs.StringVar(&a.format, "o", formatTable, "output `format`: table or json")
+	timeout := fs.Duration("timeout", time.Minute, "request `timeout`, not applied to tail and send file")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+
+		return 2
+	}
+	if a.format != formatTable && a.format != formatJSON {
+		fmt.Fprintf(a.stderr, "botctl: invalid output format %q\n", a.format)
+
+		return 2
+	}
+
+	command, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(a.stderr, "botctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+
+		return 2
+	}
+
+	// Tail runs until interrupted and uploads take as long as the files need.
+	if fs.Arg(0) != "tail" && (fs.Arg(0) != "send" || fs.Arg(1) != "file") {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	err := command(ctx, a, fs.Args()[1:])
+	var uerr usageError
+	switch {
+	case err == nil || errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &uerr):
+		if !uerr.reported {
+			fmt.Fprintf(a.stderr, "botctl %s: %v\n", fs.Arg(0), err)
+		}
+
+		return 2
+	default:
+		fmt.Fprintf(a.stderr, "botctl %s: %v\n", fs.Arg(0), err)
+
+		return 1
+	}
+}
+
+// api returns the client, creating it from the configuration on first use.
+func (a *app) api() (*bot_api_client.ClientWithResponses, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+
+	cfg, err := loadConfig(a.configPath, a.getenv, a.flags)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []bot_api_client.ClientOption{bot_api_client.WithBotToken(cfg.Token)}
+	if a.doer != nil {
+		opts = append(opts, bot_api_client.WithHTTPClient(a.doer))
+	}
+	a.client, err = bot_api_client.NewClientWithResponses(cfg.Endpoint, opts...)
+
+	return a.client, err
+}
+
+// commands maps the first argument to the command run with the remaining arguments.
+var commands = map[string]commandFunc{
+	"list":    resourceCommand(func(r resource) commandFunc { return r.list }),
+	"get":     resourceCommand(func(r resource) commandFunc { return r.get }),
+	"send":    subcommand("send", map[string]commandFunc{"text": sendText, "file": sendFile}),
+	"dialog":  subcommand("dialog", dialogCommands),
+	"command": subcommand("command", map[string]commandFunc{"set": setCommand, "delete": deleteCommand}),
+	"bot":     subcommand("bot", map[string]commandFunc{"update": updateBot}),
+	"tail":    tail,
+}
+
+type commandFunc = func(ctx context.Context, a *app, args []string) error
+
+// subcommand dispatches on the first argument.
+func subcommand(name string, subcommands map[string]commandFunc) commandFunc {
+	return func(ctx context.Context, a *app, args []string) error {
+		if len(args) == 0 {
+			names := slices.Sorted(maps.Keys(subcommands))
+
+			return usageError{err: fmt.Errorf("missing subcommand: %s", strings.Join(names, ", "))}
+		}
+		run, ok := subcommands[args[0]]
+		if !ok {
+			return usageError{err: fmt.Errorf("unknown %s subcommand %q", name, args[0])}
+		}
+
+		return run(ctx, a, args[1:])
+	}
+}
+
+// resourceCommand dispatches to the command of the resource named by the first argument.
+func resourceCommand(command func(resource) commandFunc) commandFunc {
+	return func(ctx context.Context, a *app, args []string) error {
+		r, err := findResource(args)
+		if err != nil {
+			return err
+		}
+
+		return command(r)(ctx, a, args[1:])
+	}
+}
+
+// flagSet creates the flag set of a command taking the given positional arguments.
+func (a *app) flagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet("botctl "+name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		var flags int
+		fs.VisitAll(func(*flag.Flag) { flags++ })
+		if flags == 0 {
+			fmt.Fprintf(a.stderr, "Usage: botctl %s %s\n", name, arguments)
+
+			return
+		}
+
+		fmt.Fprintln(a.stderr, strings.TrimSpace("Usage: botctl "+name+" [flags] "+arguments))
+		fmt.Fprintln(a.stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses args and checks that exactly n positional arguments remain, or at least one if n is negative.
+func parseFlags(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+
+		return usageError{err: err, reported: true}
+	}
+
+	if (n < 0 && fs.NArg() == 0) || (n >= 0 && fs.NArg() != n) {
+		fs.Usage()
+
+		return usageError{err: errors.New("wrong number of arguments")}
+	}
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	bot_api_client "github.com/retailcrm/bot-api-client-go"
+)
+
+// request is a request received by the mock API.
+type request struct {
+	method string
+	url    string
+	token  string
+	body   string
+}
+
+// botctl runs the command line against a mock API that answers every request with respond.
+func botctl(
+	t *testing.T, respond func(req request) (int, any), args ...string,
+) (code int, stdout, stderr string, requests []request) {
+	t.Helper()
+
+	mockDoer := bot_api_client.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		r := request{method: req.Method, url: req.URL.RequestURI(), token: req.Header.Get("X-Bot-Token")}
+		if req.Body != nil {
+			data, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			r.body = string(data)
+		}
+		requests = append(requests, r)
+
+		status, body := respond(r)
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(data)),
+		}, nil
+	})
+
+	env := map[string]string{envEndpoint: "https://example.com/api/bot/v1", envToken: "secret"}
+	var out, errOut bytes.Buffer
+	a := &app{
+		stdin:  strings.NewReader("from stdin\n"),
+		stdout: &out,
+		stderr: &errOut,
+		getenv: func(key string) string { return env[key] },
+		doer:   mockDoer,
+	}
+	code = a.run(context.Background(), args)
+
+	return code, out.String(), errOut.String(), requests
+}
+
+func ok(body any) func(request) (int, any) {
+	return func(request) (int, any) { return http.StatusOK, body }
+}
+
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	chats := []bot_api_client.ChatsListResponseItem{
+		{
+			ID:       11,
+			Channel:  &bot_api_client.Channel{ID: 3, Type: bot_api_client.ChannelTypeTelegram},
+			Customer: &bot_api_client.Actor{ID: 7, Name: "Ann Lee"},
+		},
+		{ID: 12},
+	}
+
+	t.Run("table with filters", func(t *testing.T) {
+		t.Parallel()
+
+		code, stdout, stderr, requests := botctl(t, ok(chats),
+			"list", "chats", "-channel-id", "3", "-channel-type", "telegram", "-since", "2024-05-01T00:00:00Z")
+		require.Equal(t, 0, code, stderr)
+		require.Len(t, requests, 1)
+		assert.Equal(t,
+			"/api/bot/v1/chats?channel_id=3&channel_type=telegram&since=2024-05-01T00%3A00%3A00Z", requests[0].url)
+		assert.Equal(t, "secret", requests[0].token)
+
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		require.Len(t, lines, 3)
+		assert.Equal(t, []string{"ID", "CHANNEL", "CUSTOMER", "WAITING", "LAST", "ACTIVITY"}, strings.Fields(lines[0]))
+		assert.Equal(t, []string{"11", "telegram", "3", "Ann", "Lee", "(7)", "-", "-"}, strings.Fields(lines[1]))
+		assert.Equal(t, []string{"12", "-", "-", "-", "-"}, strings.Fields(lines[2]))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		code, stdout, _, _ := botctl(t, ok(chats), "-o", "json", "list", "chats")
+		require.Equal(t, 0, code)
+		var got []bot_api_client.ChatsListResponseItem
+		require.NoError(t, json.Unmarshal([]byte(stdout), &got))
+		assert.Equal(t, chats, got)
+
+		code, stdout, _, _ = botctl(t, ok([]any{}), "-o", "json", "list", "bots")
+		require.Equal(t, 0, code)
+		assert.Equal(t, "[]\n", stdout)
+	})
+
+	t.Run("all pages", func(t *testing.T) {
+		t.Parallel()
+
+		respond := func(req request) (int, any) {
+			if strings.Contains(req.url, "since_id=2") {
+				return http.StatusOK, []bot_api_client.Command{{ID: 3, Name: "c"}}
+			}
+
+			return http.StatusOK, []bot_api_client.Command{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+		}
+		code, stdout, _, requests := botctl(t, respond, "list", "commands", "-all", "-limit", "2")
+		require.Equal(t, 0, code)
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/api/bot/v1/my/commands?limit=2", requests[0].url)
+		assert.Equal(t, "/api/bot/v1/my/commands?limit=2&since_id=2", requests[1].url)
+		assert.Len(t, strings.Split(strings.TrimSpace(stdout), "\n"), 4)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		t.Parallel()
+
+		code, _, stderr, requests := botctl(t, ok(chats), "list", "dialogs", "-active", "maybe")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, `invalid value "maybe": must be one of`)
+		assert.Empty(t, requests)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		t.Parallel()
+
+		respond := func(request) (int, any) {
+			return http.StatusBadRequest, bot_api_client.ErrorResponse{Errors: []string{"invalid limit"}}
+		}
+		code, _, stderr, _ := botctl(t, respond, "list", "users")
+		assert.Equal(t, 1, code)
+		assert.Equal(t, "botctl list: list users: invalid limit\n", stderr)
+	})
+}
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	messages := []bot_api_client.MessageListResponseItem{{ID: 5, ChatID: 11, Type: bot_api_client.MessageTypeText}}
+
+	code, stdout, stderr, requests := botctl(t, ok(messages), "get", "message", "5")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "/api/bot/v1/messages?id=5", requests[0].url)
+	assert.Contains(t, stdout, "CHAT     11\n")
+	assert.Contains(t, stdout, "TYPE     text\n")
+
+	code, _, stderr, _ = botctl(t, ok(messages), "get", "message", "6")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "botctl get: message 6 not found\n", stderr)
+
+	code, _, stderr, _ = botctl(t, ok(messages), "get", "orders", "6")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown resource "orders"`)
+}
+
+func TestActions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		args     []string
+		response any
+		method   string
+		url      string
+		body     string
+		stdout   string
+	}{
+		{
+			name:     "send text",
+			args:     []string{"send", "text", "-chat", "11", "-quote", "4", "Hello", "there"},
+			response: bot_api_client.SendMessageResponse{MessageId: 42},
+			method:   http.MethodPost,
+			url:      "/api/bot/v1/messages",
+			body: `{"chat_id":11,"content":"Hello there","quote_message_id":4,"scope":"public",
+				"transport_attachments":null,"type":"text"}`,
+			stdout: "sent message 42\n",
+		},
+		{
+			name:     "send text from stdin",
+			args:     []string{"send", "text", "-chat", "11", "-private", "-"},
+			response: bot_api_client.SendMessageResponse{MessageId: 43},
+			method:   http.MethodPost,
+			url:      "/api/bot/v1/messages",
+			body:     `{"chat_id":11,"content":"from stdin","scope":"private","transport_attachments":null,"type":"text"}`,
+			stdout:   "sent message 43\n",
+		},
+		{
+			name: "assign",
+			args: []string{"dialog", "assign", "-user", "9", "21"},
+			response: bot_api_client.DialogAssignResponse{
+				Responsible: bot_api_client.Responsible{ID: 9, Type: bot_api_client.ResponsibleTypeUser},
+			},
+			method: http.MethodPatch,
+			url:    "/api/bot/v1/dialogs/21/assign",
+			body:   `{"bot_id":0,"user_id":9}`,
+			stdout: "assigned dialog 21 to user 9\n",
+		},
+		{
+			name:     "unassign",
+			args:     []string{"dialog", "unassign", "21"},
+			response: bot_api_client.DialogUnassignResponse{},
+			method:   http.MethodPatch,
+			url:      "/api/bot/v1/dialogs/21/unassign",
+			stdout:   "unassigned dialog 21\n",
+		},
+		{
+			name:     "close",
+			args:     []string{"dialog", "close", "21"},
+			response: map[string]any{},
+			method:   http.MethodDelete,
+			url:      "/api/bot/v1/dialogs/21/close",
+			stdout:   "closed dialog 21\n",
+		},
+		{
+			name:     "add tags",
+			args:     []string{"dialog", "tag", "-color", "red", "21", "vip", "urgent"},
+			response: map[string]any{},
+			method:   http.MethodPatch,
+			url:      "/api/bot/v1/dialogs/21/tags/add",
+			body:     `{"tags":[{"color_code":"red","name":"vip"},{"color_code":"red","name":"urgent"}]}`,
+			stdout:   "added tags vip, urgent to dialog 21\n",
+		},
+		{
+			name:     "remove tags",
+			args:     []string{"dialog", "tag", "-remove", "21", "vip"},
+			response: map[string]any{},
+			method:   http.MethodPatch,
+			url:      "/api/bot/v1/dialogs/21/tags/delete",
+			body:     `{"tags":[{"name":"vip"}]}`,
+			stdout:   "removed tags vip from dialog 21\n",
+		},
+		{
+			name:     "set command",
+			args:     []string{"command", "set", "help", "Show help"},
+			response: bot_api_client.CommandCreate{ID: 1, Name: "help"},
+			method:   http.MethodPut,
+			url:      "/api/bot/v1/my/commands/help",
+			body:     `{"description":"Show help","name":"help"}`,
+			stdout:   "saved command help\n",
+		},
+		{
+			name:     "delete command",
+			args:     []string{"command", "delete", "help"},
+			response: map[string]any{},
+			method:   http.MethodDelete,
+			url:      "/api/bot/v1/my/commands/help",
+			stdout:   "deleted command help\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			code, stdout, stderr, requests := botctl(t, ok(tt.response), tt.args...)
+			require.Equal(t, 0, code, stderr)
+			require.Len(t, requests, 1)
+			assert.Equal(t, tt.method, requests[0].method)
+			assert.Equal(t, tt.url, requests[0].url)
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, requests[0].body)
+			}
+			assert.Equal(t, tt.stdout, stdout)
+		})
+	}
+
+	t.Run("update bot", func(t *testing.T) {
+		t.Parallel()
+
+		avatarURL := "https://example.com/a.png"
+		respond := func(req request) (int, any) {
+			if req.method == http.MethodGet {
+				return http.StatusOK, []bot_api_client.Bot{{
+					ID: 2, Name: "Helper", AvatarUrl: &avatarURL,
+					Roles: []bot_api_client.Role{bot_api_client.RoleBotRoleResponsible},
+				}}
+			}
+
+			return http.StatusOK, map[string]any{}
+		}
+		code, stdout, stderr, requests := botctl(t, respond, "bot", "update", "-roles", "responsible,hidden")
+		require.Equal(t, 0, code, stderr)
+		require.Len(t, requests, 2)
+		assert.Equal(t, "/api/bot/v1/bots?self=true", requests[0].url)
+		assert.Equal(t, http.MethodPatch, requests[1].method)
+		assert.JSONEq(t,
+			`{"name":"Helper","avatar_url":"https://example.com/a.png","roles":["responsible","hidden"]}`,
+			requests[1].body)
+		assert.Equal(t, "updated bot 2\n", stdout)
+	})
+
+	t.Run("usage errors", func(t *testing.T) {
+		t.Parallel()
+
+		for _, args := range [][]string{
+			{"send", "text", "Hello"},
+			{"dialog", "assign", "-user", "1", "-bot", "2", "21"},
+			{"dialog", "close", "abc"},
+			{"dialog", "tag", "21"},
+			{"dialog", "reopen", "21"},
+			{"bot", "update"},
+			{"frobnicate"},
+		} {
+			code, _, _, requests := botctl(t, ok(nil), args...)
+			assert.Equal(t, 2, code, args)
+			assert.Empty(t, requests, args)
+		}
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"endpoint": "https://file", "token": "file"}`), 0o600))
+	env := func(values map[string]string) func(string) string {
+		return func(key string) string { return values[key] }
+	}
+
+	cfg, err := loadConfig(path, env(nil), config{})
+	require.NoError(t, err)
+	assert.Equal(t, config{Endpoint: "https://file", Token: "file"}, cfg)
+
+	cfg, err = loadConfig("", env(map[string]string{envConfig: path, envToken: "env"}), config{})
+	require.NoError(t, err)
+	assert.Equal(t, config{Endpoint: "https://file", Token: "env"}, cfg)
+
+	cfg, err = loadConfig(path, env(map[string]string{envToken: "env"}), config{Token: "flag"})
+	require.NoError(t, err)
+	assert.Equal(t, "flag", cfg.Token)
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.json"), env(nil), config{})
+	require.Error(t, err)
+
+	_, err = loadConfig("", env(map[string]string{envConfig: path}), config{Endpoint: "https://flag", Token: ""})
+	require.NoError(t, err)
+
+	_, err = loadConfig("", env(map[string]string{envConfig: os.DevNull}), config{Token: "flag"})
+	require.ErrorContains(t, err, "config")
+}
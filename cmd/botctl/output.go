@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// column is a column of the table output of items of type T.
+type column[T any] struct {
+	header string
+	value  func(T) string
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// printTable prints one row per item.
+func printTable[T any](w io.Writer, items []T, columns []column[T]) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = c.header
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for _, item := range items {
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			values[i] = cell(c.value(item))
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// printRecord prints one line per column of a single item.
+func printRecord[T any](w io.Writer, item T, columns []column[T]) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range columns {
+		fmt.Fprintf(tw, "%s\t%s\n", c.header, cell(c.value(item)))
+	}
+
+	return tw.Flush()
+}
+
+// cell keeps a value on one line of the table and marks empty values.
+func cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return "-"
+	}
+	if len([]rune(s)) > 60 {
+		return string([]rune(s)[:59]) + "…"
+	}
+
+	return s
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Local().Format(time.DateTime)
+}
+
+func formatID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+
+	return strconv.FormatInt(*id, 10)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+
+	return v
+}
+
+func join[T ~string](values []T) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = string(v)
+	}
+
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// bindParams registers a flag for every query parameter of params, a pointer to one of the List*Params structs.
+// Flags are named after the form tags with dashes instead of underscores, e.g. -channel-id for channel_id.
+func bindParams(fs *flag.FlagSet, params any, skip ...string) {
+	v := reflect.ValueOf(params).Elem()
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if name == "" || slices.Contains(skip, name) {
+			continue
+		}
+
+		description := cmp.Or(paramDescriptions[name], "filter by "+strings.ReplaceAll(name, "_", " "))
+		fs.Var(paramValue{v.Field(i)}, strings.ReplaceAll(name, "_", "-"),
+			description+" ("+paramUsage(field.Type.Elem())+")")
+	}
+}
+
+// paramDescriptions describe the paging parameters shared by the List*Params.
+var paramDescriptions = map[string]string{
+	"since":    "only items created at or after the time",
+	"until":    "only items created before the time",
+	"since_id": "only items with a greater ID",
+	"until_id": "only items with a smaller ID",
+	"limit":    "maximum number of items, up to 1000",
+}
+
+// setParam sets the query parameter with the given form tag name to s, parsed as for the flag.
+func setParam(params any, name, s string) error {
+	field, ok := paramField(params, name)
+	if !ok {
+		return fmt.Errorf("no %s parameter", name)
+	}
+
+	return paramValue{field}.Set(s)
+}
+
+// paramField returns the pointer field of the query parameter with the given form tag name.
+func paramField(params any, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(params).Elem()
+	for i := range v.NumField() {
+		if tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("form"), ","); tag == name {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// paramValue is a flag.Value setting a pointer field of a params struct.
+type paramValue struct {
+	field reflect.Value
+}
+
+func (p paramValue) String() string {
+	if !p.field.IsValid() || p.field.IsNil() {
+		return ""
+	}
+
+	return fmt.Sprint(p.field.Elem().Interface())
+}
+
+func (p paramValue) Set(s string) error {
+	value := reflect.New(p.field.Type().Elem())
+	if err := parseValue(value.Elem(), s); err != nil {
+		return err
+	}
+	p.field.Set(value)
+
+	return nil
+}
+
+type enum interface {
+	EnumValues() []string
+	ValidateEnum() error
+}
+
+// parseValue parses s into v. Slices are comma-separated, times are in RFC 3339 format or dates,
+// enum values are validated.
+func parseValue(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == timeType:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			if t, err = time.ParseInLocation(time.DateOnly, s, time.Local); err != nil {
+				return fmt.Errorf("invalid time %q: use RFC 3339 format or YYYY-MM-DD", s)
+			}
+		}
+		v.Set(reflect.ValueOf(t))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+		if e, ok := v.Interface().(enum); ok && e.ValidateEnum() != nil {
+			return fmt.Errorf("invalid value %q: must be one of %s", s, strings.Join(e.EnumValues(), ", "))
+		}
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Slice:
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		for item := range strings.SplitSeq(s, ",") {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := parseValue(elem, strings.TrimSpace(item)); err != nil {
+				return err
+			}
+			items = reflect.Append(items, elem)
+		}
+		v.Set(items)
+	default:
+		return fmt.Errorf("unsupported parameter type %s", v.Type())
+	}
+
+	return nil
+}
+
+// paramUsage describes the values a flag of type t accepts. The back-quoted word is shown as the argument
+// name in the flag defaults.
+func paramUsage(t reflect.Type) string {
+	switch {
+	case t == timeType:
+		return "`time` in RFC 3339 format or YYYY-MM-DD"
+	case t.Kind() == reflect.Slice:
+		if e, ok := reflect.Zero(t.Elem()).Interface().(enum); ok {
+			return "comma-separated `list` of " + strings.Join(e.EnumValues(), ", ")
+		}
+
+		return "comma-separated `list`"
+	case t.Kind() == reflect.String:
+		if e, ok := reflect.Zero(t).Interface().(enum); ok {
+			return "`value`: " + strings.Join(e.EnumValues(), ", ")
+		}
+
+		return "`string`"
+	default:
+		return "`number`"
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	bot_api_client "github.com/retailcrm/bot-api-client-go"
+)
+
+// maxListLimit is the largest page the API returns.
+const maxListLimit = 1000
+
+// resource lists and inspects the items of a List* endpoint.
+type resource struct {
+	name string
+	list func(ctx context.Context, a *app, args []string) error
+	get  func(ctx context.Context, a *app, args []string) error
+}
+
+// newResource creates a resource listing items of type T with parameters of type P. The list command has a flag
+// per query parameter and -all to fetch every page; the get command finds the item by the idParam parameter.
+func newResource[P, T any](
+	name, idParam string,
+	list func(ctx context.Context, client bot_api_client.ClientWithResponsesInterface, params *P) ([]T, error),
+	id func(T) int64,
+	columns []column[T],
+) resource {
+	return resource{
+		name: name,
+		list: func(ctx context.Context, a *app, args []string) error {
+			fs := a.flagSet("list "+name, "")
+			params := new(P)
+			bindParams(fs, params)
+			all := fs.Bool("all", false, "fetch all pages")
+			if err := parseFlags(fs, args, 0); err != nil {
+				return err
+			}
+
+			var pageSize int64
+			if *all {
+				limit, _ := paramField(params, "limit")
+				if limit.IsNil() {
+					_ = setParam(params, "limit", strconv.Itoa(maxListLimit))
+				}
+				pageSize = limit.Elem().Int()
+			}
+
+			client, err := a.api()
+			if err != nil {
+				return err
+			}
+
+			var items []T
+			for {
+				page, err := list(ctx, client, params)
+				if err != nil {
+					return fmt.Errorf("list %s: %w", name, err)
+				}
+				items = append(items, page...)
+				if !*all || int64(len(page)) < pageSize {
+					break
+				}
+				if err = setParam(params, "since_id", strconv.FormatInt(id(page[len(page)-1]), 10)); err != nil {
+					return err
+				}
+			}
+
+			if a.format == formatJSON {
+				if items == nil {
+					items = []T{}
+				}
+
+				return printJSON(a.stdout, items)
+			}
+
+			return printTable(a.stdout, items, columns)
+		},
+		get: func(ctx context.Context, a *app, args []string) error {
+			fs := a.flagSet("get "+name, "<id>")
+			if err := parseFlags(fs, args, 1); err != nil {
+				return err
+			}
+
+			itemID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+			if err != nil {
+				return usageError{err: fmt.Errorf("invalid ID %q", fs.Arg(0))}
+			}
+			params := new(P)
+			if err = setParam(params, idParam, fs.Arg(0)); err != nil {
+				return err
+			}
+
+			client, err := a.api()
+			if err != nil {
+				return err
+			}
+			items, err := list(ctx, client, params)
+			if err != nil {
+				return fmt.Errorf("get %s: %w", name, err)
+			}
+			for _, item := range items {
+				if id(item) != itemID {
+					continue
+				}
+				if a.format == formatJSON {
+					return printJSON(a.stdout, item)
+				}
+
+				return printRecord(a.stdout, item, columns)
+			}
+
+			return fmt.Errorf("%s %d not found", strings.TrimSuffix(name, "s"), itemID)
+		},
+	}
+}
+
+var resources = []resource{
+	newResource("bots", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListBotsParams) (
+			[]bot_api_client.Bot, error,
+		) {
+			resp, err := c.ListBotsWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(b bot_api_client.Bot) int64 { return b.ID },
+		[]column[bot_api_client.Bot]{
+			{"ID", func(b bot_api_client.Bot) string { return strconv.FormatInt(b.ID, 10) }},
+			{"NAME", func(b bot_api_client.Bot) string { return b.Name }},
+			{"ROLES", func(b bot_api_client.Bot) string { return join(b.Roles) }},
+			{"ACTIVE", func(b bot_api_client.Bot) string { return formatBool(b.IsActive) }},
+			{"SELF", func(b bot_api_client.Bot) string { return formatBool(b.IsSelf) }},
+			{"CREATED", func(b bot_api_client.Bot) string { return formatTime(b.CreatedAt.Time) }},
+		},
+	),
+	newResource("channels", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListChannelsParams) (
+			[]bot_api_client.ChannelListResponseItem, error,
+		) {
+			resp, err := c.ListChannelsWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(ch bot_api_client.ChannelListResponseItem) int64 { return ch.ID },
+		[]column[bot_api_client.ChannelListResponseItem]{
+			{"ID", func(ch bot_api_client.ChannelListResponseItem) string { return strconv.FormatInt(ch.ID, 10) }},
+			{"TYPE", func(ch bot_api_client.ChannelListResponseItem) string { return string(ch.Type) }},
+			{"NAME", func(ch bot_api_client.ChannelListResponseItem) string { return deref(ch.Name) }},
+			{"ACTIVE", func(ch bot_api_client.ChannelListResponseItem) string { return formatBool(ch.IsActive) }},
+			{"WHATSAPP", func(ch bot_api_client.ChannelListResponseItem) string {
+				wa := ch.Settings.Whatsapp
+				if wa == nil {
+					return ""
+				}
+
+				return fmt.Sprintf("%s/%s", deref(wa.ChannelStatus), deref(wa.ChannelQuality))
+			}},
+			{"CREATED", func(ch bot_api_client.ChannelListResponseItem) string { return formatTime(ch.CreatedAt.Time) }},
+		},
+	),
+	newResource("chats", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListChatsParams) (
+			[]bot_api_client.ChatsListResponseItem, error,
+		) {
+			resp, err := c.ListChatsWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(ch bot_api_client.ChatsListResponseItem) int64 { return ch.ID },
+		[]column[bot_api_client.ChatsListResponseItem]{
+			{"ID", func(ch bot_api_client.ChatsListResponseItem) string { return strconv.FormatInt(ch.ID, 10) }},
+			{"CHANNEL", func(ch bot_api_client.ChatsListResponseItem) string {
+				if ch.Channel == nil {
+					return ""
+				}
+
+				return fmt.Sprintf("%s %d", ch.Channel.Type, ch.Channel.ID)
+			}},
+			{"CUSTOMER", func(ch bot_api_client.ChatsListResponseItem) string {
+				if ch.Customer == nil {
+					return ""
+				}
+
+				return fmt.Sprintf("%s (%d)", ch.Customer.Name, ch.Customer.ID)
+			}},
+			{"WAITING", func(ch bot_api_client.ChatsListResponseItem) string { return string(deref(ch.WaitingLevel)) }},
+			{"LAST ACTIVITY", func(ch bot_api_client.ChatsListResponseItem) string {
+				return formatTime(deref(ch.LastActivity).Time)
+			}},
+		},
+	),
+	newResource("dialogs", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListDialogsParams) (
+			[]bot_api_client.DialogListResponseItem, error,
+		) {
+			resp, err := c.ListDialogsWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(d bot_api_client.DialogListResponseItem) int64 { return d.ID },
+		[]column[bot_api_client.DialogListResponseItem]{
+			{"ID", func(d bot_api_client.DialogListResponseItem) string { return strconv.FormatInt(d.ID, 10) }},
+			{"CHAT", func(d bot_api_client.DialogListResponseItem) string { return strconv.FormatInt(d.ChatID, 10) }},
+			{"ACTIVE", func(d bot_api_client.DialogListResponseItem) string { return formatBool(d.IsActive) }},
+			{"RESPONSIBLE", func(d bot_api_client.DialogListResponseItem) string {
+				if d.Responsible == nil {
+					return ""
+				}
+
+				return fmt.Sprintf("%s %d", d.Responsible.Type, d.Responsible.ID)
+			}},
+			{"TAGS", func(d bot_api_client.DialogListResponseItem) string {
+				names := make([]string, len(d.Tags))
+				for i, tag := range d.Tags {
+					names[i] = tag.Name
+				}
+
+				return strings.Join(names, ",")
+			}},
+			{"CREATED", func(d bot_api_client.DialogListResponseItem) string { return formatTime(d.CreatedAt.Time) }},
+		},
+	),
+	newResource("customers", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListCustomersParams) (
+			[]bot_api_client.Customer, error,
+		) {
+			resp, err := c.ListCustomersWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(c bot_api_client.Customer) int64 { return c.ID },
+		[]column[bot_api_client.Customer]{
+			{"ID", func(c bot_api_client.Customer) string { return strconv.FormatInt(c.ID, 10) }},
+			{"NAME", func(c bot_api_client.Customer) string {
+				return strings.TrimSpace(deref(c.FirstName) + " " + deref(c.LastName))
+			}},
+			{"PHONE", func(c bot_api_client.Customer) string { return deref(c.Phone) }},
+			{"EMAIL", func(c bot_api_client.Customer) string { return deref(c.Email) }},
+			{"CHANNEL", func(c bot_api_client.Customer) string { return formatID(c.ChannelID) }},
+			{"EXTERNAL ID", func(c bot_api_client.Customer) string { return deref(c.ExternalID) }},
+			{"CREATED", func(c bot_api_client.Customer) string { return formatTime(c.CreatedAt.Time) }},
+		},
+	),
+	newResource("users", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListUsersParams) (
+			[]bot_api_client.UserListResponseItem, error,
+		) {
+			resp, err := c.ListUsersWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(u bot_api_client.UserListResponseItem) int64 { return u.ID },
+		[]column[bot_api_client.UserListResponseItem]{
+			{"ID", func(u bot_api_client.UserListResponseItem) string { return strconv.FormatInt(u.ID, 10) }},
+			{"NAME", func(u bot_api_client.UserListResponseItem) string {
+				return strings.TrimSpace(deref(u.FirstName) + " " + deref(u.LastName))
+			}},
+			{"USERNAME", func(u bot_api_client.UserListResponseItem) string { return deref(u.Username) }},
+			{"ACTIVE", func(u bot_api_client.UserListResponseItem) string { return formatBool(u.IsActive) }},
+			{"ONLINE", func(u bot_api_client.UserListResponseItem) string { return formatBool(u.IsOnline) }},
+			{"EXTERNAL ID", func(u bot_api_client.UserListResponseItem) string { return deref(u.ExternalID) }},
+		},
+	),
+	newResource("members", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListMembersParams) (
+			[]bot_api_client.ChatMemberListResponseItem, error,
+		) {
+			resp, err := c.ListMembersWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(m bot_api_client.ChatMemberListResponseItem) int64 { return m.ID },
+		[]column[bot_api_client.ChatMemberListResponseItem]{
+			{"ID", func(m bot_api_client.ChatMemberListResponseItem) string { return strconv.FormatInt(m.ID, 10) }},
+			{"CHAT", func(m bot_api_client.ChatMemberListResponseItem) string { return strconv.FormatInt(m.ChatID, 10) }},
+			{"USER", func(m bot_api_client.ChatMemberListResponseItem) string { return strconv.FormatInt(m.UserID, 10) }},
+			{"STATE", func(m bot_api_client.ChatMemberListResponseItem) string { return string(m.State) }},
+			{"AUTHOR", func(m bot_api_client.ChatMemberListResponseItem) string { return formatBool(m.IsAuthor) }},
+		},
+	),
+	newResource("messages", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListMessagesParams) (
+			[]bot_api_client.MessageListResponseItem, error,
+		) {
+			resp, err := c.ListMessagesWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(m bot_api_client.MessageListResponseItem) int64 { return m.ID },
+		[]column[bot_api_client.MessageListResponseItem]{
+			{"ID", func(m bot_api_client.MessageListResponseItem) string { return strconv.FormatInt(m.ID, 10) }},
+			{"CHAT", func(m bot_api_client.MessageListResponseItem) string { return strconv.FormatInt(m.ChatID, 10) }},
+			{"TIME", func(m bot_api_client.MessageListResponseItem) string { return formatTime(m.Time.Time) }},
+			{"FROM", func(m bot_api_client.MessageListResponseItem) string {
+				if m.From == nil {
+					return ""
+				}
+
+				return fmt.Sprintf("%s %d", m.From.Type, m.From.ID)
+			}},
+			{"TYPE", func(m bot_api_client.MessageListResponseItem) string { return string(m.Type) }},
+			{"SCOPE", func(m bot_api_client.MessageListResponseItem) string { return string(m.Scope) }},
+			{"STATUS", func(m bot_api_client.MessageListResponseItem) string { return string(m.Status) }},
+			{"CONTENT", func(m bot_api_client.MessageListResponseItem) string { return deref(m.Content) }},
+		},
+	),
+	newResource("commands", "id",
+		func(ctx context.Context, c bot_api_client.ClientWithResponsesInterface, p *bot_api_client.ListCommandsParams) (
+			[]bot_api_client.Command, error,
+		) {
+			resp, err := c.ListCommandsWithResponse(ctx, p)
+			if err = bot_api_client.ExtractError(resp, err); err != nil {
+				return nil, err
+			}
+
+			return deref(resp.JSON200), nil
+		},
+		func(c bot_api_client.Command) int64 { return c.ID },
+		[]column[bot_api_client.Command]{
+			{"ID", func(c bot_api_client.Command) string { return strconv.FormatInt(c.ID, 10) }},
+			{"NAME", func(c bot_api_client.Command) string { return c.Name }},
+			{"DESCRIPTION", func(c bot_api_client.Command) string { return c.Description }},
+			{"CREATED", func(c bot_api_client.Command) string { return formatTime(c.CreatedAt.Time) }},
+		},
+	),
+}
+
+// findResource returns the resource named by the first argument, which may also be singular.
+func findResource(args []string) (resource, error) {
+	names := make([]string, len(resources))
+	for i, r := range resources {
+		if len(args) > 0 && (r.name == args[0] || r.name == args[0]+"s") {
+			return r, nil
+		}
+		names[i] = r.name
+	}
+
+	if len(args) == 0 {
+		return resource{}, usageError{err: fmt.Errorf("missing resource: %s", strings.Join(names, ", "))}
+	}
+
+	return resource{}, usageError{err: fmt.Errorf("unknown resource %q: use %s", args[0], strings.Join(names, ", "))}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+
+	bot_api_client "github.com/retailcrm/bot-api-client-go"
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// eventTypes are the events tail subscribes to by default.
+var eventTypes = []ws.EventTypeSchema{
+	ws.EventTypeMessageNew, ws.EventTypeMessageUpdated, ws.EventTypeMessageDeleted, ws.EventTypeMessageRestored,
+	ws.EventTypeChatCrated, ws.EventTypeChatUpdated, ws.EventTypeChatsDeleted,
+	ws.EventTypeDialogOpened, ws.EventTypeDialogClosed, ws.EventTypeDialogAssign,
+	ws.EventTypeUserOnlineUpdated, ws.EventTypeUserJoinedChat, ws.EventTypeUserLeftChat, ws.EventTypeUserUpdated,
+	ws.EventTypeCustomerUpdated, ws.EventTypeBotUpdated, ws.EventTypeChannelUpdated,
+}
+
+// tail prints, and optionally records, the WebSocket events until interrupted, or replays a recording.
+func tail(ctx context.Context, a *app, args []string) error {
+	fs := a.flagSet("tail", "")
+	events := fs.String("events", "", "comma-separated event `types` to subscribe to (default all)")
+	options := fs.String("options", "", "comma-separated subscription `options`")
+	wsURL := fs.String("ws-url", "", "WebSocket `URL` (default the endpoint with ws or wss scheme and /ws path)")
+	var filter bot_api_client.TailFilter
+	filterFlag(fs, "type", "show only events of the comma-separated `types`", &filter.Types)
+	filterFlag(fs, "chat", "show only events of the comma-separated chat `IDs`", &filter.ChatIDs)
+	filterFlag(fs, "channel", "show only events of the comma-separated channel `IDs`", &filter.ChannelIDs)
+	filterFlag(fs, "customer", "show only events of the comma-separated customer `IDs`", &filter.CustomerIDs)
+	count := fs.Int("n", 0, "exit after `count` events")
+	record := fs.String("record", "", "record the events to JSON lines files in `dir`")
+	rotateSize := fs.Int64("rotate-size", bot_api_client.DefaultTailRecorderMaxSize>>20,
+		"start a new recording file at `MiB`")
+	rotateInterval := fs.Duration("rotate-interval", 0, "start a new recording file every `interval`")
+	replay := fs.String("replay", "", "print the events of a recording `file`, - for stdin, instead of subscribing")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	opts := []bot_api_client.TailOption{bot_api_client.WithTailFilter(filter)}
+	if a.format == formatJSON {
+		opts = append(opts, bot_api_client.WithTailFormat(bot_api_client.TailFormatJSONL))
+	}
+	if *record != "" {
+		recorder, err := bot_api_client.NewTailRecorder(*record,
+			bot_api_client.WithTailRecorderMaxSize(*rotateSize<<20),
+			bot_api_client.WithTailRecorderInterval(*rotateInterval))
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		opts = append(opts, bot_api_client.WithTailRecorder(recorder))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var seen int
+	handler := bot_api_client.NewTail(a.stdout, opts...).Handler(
+		func(_ context.Context, msg ws.EventMessageFromEventsChannel) error {
+			if *count > 0 && filter.Match(msg.Payload) {
+				if seen++; seen == *count {
+					cancel()
+				}
+			}
+
+			return nil
+		})
+
+	if *replay != "" {
+		return replayRecording(ctx, a, *replay, handler)
+	}
+
+	cfg, err := loadConfig(a.configPath, a.getenv, a.flags)
+	if err != nil {
+		return err
+	}
+	if *wsURL == "" {
+		if *wsURL, err = eventsURL(cfg.Endpoint); err != nil {
+			return err
+		}
+	}
+
+	// Errors of the output are reported to the controller, which only logs them. The first one, or the loss of
+	// the connection, stops the tail.
+	failed := make(chan error, 1)
+	fail := func(err error) {
+		select {
+		case failed <- err:
+		default:
+		}
+		cancel()
+	}
+
+	controller, err := ws.NewController(*wsURL, cfg.Token, ws.WithDisconnectHandler(func(_ string, err error) {
+		fail(fmt.Errorf("connection lost: %w", err))
+	}))
+	if err != nil {
+		return err
+	}
+
+	if *events == "" {
+		types := make([]string, len(eventTypes))
+		for i, t := range eventTypes {
+			types[i] = string(t)
+		}
+		*events = strings.Join(types, ",")
+	}
+	params := ws.EventsChannelParameters{Events: *events, Options: *options}
+	err = controller.SubscribeToReceiveEventsOperation(ctx, params,
+		func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+			if err := handler(ctx, msg); err != nil {
+				fail(err)
+			}
+
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("subscribe: %w", err)
+	}
+	defer controller.UnsubscribeFromReceiveEventsOperation(context.Background(), params)
+
+	<-ctx.Done()
+	select {
+	case err = <-failed:
+		return err
+	default:
+		return nil
+	}
+}
+
+// filterFlag registers a flag setting a slice field of the filter.
+func filterFlag(fs *flag.FlagSet, name, usage string, field any) {
+	fs.Func(name, usage, func(s string) error {
+		return parseValue(reflect.ValueOf(field).Elem(), s)
+	})
+}
+
+// replayRecording passes the events of the recording at path to handler. The replay is stopped without an error
+// when ctx is cancelled, e.g. after -n events.
+func replayRecording(
+	ctx context.Context, a *app, path string,
+	handler func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) error {
+	r := a.stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	err := bot_api_client.ReplayTail(ctx, r, handler)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+// eventsURL returns the WebSocket URL of the events of the Bot API at endpoint.
+func eventsURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("endpoint %q: unsupported scheme", endpoint)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/ws"
+
+	return u.String(), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var frames = []string{
+	`{"type":"message_new","meta":{"timestamp":1714564800},"data":{"message":{"id":1,"chat_id":11,` +
+		`"type":"text","scope":"public","status":"received","time":"2024-05-01T12:00:00Z","content":"Hi"}}}`,
+	`{"type":"message_new","meta":{"timestamp":1714564801},"data":{"message":{"id":2,"chat_id":12,` +
+		`"type":"text","scope":"public","status":"received","time":"2024-05-01T12:00:01Z","content":"Hello"}}}`,
+	`{"type":"message_new", "meta":{"timestamp":1714564802}, "data":{"message":{"id":3,"chat_id":11,` +
+		`"type":"text","scope":"public","status":"received","time":"2024-05-01T12:00:02Z","content":"Bye"}}}`,
+}
+
+func TestTail(t *testing.T) {
+	t.Parallel()
+
+	t.Run("subscribe and record", func(t *testing.T) {
+		t.Parallel()
+
+		requests := make(chan *http.Request, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/bot/v1/ws", r.URL.Path)
+			requests <- r
+
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			for _, frame := range frames {
+				assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+			}
+			// Wait for the client to disconnect.
+			for {
+				if _, _, err = conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+		defer srv.Close()
+
+		dir := t.TempDir()
+		code, stdout, stderr, _ := botctl(t, nil, "-endpoint", srv.URL+"/api/bot/v1", "-o", "json",
+			"tail", "-events", "message_new", "-chat", "11", "-n", "2", "-record", dir)
+		require.Equal(t, 0, code, stderr)
+		r := <-requests
+		assert.Equal(t, "events=message_new&options=", r.URL.RawQuery)
+		assert.Equal(t, "secret", r.Header.Get("X-Bot-Token"))
+
+		lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"event":`+frames[0]+"}")
+		assert.Contains(t, lines[1], `"event":`+frames[2]+"}")
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		recording, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+		require.NoError(t, err)
+		assert.Equal(t, stdout, string(recording))
+	})
+
+	t.Run("connection lost", func(t *testing.T) {
+		t.Parallel()
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frames[0])))
+			_ = conn.Close()
+		}))
+		defer srv.Close()
+
+		code, stdout, stderr, _ := botctl(t, nil, "-endpoint", srv.URL+"/api/bot/v1", "-o", "json", "tail")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stdout, `"event":`+frames[0]+"}")
+		assert.True(t, strings.HasPrefix(stderr, "botctl tail: connection lost: "), stderr)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		t.Parallel()
+
+		var recording string
+		for i, frame := range frames {
+			recording += `{"received_at":"2024-05-01T12:00:0` + string(rune('0'+i)) + `Z","event":` + frame + "}\n"
+		}
+		path := filepath.Join(t.TempDir(), "events.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte(recording), 0o600))
+
+		code, stdout, stderr, _ := botctl(t, nil, "-o", "json", "tail", "-replay", path)
+		require.Equal(t, 0, code, stderr)
+		assert.Equal(t, recording, stdout)
+
+		code, stdout, _, _ = botctl(t, nil, "tail", "-replay", path, "-chat", "12", "-n", "1")
+		require.Equal(t, 0, code)
+		assert.Contains(t, stdout, " message_new chat=12\n{\n")
+		assert.Equal(t, 1, strings.Count(stdout, "message_new chat="))
+
+		code, _, stderr, _ = botctl(t, nil, "tail", "-replay", path, "-chat", "x")
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr, `invalid number "x"`)
+	})
+
+	t.Run("events URL", func(t *testing.T) {
+		t.Parallel()
+
+		u, err := eventsURL("https://mg-s1.retailcrm.pro/api/bot/v1/")
+		require.NoError(t, err)
+		assert.Equal(t, "wss://mg-s1.retailcrm.pro/api/bot/v1/ws", u)
+
+		_, err = eventsURL("mg-s1.retailcrm.pro")
+		require.Error(t, err)
+	})
+}
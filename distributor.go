@@ -0,0 +1,697 @@
+package bot_api_client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+const (
+	// DefaultOperatorsTTL is how long Distributor caches the list of operators.
+	DefaultOperatorsTTL = 30 * time.Second
+
+	// DefaultDistributorConcurrency is the number of dialogs the handler of Distributor distributes at the same time.
+	DefaultDistributorConcurrency = 4
+
+	// maxListLimit is the largest page size the list methods accept.
+	maxListLimit = 1000
+)
+
+// ErrNoOperator is returned when no operator can take a dialog.
+var ErrNoOperator = errors.New("no operator available")
+
+// AssignConflictError is returned when a dialog turns out to be assigned by someone else.
+type AssignConflictError struct {
+	DialogID    int64
+	Responsible Responsible
+}
+
+func (e *AssignConflictError) Error() string {
+	return fmt.Sprintf("dialog %d is already assigned to %s %d", e.DialogID, e.Responsible.Type, e.Responsible.ID)
+}
+
+// Operator is a user who can be assigned to dialogs, together with the number of open dialogs assigned to them.
+type Operator struct {
+	User UserListResponseItem
+	Load int
+}
+
+// DistributionRequest is the dialog to distribute. Chat is nil if the chat could not be resolved.
+type DistributionRequest struct {
+	Dialog DialogListResponseItem
+	Chat   *ChatsListResponseItem
+}
+
+// DistributionStrategy picks the operator to assign a dialog to from the candidates ordered by user ID.
+// It returns false if none of them fits.
+type DistributionStrategy interface {
+	Pick(ctx context.Context, req DistributionRequest, candidates []Operator) (Operator, bool, error)
+}
+
+// DistributionStrategyFunc adapts a function to DistributionStrategy.
+type DistributionStrategyFunc func(
+	ctx context.Context, req DistributionRequest, candidates []Operator,
+) (Operator, bool, error)
+
+func (f DistributionStrategyFunc) Pick(
+	ctx context.Context, req DistributionRequest, candidates []Operator,
+) (Operator, bool, error) {
+	return f(ctx, req, candidates)
+}
+
+// RoundRobin returns a strategy that assigns dialogs to the candidates in turn.
+func RoundRobin() DistributionStrategy {
+	var (
+		mu   sync.Mutex
+		last int64
+	)
+
+	return DistributionStrategyFunc(func(
+		_ context.Context, _ DistributionRequest, candidates []Operator,
+	) (Operator, bool, error) {
+		if len(candidates) == 0 {
+			return Operator{}, false, nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		next := candidates[0]
+		for _, c := range candidates {
+			if c.User.ID > last {
+				next = c
+
+				break
+			}
+		}
+		last = next.User.ID
+
+		return next, true, nil
+	})
+}
+
+// LeastLoaded returns a strategy that picks the candidate with the fewest open dialogs.
+func LeastLoaded() DistributionStrategy {
+	return DistributionStrategyFunc(func(
+		_ context.Context, _ DistributionRequest, candidates []Operator,
+	) (Operator, bool, error) {
+		if len(candidates) == 0 {
+			return Operator{}, false, nil
+		}
+
+		return slices.MinFunc(candidates, func(a, b Operator) int { return a.Load - b.Load }), true, nil
+	})
+}
+
+// Sticky returns a strategy that picks the user responsible for the previous dialog of the chat
+// if they are among the candidates, and asks fallback otherwise.
+func Sticky(client ClientWithResponsesInterface, fallback DistributionStrategy) DistributionStrategy {
+	return DistributionStrategyFunc(func(
+		ctx context.Context, req DistributionRequest, candidates []Operator,
+	) (Operator, bool, error) {
+		chatID := int(req.Dialog.ChatID)
+		resp, err := client.ListDialogsWithResponse(ctx, &ListDialogsParams{
+			ChatID: &chatID,
+			Limit:  optional(maxListLimit),
+		})
+		if err = ExtractError(resp, err); err != nil {
+			return Operator{}, false, fmt.Errorf("list dialogs of chat %d: %w", req.Dialog.ChatID, err)
+		}
+
+		var previous *DialogListResponseItem
+		for _, d := range deref(resp.JSON200) {
+			if d.ID < req.Dialog.ID && d.Responsible != nil && d.Responsible.Type == ResponsibleTypeUser &&
+				(previous == nil || d.ID > previous.ID) {
+				previous = &d
+			}
+		}
+
+		if previous != nil {
+			for _, c := range candidates {
+				if c.User.ID == previous.Responsible.ID {
+					return c, true, nil
+				}
+			}
+		}
+
+		return fallback.Pick(ctx, req, candidates)
+	})
+}
+
+// OperatorSkills describe the dialogs an operator is suited for.
+type OperatorSkills struct {
+	Tags         []string
+	ChannelIDs   []int64
+	ChannelTypes []ChannelType
+}
+
+func (s OperatorSkills) matches(req DistributionRequest) bool {
+	for _, tag := range req.Dialog.Tags {
+		if slices.Contains(s.Tags, tag.Name) {
+			return true
+		}
+	}
+
+	if req.Chat != nil && req.Chat.Channel != nil {
+		channel := req.Chat.Channel
+
+		return slices.Contains(s.ChannelIDs, channel.ID) || slices.Contains(s.ChannelTypes, channel.Type)
+	}
+
+	return false
+}
+
+// SkillBased returns a strategy that narrows the candidates down to the operators whose skills match a tag
+// of the dialog or the channel of its chat, and lets next pick among them. If no operator matches,
+// next picks among all candidates. Skills are keyed by user ID.
+func SkillBased(skills map[int64]OperatorSkills, next DistributionStrategy) DistributionStrategy {
+	return DistributionStrategyFunc(func(
+		ctx context.Context, req DistributionRequest, candidates []Operator,
+	) (Operator, bool, error) {
+		skilled := slices.DeleteFunc(slices.Clone(candidates), func(c Operator) bool {
+			s, ok := skills[c.User.ID]
+
+			return !ok || !s.matches(req)
+		})
+		if len(skilled) == 0 {
+			skilled = candidates
+		}
+
+		return next.Pick(ctx, req, skilled)
+	})
+}
+
+// Assignment is a dialog assigned by Distributor.
+type Assignment struct {
+	DialogID int64
+	ChatID   int64
+	Operator UserListResponseItem
+	Response DialogAssignResponse
+}
+
+// Distributor assigns unassigned dialogs to operators, for bots with the distributor role.
+// Candidates are active, online users other than technical accounts; a strategy picks one of them.
+// Distributor tracks the number of open dialogs assigned to each operator and keeps it up to date
+// from dialog events. It is safe for concurrent use.
+type Distributor struct {
+	client     ClientWithResponsesInterface
+	strategy   DistributionStrategy
+	filter     func(UserListResponseItem) bool
+	maxLoad    int
+	ttl        time.Duration
+	now        func() time.Time
+	onAssigned func(ctx context.Context, assignment Assignment)
+	onError    func(ctx context.Context, err error)
+
+	mu         sync.Mutex
+	operators  *cached[[]UserListResponseItem]
+	load       map[int64]int
+	dialogs    map[int64]int64
+	inProgress map[int64]bool
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// DistributorOption configures a Distributor.
+type DistributorOption func(*Distributor)
+
+// WithDistributionStrategy sets the strategy picking operators. LeastLoaded is used by default.
+func WithDistributionStrategy(strategy DistributionStrategy) DistributorOption {
+	return func(d *Distributor) {
+		d.strategy = strategy
+	}
+}
+
+// WithOperatorFilter excludes operators for which filter returns false from distribution.
+func WithOperatorFilter(filter func(UserListResponseItem) bool) DistributorOption {
+	return func(d *Distributor) {
+		d.filter = filter
+	}
+}
+
+// WithMaxOperatorLoad excludes operators with n or more open dialogs from distribution.
+func WithMaxOperatorLoad(n int) DistributorOption {
+	return func(d *Distributor) {
+		d.maxLoad = n
+	}
+}
+
+// WithOperatorsTTL sets how long the list of operators is cached.
+func WithOperatorsTTL(ttl time.Duration) DistributorOption {
+	return func(d *Distributor) {
+		d.ttl = ttl
+	}
+}
+
+// WithAssignmentHandler sets the function called after a dialog is assigned.
+func WithAssignmentHandler(onAssigned func(ctx context.Context, assignment Assignment)) DistributorOption {
+	return func(d *Distributor) {
+		d.onAssigned = onAssigned
+	}
+}
+
+// WithDistributorErrorHandler sets the function called with errors of dialogs distributed by the handler.
+func WithDistributorErrorHandler(onError func(ctx context.Context, err error)) DistributorOption {
+	return func(d *Distributor) {
+		d.onError = onError
+	}
+}
+
+// WithDistributorConcurrency sets the number of dialogs the handler distributes at the same time.
+func WithDistributorConcurrency(n int) DistributorOption {
+	return func(d *Distributor) {
+		d.slots = make(chan struct{}, max(n, 1))
+	}
+}
+
+// NewDistributor creates a Distributor that lists users and assigns dialogs with the given client.
+func NewDistributor(client ClientWithResponsesInterface, opts ...DistributorOption) *Distributor {
+	d := &Distributor{
+		client:     client,
+		strategy:   LeastLoaded(),
+		ttl:        DefaultOperatorsTTL,
+		now:        time.Now,
+		onAssigned: func(context.Context, Assignment) {},
+		onError:    func(context.Context, error) {},
+		load:       make(map[int64]int),
+		dialogs:    make(map[int64]int64),
+		inProgress: make(map[int64]bool),
+		slots:      make(chan struct{}, DefaultDistributorConcurrency),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Load returns the number of open dialogs assigned to each operator, keyed by user ID.
+func (d *Distributor) Load() map[int64]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	load := make(map[int64]int, len(d.load))
+	for id, n := range d.load {
+		if n > 0 {
+			load[id] = n
+		}
+	}
+
+	return load
+}
+
+// RefreshLoad recounts the open dialogs assigned to users.
+func (d *Distributor) RefreshLoad(ctx context.Context) error {
+	dialogs := make(map[int64]int64)
+	err := listDialogPages(ctx, d.client, ListDialogsParams{
+		Active: optional(BooleanTrue),
+		Assign: optional(BooleanTrue),
+	}, func(page []DialogListResponseItem) {
+		for _, dialog := range page {
+			if r := dialog.Responsible; r != nil && r.Type == ResponsibleTypeUser {
+				dialogs[dialog.ID] = r.ID
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("refresh operator load: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.dialogs = dialogs
+	d.load = make(map[int64]int)
+	for _, userID := range dialogs {
+		d.load[userID]++
+	}
+
+	return nil
+}
+
+// DistributeUnassigned assigns all open unassigned dialogs. It returns the assignments made
+// and the errors of the dialogs that could not be assigned joined together.
+func (d *Distributor) DistributeUnassigned(ctx context.Context) ([]Assignment, error) {
+	var unassigned []DialogListResponseItem
+	err := listDialogPages(ctx, d.client, ListDialogsParams{
+		Active: optional(BooleanTrue),
+		Assign: optional(BooleanFalse),
+	}, func(page []DialogListResponseItem) {
+		unassigned = append(unassigned, page...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list unassigned dialogs: %w", err)
+	}
+
+	var (
+		assignments []Assignment
+		errs        []error
+	)
+	for _, dialog := range unassigned {
+		assignment, err := d.Distribute(ctx, DistributionRequest{Dialog: dialog})
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	return assignments, errors.Join(errs...)
+}
+
+// Distribute assigns the dialog to the operator picked by the strategy. The chat of the request
+// is fetched if it is not set. The dialog is read again right before it is assigned: if it is already assigned,
+// it is left alone and *AssignConflictError is returned. If it is assigned between the two requests,
+// it is assigned back to that responsible and *AssignConflictError is returned too.
+func (d *Distributor) Distribute(ctx context.Context, req DistributionRequest) (Assignment, error) {
+	dialogID := req.Dialog.ID
+	if r := req.Dialog.Responsible; r != nil {
+		return Assignment{}, &AssignConflictError{DialogID: dialogID, Responsible: *r}
+	}
+
+	d.mu.Lock()
+	if d.inProgress[dialogID] {
+		d.mu.Unlock()
+
+		return Assignment{}, fmt.Errorf("distribute dialog %d: already in progress", dialogID)
+	}
+	d.inProgress[dialogID] = true
+	d.mu.Unlock()
+
+	defer func() {
+		d.mu.Lock()
+		delete(d.inProgress, dialogID)
+		d.mu.Unlock()
+	}()
+
+	assignment, err := d.distribute(ctx, req)
+	if err != nil {
+		var conflict *AssignConflictError
+		if errors.As(err, &conflict) {
+			return Assignment{}, err
+		}
+
+		return Assignment{}, fmt.Errorf("distribute dialog %d: %w", dialogID, err)
+	}
+
+	d.onAssigned(ctx, assignment)
+
+	return assignment, nil
+}
+
+func (d *Distributor) distribute(ctx context.Context, req DistributionRequest) (Assignment, error) {
+	if req.Chat == nil {
+		chat, err := d.chat(ctx, req.Dialog.ChatID)
+		if err != nil {
+			return Assignment{}, err
+		}
+		req.Chat = chat
+	}
+
+	candidates, err := d.candidates(ctx)
+	if err != nil {
+		return Assignment{}, err
+	}
+
+	operator, ok, err := d.strategy.Pick(ctx, req, candidates)
+	if err != nil {
+		return Assignment{}, err
+	}
+	if !ok {
+		return Assignment{}, ErrNoOperator
+	}
+
+	// The dialog may have been assigned since it was listed or the event was sent.
+	current, err := d.dialog(ctx, req.Dialog.ID)
+	if err != nil {
+		return Assignment{}, err
+	}
+	if r := current.Responsible; r != nil {
+		if r.Type == ResponsibleTypeUser {
+			d.track(current.ID, r.ID)
+		}
+
+		return Assignment{}, &AssignConflictError{DialogID: req.Dialog.ID, Responsible: *r}
+	}
+
+	resp, err := d.client.AssignDialogResponsibleWithResponse(ctx, req.Dialog.ID, AssignDialogResponsibleJSONRequestBody{
+		UserID: operator.User.ID,
+	})
+	if err = ExtractError(resp, err); err != nil {
+		return Assignment{}, err
+	}
+	if resp.JSON200 == nil {
+		return Assignment{}, fmt.Errorf("unexpected response: %s", resp.Status())
+	}
+
+	if prev := resp.JSON200.PreviousResponsible; prev != nil {
+		// The dialog was assigned between the two requests: it is given back.
+		return Assignment{}, d.restore(ctx, req.Dialog.ID, operator.User.ID, *prev)
+	}
+
+	d.track(req.Dialog.ID, operator.User.ID)
+
+	return Assignment{
+		DialogID: req.Dialog.ID,
+		ChatID:   req.Dialog.ChatID,
+		Operator: operator.User,
+		Response: *resp.JSON200,
+	}, nil
+}
+
+// restore assigns the dialog taken by the operator back to the previous responsible and returns
+// *AssignConflictError, or the error of the assignment.
+func (d *Distributor) restore(ctx context.Context, dialogID, operatorID int64, prev Responsible) error {
+	body := AssignDialogResponsibleJSONRequestBody{UserID: prev.ID}
+	if prev.Type == ResponsibleTypeBot {
+		body = AssignDialogResponsibleJSONRequestBody{BotID: prev.ID}
+	}
+
+	resp, err := d.client.AssignDialogResponsibleWithResponse(ctx, dialogID, body)
+	if err = ExtractError(resp, err); err != nil {
+		d.track(dialogID, operatorID)
+
+		return fmt.Errorf("assigned meanwhile to %s %d, give back: %w", prev.Type, prev.ID, err)
+	}
+
+	var userID int64
+	if prev.Type == ResponsibleTypeUser {
+		userID = prev.ID
+	}
+	d.track(dialogID, userID)
+
+	return &AssignConflictError{DialogID: dialogID, Responsible: prev}
+}
+
+func (d *Distributor) dialog(ctx context.Context, dialogID int64) (DialogListResponseItem, error) {
+	id := int(dialogID)
+	resp, err := d.client.ListDialogsWithResponse(ctx, &ListDialogsParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return DialogListResponseItem{}, fmt.Errorf("get dialog %d: %w", dialogID, err)
+	}
+
+	for _, dialog := range deref(resp.JSON200) {
+		if dialog.ID == dialogID {
+			return dialog, nil
+		}
+	}
+
+	return DialogListResponseItem{}, fmt.Errorf("get dialog %d: not found", dialogID)
+}
+
+func (d *Distributor) chat(ctx context.Context, chatID int64) (*ChatsListResponseItem, error) {
+	id := int(chatID)
+	resp, err := d.client.ListChatsWithResponse(ctx, &ListChatsParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return nil, fmt.Errorf("get chat %d: %w", chatID, err)
+	}
+
+	for _, chat := range deref(resp.JSON200) {
+		if chat.ID == chatID {
+			return &chat, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// candidates returns the operators that may take a dialog, ordered by user ID.
+func (d *Distributor) candidates(ctx context.Context) ([]Operator, error) {
+	users, err := d.users(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	candidates := make([]Operator, 0, len(users))
+	for _, u := range users {
+		if !u.IsActive || !u.IsOnline || u.IsTechnicalAccount || (d.filter != nil && !d.filter(u)) {
+			continue
+		}
+
+		load := d.load[u.ID]
+		if d.maxLoad > 0 && load >= d.maxLoad {
+			continue
+		}
+		candidates = append(candidates, Operator{User: u, Load: load})
+	}
+	slices.SortFunc(candidates, func(a, b Operator) int { return cmp.Compare(a.User.ID, b.User.ID) })
+
+	return candidates, nil
+}
+
+func (d *Distributor) users(ctx context.Context) ([]UserListResponseItem, error) {
+	d.mu.Lock()
+	if d.operators != nil && d.now().Before(d.operators.expires) {
+		users := d.operators.value
+		d.mu.Unlock()
+
+		return users, nil
+	}
+	d.mu.Unlock()
+
+	var users []UserListResponseItem
+	params := ListUsersParams{
+		Active: optional(BooleanTrue),
+		Online: optional(BooleanTrue),
+		Limit:  optional(maxListLimit),
+	}
+	for {
+		resp, err := d.client.ListUsersWithResponse(ctx, &params)
+		if err = ExtractError(resp, err); err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+
+		page := deref(resp.JSON200)
+		users = append(users, page...)
+		if len(page) < maxListLimit {
+			break
+		}
+		params.SinceID = &page[len(page)-1].ID
+	}
+
+	d.mu.Lock()
+	d.operators = &cached[[]UserListResponseItem]{value: users, expires: d.now().Add(d.ttl)}
+	d.mu.Unlock()
+
+	return users, nil
+}
+
+// track records that the dialog is assigned to the user, or unassigned or closed if userID is 0.
+func (d *Distributor) track(dialogID, userID int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prev, ok := d.dialogs[dialogID]; ok {
+		if prev == userID {
+			return
+		}
+		d.load[prev]--
+		delete(d.dialogs, dialogID)
+	}
+
+	if userID != 0 {
+		d.dialogs[dialogID] = userID
+		d.load[userID]++
+	}
+}
+
+// Handler returns a WebSocket event handler that distributes dialogs opened without a responsible,
+// tracks the load of operators from dialog_assign and dialog_closed events and passes every event to next,
+// which may be nil. Dialogs are distributed in the background; the handler waits while the maximum number of them
+// is being distributed. Distribution errors are reported to the error handler. Use Wait to wait for
+// the background distribution to finish.
+func (d *Distributor) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		switch data := msg.Payload.Data.(type) {
+		case ws.DialogDataSchema:
+			switch msg.Payload.Type {
+			case ws.EventTypeDialogOpened:
+				d.handleOpened(ctx, data.Dialog)
+			case ws.EventTypeDialogClosed:
+				d.track(data.Dialog.Id, 0)
+			}
+		case ws.DialogAssignDataSchema:
+			var userID int64
+			if r := data.Dialog.Responsible; r != nil && r.Type == ws.ResponsibleTypeUser {
+				userID = r.Id
+			}
+			d.track(data.Dialog.Id, userID)
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+func (d *Distributor) handleOpened(ctx context.Context, dialog ws.DialogEventSchema) {
+	if r := dialog.Responsible; r != nil {
+		if r.Type == ws.ResponsibleTypeUser {
+			d.track(dialog.Id, r.Id)
+		}
+
+		return
+	}
+
+	req := DistributionRequest{Dialog: dialogItemFromWS(DialogListResponseItem{}, dialog)}
+	if dialog.Chat != nil {
+		chat := chatItemFromWS(ChatsListResponseItem{}, *dialog.Chat)
+		req.Chat = &chat
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	d.slots <- struct{}{}
+	d.wg.Add(1)
+	go func() {
+		defer func() {
+			<-d.slots
+			d.wg.Done()
+		}()
+
+		if _, err := d.Distribute(ctx, req); err != nil {
+			d.onError(ctx, err)
+		}
+	}()
+}
+
+// Wait blocks until the dialogs passed to the handler have been distributed.
+func (d *Distributor) Wait() {
+	d.wg.Wait()
+}
+
+// listDialogPages calls fn with every page of dialogs matching the params.
+func listDialogPages(
+	ctx context.Context, client ClientWithResponsesInterface, params ListDialogsParams, fn func([]DialogListResponseItem),
+) error {
+	params.Limit = optional(maxListLimit)
+	for {
+		resp, err := client.ListDialogsWithResponse(ctx, &params)
+		if err = ExtractError(resp, err); err != nil {
+			return err
+		}
+
+		page := deref(resp.JSON200)
+		fn(page)
+		if len(page) < maxListLimit {
+			return nil
+		}
+		params.SinceID = &page[len(page)-1].ID
+	}
+}
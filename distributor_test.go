@@ -0,0 +1,307 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type distributorServer struct {
+	fakeAPI
+	users   []UserListResponseItem
+	dialogs []DialogListResponseItem
+	assigns []string
+	// meanwhile is the responsible someone else assigns the next dialog to right before the distributor does.
+	meanwhile *Responsible
+}
+
+func (s *distributorServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		query := req.URL.Query()
+		switch {
+		case req.URL.Path == "/users":
+			return http.StatusOK, s.users
+		case req.URL.Path == "/chats":
+			chatID, _ := strconv.ParseInt(query.Get("id"), 10, 64)
+
+			return http.StatusOK, []map[string]any{{"id": chatID, "channel": map[string]any{"id": 3, "type": "telegram"}}}
+		case req.URL.Path == "/dialogs":
+			dialogs := []DialogListResponseItem{}
+			for _, d := range s.dialogs {
+				assigned := strconv.FormatBool(d.Responsible != nil)
+				chatID := strconv.FormatInt(d.ChatID, 10)
+				if (query.Has("assign") && query.Get("assign") != assigned) || (query.Has("chat_id") && query.Get("chat_id") != chatID) {
+					continue
+				}
+				if query.Has("id") && query.Get("id") != strconv.FormatInt(d.ID, 10) {
+					continue
+				}
+				dialogs = append(dialogs, d)
+			}
+
+			return http.StatusOK, dialogs
+		case req.Method == http.MethodPatch && strings.HasSuffix(req.URL.Path, "/assign"):
+			var assign AssignDialogResponsibleJSONRequestBody
+			if !decodeRequest(t, req, &assign) {
+				return http.StatusBadRequest, nil
+			}
+
+			dialogID, _ := strconv.ParseInt(strings.Split(req.URL.Path, "/")[2], 10, 64)
+			s.assigns = append(s.assigns, fmt.Sprintf("%d:user=%d,bot=%d", dialogID, assign.UserID, assign.BotID))
+
+			response := DialogAssignResponse{Responsible: Responsible{ID: assign.UserID, Type: ResponsibleTypeUser}}
+			if assign.BotID != 0 {
+				response.Responsible = Responsible{ID: assign.BotID, Type: ResponsibleTypeBot}
+			}
+			for i, d := range s.dialogs {
+				if d.ID == dialogID {
+					if s.meanwhile != nil {
+						d.Responsible, s.meanwhile = s.meanwhile, nil
+					}
+					response.IsReAssign = d.Responsible != nil
+					response.PreviousResponsible = d.Responsible
+					s.dialogs[i].Responsible = &response.Responsible
+				}
+			}
+
+			return http.StatusOK, response
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func (s *distributorServer) assigned() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.assigns...)
+}
+
+func operators(loads ...int) []Operator {
+	ops := make([]Operator, len(loads))
+	for i, load := range loads {
+		ops[i] = Operator{User: UserListResponseItem{ID: int64(i + 1)}, Load: load}
+	}
+
+	return ops
+}
+
+func pickIDs(t *testing.T, strategy DistributionStrategy, req DistributionRequest, candidates []Operator, n int) []int64 {
+	ids := make([]int64, 0, n)
+	for range n {
+		op, ok, err := strategy.Pick(context.Background(), req, candidates)
+		require.NoError(t, err)
+		require.True(t, ok)
+		ids = append(ids, op.User.ID)
+	}
+
+	return ids
+}
+
+func TestDistributionStrategies(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round robin", func(t *testing.T) {
+		t.Parallel()
+
+		strategy := RoundRobin()
+		assert.Equal(t, []int64{1, 2, 3, 1}, pickIDs(t, strategy, DistributionRequest{}, operators(0, 0, 0), 4))
+		assert.Equal(t, []int64{2}, pickIDs(t, strategy, DistributionRequest{}, operators(0, 0), 1))
+
+		_, ok, err := strategy.Pick(context.Background(), DistributionRequest{}, nil)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("least loaded", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, []int64{2}, pickIDs(t, LeastLoaded(), DistributionRequest{}, operators(3, 1, 1), 1))
+	})
+
+	t.Run("skill based", func(t *testing.T) {
+		t.Parallel()
+
+		strategy := SkillBased(map[int64]OperatorSkills{
+			2: {Tags: []string{"vip"}},
+			3: {ChannelTypes: []ChannelType{ChannelTypeTelegram}},
+		}, LeastLoaded())
+
+		vip := DistributionRequest{Dialog: DialogListResponseItem{Tags: []Tag{{Name: "vip"}}}}
+		assert.Equal(t, []int64{2}, pickIDs(t, strategy, vip, operators(0, 5, 0), 1))
+
+		telegram := DistributionRequest{Chat: &ChatsListResponseItem{Channel: &Channel{Type: ChannelTypeTelegram}}}
+		assert.Equal(t, []int64{3}, pickIDs(t, strategy, telegram, operators(0, 0, 5), 1))
+
+		assert.Equal(t, []int64{1}, pickIDs(t, strategy, DistributionRequest{}, operators(0, 0, 0), 1))
+	})
+
+	t.Run("sticky", func(t *testing.T) {
+		t.Parallel()
+
+		server := distributorServer{dialogs: []DialogListResponseItem{
+			{ID: 1, ChatID: 10, Responsible: &Responsible{ID: 2, Type: ResponsibleTypeUser}},
+			{ID: 2, ChatID: 10, Responsible: &Responsible{ID: 3, Type: ResponsibleTypeUser}},
+			{ID: 3, ChatID: 10, Responsible: &Responsible{ID: 7, Type: ResponsibleTypeBot}},
+			{ID: 4, ChatID: 11, Responsible: &Responsible{ID: 1, Type: ResponsibleTypeUser}},
+		}}
+		strategy := Sticky(server.client(t), LeastLoaded())
+
+		req := DistributionRequest{Dialog: DialogListResponseItem{ID: 5, ChatID: 10}}
+		assert.Equal(t, []int64{3}, pickIDs(t, strategy, req, operators(0, 0, 5), 1))
+		assert.Equal(t, []int64{1}, pickIDs(t, strategy, req, operators(0, 0), 1))
+	})
+}
+
+func TestDistributor(t *testing.T) {
+	t.Parallel()
+
+	users := []UserListResponseItem{
+		{ID: 1, IsActive: true, IsOnline: true},
+		{ID: 2, IsActive: true, IsOnline: true},
+		{ID: 3, IsActive: true, IsOnline: true, IsTechnicalAccount: true},
+		{ID: 4, IsActive: true, IsOnline: false},
+	}
+
+	t.Run("distributes unassigned dialogs", func(t *testing.T) {
+		t.Parallel()
+
+		server := distributorServer{users: users, dialogs: []DialogListResponseItem{
+			{ID: 1, ChatID: 10, Responsible: &Responsible{ID: 1, Type: ResponsibleTypeUser}},
+			{ID: 2, ChatID: 10, Responsible: &Responsible{ID: 1, Type: ResponsibleTypeUser}},
+			{ID: 3, ChatID: 11},
+			{ID: 4, ChatID: 12},
+			{ID: 5, ChatID: 13},
+		}}
+
+		var assigned []int64
+		d := NewDistributor(server.client(t), WithAssignmentHandler(func(_ context.Context, a Assignment) {
+			assigned = append(assigned, a.DialogID)
+		}))
+		require.NoError(t, d.RefreshLoad(context.Background()))
+		assert.Equal(t, map[int64]int{1: 2}, d.Load())
+
+		assignments, err := d.DistributeUnassigned(context.Background())
+		require.NoError(t, err)
+		require.Len(t, assignments, 3)
+		assert.Equal(t, []string{"3:user=2,bot=0", "4:user=2,bot=0", "5:user=1,bot=0"}, server.assigned())
+		assert.Equal(t, []int64{3, 4, 5}, assigned)
+		assert.Equal(t, map[int64]int{1: 3, 2: 2}, d.Load())
+	})
+
+	t.Run("no operator", func(t *testing.T) {
+		t.Parallel()
+
+		server := distributorServer{users: users, dialogs: []DialogListResponseItem{
+			{ID: 1, ChatID: 10}, {ID: 2, ChatID: 10}, {ID: 3, ChatID: 10},
+		}}
+		d := NewDistributor(server.client(t), WithMaxOperatorLoad(1))
+		_, err := d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 1, ChatID: 10}})
+		require.NoError(t, err)
+		_, err = d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 2, ChatID: 10}})
+		require.NoError(t, err)
+
+		_, err = d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 3, ChatID: 10}})
+		require.ErrorIs(t, err, ErrNoOperator)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		t.Parallel()
+
+		// The dialog has been assigned since the request was made.
+		server := distributorServer{users: users, dialogs: []DialogListResponseItem{
+			{ID: 5, ChatID: 10, Responsible: &Responsible{ID: 4, Type: ResponsibleTypeUser}},
+		}}
+		d := NewDistributor(server.client(t))
+
+		_, err := d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 5, ChatID: 10}})
+
+		var conflict *AssignConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(4), conflict.Responsible.ID)
+		assert.Empty(t, server.assigned())
+		assert.Equal(t, map[int64]int{4: 1}, d.Load())
+
+		_, err = d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 6, ChatID: 10}})
+		require.ErrorContains(t, err, "get dialog 6: not found")
+		assert.Empty(t, server.assigned())
+	})
+
+	t.Run("assigned meanwhile", func(t *testing.T) {
+		t.Parallel()
+
+		server := distributorServer{users: users, dialogs: []DialogListResponseItem{
+			{ID: 5, ChatID: 10}, {ID: 6, ChatID: 10},
+		}}
+		d := NewDistributor(server.client(t))
+
+		// The dialog is given back to the responsible assigned between the read and the assignment.
+		server.meanwhile = &Responsible{ID: 4, Type: ResponsibleTypeUser}
+		_, err := d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 5, ChatID: 10}})
+
+		var conflict *AssignConflictError
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, int64(4), conflict.Responsible.ID)
+		assert.Equal(t, []string{"5:user=1,bot=0", "5:user=4,bot=0"}, server.assigned())
+		assert.Equal(t, map[int64]int{4: 1}, d.Load())
+
+		server.meanwhile = &Responsible{ID: 7, Type: ResponsibleTypeBot}
+		_, err = d.Distribute(context.Background(), DistributionRequest{Dialog: DialogListResponseItem{ID: 6, ChatID: 10}})
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, ResponsibleTypeBot, conflict.Responsible.Type)
+		assert.Equal(t, []string{"5:user=1,bot=0", "5:user=4,bot=0", "6:user=1,bot=0", "6:user=0,bot=7"},
+			server.assigned())
+		assert.Equal(t, map[int64]int{4: 1}, d.Load())
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		server := distributorServer{users: users, dialogs: []DialogListResponseItem{{ID: 1, ChatID: 10}}}
+
+		var errs []error
+		d := NewDistributor(server.client(t), WithDistributorErrorHandler(func(_ context.Context, err error) {
+			errs = append(errs, err)
+		}))
+
+		var passed int
+		handler := d.Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+			passed++
+
+			return nil
+		})
+		handle := func(eventType ws.EventTypeSchema, data any) {
+			require.NoError(t, handler(context.Background(), ws.EventMessageFromEventsChannel{
+				Payload: ws.EventSchema{Type: eventType, Data: data},
+			}))
+		}
+
+		handle(ws.EventTypeDialogOpened, ws.DialogDataSchema{Dialog: ws.DialogEventSchema{Id: 1, Chat: &ws.ChatSchema{Id: 10}}})
+		d.Wait()
+		handle(ws.EventTypeDialogOpened, ws.DialogDataSchema{Dialog: ws.DialogEventSchema{
+			Id: 2, Chat: &ws.ChatSchema{Id: 11}, Responsible: &ws.ResponsibleSchema{Id: 1, Type: ws.ResponsibleTypeUser},
+		}})
+		assert.Equal(t, []string{"1:user=1,bot=0"}, server.assigned())
+		assert.Equal(t, map[int64]int{1: 2}, d.Load())
+
+		handle(ws.EventTypeDialogAssign, ws.DialogAssignDataSchema{Dialog: ws.DialogEventSchema{
+			Id: 1, Responsible: &ws.ResponsibleSchema{Id: 2, Type: ws.ResponsibleTypeUser},
+		}})
+		assert.Equal(t, map[int64]int{1: 1, 2: 1}, d.Load())
+
+		handle(ws.EventTypeDialogClosed, ws.DialogDataSchema{Dialog: ws.DialogEventSchema{Id: 2}})
+		assert.Equal(t, map[int64]int{2: 1}, d.Load())
+
+		assert.Empty(t, errs)
+		assert.Equal(t, 4, passed)
+	})
+}
@@ -0,0 +1,351 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDownloadURLTTL is how long a resolved download URL is reused.
+	DefaultDownloadURLTTL = 5 * time.Minute
+	// DefaultDownloadResumes is how many times a download is resumed after the connection breaks.
+	DefaultDownloadResumes = 3
+)
+
+// Downloader downloads files through the temporary URLs returned by GetFileUrl.
+// Resolved URLs are cached for the configured TTL and resolved again when the storage rejects
+// an expired link with 403 or 404. Downloader is safe for concurrent use.
+type Downloader struct {
+	client  ClientWithResponsesInterface
+	doer    HttpRequestDoer
+	ttl     time.Duration
+	resumes int
+	now     func() time.Time
+
+	mu   sync.Mutex
+	urls map[FileIDPath]cached[FileWithUrl]
+}
+
+// DownloaderOption configures a Downloader.
+type DownloaderOption func(*Downloader)
+
+// WithDownloadURLTTL sets how long resolved download URLs are cached.
+func WithDownloadURLTTL(ttl time.Duration) DownloaderOption {
+	return func(d *Downloader) {
+		d.ttl = ttl
+	}
+}
+
+// WithDownloadResumes sets how many times a download is resumed after the connection breaks.
+func WithDownloadResumes(n int) DownloaderOption {
+	return func(d *Downloader) {
+		d.resumes = n
+	}
+}
+
+// WithDownloadHTTPClient sets the doer that fetches file contents.
+// By default the doer of the client, including its middlewares, is used.
+func WithDownloadHTTPClient(doer HttpRequestDoer) DownloaderOption {
+	return func(d *Downloader) {
+		d.doer = doer
+	}
+}
+
+// NewDownloader creates a Downloader that resolves file URLs with the given client.
+func NewDownloader(client ClientWithResponsesInterface, opts ...DownloaderOption) *Downloader {
+	d := &Downloader{
+		client:  client,
+		doer:    clientDoer(client),
+		ttl:     DefaultDownloadURLTTL,
+		resumes: DefaultDownloadResumes,
+		now:     time.Now,
+		urls:    make(map[FileIDPath]cached[FileWithUrl]),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// clientDoer returns the doer with the middlewares configured for the client.
+func clientDoer(client ClientWithResponsesInterface) HttpRequestDoer {
+	if c, ok := client.(*ClientWithResponses); ok {
+		if inner, ok := c.ClientInterface.(*Client); ok && inner.Client != nil {
+			return inner.Client
+		}
+	}
+
+	return &http.Client{}
+}
+
+// DownloadOption configures a single download.
+type DownloadOption func(*downloadOptions)
+
+type downloadOptions struct {
+	offset int64
+	length int64
+}
+
+// WithDownloadRange downloads length bytes starting at offset. A length of 0 reads to the end of the file.
+func WithDownloadRange(offset, length int64) DownloadOption {
+	return func(o *downloadOptions) {
+		o.offset = offset
+		o.length = length
+	}
+}
+
+// FileURL returns the file with its download URL, resolving it unless a cached URL is still valid.
+func (d *Downloader) FileURL(ctx context.Context, fileID FileIDPath) (FileWithUrl, error) {
+	file, _, err := d.fileURL(ctx, fileID)
+
+	return file, err
+}
+
+// Invalidate drops the cached URL of the file.
+func (d *Downloader) Invalidate(fileID FileIDPath) {
+	d.mu.Lock()
+	delete(d.urls, fileID)
+	d.mu.Unlock()
+}
+
+func (d *Downloader) fileURL(ctx context.Context, fileID FileIDPath) (FileWithUrl, bool, error) {
+	d.mu.Lock()
+	entry, ok := d.urls[fileID]
+	d.mu.Unlock()
+
+	if ok && d.now().Before(entry.expires) {
+		return entry.value, true, nil
+	}
+
+	resp, err := d.client.GetFileUrlWithResponse(ctx, fileID)
+	if err = ExtractError(resp, err); err != nil {
+		return FileWithUrl{}, false, fmt.Errorf("get file url: %w", err)
+	}
+	if resp.JSON200 == nil {
+		return FileWithUrl{}, false, fmt.Errorf("get file url: unexpected response %s", resp.Status())
+	}
+
+	d.mu.Lock()
+	d.urls[fileID] = cached[FileWithUrl]{value: *resp.JSON200, expires: d.now().Add(d.ttl)}
+	d.mu.Unlock()
+
+	return *resp.JSON200, false, nil
+}
+
+// DownloadFile streams the file contents. If the connection breaks, the download is resumed
+// with a range request from the last byte received. A body shorter than File.Size is treated
+// as a broken connection, so a successful read to io.EOF returns exactly the expected bytes.
+func (d *Downloader) DownloadFile(
+	ctx context.Context, fileID FileIDPath, opts ...DownloadOption,
+) (io.ReadCloser, FileWithUrl, error) {
+	var o downloadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	end := int64(-1)
+	if o.length > 0 {
+		end = o.offset + o.length
+	}
+
+	body, file, err := d.open(ctx, fileID, o.offset, end)
+	if err != nil {
+		return nil, FileWithUrl{}, err
+	}
+
+	if end < 0 && file.Size > 0 {
+		end = int64(file.Size)
+	}
+
+	return &downloadReader{ctx: ctx, d: d, fileID: fileID, body: body, pos: o.offset, end: end}, file, nil
+}
+
+// SaveFile downloads the file to path. The contents are written to path + ".part" first, and a partial
+// file left by an interrupted download is resumed. The file is moved to path once its size matches File.Size.
+func (d *Downloader) SaveFile(ctx context.Context, fileID FileIDPath, path string) (FileWithUrl, error) {
+	file, err := d.FileURL(ctx, fileID)
+	if err != nil {
+		return FileWithUrl{}, err
+	}
+
+	part := path + ".part"
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return FileWithUrl{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return FileWithUrl{}, err
+	}
+
+	offset := info.Size()
+	if offset > int64(file.Size) {
+		offset = 0
+	}
+	if err = f.Truncate(offset); err != nil {
+		return FileWithUrl{}, err
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return FileWithUrl{}, err
+	}
+
+	if offset < int64(file.Size) {
+		body, downloaded, err := d.DownloadFile(ctx, fileID, WithDownloadRange(offset, 0))
+		if err != nil {
+			return FileWithUrl{}, err
+		}
+		file = downloaded
+
+		_, err = io.Copy(f, body)
+		_ = body.Close()
+		if err != nil {
+			return FileWithUrl{}, err
+		}
+	}
+
+	written, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return FileWithUrl{}, err
+	}
+	if written != int64(file.Size) {
+		return FileWithUrl{}, fmt.Errorf("download file %s: saved %d bytes, expected %d", fileID, written, file.Size)
+	}
+
+	if err = f.Close(); err != nil {
+		return FileWithUrl{}, err
+	}
+
+	return file, os.Rename(part, path)
+}
+
+// open requests the bytes from start to end, or to the end of the file if end is -1.
+func (d *Downloader) open(ctx context.Context, fileID FileIDPath, start, end int64) (io.ReadCloser, FileWithUrl, error) {
+	file, fromCache, err := d.fileURL(ctx, fileID)
+	if err != nil {
+		return nil, FileWithUrl{}, err
+	}
+
+	resp, err := d.get(ctx, file.Url, start, end)
+	if err != nil {
+		return nil, FileWithUrl{}, fmt.Errorf("download file %s: %w", fileID, err)
+	}
+
+	if fromCache && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+		_ = resp.Body.Close()
+		d.Invalidate(fileID)
+
+		if file, _, err = d.fileURL(ctx, fileID); err != nil {
+			return nil, FileWithUrl{}, err
+		}
+		if resp, err = d.get(ctx, file.Url, start, end); err != nil {
+			return nil, FileWithUrl{}, fmt.Errorf("download file %s: %w", fileID, err)
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, file, nil
+	case http.StatusOK:
+		// The storage ignored the range and sent the whole file.
+		if _, err = io.CopyN(io.Discard, resp.Body, start); err != nil {
+			_ = resp.Body.Close()
+
+			return nil, FileWithUrl{}, fmt.Errorf("download file %s: %w", fileID, err)
+		}
+
+		return resp.Body, file, nil
+	}
+
+	_ = resp.Body.Close()
+
+	return nil, FileWithUrl{}, fmt.Errorf("download file %s: unexpected status %s", fileID, resp.Status)
+}
+
+func (d *Downloader) get(ctx context.Context, url string, start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case end >= 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	case start > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+
+	return d.doer.Do(req)
+}
+
+// downloadReader reads the file from pos to end, reopening the download when the body breaks off early.
+type downloadReader struct {
+	ctx     context.Context
+	d       *Downloader
+	fileID  FileIDPath
+	body    io.ReadCloser
+	pos     int64
+	end     int64
+	resumes int
+	err     error
+}
+
+func (r *downloadReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	for {
+		if r.end >= 0 && r.pos >= r.end {
+			return 0, io.EOF
+		}
+		if r.end >= 0 && int64(len(p)) > r.end-r.pos {
+			p = p[:r.end-r.pos]
+		}
+
+		n, err := r.body.Read(p)
+		r.pos += int64(n)
+		if err == nil || (errors.Is(err, io.EOF) && (r.end < 0 || r.pos >= r.end)) {
+			return n, err
+		}
+		if ctxErr := r.ctx.Err(); ctxErr != nil {
+			return n, ctxErr
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if r.resumes >= r.d.resumes {
+			r.err = fmt.Errorf("download file %s: %w", r.fileID, err)
+
+			return n, r.err
+		}
+		r.resumes++
+
+		_ = r.body.Close()
+		body, _, openErr := r.d.open(r.ctx, r.fileID, r.pos, r.end)
+		if openErr != nil {
+			r.body, r.err = http.NoBody, openErr
+
+			return n, r.err
+		}
+		r.body = body
+
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *downloadReader) Close() error {
+	return r.body.Close()
+}
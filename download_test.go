@@ -0,0 +1,206 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const downloadContent = "0123456789abcdefghij"
+
+type downloadServer struct {
+	fakeAPI
+	// resolved counts GetFileUrl requests; each returns a new URL version.
+	resolved int
+	ranges   []string
+	// expired rejects URLs of versions lower than it.
+	expired int
+	// truncate cuts the next storage responses to the given number of bytes.
+	truncate []int
+}
+
+func (s *downloadServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		if req.URL.Host == "example.com" {
+			if !strings.HasPrefix(req.URL.Path, "/files/") {
+				return unexpectedRequest(t, req)
+			}
+			s.resolved++
+
+			return http.StatusOK, fmt.Sprintf(
+				`{"id": %q, "size": %d, "type": "file", "url": "https://storage.example.com/file?v=%d"}`,
+				uuid.New(), len(downloadContent), s.resolved,
+			)
+		}
+
+		if req.URL.Host != "storage.example.com" {
+			return unexpectedRequest(t, req)
+		}
+		if version, _ := strconv.Atoi(req.URL.Query().Get("v")); version < s.expired {
+			return http.StatusForbidden, ""
+		}
+
+		content, status := downloadContent, http.StatusOK
+		s.ranges = append(s.ranges, req.Header.Get("Range"))
+		if r := req.Header.Get("Range"); r != "" {
+			var start, end int
+			if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil {
+				end = len(content) - 1
+			}
+			content, status = content[start:end+1], http.StatusPartialContent
+		}
+		if len(s.truncate) > 0 {
+			content, s.truncate = content[:s.truncate[0]], s.truncate[1:]
+		}
+
+		return status, content
+	})
+}
+
+func TestDownloader(t *testing.T) {
+	t.Parallel()
+
+	fileID := uuid.New()
+
+	t.Run("caches the file url", func(t *testing.T) {
+		t.Parallel()
+
+		var server downloadServer
+		d := NewDownloader(server.client(t))
+
+		for range 2 {
+			body, file, err := d.DownloadFile(context.Background(), fileID)
+			require.NoError(t, err)
+			content, err := io.ReadAll(body)
+			require.NoError(t, err)
+			require.NoError(t, body.Close())
+
+			assert.Equal(t, downloadContent, string(content))
+			assert.Equal(t, len(downloadContent), file.Size)
+		}
+		assert.Equal(t, 1, server.resolved)
+	})
+
+	t.Run("refreshes an expired url", func(t *testing.T) {
+		t.Parallel()
+
+		var server downloadServer
+		d := NewDownloader(server.client(t))
+
+		_, err := d.FileURL(context.Background(), fileID)
+		require.NoError(t, err)
+		server.expired = 2
+
+		body, file, err := d.DownloadFile(context.Background(), fileID)
+		require.NoError(t, err)
+		defer body.Close()
+
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, downloadContent, string(content))
+		assert.Equal(t, "https://storage.example.com/file?v=2", file.Url)
+		assert.Equal(t, 2, server.resolved)
+	})
+
+	t.Run("does not refresh a fresh url", func(t *testing.T) {
+		t.Parallel()
+
+		server := downloadServer{expired: 10}
+		_, _, err := NewDownloader(server.client(t)).DownloadFile(context.Background(), fileID)
+		require.ErrorContains(t, err, "unexpected status 403")
+		assert.Equal(t, 1, server.resolved)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		t.Parallel()
+
+		var server downloadServer
+		body, _, err := NewDownloader(server.client(t)).DownloadFile(
+			context.Background(), fileID, WithDownloadRange(2, 3),
+		)
+		require.NoError(t, err)
+		defer body.Close()
+
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, "234", string(content))
+		assert.Equal(t, []string{"bytes=2-4"}, server.ranges)
+	})
+
+	t.Run("resumes a broken download", func(t *testing.T) {
+		t.Parallel()
+
+		server := downloadServer{truncate: []int{5, 4}}
+		body, _, err := NewDownloader(server.client(t)).DownloadFile(context.Background(), fileID)
+		require.NoError(t, err)
+		defer body.Close()
+
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		assert.Equal(t, downloadContent, string(content))
+		assert.Equal(t, []string{"", "bytes=5-19", "bytes=9-19"}, server.ranges)
+	})
+
+	t.Run("gives up after the resume limit", func(t *testing.T) {
+		t.Parallel()
+
+		server := downloadServer{truncate: []int{5, 4}}
+		body, _, err := NewDownloader(server.client(t), WithDownloadResumes(1)).DownloadFile(
+			context.Background(), fileID,
+		)
+		require.NoError(t, err)
+		defer body.Close()
+
+		_, err = io.ReadAll(body)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
+
+func TestDownloaderSaveFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("resumes a partial file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "file.txt")
+		require.NoError(t, os.WriteFile(path+".part", []byte(downloadContent[:7]), 0o600))
+
+		var server downloadServer
+		file, err := NewDownloader(server.client(t)).SaveFile(context.Background(), uuid.New(), path)
+		require.NoError(t, err)
+		assert.Equal(t, len(downloadContent), file.Size)
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, downloadContent, string(content))
+		assert.Equal(t, []string{"bytes=7-"}, server.ranges)
+		assert.NoFileExists(t, path+".part")
+	})
+
+	t.Run("keeps an incomplete file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "file.txt")
+
+		server := downloadServer{truncate: []int{5}}
+		_, err := NewDownloader(server.client(t), WithDownloadResumes(0)).SaveFile(
+			context.Background(), uuid.New(), path,
+		)
+		require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.NoFileExists(t, path)
+
+		content, err := os.ReadFile(path + ".part")
+		require.NoError(t, err)
+		assert.Equal(t, downloadContent[:5], string(content))
+	})
+}
@@ -0,0 +1,255 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// EnumDecodingMode controls how responses carrying enum values unknown to the client are handled.
+type EnumDecodingMode int
+
+const (
+	// EnumDecodingLenient accepts unknown enum values and reports them to the hook.
+	EnumDecodingLenient EnumDecodingMode = iota
+	// EnumDecodingStrict fails the request with UnknownEnumError.
+	EnumDecodingStrict
+)
+
+// UnknownEnumValue is an enum value the client does not know about.
+type UnknownEnumValue struct {
+	// Type is the name of the enum type, e.g. ChannelType.
+	Type string
+	// Value is the value received from the server.
+	Value string
+	// Path is the JSON path of the value within the response body, e.g. [0].settings.text.creating.
+	Path string
+}
+
+// UnknownEnumHook receives unknown enum values found in responses.
+type UnknownEnumHook func(ctx context.Context, value UnknownEnumValue)
+
+// UnknownEnumError is returned in strict mode when a response contains unknown enum values.
+type UnknownEnumError struct {
+	Values []UnknownEnumValue
+}
+
+func (e *UnknownEnumError) Error() string {
+	parts := make([]string, 0, len(e.Values))
+	for _, v := range e.Values {
+		parts = append(parts, fmt.Sprintf("%s: unknown %s value %q", v.Path, v.Type, v.Value))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// WithEnumDecoding checks enum values in successful responses against the values known to the client.
+// In lenient mode unknown values are passed to the hook and the response is returned as is.
+// In strict mode they are passed to the hook too, and the request fails with UnknownEnumError.
+// The hook may be nil.
+func WithEnumDecoding(mode EnumDecodingMode, hook UnknownEnumHook) ClientOption {
+	return func(c *Client) error {
+		return WithMiddlewares(EnumDecoding(c.Server, mode, hook))(c)
+	}
+}
+
+// EnumDecoding is a middleware checking enum values in responses of the API at server, see WithEnumDecoding.
+func EnumDecoding(server string, mode EnumDecodingMode, hook UnknownEnumHook) Middleware {
+	var base []string
+	if u, err := url.Parse(server); err == nil {
+		base = pathSegments(u.Path)
+	}
+
+	return func(next HttpRequestDoer) HttpRequestDoer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err != nil || resp == nil || resp.StatusCode < 200 || resp.StatusCode >= 300 ||
+				!strings.Contains(resp.Header.Get("Content-Type"), "json") {
+				return resp, err
+			}
+
+			response, ok := findResponseType(base, req)
+			if !ok {
+				return resp, nil
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read response body: %w", err)
+			}
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+
+			body := reflect.New(response)
+			if err := json.Unmarshal(data, body.Interface()); err != nil {
+				// Malformed bodies are reported by the response parser.
+				return resp, nil
+			}
+
+			unknown := UnknownEnums(body.Interface())
+			if len(unknown) == 0 {
+				return resp, nil
+			}
+
+			if hook != nil {
+				for _, v := range unknown {
+					hook(req.Context(), v)
+				}
+			}
+
+			if mode == EnumDecodingStrict {
+				return nil, &UnknownEnumError{Values: unknown}
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+// responseRoute describes an API operation with a JSON response.
+type responseRoute struct {
+	method   string
+	segments []string
+	response reflect.Type
+}
+
+// responseRoutes lists the API operations returning JSON bodies with their paths relative to the server URL.
+// "*" matches a path parameter.
+var responseRoutes = []responseRoute{
+	{http.MethodPatch, []string{"dialogs", "*", "tags", "add"}, typeOf[EmptyResponse]()},
+	{http.MethodPatch, []string{"dialogs", "*", "tags", "delete"}, typeOf[EmptyResponse]()},
+	{http.MethodPost, []string{"chats", "*", "dialogs"}, typeOf[CreateDialogResponse]()},
+	{http.MethodPatch, []string{"dialogs", "*", "assign"}, typeOf[DialogAssignResponse]()},
+	{http.MethodDelete, []string{"dialogs", "*", "close"}, typeOf[EmptyResponse]()},
+	{http.MethodPatch, []string{"dialogs", "*", "unassign"}, typeOf[DialogUnassignResponse]()},
+	{http.MethodPut, []string{"files", "*", "meta"}, typeOf[UploadResponse]()},
+	{http.MethodDelete, []string{"my", "commands", "*"}, typeOf[EmptyResponse]()},
+	{http.MethodPut, []string{"my", "commands", "*"}, typeOf[CommandCreateResponse]()},
+	{http.MethodPost, []string{"files", "upload"}, typeOf[UploadResponse]()},
+	{http.MethodPost, []string{"files", "upload_by_url"}, typeOf[UploadResponse]()},
+	{http.MethodGet, []string{"files", "*"}, typeOf[FullFileResponse]()},
+	{http.MethodDelete, []string{"messages", "*"}, typeOf[EmptyResponse]()},
+	{http.MethodPatch, []string{"messages", "*"}, typeOf[EmptyResponse]()},
+	{http.MethodGet, []string{"my", "commands"}, typeOf[CommandsResponse]()},
+	{http.MethodPatch, []string{"my", "info"}, typeOf[EmptyResponse]()},
+	{http.MethodGet, []string{"bots"}, typeOf[BotsListResponse]()},
+	{http.MethodGet, []string{"channels"}, typeOf[ChannelsListResponse]()},
+	{http.MethodGet, []string{"chats"}, typeOf[ChatsListResponse]()},
+	{http.MethodGet, []string{"customers"}, typeOf[CustomersListResponse]()},
+	{http.MethodGet, []string{"dialogs"}, typeOf[DialogsListResponse]()},
+	{http.MethodGet, []string{"members"}, typeOf[ChatMemberListResponse]()},
+	{http.MethodGet, []string{"messages"}, typeOf[MessageListResponse]()},
+	{http.MethodPost, []string{"messages"}, typeOf[SendMessageResponse]()},
+	{http.MethodGet, []string{"users"}, typeOf[UserListResponse]()},
+}
+
+// findResponseType returns the type of the successful response to the request to the API with the base path.
+func findResponseType(base []string, req *http.Request) (reflect.Type, bool) {
+	segments := pathSegments(req.URL.Path)
+	if len(segments) < len(base) || !slices.Equal(segments[:len(base)], base) {
+		return nil, false
+	}
+	segments = segments[len(base):]
+
+	for _, r := range responseRoutes {
+		if r.matches(req.Method, segments) {
+			return r.response, true
+		}
+	}
+
+	return nil, false
+}
+
+// matches reports whether a request with the method and the path segments relative to the server URL is
+// to the operation.
+func (r responseRoute) matches(method string, segments []string) bool {
+	if r.method != method || len(segments) != len(r.segments) {
+		return false
+	}
+
+	for i, s := range r.segments {
+		if s != "*" && s != segments[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pathSegments splits a URL path into its segments.
+func pathSegments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+// UnknownEnums walks a decoded value and returns the enum values failing ValidateEnum.
+// Empty values are treated as missing and not reported.
+func UnknownEnums(v interface{}) []UnknownEnumValue {
+	var unknown []UnknownEnumValue
+	findUnknownEnums(&unknown, "", reflect.ValueOf(v))
+
+	return unknown
+}
+
+var enumValidatorType = typeOf[enumValidator]()
+
+func findUnknownEnums(unknown *[]UnknownEnumValue, path string, v reflect.Value) {
+	v = indirect(v)
+	if !v.IsValid() {
+		return
+	}
+
+	if v.Kind() == reflect.String && v.Type().Implements(enumValidatorType) {
+		if v.Len() > 0 && v.Interface().(enumValidator).ValidateEnum() != nil {
+			*unknown = append(*unknown, UnknownEnumValue{Type: v.Type().Name(), Value: v.String(), Path: path})
+		}
+
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			switch {
+			case name == "-":
+				continue
+			case f.Anonymous && name == "":
+				findUnknownEnums(unknown, path, v.Field(i))
+
+				continue
+			case name == "":
+				name = f.Name
+			}
+
+			if path != "" {
+				name = path + "." + name
+			}
+			findUnknownEnums(unknown, name, v.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			findUnknownEnums(unknown, fmt.Sprintf("%s[%d]", path, i), v.Index(i))
+		}
+	}
+}
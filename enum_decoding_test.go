@@ -0,0 +1,158 @@
+package bot_api_client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEnumDecoding(t *testing.T) {
+	t.Parallel()
+
+	const channels = `[
+		{"id": 1, "type": "telegram", "settings": {"text": {"creating": "both"}}},
+		{"id": 2, "type": "pigeon_post", "settings": {"text": {"creating": "sometimes"}}}
+	]`
+
+	newClient := func(t *testing.T, mode EnumDecodingMode, reported *[]UnknownEnumValue) *ClientWithResponses {
+		mockDoer := DoerFunc(func(_ *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(channels)),
+			}, nil
+		})
+
+		client, err := NewClientWithResponses(
+			"https://example.com/api/bot/v1/",
+			WithHTTPClient(mockDoer),
+			WithEnumDecoding(mode, func(_ context.Context, v UnknownEnumValue) {
+				*reported = append(*reported, v)
+			}),
+		)
+		require.NoError(t, err)
+
+		return client
+	}
+
+	expected := []UnknownEnumValue{
+		{Type: "ChannelFeature", Value: "sometimes", Path: "[1].settings.text.creating"},
+		{Type: "ChannelType", Value: "pigeon_post", Path: "[1].type"},
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		t.Parallel()
+
+		var reported []UnknownEnumValue
+		resp, err := newClient(t, EnumDecodingLenient, &reported).ListChannelsWithResponse(context.Background(), nil)
+		require.NoError(t, err)
+		require.NotNil(t, resp.JSON200)
+		assert.Len(t, *resp.JSON200, 2)
+		assert.Equal(t, ChannelType("pigeon_post"), (*resp.JSON200)[1].Type)
+		assert.ElementsMatch(t, expected, reported)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		t.Parallel()
+
+		var reported []UnknownEnumValue
+		_, err := newClient(t, EnumDecodingStrict, &reported).ListChannelsWithResponse(context.Background(), nil)
+
+		var enumErr *UnknownEnumError
+		require.ErrorAs(t, err, &enumErr)
+		assert.ElementsMatch(t, expected, enumErr.Values)
+		assert.ElementsMatch(t, expected, reported)
+		assert.Contains(t, err.Error(), `[1].type: unknown ChannelType value "pigeon_post"`)
+	})
+}
+
+func TestUnknownEnums(t *testing.T) {
+	t.Parallel()
+
+	body := SendMessageRequestBody{
+		ChatID: 1,
+		TransportAttachments: &MessageTransportAttachments{
+			Suggestions: []Suggestion{{Type: "carrier_pigeon", Title: "Send"}, {Type: SuggestionTypeText, Title: "Ok"}},
+		},
+	}
+
+	assert.Equal(t, []UnknownEnumValue{
+		{Type: "SuggestionType", Value: "carrier_pigeon", Path: "transport_attachments.suggestions[0].type"},
+	}, UnknownEnums(body))
+	assert.Empty(t, UnknownEnums(Channel{}), "empty values are not reported")
+}
+
+func TestResponseRoutes(t *testing.T) {
+	t.Parallel()
+
+	// Every operation of the generated client is sent to the API under a path prefix. The route found for it must
+	// give the type of its JSON200 field, or none if it has no JSON response.
+	var req *http.Request
+	mockDoer := DoerFunc(func(r *http.Request) (*http.Response, error) {
+		req = r
+
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody}, nil
+	})
+	client, err := NewClientWithResponses("https://example.com/api/bot/v1/", WithHTTPClient(mockDoer))
+	require.NoError(t, err)
+
+	base := []string{"api", "bot", "v1"}
+	var requests []*http.Request
+	v := reflect.ValueOf(client)
+	for i := 0; i < v.NumMethod(); i++ {
+		method := v.Type().Method(i)
+		if !strings.HasSuffix(method.Name, "WithResponse") {
+			continue
+		}
+
+		// The arguments follow the receiver and the context, up to the request editors.
+		args := []reflect.Value{reflect.ValueOf(context.Background())}
+		for j := 2; j < method.Type.NumIn()-1; j++ {
+			switch in := method.Type.In(j); {
+			case in.Kind() == reflect.String:
+				args = append(args, reflect.ValueOf("x").Convert(in))
+			case in.Kind() == reflect.Pointer:
+				args = append(args, reflect.New(in.Elem()))
+			case in == typeOf[io.Reader]():
+				args = append(args, reflect.ValueOf(strings.NewReader("{}")))
+			default:
+				args = append(args, reflect.Zero(in))
+			}
+		}
+		req = nil
+		v.Method(i).Call(args)
+		require.NotNil(t, req, method.Name)
+		requests = append(requests, req)
+
+		response, ok := findResponseType(base, req)
+		field, hasJSON := method.Type.Out(0).Elem().FieldByName("JSON200")
+		if !hasJSON {
+			assert.False(t, ok, "%s: %s %s has no JSON response", method.Name, req.Method, req.URL.Path)
+
+			continue
+		}
+		if assert.True(t, ok, "%s: no route for %s %s", method.Name, req.Method, req.URL.Path) {
+			assert.Equal(t, field.Type.Elem(), response, method.Name)
+		}
+	}
+
+	// Every route is used by an operation.
+	for _, r := range responseRoutes {
+		assert.True(t, slices.ContainsFunc(requests, func(req *http.Request) bool {
+			return r.matches(req.Method, pathSegments(req.URL.Path)[len(base):])
+		}), "%s %s", r.method, strings.Join(r.segments, "/"))
+	}
+
+	_, ok := findResponseType(base, httptest.NewRequest(http.MethodGet, "/api/bot/v1/other/files/x", nil))
+	assert.False(t, ok, "paths are matched in full")
+	_, ok = findResponseType(base, httptest.NewRequest(http.MethodGet, "/files/x", nil))
+	assert.False(t, ok, "paths outside the server URL are not matched")
+}
@@ -0,0 +1,71 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI is embedded in the fake servers of the tests. mu is held while a request is handled,
+// so the servers use it to guard their own fields too.
+type fakeAPI struct {
+	mu sync.Mutex
+}
+
+// serve returns a client whose requests, to other hosts too, are answered by handle with the lock held.
+// handle returns the status and the body of the response: a string is sent as is, an error is returned by
+// the transport instead of a response and any other value is encoded as JSON. It runs on the goroutines making
+// the requests, where require cannot stop the test, so it reports failures with assert and answers the requests
+// it does not expect with unexpectedRequest.
+func (a *fakeAPI) serve(t *testing.T, handle func(req *http.Request) (int, any)) *ClientWithResponses {
+	mockDoer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		a.mu.Lock()
+		status, body := handle(req)
+		a.mu.Unlock()
+
+		if err, ok := body.(error); ok {
+			return nil, err
+		}
+
+		data, ok := body.(string)
+		if !ok {
+			encoded, err := json.Marshal(body)
+			if !assert.NoError(t, err) {
+				return nil, err
+			}
+			data = string(encoded)
+		}
+
+		return &http.Response{
+			StatusCode: status,
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte(data))),
+		}, nil
+	})
+
+	client, err := NewClientWithResponses("https://example.com", WithHTTPClient(mockDoer))
+	require.NoError(t, err)
+
+	return client
+}
+
+// decodeRequest decodes the JSON body of req into v. It reports the error and returns false if that fails;
+// the request is then to be answered with http.StatusBadRequest.
+func decodeRequest(t *testing.T, req *http.Request, v any) bool {
+	return assert.NoError(t, json.NewDecoder(req.Body).Decode(v))
+}
+
+// unexpectedRequest reports a request the fake server does not expect and returns the response to it.
+func unexpectedRequest(t *testing.T, req *http.Request) (int, any) {
+	assert.Failf(t, "unexpected request", "%s %s", req.Method, req.URL)
+
+	return http.StatusInternalServerError, ErrorResponse{Errors: []string{"unexpected request"}}
+}
@@ -0,0 +1,473 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+)
+
+const (
+	// DefaultImageQuality is the JPEG quality images are re-encoded with before it is lowered to fit the byte budget.
+	DefaultImageQuality = 90
+	// minImageQuality is the lowest JPEG quality tried before the image is scaled down further.
+	minImageQuality = 50
+	// minImageSide is the smallest width or height an image is scaled down to.
+	minImageSide = 16
+	// previewQuality is the JPEG quality of previews.
+	previewQuality = 75
+)
+
+// ErrImageTooLarge is returned when an image cannot be made to fit the byte budget.
+var ErrImageTooLarge = errors.New("image does not fit the byte budget")
+
+// ImageOptions configures image preprocessing.
+type ImageOptions struct {
+	// MaxBytes is the byte budget of the encoded image. Zero means no budget.
+	MaxBytes int64
+	// MaxWidth and MaxHeight limit the dimensions of the image. Zero means no limit.
+	MaxWidth  int
+	MaxHeight int
+	// PreviewSize is the longest side of the JPEG preview in pixels. Zero disables the preview.
+	PreviewSize int
+}
+
+// ImageOptionsForChannel returns options that fit images into the image size limit of the channel.
+func ImageOptionsForChannel(settings ChannelSettings) ImageOptions {
+	var opts ImageOptions
+	if settings.Image.MaxItemSize != nil {
+		opts.MaxBytes = *settings.Image.MaxItemSize
+	}
+
+	return opts
+}
+
+// ImageReport describes what PreprocessImage changed.
+type ImageReport struct {
+	// Name is the name of the uploaded file; it is set when the image is preprocessed by an upload.
+	Name string
+	// Format is "jpeg" or "png". It is empty when the content is not a supported image and was left unchanged.
+	Format         string
+	OriginalSize   int64
+	Size           int64
+	OriginalWidth  int
+	OriginalHeight int
+	Width          int
+	Height         int
+	// MetadataStripped is set when EXIF, XMP, IPTC, comments or PNG text chunks were removed.
+	MetadataStripped bool
+	// Rotated is set when the EXIF orientation was applied to the pixels before the metadata was removed.
+	Rotated bool
+	Resized bool
+	// Reencoded is set when the image was decoded and encoded again; Quality is the JPEG quality used.
+	Reencoded bool
+	Quality   int
+	// Preview is a small JPEG version of the image.
+	Preview []byte
+}
+
+// Changed reports whether the image content was modified.
+func (r ImageReport) Changed() bool {
+	return r.MetadataStripped || r.Rotated || r.Resized || r.Reencoded
+}
+
+// WithImagePreprocessing runs PreprocessImage on JPEG and PNG files before they are uploaded.
+// The file is read into memory to be processed; other files are streamed unchanged.
+// Report, which may be nil, is called with the result for every image.
+func WithImagePreprocessing(opts ImageOptions, report func(ImageReport)) UploadOption {
+	return func(o *uploadOptions) {
+		o.image = &opts
+		o.imageReport = report
+	}
+}
+
+// PreprocessImage prepares a JPEG or PNG image for upload:
+//   - metadata that may reveal private information, such as EXIF with GPS coordinates, is removed;
+//   - the EXIF orientation is applied to the pixels so the image is not displayed rotated;
+//   - the image is scaled down, keeping the aspect ratio, to fit MaxWidth and MaxHeight;
+//   - the JPEG quality is lowered and the image is scaled down further until it fits MaxBytes.
+//
+// Images that only carry metadata are not re-encoded. Content of other formats is returned unchanged.
+func PreprocessImage(data []byte, opts ImageOptions) ([]byte, ImageReport, error) {
+	report := ImageReport{OriginalSize: int64(len(data)), Size: int64(len(data))}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return data, report, nil
+	}
+	report.Format = format
+	report.OriginalWidth, report.OriginalHeight = cfg.Width, cfg.Height
+	report.Width, report.Height = cfg.Width, cfg.Height
+
+	orientation := 1
+	var stripped []byte
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+		stripped, err = stripJPEGMetadata(data)
+	} else {
+		stripped, err = stripPNGMetadata(data)
+	}
+	if err != nil {
+		return nil, report, fmt.Errorf("strip image metadata: %w", err)
+	}
+	report.MetadataStripped = len(stripped) != len(data)
+
+	width, height := orientedSize(cfg.Width, cfg.Height, orientation)
+	targetW, targetH := fitSize(width, height, opts.MaxWidth, opts.MaxHeight)
+	fits := opts.MaxBytes <= 0 || int64(len(stripped)) <= opts.MaxBytes
+
+	var img image.Image
+	if orientation != 1 || targetW != width || targetH != height || !fits || opts.PreviewSize > 0 {
+		if img, _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return nil, report, fmt.Errorf("decode image: %w", err)
+		}
+		img = orient(img, orientation)
+	}
+
+	result := stripped
+	if orientation != 1 || targetW != width || targetH != height || !fits {
+		report.Rotated = orientation != 1
+		if result, err = encodeToFit(img, format, targetW, targetH, opts.MaxBytes, &report); err != nil {
+			return nil, report, err
+		}
+		report.Reencoded = true
+	}
+	report.Size = int64(len(result))
+
+	if opts.PreviewSize > 0 {
+		w, h := fitSize(img.Bounds().Dx(), img.Bounds().Dy(), opts.PreviewSize, opts.PreviewSize)
+		var buf bytes.Buffer
+		if err = jpeg.Encode(&buf, onWhite(resize(img, w, h)), &jpeg.Options{Quality: previewQuality}); err != nil {
+			return nil, report, fmt.Errorf("encode preview: %w", err)
+		}
+		report.Preview = buf.Bytes()
+	}
+
+	return result, report, nil
+}
+
+// preprocessUpload reads r and preprocesses the image, returning a reader of the result and its size.
+func preprocessUpload(name string, r io.Reader, opts ImageOptions, reportFn func(ImageReport)) (io.Reader, int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read file: %w", err)
+	}
+
+	data, report, err := PreprocessImage(data, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if reportFn != nil && report.Format != "" {
+		report.Name = name
+		reportFn(report)
+	}
+
+	return bytes.NewReader(data), int64(len(data)), nil
+}
+
+// encodeToFit scales the image to width x height and encodes it, lowering the JPEG quality
+// and scaling the image down further until the result fits maxBytes.
+func encodeToFit(img image.Image, format string, width, height int, maxBytes int64, report *ImageReport) ([]byte, error) {
+	for {
+		scaled := img
+		if width != img.Bounds().Dx() || height != img.Bounds().Dy() {
+			scaled = resize(img, width, height)
+			report.Resized = true
+		}
+		report.Width, report.Height = width, height
+
+		var (
+			buf  bytes.Buffer
+			size int64
+		)
+		if format == "png" {
+			if err := (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, scaled); err != nil {
+				return nil, fmt.Errorf("encode image: %w", err)
+			}
+			size = int64(buf.Len())
+			if maxBytes <= 0 || size <= maxBytes {
+				return buf.Bytes(), nil
+			}
+		} else {
+			for quality := DefaultImageQuality; quality >= minImageQuality; quality -= 10 {
+				buf.Reset()
+				if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: quality}); err != nil {
+					return nil, fmt.Errorf("encode image: %w", err)
+				}
+				report.Quality = quality
+				size = int64(buf.Len())
+				if maxBytes <= 0 || size <= maxBytes {
+					return buf.Bytes(), nil
+				}
+			}
+		}
+
+		// The encoded size is roughly proportional to the number of pixels.
+		scale := math.Min(math.Sqrt(float64(maxBytes)/float64(size))*0.9, 0.9)
+		width, height = int(float64(width)*scale), int(float64(height)*scale)
+		if width < minImageSide || height < minImageSide {
+			return nil, fmt.Errorf("%w: %d bytes at the smallest size, the budget is %d", ErrImageTooLarge, size, maxBytes)
+		}
+	}
+}
+
+// fitSize scales width x height down to fit maxWidth x maxHeight, keeping the aspect ratio.
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = math.Min(scale, float64(maxWidth)/float64(width))
+	}
+	if maxHeight > 0 && height > maxHeight {
+		scale = math.Min(scale, float64(maxHeight)/float64(height))
+	}
+	if scale == 1 {
+		return width, height
+	}
+
+	return max(int(math.Round(float64(width)*scale)), 1), max(int(math.Round(float64(height)*scale)), 1)
+}
+
+// resize scales the image with a box filter, averaging the source pixels covered by each target pixel.
+func resize(src image.Image, width, height int) *image.NRGBA {
+	b := src.Bounds()
+	in := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		y0, y1 := y*b.Dy()/height, max((y+1)*b.Dy()/height, y*b.Dy()/height+1)
+		for x := range width {
+			x0, x1 := x*b.Dx()/width, max((x+1)*b.Dx()/width, x*b.Dx()/width+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := in.NRGBAAt(sx, sy)
+					// Weight colors by alpha so transparent pixels do not darken the result.
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+
+			if a > 0 {
+				out.SetNRGBA(x, y, color.NRGBA{R: uint8(r / a), G: uint8(g / a), B: uint8(bl / a), A: uint8(a / n)})
+			}
+		}
+	}
+
+	return out
+}
+
+// onWhite composes the image over a white background for formats without transparency.
+func onWhite(img image.Image) image.Image {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	return out
+}
+
+// orientedSize returns the displayed size of an image with the EXIF orientation applied.
+func orientedSize(width, height, orientation int) (int, int) {
+	if orientation >= 5 && orientation <= 8 {
+		return height, width
+	}
+
+	return width, height
+}
+
+// orient applies an EXIF orientation (1-8) to the pixels.
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := orientedSize(w, h, orientation)
+	out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored horizontally
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90° counterclockwise
+				dx, dy = y, w-1-x
+			}
+			out.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return out
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG image, or 1 if it has none.
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	_ = walkJPEGSegments(data, func(marker byte, payload []byte) bool {
+		if marker != 0xE1 || !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return true
+		}
+		if o, ok := exifOrientation(payload[6:]); ok {
+			orientation = o
+		}
+
+		return false
+	})
+
+	return orientation
+}
+
+// exifOrientation reads the orientation tag from the first IFD of TIFF-formatted EXIF data.
+func exifOrientation(tiff []byte) (int, bool) {
+	if len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) || offset < 0 {
+		return 0, false
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := range count {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:])), true
+		}
+	}
+
+	return 0, false
+}
+
+// strippedJPEGMarkers are the segments removed from JPEG images: EXIF and XMP (APP1),
+// IPTC (APP13) and comments. JFIF, ICC profiles and Adobe color transforms are kept.
+var strippedJPEGMarkers = map[byte]bool{0xE1: true, 0xED: true, 0xFE: true}
+
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+
+	rest, err := walkJPEGSegmentsRaw(data, func(marker byte, segment []byte) {
+		if !strippedJPEGMarkers[marker] {
+			out = append(out, segment...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(out, rest...), nil
+}
+
+// walkJPEGSegments calls fn with the payload of each segment before the image data until fn returns false.
+func walkJPEGSegments(data []byte, fn func(marker byte, payload []byte) bool) error {
+	stop := false
+	_, err := walkJPEGSegmentsRaw(data, func(marker byte, segment []byte) {
+		if !stop {
+			stop = !fn(marker, segment[4:])
+		}
+	})
+
+	return err
+}
+
+// walkJPEGSegmentsRaw calls fn with each complete segment, marker included, before the start of scan
+// and returns the rest of the image starting at the start of scan marker.
+func walkJPEGSegmentsRaw(data []byte, fn func(marker byte, segment []byte)) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errors.New("not a JPEG image")
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		}
+
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// Fill byte.
+			pos++
+
+			continue
+		}
+		if marker == 0xDA {
+			return data[pos:], nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, fmt.Errorf("invalid JPEG segment length at offset %d", pos)
+		}
+		fn(marker, data[pos:pos+2+length])
+		pos += 2 + length
+	}
+
+	return nil, errors.New("JPEG image has no image data")
+}
+
+// strippedPNGChunks are the ancillary chunks removed from PNG images.
+var strippedPNGChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	const signatureLen = 8
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:signatureLen]...)
+
+	for pos := signatureLen; pos < len(data); {
+		if pos+12 > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk at offset %d", pos)
+		}
+
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk length at offset %d", pos)
+		}
+
+		if !strippedPNGChunks[string(data[pos+4:pos+8])] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+
+	return out, nil
+}
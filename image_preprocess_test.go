@@ -0,0 +1,210 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noisyImage returns an image that compresses poorly, so byte budgets are hard to meet.
+func noisyImage(width, height int) *image.NRGBA {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(rnd.IntN(256)), G: uint8(x), B: uint8(y), A: 255})
+		}
+	}
+
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	return buf.Bytes()
+}
+
+// withEXIF inserts an APP1 segment with the orientation tag and a comment after the SOI marker.
+func withEXIF(data []byte, orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00\x01\x00")
+	entry := make([]byte, 12)
+	binary.LittleEndian.PutUint16(entry[0:], 0x0112)
+	binary.LittleEndian.PutUint16(entry[2:], 3)
+	binary.LittleEndian.PutUint32(entry[4:], 1)
+	binary.LittleEndian.PutUint16(entry[8:], orientation)
+	payload := append(append([]byte("Exif\x00\x00"), tiff...), entry...)
+
+	segment := func(marker byte, payload []byte) []byte {
+		header := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)+2))
+
+		return append(header, payload...)
+	}
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment(0xE1, payload)...)
+	out = append(out, segment(0xFE, []byte("taken at home"))...)
+
+	return append(out, data[2:]...)
+}
+
+// withTextChunk inserts a tEXt chunk after the IHDR chunk of a PNG image.
+func withTextChunk(data []byte) []byte {
+	text := []byte("Location\x00home")
+	chunk := make([]byte, 8, 12+len(text))
+	binary.BigEndian.PutUint32(chunk, uint32(len(text)))
+	copy(chunk[4:], "tEXt")
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	const ihdrEnd = 8 + 12 + 13
+
+	return append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+func TestPreprocessImage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("strips metadata without re-encoding", func(t *testing.T) {
+		t.Parallel()
+
+		original := encodeJPEG(t, noisyImage(40, 20))
+		data, report, err := PreprocessImage(withEXIF(original, 1), ImageOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, original, data)
+		assert.True(t, report.MetadataStripped)
+		assert.False(t, report.Reencoded)
+		assert.Equal(t, "jpeg", report.Format)
+		assert.Equal(t, int64(len(original)), report.Size)
+	})
+
+	t.Run("applies the orientation", func(t *testing.T) {
+		t.Parallel()
+
+		data, report, err := PreprocessImage(withEXIF(encodeJPEG(t, noisyImage(40, 20)), 6), ImageOptions{})
+		require.NoError(t, err)
+
+		assert.True(t, report.Rotated)
+		assert.True(t, report.Reencoded)
+		assert.False(t, report.Resized)
+		assert.Equal(t, [2]int{20, 40}, [2]int{report.Width, report.Height})
+		assert.NotContains(t, string(data), "Exif")
+		assert.NotContains(t, string(data), "taken at home")
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{20, 40}, [2]int{cfg.Width, cfg.Height})
+	})
+
+	t.Run("fits the byte budget", func(t *testing.T) {
+		t.Parallel()
+
+		original := encodeJPEG(t, noisyImage(400, 300))
+		budget := int64(len(original) / 5)
+
+		data, report, err := PreprocessImage(original, ImageOptions{MaxBytes: budget})
+		require.NoError(t, err)
+
+		assert.LessOrEqual(t, int64(len(data)), budget)
+		assert.Equal(t, int64(len(data)), report.Size)
+		assert.True(t, report.Reencoded)
+		assert.True(t, report.Resized)
+		assert.GreaterOrEqual(t, report.Quality, minImageQuality)
+		assert.InDelta(t, 4.0/3.0, float64(report.Width)/float64(report.Height), 0.02)
+	})
+
+	t.Run("fits the dimensions", func(t *testing.T) {
+		t.Parallel()
+
+		data, report, err := PreprocessImage(withTextChunk(encodePNG(t, noisyImage(400, 300))), ImageOptions{
+			MaxWidth: 100,
+		})
+		require.NoError(t, err)
+
+		assert.True(t, report.MetadataStripped)
+		assert.True(t, report.Resized)
+		assert.NotContains(t, string(data), "Location")
+
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, "png", format)
+		assert.Equal(t, [2]int{100, 75}, [2]int{cfg.Width, cfg.Height})
+	})
+
+	t.Run("preview", func(t *testing.T) {
+		t.Parallel()
+
+		_, report, err := PreprocessImage(encodePNG(t, noisyImage(400, 300)), ImageOptions{PreviewSize: 32})
+		require.NoError(t, err)
+		assert.False(t, report.Changed())
+
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(report.Preview))
+		require.NoError(t, err)
+		assert.Equal(t, [2]int{32, 24}, [2]int{cfg.Width, cfg.Height})
+	})
+
+	t.Run("impossible budget", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := PreprocessImage(encodeJPEG(t, noisyImage(400, 300)), ImageOptions{MaxBytes: 100})
+		require.ErrorIs(t, err, ErrImageTooLarge)
+	})
+
+	t.Run("other content", func(t *testing.T) {
+		t.Parallel()
+
+		data, report, err := PreprocessImage([]byte("plain text"), ImageOptions{MaxBytes: 1})
+		require.NoError(t, err)
+		assert.Equal(t, "plain text", string(data))
+		assert.Empty(t, report.Format)
+	})
+}
+
+func TestUploadWithImagePreprocessing(t *testing.T) {
+	t.Parallel()
+
+	original := withEXIF(encodeJPEG(t, noisyImage(400, 300)), 1)
+
+	var (
+		received receivedUpload
+		reports  []ImageReport
+	)
+	client := newUploadClient(t, &received)
+	_, err := UploadFileFromReader(
+		context.Background(), client, "photo.jpg", bytes.NewReader(original), int64(len(original)),
+		WithImagePreprocessing(ImageOptions{MaxWidth: 200}, func(r ImageReport) {
+			reports = append(reports, r)
+		}),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, reports, 1)
+	assert.Equal(t, "photo.jpg", reports[0].Name)
+	assert.Equal(t, "image/jpeg", received.contentType)
+	assert.Equal(t, int64(len(received.content)), reports[0].Size)
+	assert.Less(t, len(received.content), len(original))
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(received.content))
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{200, 150}, [2]int{cfg.Width, cfg.Height})
+}
@@ -0,0 +1,428 @@
+package bot_api_client
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Node is an element of a rich text document.
+// A node with an empty Format is plain text. Monospace nodes carry their content in Text,
+// other styled nodes and links wrap Children.
+type Node struct {
+	Format   MarkupFormat
+	Text     string
+	URL      string
+	Children []Node
+}
+
+// Plain returns a plain text node.
+func Plain(text string) Node {
+	return Node{Text: text}
+}
+
+// Bold returns a bold node.
+func Bold(children ...Node) Node {
+	return Node{Format: MarkupFormatBold, Children: children}
+}
+
+// Italic returns an italic node.
+func Italic(children ...Node) Node {
+	return Node{Format: MarkupFormatItalic, Children: children}
+}
+
+// Underline returns an underlined node.
+func Underline(children ...Node) Node {
+	return Node{Format: MarkupFormatUnderline, Children: children}
+}
+
+// Strike returns a strikethrough node.
+func Strike(children ...Node) Node {
+	return Node{Format: MarkupFormatStrikethrough, Children: children}
+}
+
+// Code returns an inline monospace node.
+func Code(text string) Node {
+	return Node{Format: MarkupFormatInlineMonospace, Text: text}
+}
+
+// CodeBlock returns a block monospace node.
+func CodeBlock(text string) Node {
+	return Node{Format: MarkupFormatBlockMonospace, Text: text}
+}
+
+// Link returns a link node. Without children the URL is used as the link text.
+func Link(url string, children ...Node) Node {
+	return Node{Format: MarkupFormatLink, URL: url, Children: children}
+}
+
+// MarkupSyntax defines the delimiters used to render rich text.
+// Links are always rendered as [text](url).
+type MarkupSyntax struct {
+	Bold            string
+	Italic          string
+	Underline       string
+	Strikethrough   string
+	InlineMonospace string
+	BlockMonospace  string
+	// Escape prefixes characters of user content that would otherwise be read as markup.
+	Escape rune
+	// Separator is written between two delimiters that would otherwise run together, e.g. the closing
+	// delimiters of italic text inside underlined text, which are "_" and "__". It must be ignored by the channel.
+	Separator string
+}
+
+// DefaultMarkupSyntax is the MarkdownV2 syntax of Telegram (https://core.telegram.org/bots/api#markdownv2-style):
+// *bold*, _italic_, __underline__, ~strikethrough~, `inline monospace` and ```block monospace```, with backslash
+// escaping. Adjacent delimiters are separated with "\r", which Telegram ignores, as its documentation recommends
+// for italic text inside underlined text. The Bot API does not document the markup syntax of the channels,
+// so for other transports render with their own MarkupSyntax.
+var DefaultMarkupSyntax = MarkupSyntax{
+	Bold:            "*",
+	Italic:          "_",
+	Underline:       "__",
+	Strikethrough:   "~",
+	InlineMonospace: "`",
+	BlockMonospace:  "```",
+	Escape:          '\\',
+	Separator:       "\r",
+}
+
+// RenderMarkup renders nodes with DefaultMarkupSyntax for a channel supporting the given formats.
+func RenderMarkup(nodes []Node, formats []MarkupFormat) string {
+	return DefaultMarkupSyntax.Render(nodes, formats)
+}
+
+// MarkupLength returns the length of the rendered text as counted against Text.MaxCharsCount.
+func MarkupLength(nodes []Node, formats []MarkupFormat) int {
+	return TextLength(RenderMarkup(nodes, formats))
+}
+
+// PlainText returns the text of the nodes without any markup.
+func PlainText(nodes []Node) string {
+	return DefaultMarkupSyntax.Render(nodes, nil)
+}
+
+// Render renders nodes for a channel supporting the given formats.
+// Styles the channel does not support are rendered as their plain content and links
+// as "text (url)". User content is escaped unless the channel supports no markup at all,
+// in which case the text is sent verbatim.
+func (s MarkupSyntax) Render(nodes []Node, formats []MarkupFormat) string {
+	r := &markupRenderer{syntax: s, formats: formats}
+	if len(formats) > 0 {
+		r.special = s.specialChars()
+		r.codeSpecial = string(s.Escape) + s.InlineMonospace + s.BlockMonospace
+	}
+
+	var b strings.Builder
+	r.render(&b, nodes)
+
+	return b.String()
+}
+
+func (s MarkupSyntax) specialChars() string {
+	return string(s.Escape) + s.Bold + s.Italic + s.Underline + s.Strikethrough +
+		s.InlineMonospace + s.BlockMonospace + "[]()"
+}
+
+type markupRenderer struct {
+	syntax      MarkupSyntax
+	formats     []MarkupFormat
+	special     string
+	codeSpecial string
+	// delimiter is the last delimiter written and delimiterEnd the length of the output right after it.
+	delimiter    string
+	delimiterEnd int
+}
+
+func (r *markupRenderer) supports(f MarkupFormat) bool {
+	return slices.Contains(r.formats, f)
+}
+
+func (r *markupRenderer) render(b *strings.Builder, nodes []Node) {
+	for _, n := range nodes {
+		switch n.Format {
+		case MarkupFormatBold:
+			r.wrap(b, n, r.syntax.Bold)
+		case MarkupFormatItalic:
+			r.wrap(b, n, r.syntax.Italic)
+		case MarkupFormatUnderline:
+			r.wrap(b, n, r.syntax.Underline)
+		case MarkupFormatStrikethrough:
+			r.wrap(b, n, r.syntax.Strikethrough)
+		case MarkupFormatInlineMonospace:
+			if r.supports(n.Format) {
+				r.delimit(b, r.syntax.InlineMonospace)
+				r.escape(b, n.Text, r.codeSpecial)
+				r.delimit(b, r.syntax.InlineMonospace)
+			} else {
+				r.escape(b, n.Text, r.special)
+			}
+		case MarkupFormatBlockMonospace:
+			if r.supports(n.Format) {
+				r.delimit(b, r.syntax.BlockMonospace)
+				b.WriteString("\n")
+				r.escape(b, strings.Trim(n.Text, "\n"), r.codeSpecial)
+				b.WriteString("\n")
+				r.delimit(b, r.syntax.BlockMonospace)
+			} else {
+				r.escape(b, n.Text, r.special)
+			}
+		case MarkupFormatLink:
+			r.link(b, n)
+		default:
+			r.escape(b, n.Text, r.special)
+			r.render(b, n.Children)
+		}
+	}
+}
+
+func (r *markupRenderer) wrap(b *strings.Builder, n Node, delimiter string) {
+	if !r.supports(n.Format) {
+		r.render(b, n.Children)
+
+		return
+	}
+
+	r.delimit(b, delimiter)
+	r.render(b, n.Children)
+	r.delimit(b, delimiter)
+}
+
+// delimit writes the delimiter, preceded by the separator if it directly follows a delimiter
+// ending with the character it starts with.
+func (r *markupRenderer) delimit(b *strings.Builder, delimiter string) {
+	if r.syntax.Separator != "" && b.Len() > 0 && b.Len() == r.delimiterEnd {
+		last, _ := utf8.DecodeLastRuneInString(r.delimiter)
+		first, _ := utf8.DecodeRuneInString(delimiter)
+		if last == first {
+			b.WriteString(r.syntax.Separator)
+		}
+	}
+
+	b.WriteString(delimiter)
+	r.delimiter, r.delimiterEnd = delimiter, b.Len()
+}
+
+func (r *markupRenderer) link(b *strings.Builder, n Node) {
+	children := n.Children
+	if len(children) == 0 {
+		children = []Node{Plain(n.URL)}
+	}
+
+	if r.supports(MarkupFormatLink) {
+		b.WriteString("[")
+		r.render(b, children)
+		b.WriteString("](")
+		r.escape(b, n.URL, string(r.syntax.Escape)+")")
+		b.WriteString(")")
+
+		return
+	}
+
+	r.render(b, children)
+	if PlainText(children) != n.URL {
+		b.WriteString(" (")
+		r.escape(b, n.URL, r.special)
+		b.WriteString(")")
+	}
+}
+
+func (r *markupRenderer) escape(b *strings.Builder, text, special string) {
+	if special == "" {
+		b.WriteString(text)
+
+		return
+	}
+
+	for _, c := range text {
+		if strings.ContainsRune(special, c) {
+			b.WriteRune(r.syntax.Escape)
+		}
+		b.WriteRune(c)
+	}
+}
+
+// maxMarkdownDepth limits nesting of emphasis in ParseMarkdown.
+const maxMarkdownDepth = 16
+
+// ParseMarkdown parses a subset of Markdown into rich text nodes:
+// **bold** and __bold__, *italic* and _italic_, ~~strikethrough~~, `code`, ```code blocks```,
+// [links](url) and backslash escapes. Unmatched delimiters are kept as text.
+func ParseMarkdown(s string) []Node {
+	nodes, _, _ := parseMarkdown(s, "", 0)
+
+	return nodes
+}
+
+var markdownEmphasis = []struct {
+	token  string
+	format MarkupFormat
+}{
+	{"**", MarkupFormatBold},
+	{"__", MarkupFormatBold},
+	{"~~", MarkupFormatStrikethrough},
+	{"*", MarkupFormatItalic},
+	{"_", MarkupFormatItalic},
+}
+
+// parseMarkdown parses s until closer is found.
+// It returns the parsed nodes, the number of bytes consumed including the closer and whether the closer was found.
+func parseMarkdown(s, closer string, depth int) ([]Node, int, bool) {
+	var (
+		nodes []Node
+		text  strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, Plain(text.String()))
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+
+		if closer != "" && strings.HasPrefix(rest, closer) && canCloseEmphasis(s, i, closer) {
+			flush()
+
+			return nodes, i + len(closer), true
+		}
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte(markdownPunctuation, rest[1]) >= 0:
+			text.WriteByte(rest[1])
+			i += 2
+
+			continue
+		case strings.HasPrefix(rest, "```"):
+			if end := strings.Index(rest[3:], "```"); end >= 0 {
+				flush()
+				nodes = append(nodes, CodeBlock(codeBlockContent(rest[3:3+end])))
+				i += end + 6
+
+				continue
+			}
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				flush()
+				nodes = append(nodes, Code(rest[1:1+end]))
+				i += end + 2
+
+				continue
+			}
+		case rest[0] == '[':
+			if label, url, n, ok := parseMarkdownLink(rest); ok {
+				flush()
+				children := ParseMarkdown(label)
+				nodes = append(nodes, Link(url, children...))
+				i += n
+
+				continue
+			}
+		}
+
+		if depth < maxMarkdownDepth {
+			if n, node, ok := parseEmphasis(s, i, depth); ok {
+				flush()
+				nodes = append(nodes, node)
+				i += n
+
+				continue
+			}
+		}
+
+		text.WriteByte(rest[0])
+		i++
+	}
+
+	flush()
+
+	return nodes, len(s), false
+}
+
+func parseEmphasis(s string, i, depth int) (int, Node, bool) {
+	rest := s[i:]
+
+	for _, e := range markdownEmphasis {
+		if !strings.HasPrefix(rest, e.token) {
+			continue
+		}
+
+		if !canOpenEmphasis(s, i, e.token) || !strings.Contains(rest[len(e.token):], e.token) {
+			return 0, Node{}, false
+		}
+
+		children, n, ok := parseMarkdown(rest[len(e.token):], e.token, depth+1)
+		if !ok || len(children) == 0 {
+			return 0, Node{}, false
+		}
+
+		return len(e.token) + n, Node{Format: e.format, Children: children}, true
+	}
+
+	return 0, Node{}, false
+}
+
+const markdownPunctuation = "\\`*_{}[]()#+-.!~|>"
+
+// canOpenEmphasis requires the delimiter to be followed by a non-space and,
+// for underscores, not to be preceded by a letter or digit (snake_case is not emphasis).
+func canOpenEmphasis(s string, i int, token string) bool {
+	next := i + len(token)
+	if next >= len(s) || isMarkdownSpace(s[next]) {
+		return false
+	}
+
+	return token[0] != '_' || i == 0 || !isMarkdownWordChar(s[i-1])
+}
+
+// canCloseEmphasis requires the delimiter to be preceded by a non-space and,
+// for underscores, not to be followed by a letter or digit.
+func canCloseEmphasis(s string, i int, token string) bool {
+	if i == 0 || isMarkdownSpace(s[i-1]) {
+		return false
+	}
+
+	next := i + len(token)
+
+	return token[0] != '_' || next >= len(s) || !isMarkdownWordChar(s[next])
+}
+
+func isMarkdownSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isMarkdownWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// parseMarkdownLink parses [label](url) at the start of s.
+func parseMarkdownLink(s string) (label, url string, n int, ok bool) {
+	closeLabel := strings.Index(s, "](")
+	if closeLabel < 1 {
+		return "", "", 0, false
+	}
+
+	closeURL := strings.IndexByte(s[closeLabel+2:], ')')
+	if closeURL < 1 {
+		return "", "", 0, false
+	}
+
+	label = s[1:closeLabel]
+	url = s[closeLabel+2 : closeLabel+2+closeURL]
+	if strings.ContainsAny(url, " \n") || strings.Contains(label, "\n\n") {
+		return "", "", 0, false
+	}
+
+	return label, url, closeLabel + 3 + closeURL, true
+}
+
+// codeBlockContent strips the optional language tag and the newlines around a fenced code block.
+func codeBlockContent(s string) string {
+	if nl := strings.IndexByte(s, '\n'); nl >= 0 && !strings.ContainsAny(s[:nl], " \t`") {
+		s = s[nl+1:]
+	}
+
+	return strings.Trim(s, "\n")
+}
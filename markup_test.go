@@ -0,0 +1,238 @@
+package bot_api_client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkup(t *testing.T) {
+	t.Parallel()
+
+	doc := []Node{
+		Bold(Plain("Order "), Italic(Plain("#1_2"))),
+		Plain(" is "),
+		Strike(Plain("pending")),
+		Plain(" shipped. Track: "),
+		Link("https://example.com/t?id=(1)", Plain("here")),
+		Plain(", code "),
+		Code("a`b"),
+	}
+
+	all := []MarkupFormat{
+		MarkupFormatBold, MarkupFormatItalic, MarkupFormatUnderline, MarkupFormatStrikethrough,
+		MarkupFormatInlineMonospace, MarkupFormatBlockMonospace, MarkupFormatLink,
+	}
+
+	testCases := []struct {
+		name     string
+		formats  []MarkupFormat
+		expected string
+	}{
+		{
+			name:     "all formats",
+			formats:  all,
+			expected: "*Order _#1\\_2_* is ~pending~ shipped. Track: [here](https://example.com/t?id=(1\\)), code `a\\`b`",
+		},
+		{
+			name:     "degraded styles are escaped",
+			formats:  []MarkupFormat{MarkupFormatBold},
+			expected: "*Order #1\\_2* is pending shipped. Track: here (https://example.com/t?id=\\(1\\)), code a\\`b",
+		},
+		{
+			name:     "no markup support",
+			formats:  nil,
+			expected: "Order #1_2 is pending shipped. Track: here (https://example.com/t?id=(1)), code a`b",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rendered := RenderMarkup(doc, tc.formats)
+			assert.Equal(t, tc.expected, rendered)
+			assert.Equal(t, TextLength(rendered), MarkupLength(doc, tc.formats))
+		})
+	}
+
+	t.Run("link without text and code block", func(t *testing.T) {
+		t.Parallel()
+
+		nodes := []Node{Link("https://example.com"), Plain("\n"), CodeBlock("x := 1\n")}
+
+		assert.Equal(t, "https://example.com\nx := 1\n", PlainText(nodes))
+		assert.Equal(t,
+			"[https://example\\.com](https://example.com)\n```\nx := 1\n```",
+			MarkupSyntax{BlockMonospace: "```", Escape: '\\', Bold: "."}.Render(nodes, all),
+		)
+	})
+}
+
+// styledRun is text rendered with a set of styles.
+type styledRun struct {
+	text   string
+	styles string
+}
+
+// decodeTelegramMarkup decodes the emphasis of text rendered with DefaultMarkupSyntax the way Telegram does:
+// "__" is read greedily as underline, "\r" is ignored and a backslash escapes the next character.
+// Styles are listed as b(old), i(talic), u(nderline) and s(trikethrough).
+func decodeTelegramMarkup(text string) []styledRun {
+	var (
+		runs   []styledRun
+		active = map[byte]bool{}
+		buf    strings.Builder
+	)
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		var styles string
+		for _, style := range "bisu" {
+			if active[byte(style)] {
+				styles += string(style)
+			}
+		}
+		runs = append(runs, styledRun{text: buf.String(), styles: styles})
+		buf.Reset()
+	}
+	toggle := func(style byte) {
+		flush()
+		active[style] = !active[style]
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\r':
+		case text[i] == '\\' && i+1 < len(text):
+			i++
+			buf.WriteByte(text[i])
+		case strings.HasPrefix(text[i:], "__"):
+			toggle('u')
+			i++
+		case text[i] == '_':
+			toggle('i')
+		case text[i] == '*':
+			toggle('b')
+		case text[i] == '~':
+			toggle('s')
+		default:
+			buf.WriteByte(text[i])
+		}
+	}
+	flush()
+
+	return runs
+}
+
+func TestRenderMarkupNestedDelimiters(t *testing.T) {
+	t.Parallel()
+
+	formats := []MarkupFormat{MarkupFormatItalic, MarkupFormatUnderline, MarkupFormatBold}
+
+	testCases := []struct {
+		name     string
+		nodes    []Node
+		rendered string
+		runs     []styledRun
+	}{
+		{
+			name:     "italic inside underline",
+			nodes:    []Node{Underline(Plain("a "), Italic(Plain("b")))},
+			rendered: "__a _b_\r__",
+			runs:     []styledRun{{"a ", "u"}, {"b", "iu"}},
+		},
+		{
+			name:     "italic wrapping underline",
+			nodes:    []Node{Italic(Underline(Plain("a")), Plain(" b"))},
+			rendered: "_\r__a__ b_",
+			runs:     []styledRun{{"a", "iu"}, {" b", "i"}},
+		},
+		{
+			name:     "underline wrapping italic",
+			nodes:    []Node{Underline(Italic(Plain("a_b")))},
+			rendered: "__\r_a\\_b_\r__",
+			runs:     []styledRun{{"a_b", "iu"}},
+		},
+		{
+			name:     "adjacent italics",
+			nodes:    []Node{Italic(Plain("a")), Italic(Bold(Plain("b")))},
+			rendered: "_a_\r_*b*_",
+			runs:     []styledRun{{"a", "i"}, {"b", "bi"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rendered := RenderMarkup(tc.nodes, formats)
+			assert.Equal(t, tc.rendered, rendered)
+			assert.Equal(t, tc.runs, decodeTelegramMarkup(rendered))
+		})
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected []Node
+	}{
+		{
+			name:     "plain",
+			input:    "just text",
+			expected: []Node{Plain("just text")},
+		},
+		{
+			name:  "emphasis",
+			input: "**bold *and italic*** and ~~gone~~",
+			expected: []Node{
+				Bold(Plain("bold "), Italic(Plain("and italic"))),
+				Plain(" and "),
+				Strike(Plain("gone")),
+			},
+		},
+		{
+			name:     "underscores inside words",
+			input:    "snake_case_name and _it_",
+			expected: []Node{Plain("snake_case_name and "), Italic(Plain("it"))},
+		},
+		{
+			name:     "code and escapes",
+			input:    "use `a*b` not \\*this\\*",
+			expected: []Node{Plain("use "), Code("a*b"), Plain(" not *this*")},
+		},
+		{
+			name:     "code block with language",
+			input:    "```go\nfmt.Println()\n```",
+			expected: []Node{CodeBlock("fmt.Println()")},
+		},
+		{
+			name:  "link",
+			input: "see [the **docs**](https://example.com/docs).",
+			expected: []Node{
+				Plain("see "),
+				Link("https://example.com/docs", Plain("the "), Bold(Plain("docs"))),
+				Plain("."),
+			},
+		},
+		{
+			name:     "unmatched delimiters",
+			input:    "2 * 3 = 6 and [not a link]",
+			expected: []Node{Plain("2 * 3 = 6 and [not a link]")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, ParseMarkdown(tc.input))
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package bot_api_client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+const (
+	maxCaptionLength     = 1024
+	maxProductNameLength = 255
+	maxArticleLength     = 128
+	maxURLLength         = 2048
+	maxUnitLength        = 16
+	maxOrderNumberLength = 255
+	maxStatusNameLength  = 255
+)
+
+// MessageItem is a file attachment of an outgoing or edited message.
+// It is identical to the element type of SendMessageRequestBody.Items and EditMessageJSONRequestBody.Items.
+type MessageItem = struct {
+	// Caption Caption for the file
+	Caption string `binding:"min=1,max=1024" json:"caption" mod:"trim,escape"`
+
+	// ID Unique identifier of the file
+	ID openapi_types.UUID `json:"id"`
+}
+
+// NewMessageItem returns an attachment referencing an uploaded file.
+func NewMessageItem(id openapi_types.UUID, caption string) MessageItem {
+	return MessageItem{ID: id, Caption: caption}
+}
+
+// MessageBuilder assembles a SendMessageRequestBody and validates it before sending.
+// Use NewText, NewFiles, NewImages, NewAudio, NewOrder or NewProduct to create one.
+type MessageBuilder struct {
+	body SendMessageRequestBody
+}
+
+// NewText starts a text message.
+func NewText(chatID int64, text string) *MessageBuilder {
+	return newMessageBuilder(chatID, MessageTypeText).content(text)
+}
+
+// NewFiles starts a message with file attachments.
+func NewFiles(chatID int64, files ...MessageItem) *MessageBuilder {
+	return newMessageBuilder(chatID, MessageTypeFile).items(files)
+}
+
+// NewImages starts a message with image attachments.
+func NewImages(chatID int64, images ...MessageItem) *MessageBuilder {
+	return newMessageBuilder(chatID, MessageTypeImage).items(images)
+}
+
+// NewAudio starts a message with audio attachments.
+func NewAudio(chatID int64, audio ...MessageItem) *MessageBuilder {
+	return newMessageBuilder(chatID, MessageTypeAudio).items(audio)
+}
+
+// NewOrder starts an order message.
+func NewOrder(chatID int64, order MessageOrder) *MessageBuilder {
+	b := newMessageBuilder(chatID, MessageTypeOrder)
+	b.body.Order = &order
+
+	return b
+}
+
+// NewProduct starts a product message.
+func NewProduct(chatID int64, product MessageProduct) *MessageBuilder {
+	b := newMessageBuilder(chatID, MessageTypeProduct)
+	b.body.Product = &product
+
+	return b
+}
+
+func newMessageBuilder(chatID int64, t MessageType) *MessageBuilder {
+	return &MessageBuilder{
+		body: SendMessageRequestBody{
+			ChatID: chatID,
+			Scope:  MessageScopePublic,
+			Type:   &t,
+		},
+	}
+}
+
+func (b *MessageBuilder) content(text string) *MessageBuilder {
+	b.body.Content = &text
+
+	return b
+}
+
+func (b *MessageBuilder) items(items []MessageItem) *MessageBuilder {
+	list := append([]MessageItem(nil), items...)
+	b.body.Items = &list
+
+	return b
+}
+
+// Private marks the message as a private note visible to operators only.
+func (b *MessageBuilder) Private() *MessageBuilder {
+	b.body.Scope = MessageScopePrivate
+
+	return b
+}
+
+// Quote makes the message a reply to the message with the given ID.
+func (b *MessageBuilder) Quote(messageID int64) *MessageBuilder {
+	b.body.QuoteMessageID = messageID
+
+	return b
+}
+
+// Note sets the annotation of a file, image or audio message.
+func (b *MessageBuilder) Note(note string) *MessageBuilder {
+	b.body.Note = &note
+
+	return b
+}
+
+// Suggestions attaches quick replies to the message.
+func (b *MessageBuilder) Suggestions(suggestions ...Suggestion) *MessageBuilder {
+	if b.body.TransportAttachments == nil {
+		b.body.TransportAttachments = &MessageTransportAttachments{}
+	}
+	b.body.TransportAttachments.Suggestions = append(b.body.TransportAttachments.Suggestions, suggestions...)
+
+	return b
+}
+
+// MassCommunication marks the message as part of a mass mailing.
+func (b *MessageBuilder) MassCommunication() *MessageBuilder {
+	mass := true
+	b.body.MassCommunication = &mass
+
+	return b
+}
+
+// Build validates the message and returns the request body.
+// The returned error is of type ValidationErrors when the message violates API constraints.
+func (b *MessageBuilder) Build() (SendMessageRequestBody, error) {
+	var errs ValidationErrors
+
+	if b.body.ChatID <= 0 {
+		errs.add("chat_id", "required", "", "must be a positive chat ID")
+	}
+
+	switch *b.body.Type {
+	case MessageTypeText:
+		if b.body.Content == nil || strings.TrimSpace(*b.body.Content) == "" {
+			errs.add("content", "required", "", "must not be empty for text messages")
+		}
+	case MessageTypeFile, MessageTypeImage, MessageTypeAudio:
+		validateMessageItems(&errs, b.body.Items)
+	case MessageTypeOrder:
+		validateMessageOrder(&errs, "order", b.body.Order)
+	case MessageTypeProduct:
+		validateMessageProduct(&errs, "product", b.body.Product)
+	}
+
+	if b.body.TransportAttachments != nil {
+		validateSuggestions(&errs, b.body.TransportAttachments.Suggestions)
+	}
+
+	if err := errs.err(); err != nil {
+		return SendMessageRequestBody{}, err
+	}
+
+	return b.body, nil
+}
+
+func validateMessageItems(errs *ValidationErrors, items *[]MessageItem) {
+	if items == nil || len(*items) == 0 {
+		errs.add("items", "required", "", "at least one attachment is required")
+
+		return
+	}
+
+	for i, item := range *items {
+		field := fmt.Sprintf("items[%d]", i)
+		if item.ID == (openapi_types.UUID{}) {
+			errs.add(field+".id", "required", "", "file ID is required")
+		}
+		validateLength(errs, field+".caption", item.Caption, 1, maxCaptionLength)
+	}
+}
+
+func validateMessageOrder(errs *ValidationErrors, field string, order *MessageOrder) {
+	if order == nil {
+		errs.add(field, "required", "", "order is required for order messages")
+
+		return
+	}
+
+	validateLength(errs, field+".number", order.Number, 0, maxOrderNumberLength)
+	validateLength(errs, field+".url", order.Url, 0, maxURLLength)
+	validateCost(errs, field+".cost", order.Cost)
+	validateCost(errs, field+".discount", order.Discount)
+
+	if order.Status != nil {
+		if order.Status.Code != "" {
+			validateEnum(errs, field+".status.code", order.Status.Code)
+		}
+		validateLength(errs, field+".status.name", order.Status.Name, 0, maxStatusNameLength)
+	}
+
+	if order.Delivery != nil {
+		validateCost(errs, field+".delivery.price", order.Delivery.Price)
+	}
+
+	for i, item := range order.Items {
+		itemField := fmt.Sprintf("%s.items[%d]", field, i)
+		validateLength(errs, itemField+".name", item.Name, 0, maxProductNameLength)
+		validateLength(errs, itemField+".img", item.Img, 0, maxURLLength)
+		validateLength(errs, itemField+".url", item.Url, 0, maxURLLength)
+		validateLength(errs, itemField+".quantity.unit", item.Quantity.Unit, 0, maxUnitLength)
+		if item.Quantity.Value < 0 {
+			errs.add(itemField+".quantity.value", "gte", "0", "must not be negative")
+		}
+		validateCost(errs, itemField+".price", item.Price)
+	}
+
+	for i, payment := range order.Payments {
+		validateCost(errs, fmt.Sprintf("%s.payments[%d].amount", field, i), payment.Amount)
+	}
+}
+
+func validateMessageProduct(errs *ValidationErrors, field string, product *MessageProduct) {
+	if product == nil {
+		errs.add(field, "required", "", "product is required for product messages")
+
+		return
+	}
+
+	validateLength(errs, field+".name", product.Name, 1, maxProductNameLength)
+	validateLength(errs, field+".article", product.Article, 0, maxArticleLength)
+	validateLength(errs, field+".img", product.Img, 0, maxURLLength)
+	validateLength(errs, field+".url", product.Url, 0, maxURLLength)
+	validateLength(errs, field+".unit", product.Unit, 0, maxUnitLength)
+	validateCost(errs, field+".cost", product.Cost)
+}
+
+func validateSuggestions(errs *ValidationErrors, suggestions []Suggestion) {
+	for i, s := range suggestions {
+		if s.Type != "" {
+			validateEnum(errs, fmt.Sprintf("transport_attachments.suggestions[%d].type", i), s.Type)
+		}
+	}
+}
+
+func validateCost(errs *ValidationErrors, field string, cost *Cost) {
+	if cost == nil {
+		return
+	}
+
+	if !isCurrencyCode(cost.Currency) {
+		errs.add(field+".currency", "currency", "", "%q is not an ISO 4217 currency code", cost.Currency)
+	}
+	if cost.Value < 0 {
+		errs.add(field+".value", "gte", "0", "must not be negative")
+	}
+}
+
+// validateLength checks the length of a trimmed string in characters.
+// A zero min means the value is optional.
+func validateLength(errs *ValidationErrors, field, value string, min, max int) {
+	n := utf8.RuneCountInString(strings.TrimSpace(value))
+
+	switch {
+	case n < min:
+		errs.add(field, "min", strconv.Itoa(min), "must be at least %d characters long", min)
+	case n > max:
+		errs.add(field, "max", strconv.Itoa(max), "must be at most %d characters long, got %d", max, n)
+	}
+}
+
+type enumValidator interface {
+	ValidateEnum() error
+}
+
+func validateEnum(errs *ValidationErrors, field string, v enumValidator) {
+	if err := v.ValidateEnum(); err != nil {
+		errs.add(field, "enum-valid", "", "%v", err)
+	}
+}
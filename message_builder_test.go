@@ -0,0 +1,163 @@
+package bot_api_client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	t.Parallel()
+
+	fileID := uuid.New()
+
+	t.Run("text message defaults", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewText(1, "hello").Build()
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(1), body.ChatID)
+		assert.Equal(t, MessageScopePublic, body.Scope)
+		require.NotNil(t, body.Type)
+		assert.Equal(t, MessageTypeText, *body.Type)
+		require.NotNil(t, body.Content)
+		assert.Equal(t, "hello", *body.Content)
+		assert.Nil(t, body.Items)
+	})
+
+	t.Run("modifiers", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewText(1, "hello").
+			Private().
+			Quote(42).
+			MassCommunication().
+			Suggestions(Suggestion{Type: SuggestionTypeText, Title: "Yes"}).
+			Build()
+		require.NoError(t, err)
+
+		assert.Equal(t, MessageScopePrivate, body.Scope)
+		assert.Equal(t, int64(42), body.QuoteMessageID)
+		require.NotNil(t, body.MassCommunication)
+		assert.True(t, *body.MassCommunication)
+		require.NotNil(t, body.TransportAttachments)
+		assert.Len(t, body.TransportAttachments.Suggestions, 1)
+	})
+
+	t.Run("files message", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewFiles(1, NewMessageItem(fileID, "invoice.pdf")).Note("your invoice").Build()
+		require.NoError(t, err)
+
+		assert.Equal(t, MessageTypeFile, *body.Type)
+		require.NotNil(t, body.Items)
+		require.Len(t, *body.Items, 1)
+		assert.Equal(t, fileID, (*body.Items)[0].ID)
+		assert.Equal(t, "your invoice", *body.Note)
+		assert.Nil(t, body.Content)
+	})
+
+	t.Run("invalid captions", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewImages(1,
+			NewMessageItem(fileID, ""),
+			NewMessageItem(fileID, strings.Repeat("a", 1025)),
+		).Build()
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		require.Len(t, verrs, 2)
+		assert.Equal(t, "items[0].caption", verrs[0].Field)
+		assert.Equal(t, "min", verrs[0].Rule)
+		assert.Equal(t, "items[1].caption", verrs[1].Field)
+		assert.Equal(t, "max", verrs[1].Rule)
+	})
+
+	t.Run("empty text and missing attachments", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewText(0, "  ").Build()
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, []string{"chat_id", "content"}, fieldNames(verrs))
+
+		_, err = NewAudio(1).Build()
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, []string{"items"}, fieldNames(verrs))
+	})
+
+	t.Run("product constraints", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewProduct(1, MessageProduct{
+			Name: strings.Repeat("n", 256),
+			Unit: strings.Repeat("u", 17),
+			Cost: &Cost{Currency: "XXY", Value: -1},
+		}).Build()
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t,
+			[]string{"product.name", "product.unit", "product.cost.currency", "product.cost.value"},
+			fieldNames(verrs),
+		)
+	})
+
+	t.Run("valid product", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewProduct(1, MessageProduct{
+			ID:   7,
+			Name: "Тёплый свитер",
+			Unit: "шт",
+			Cost: &Cost{Currency: "RUB", Value: 1500},
+		}).Build()
+		require.NoError(t, err)
+		assert.Equal(t, MessageTypeProduct, *body.Type)
+		assert.Equal(t, uint64(7), body.Product.ID)
+	})
+
+	t.Run("order constraints", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewOrder(1, MessageOrder{
+			Number: "A-1",
+			Status: &MessageOrderStatus{Code: "shipped"},
+			Items: []MessageOrderItem{
+				{Name: "Socks", Quantity: Quantity{Unit: strings.Repeat("u", 17), Value: 1}, Price: &Cost{Currency: "usd"}},
+			},
+		}).Build()
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t,
+			[]string{"order.status.code", "order.items[0].quantity.unit", "order.items[0].price.currency"},
+			fieldNames(verrs),
+		)
+	})
+
+	t.Run("invalid suggestion type", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewText(1, "pick one").Suggestions(Suggestion{Type: "location", Title: "Here"}).Build()
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, []string{"transport_attachments.suggestions[0].type"}, fieldNames(verrs))
+	})
+}
+
+func fieldNames(errs ValidationErrors) []string {
+	names := make([]string, 0, len(errs))
+	for _, e := range errs {
+		names = append(names, e.Field)
+	}
+
+	return names
+}
@@ -227,3 +227,18 @@ func (r WebSocketConnectionResp) Error() error {
 
 	return nil
 }
+
+// FromCreateCommandRequestBody sets the body to create the command or replace it.
+// The generated body type cannot be filled otherwise, so CreateOrUpdateCommandWithResponse would send no command.
+func (t *CreateOrUpdateCommandJSONRequestBody) FromCreateCommandRequestBody(v CreateCommandRequestBody) error {
+	return (*CreateOrUpdateCommandRequest)(t).FromCreateCommandRequestBody(v)
+}
+
+// FromUpdateCommandRequestBody sets the body to update the command.
+func (t *CreateOrUpdateCommandJSONRequestBody) FromUpdateCommandRequestBody(v UpdateCommandRequestBody) error {
+	return (*CreateOrUpdateCommandRequest)(t).FromUpdateCommandRequestBody(v)
+}
+
+func (t CreateOrUpdateCommandJSONRequestBody) MarshalJSON() ([]byte, error) {
+	return CreateOrUpdateCommandRequest(t).MarshalJSON()
+}
@@ -0,0 +1,438 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// DefaultMirrorPageSize is the number of entities Mirror requests per page.
+const DefaultMirrorPageSize = 100
+
+// Mirror keeps a local copy of chats, dialogs, messages and customers in a MirrorStore.
+// Sync backfills the store with the list methods and picks up changes made while no events were received;
+// Handler applies WebSocket events as they arrive. Sync positions are saved to the store after every page,
+// so a restarted Mirror resumes where it stopped. Handler may be used while Sync runs, but Sync itself
+// must not be called concurrently.
+type Mirror struct {
+	client     ClientWithResponsesInterface
+	store      MirrorStore
+	pageSize   int
+	since      time.Time
+	now        func() time.Time
+	reqEditors []RequestEditorFn
+}
+
+// MirrorOption configures a Mirror.
+type MirrorOption func(*Mirror)
+
+// WithMirrorPageSize sets the number of entities requested per page, from 1 to 1000.
+func WithMirrorPageSize(n int) MirrorOption {
+	return func(m *Mirror) {
+		m.pageSize = min(max(n, 1), 1000)
+	}
+}
+
+// WithMirrorBackfillSince limits the initial backfill to entities changed after t.
+// It has no effect once an entity has been synced.
+func WithMirrorBackfillSince(t time.Time) MirrorOption {
+	return func(m *Mirror) {
+		m.since = t
+	}
+}
+
+// WithMirrorRequestEditors sets the request editors applied to the list requests.
+func WithMirrorRequestEditors(reqEditors ...RequestEditorFn) MirrorOption {
+	return func(m *Mirror) {
+		m.reqEditors = reqEditors
+	}
+}
+
+// NewMirror creates a Mirror that fetches entities with the client and keeps them in the store.
+func NewMirror(client ClientWithResponsesInterface, store MirrorStore, opts ...MirrorOption) *Mirror {
+	m := &Mirror{
+		client:   client,
+		store:    store,
+		pageSize: DefaultMirrorPageSize,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Store returns the store the mirror writes to.
+func (m *Mirror) Store() MirrorStore {
+	return m.store
+}
+
+// Sync fetches the entities changed since the last completed sync, or all of them on the first call,
+// and puts them into the store. Chats are synced first, then dialogs, messages and customers.
+func (m *Mirror) Sync(ctx context.Context) error {
+	checkpoints, err := m.store.Checkpoints(ctx)
+	if err != nil {
+		return fmt.Errorf("mirror sync: %w", err)
+	}
+
+	save := func() error {
+		return m.store.SaveCheckpoints(ctx, checkpoints)
+	}
+
+	chats := func(ctx context.Context, since time.Time, sinceID int64) ([]ChatsListResponseItem, error) {
+		resp, err := m.client.ListChatsWithResponse(ctx, &ListChatsParams{
+			Since: optional(since), SinceID: optional(sinceID), Limit: &m.pageSize,
+		}, m.reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return nil, err
+		}
+
+		return deref(resp.JSON200), nil
+	}
+	dialogs := func(ctx context.Context, since time.Time, sinceID int64) ([]DialogListResponseItem, error) {
+		resp, err := m.client.ListDialogsWithResponse(ctx, &ListDialogsParams{
+			Since: optional(since), SinceID: optional(sinceID), Limit: &m.pageSize,
+		}, m.reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return nil, err
+		}
+
+		return deref(resp.JSON200), nil
+	}
+	messages := func(ctx context.Context, since time.Time, sinceID int64) ([]MessageListResponseItem, error) {
+		resp, err := m.client.ListMessagesWithResponse(ctx, &ListMessagesParams{
+			Since: optional(since), SinceID: optional(sinceID), Limit: &m.pageSize,
+		}, m.reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return nil, err
+		}
+
+		return deref(resp.JSON200), nil
+	}
+	customers := func(ctx context.Context, since time.Time, sinceID int64) ([]Customer, error) {
+		resp, err := m.client.ListCustomersWithResponse(ctx, &ListCustomersParams{
+			Since: optional(since), SinceID: optional(sinceID), Limit: &m.pageSize,
+		}, m.reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return nil, err
+		}
+
+		return deref(resp.JSON200), nil
+	}
+
+	chatID := func(c ChatsListResponseItem) int64 { return c.ID }
+	if err = syncPass(ctx, m, &checkpoints.Chats, save, chats, chatID, m.store.PutChats); err != nil {
+		return fmt.Errorf("mirror sync chats: %w", err)
+	}
+	dialogID := func(d DialogListResponseItem) int64 { return d.ID }
+	if err = syncPass(ctx, m, &checkpoints.Dialogs, save, dialogs, dialogID, m.store.PutDialogs); err != nil {
+		return fmt.Errorf("mirror sync dialogs: %w", err)
+	}
+	messageID := func(msg MessageListResponseItem) int64 { return msg.ID }
+	if err = syncPass(ctx, m, &checkpoints.Messages, save, messages, messageID, m.store.PutMessages); err != nil {
+		return fmt.Errorf("mirror sync messages: %w", err)
+	}
+	customerID := func(c Customer) int64 { return c.ID }
+	if err = syncPass(ctx, m, &checkpoints.Customers, save, customers, customerID, m.store.PutCustomers); err != nil {
+		return fmt.Errorf("mirror sync customers: %w", err)
+	}
+
+	return nil
+}
+
+// syncPass pages through the entities changed since the checkpoint in the order of their IDs.
+// A pass interrupted by an error resumes after the last saved ID; once it completes,
+// the next pass requests the entities changed after the pass started.
+func syncPass[T any](
+	ctx context.Context,
+	m *Mirror,
+	checkpoint *MirrorCheckpoint,
+	save func() error,
+	list func(ctx context.Context, since time.Time, sinceID int64) ([]T, error),
+	id func(T) int64,
+	put func(context.Context, []T) error,
+) error {
+	if checkpoint.SinceID == 0 || checkpoint.Started.IsZero() {
+		checkpoint.Started = m.now()
+	}
+
+	since := checkpoint.Since
+	if since.IsZero() {
+		since = m.since
+	}
+
+	for {
+		items, err := list(ctx, since, checkpoint.SinceID)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		if err = put(ctx, items); err != nil {
+			return err
+		}
+
+		last := id(items[len(items)-1])
+		if last <= checkpoint.SinceID {
+			// The server ignored the cursor; stop instead of requesting the same page forever.
+			break
+		}
+		checkpoint.SinceID = last
+		if err = save(); err != nil {
+			return err
+		}
+
+		if len(items) < m.pageSize {
+			break
+		}
+	}
+
+	checkpoint.Since, checkpoint.SinceID, checkpoint.Started = checkpoint.Started, 0, time.Time{}
+
+	return save()
+}
+
+// Handler returns a WebSocket event handler that applies message, chat and dialog events to the store
+// and then passes every event to next, which may be nil. Errors of the store are returned without calling next.
+func (m *Mirror) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		if err := m.Apply(ctx, msg.Payload); err != nil {
+			return err
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// Apply applies an event to the store. Events carry fewer fields than the list responses, so
+// the converted entities are merged into the stored ones: fields without an event counterpart keep their values.
+// The time of the event becomes the update time of the entities, and events older than the stored entities
+// are skipped. Events of other types are ignored.
+func (m *Mirror) Apply(ctx context.Context, event ws.EventSchema) error {
+	var (
+		err error
+		at  time.Time
+	)
+	if event.Meta.Timestamp > 0 {
+		at = time.Unix(event.Meta.Timestamp, 0)
+	}
+
+	switch data := event.Data.(type) {
+	case ws.MessageDataSchema:
+		switch event.Type {
+		case ws.EventTypeMessageDeleted:
+			err = m.store.DeleteMessages(ctx, []int64{data.Message.Id})
+		case ws.EventTypeMessageNew, ws.EventTypeMessageUpdated, ws.EventTypeMessageRestored:
+			err = m.putMessage(ctx, data.Message, at)
+		}
+	case ws.ChatDataSchema:
+		err = m.putChat(ctx, data.Chat, at)
+	case ws.ChatsDeletedDataSchema:
+		err = m.store.DeleteChats(ctx, data.ChatIds)
+	case ws.DialogDataSchema:
+		err = m.putDialog(ctx, data.Dialog, nil, at)
+	case ws.DialogAssignDataSchema:
+		if err = m.putChat(ctx, data.Chat, at); err == nil {
+			err = m.putDialog(ctx, data.Dialog, &data.Chat, at)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("mirror %s event: %w", event.Type, err)
+	}
+
+	return nil
+}
+
+func (m *Mirror) putMessage(ctx context.Context, message ws.MessagePropertyFromMessageDataSchema, at time.Time) error {
+	prev, _, err := m.store.Message(ctx, message.Id)
+	if err != nil || staleEvent(at, prev.UpdatedAt) {
+		return err
+	}
+
+	item := messageItemFromWS(prev, message)
+	item.UpdatedAt = laterUpdate(item.UpdatedAt, at)
+
+	return m.store.PutMessages(ctx, []MessageListResponseItem{item})
+}
+
+func (m *Mirror) putChat(ctx context.Context, chat ws.ChatSchema, at time.Time) error {
+	prev, _, err := m.store.Chat(ctx, chat.Id)
+	if err != nil || staleEvent(at, prev.UpdatedAt) {
+		return err
+	}
+
+	item := chatItemFromWS(prev, chat)
+	item.UpdatedAt = laterUpdate(item.UpdatedAt, at)
+
+	return m.store.PutChats(ctx, []ChatsListResponseItem{item})
+}
+
+func (m *Mirror) putDialog(ctx context.Context, dialog ws.DialogEventSchema, chat *ws.ChatSchema, at time.Time) error {
+	prev, _, err := m.store.Dialog(ctx, dialog.Id)
+	if err != nil || staleEvent(at, prev.UpdatedAt) {
+		return err
+	}
+
+	item := dialogItemFromWS(prev, dialog)
+	item.UpdatedAt = laterUpdate(item.UpdatedAt, at)
+	if chat != nil {
+		item.ChatID = chat.Id
+	}
+	if item.ChatID == 0 {
+		return errors.New("dialog without chat")
+	}
+
+	return m.store.PutDialogs(ctx, []DialogListResponseItem{item})
+}
+
+// messageItemFromWS merges the message of an event into the stored message.
+// The list fields ChannelID, ChannelSentAt and User have no event counterpart.
+func messageItemFromWS(prev MessageListResponseItem, m ws.MessagePropertyFromMessageDataSchema) MessageListResponseItem {
+	msg := MessageFromWS(m)
+	item := MessageListResponseItem{
+		Action:               msg.Action,
+		Actions:              msg.Actions,
+		ChannelID:            prev.ChannelID,
+		ChannelSentAt:        prev.ChannelSentAt,
+		ChatID:               msg.ChatID,
+		Content:              msg.Content,
+		CreatedAt:            prev.CreatedAt,
+		Dialog:               msg.Dialog,
+		Error:                msg.Error,
+		From:                 msg.From,
+		ID:                   msg.ID,
+		IsEdit:               msg.IsEdit,
+		IsRead:               msg.IsRead,
+		Items:                msg.Items,
+		Note:                 msg.Note,
+		Order:                msg.Order,
+		Product:              msg.Product,
+		Quote:                msg.Quote,
+		Responsible:          msg.Responsible,
+		Scope:                msg.Scope,
+		Status:               msg.Status,
+		TemplateCode:         msg.TemplateCode,
+		Time:                 msg.Time,
+		TransportAttachments: msg.TransportAttachments,
+		Type:                 msg.Type,
+		UpdatedAt:            prev.UpdatedAt,
+		User:                 prev.User,
+	}
+	if !m.CreatedAt.IsZero() {
+		item.CreatedAt = DateTimeRFC3339Micro{Time: m.CreatedAt}
+	}
+	if m.EditedAt != nil {
+		item.UpdatedAt = &DateTimeRFC3339Micro{Time: *m.EditedAt}
+	}
+
+	return item
+}
+
+// chatItemFromWS merges the chat of an event into the stored chat.
+// Channel, customer and the last dialog and messages are kept if the event leaves them out.
+func chatItemFromWS(prev ChatsListResponseItem, c ws.ChatSchema) ChatsListResponseItem {
+	chat := ChatFromWS(c)
+	item := ChatsListResponseItem{
+		AuthorID:                   chat.AuthorID,
+		Avatar:                     chat.Avatar,
+		Channel:                    orElse(chat.Channel, prev.Channel),
+		CreatedAt:                  DateTimeRFC3339Micro{Time: c.CreatedAt},
+		Customer:                   orElse(chat.Customer, prev.Customer),
+		ID:                         chat.ID,
+		LastActivity:               orElse(chat.LastActivity, prev.LastActivity),
+		LastDialog:                 orElse(chat.LastDialog, prev.LastDialog),
+		LastMessage:                orElse(chat.LastMessage, prev.LastMessage),
+		LastUserMessage:            orElse(chat.LastUserMessage, prev.LastUserMessage),
+		Name:                       chat.Name,
+		NotReadMessagesCount:       chat.NotReadMessagesCount,
+		ReplyDeadline:              chat.ReplyDeadline,
+		Unread:                     chat.Unread,
+		UpdatedAt:                  prev.UpdatedAt,
+		WaitingLevel:               convertEnum[ChatsListResponseItemWaitingLevel](chat.WaitingLevel),
+		WaitingLevelTransitionTime: chat.WaitingLevelTransitionTime,
+	}
+	if c.CreatedAt.IsZero() {
+		item.CreatedAt = prev.CreatedAt
+	}
+
+	return item
+}
+
+// dialogItemFromWS merges the dialog of an event into the stored dialog.
+// The list fields BotID, Tags, UpdatedAt and the external ID of the responsible have no event counterpart.
+func dialogItemFromWS(prev DialogListResponseItem, d ws.DialogEventSchema) DialogListResponseItem {
+	item := DialogListResponseItem{
+		BeginMessageID:  orElse(d.BeginMessageId, prev.BeginMessageID),
+		BotID:           prev.BotID,
+		ChatID:          prev.ChatID,
+		CreatedAt:       DateTimeRFC3339Micro{Time: d.CreatedAt},
+		EndingMessageID: orElse(d.EndingMessageId, prev.EndingMessageID),
+		ID:              d.Id,
+		IsActive:        d.ClosedAt == nil,
+		IsAssigned:      d.Responsible != nil,
+		Tags:            prev.Tags,
+		UpdatedAt:       prev.UpdatedAt,
+		Utm:             prev.Utm,
+	}
+	if d.Chat != nil {
+		item.ChatID = d.Chat.Id
+	}
+	if d.CreatedAt.IsZero() {
+		item.CreatedAt = prev.CreatedAt
+	}
+	if d.ClosedAt != nil {
+		item.ClosedAt = &DateTimeRFC3339Micro{Time: *d.ClosedAt}
+	}
+	if r := d.Responsible; r != nil {
+		item.Responsible = &Responsible{ID: r.Id, Type: ResponsibleType(r.Type)}
+		if r.AssignedAt != nil {
+			item.Responsible.AssignedAt = &DateTimeRFC3339Micro{Time: *r.AssignedAt}
+		}
+		if prev.Responsible != nil && prev.Responsible.ID == r.Id && prev.Responsible.Type == item.Responsible.Type {
+			item.Responsible.ExternalID = prev.Responsible.ExternalID
+		}
+	}
+	if u := d.Utm; u != nil {
+		item.Utm = &Utm{Campaign: u.Campaign, Content: u.Content, Medium: u.Medium, Source: u.Source, Term: u.Term}
+	}
+
+	return item
+}
+
+// staleEvent reports whether an event that occurred at t is older than an entity updated at stored.
+// Event times have a precision of a second, so an event is only stale if its whole second is before stored.
+func staleEvent(t time.Time, stored *DateTimeRFC3339Micro) bool {
+	return !t.IsZero() && stored != nil && !t.Add(time.Second).After(stored.Time)
+}
+
+// laterUpdate returns the later of the update time and t.
+func laterUpdate(updated *DateTimeRFC3339Micro, t time.Time) *DateTimeRFC3339Micro {
+	if t.IsZero() || (updated != nil && !updated.Time.Before(t)) {
+		return updated
+	}
+
+	return &DateTimeRFC3339Micro{Time: t}
+}
+
+// orElse returns p, or fallback if p is nil.
+func orElse[T any](p, fallback *T) *T {
+	if p == nil {
+		return fallback
+	}
+
+	return p
+}
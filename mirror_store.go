@@ -0,0 +1,564 @@
+package bot_api_client
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MirrorStore keeps the entities synchronized by Mirror. Implementations must be safe for concurrent use.
+// The Put methods keep a stored entity updated later than the one put, so a page fetched by Sync
+// does not overwrite the changes of the events applied meanwhile.
+type MirrorStore interface {
+	PutChats(ctx context.Context, chats []ChatsListResponseItem) error
+	PutDialogs(ctx context.Context, dialogs []DialogListResponseItem) error
+	PutMessages(ctx context.Context, messages []MessageListResponseItem) error
+	PutCustomers(ctx context.Context, customers []Customer) error
+	// DeleteChats removes the chats together with their dialogs and messages.
+	DeleteChats(ctx context.Context, ids []int64) error
+	DeleteMessages(ctx context.Context, ids []int64) error
+
+	Chat(ctx context.Context, id int64) (ChatsListResponseItem, bool, error)
+	Dialog(ctx context.Context, id int64) (DialogListResponseItem, bool, error)
+	Message(ctx context.Context, id int64) (MessageListResponseItem, bool, error)
+	Customer(ctx context.Context, id int64) (Customer, bool, error)
+
+	// Chats returns all chats ordered by ID.
+	Chats(ctx context.Context) ([]ChatsListResponseItem, error)
+	// ChatDialogs returns the dialogs of the chat ordered by ID.
+	ChatDialogs(ctx context.Context, chatID int64) ([]DialogListResponseItem, error)
+	// ChatMessages returns the messages of the chat ordered by ID.
+	ChatMessages(ctx context.Context, chatID int64) ([]MessageListResponseItem, error)
+
+	Checkpoints(ctx context.Context) (MirrorCheckpoints, error)
+	SaveCheckpoints(ctx context.Context, checkpoints MirrorCheckpoints) error
+}
+
+// MirrorCheckpoint is the sync position of an entity.
+type MirrorCheckpoint struct {
+	// Since is the start time of the last completed pass; the next pass requests entities changed after it.
+	Since time.Time `json:"since"`
+	// SinceID is the last ID received in the pass in progress. It is reset when the pass completes.
+	SinceID int64 `json:"since_id,omitempty"`
+	// Started is the start time of the pass in progress, which becomes Since when the pass completes.
+	Started time.Time `json:"started,omitzero"`
+}
+
+// MirrorCheckpoints are the sync positions of all mirrored entities.
+type MirrorCheckpoints struct {
+	Chats     MirrorCheckpoint `json:"chats"`
+	Dialogs   MirrorCheckpoint `json:"dialogs"`
+	Messages  MirrorCheckpoint `json:"messages"`
+	Customers MirrorCheckpoint `json:"customers"`
+}
+
+// MemoryMirrorStore is a MirrorStore keeping entities in memory.
+type MemoryMirrorStore struct {
+	mu          sync.RWMutex
+	chats       map[int64]ChatsListResponseItem
+	dialogs     map[int64]DialogListResponseItem
+	messages    map[int64]MessageListResponseItem
+	customers   map[int64]Customer
+	checkpoints MirrorCheckpoints
+}
+
+// NewMemoryMirrorStore creates an empty in-memory store.
+func NewMemoryMirrorStore() *MemoryMirrorStore {
+	return &MemoryMirrorStore{
+		chats:     make(map[int64]ChatsListResponseItem),
+		dialogs:   make(map[int64]DialogListResponseItem),
+		messages:  make(map[int64]MessageListResponseItem),
+		customers: make(map[int64]Customer),
+	}
+}
+
+func (s *MemoryMirrorStore) PutChats(_ context.Context, chats []ChatsListResponseItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range chats {
+		if !updatedBefore(c.UpdatedAt, s.chats[c.ID].UpdatedAt) {
+			s.chats[c.ID] = c
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryMirrorStore) PutDialogs(_ context.Context, dialogs []DialogListResponseItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range dialogs {
+		if !updatedBefore(d.UpdatedAt, s.dialogs[d.ID].UpdatedAt) {
+			s.dialogs[d.ID] = d
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryMirrorStore) PutMessages(_ context.Context, messages []MessageListResponseItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range messages {
+		if !updatedBefore(m.UpdatedAt, s.messages[m.ID].UpdatedAt) {
+			s.messages[m.ID] = m
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryMirrorStore) PutCustomers(_ context.Context, customers []Customer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range customers {
+		if !updatedBefore(c.UpdatedAt, s.customers[c.ID].UpdatedAt) {
+			s.customers[c.ID] = c
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryMirrorStore) DeleteChats(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.chats, id)
+	}
+	for id, d := range s.dialogs {
+		if slices.Contains(ids, d.ChatID) {
+			delete(s.dialogs, id)
+		}
+	}
+	for id, m := range s.messages {
+		if slices.Contains(ids, m.ChatID) {
+			delete(s.messages, id)
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryMirrorStore) DeleteMessages(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		delete(s.messages, id)
+	}
+
+	return nil
+}
+
+func (s *MemoryMirrorStore) Chat(_ context.Context, id int64) (ChatsListResponseItem, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.chats[id]
+
+	return c, ok, nil
+}
+
+func (s *MemoryMirrorStore) Dialog(_ context.Context, id int64) (DialogListResponseItem, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.dialogs[id]
+
+	return d, ok, nil
+}
+
+func (s *MemoryMirrorStore) Message(_ context.Context, id int64) (MessageListResponseItem, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.messages[id]
+
+	return m, ok, nil
+}
+
+func (s *MemoryMirrorStore) Customer(_ context.Context, id int64) (Customer, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.customers[id]
+
+	return c, ok, nil
+}
+
+func (s *MemoryMirrorStore) Chats(context.Context) ([]ChatsListResponseItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sortedValues(s.chats, func(ChatsListResponseItem) bool { return true }), nil
+}
+
+func (s *MemoryMirrorStore) ChatDialogs(_ context.Context, chatID int64) ([]DialogListResponseItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sortedValues(s.dialogs, func(d DialogListResponseItem) bool { return d.ChatID == chatID }), nil
+}
+
+func (s *MemoryMirrorStore) ChatMessages(_ context.Context, chatID int64) ([]MessageListResponseItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sortedValues(s.messages, func(m MessageListResponseItem) bool { return m.ChatID == chatID }), nil
+}
+
+func (s *MemoryMirrorStore) Checkpoints(context.Context) (MirrorCheckpoints, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.checkpoints, nil
+}
+
+func (s *MemoryMirrorStore) SaveCheckpoints(_ context.Context, checkpoints MirrorCheckpoints) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints = checkpoints
+
+	return nil
+}
+
+// updatedBefore reports whether an entity updated at t was updated before one updated at stored.
+// Entities without an update time are not ordered.
+func updatedBefore(t, stored *DateTimeRFC3339Micro) bool {
+	return t != nil && stored != nil && t.Time.Before(stored.Time)
+}
+
+// sortedValues returns the values matching the filter ordered by their map key.
+func sortedValues[T any](m map[int64]T, filter func(T) bool) []T {
+	keys := make([]int64, 0, len(m))
+	for k, v := range m {
+		if filter(v) {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, cmp.Compare)
+
+	values := make([]T, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+
+	return values
+}
+
+// fileMirrorRecord is a line of the FileMirrorStore log.
+type fileMirrorRecord struct {
+	Op     string          `json:"op"`
+	Entity string          `json:"entity,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	IDs    []int64         `json:"ids,omitempty"`
+}
+
+const (
+	mirrorOpPut         = "put"
+	mirrorOpDelete      = "delete"
+	mirrorOpCheckpoints = "checkpoints"
+
+	mirrorEntityChats     = "chats"
+	mirrorEntityDialogs   = "dialogs"
+	mirrorEntityMessages  = "messages"
+	mirrorEntityCustomers = "customers"
+
+	// compactMinEntities is the number of entities in the file below which it is never compacted automatically.
+	compactMinEntities = 1000
+)
+
+// FileMirrorStore is a MirrorStore persisted to a single file. Entities are kept in memory and
+// every change is appended to the file as a JSON line, which is replayed when the store is opened.
+// The file is compacted when it holds several times as many entities as the store.
+type FileMirrorStore struct {
+	*MemoryMirrorStore
+
+	mu   sync.Mutex
+	path string
+	file *os.File
+	// entities is the number of entities written to the file, counting the checkpoints as one.
+	entities int
+}
+
+// OpenFileMirrorStore opens the store at path, creating the file if it does not exist.
+// A record cut short by a crash at the end of the file is discarded.
+func OpenFileMirrorStore(path string) (*FileMirrorStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileMirrorStore{MemoryMirrorStore: NewMemoryMirrorStore(), path: path, file: file}
+	if err = s.replay(); err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("open mirror store %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *FileMirrorStore) replay() error {
+	ctx := context.Background()
+	reader := bufio.NewReader(s.file)
+
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A line without the trailing newline was not written completely.
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var record fileMirrorRecord
+		if err = json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("record at offset %d: %w", valid, err)
+		}
+		n, err := applyMirrorRecord(ctx, s.MemoryMirrorStore, record)
+		if err != nil {
+			return fmt.Errorf("record at offset %d: %w", valid, err)
+		}
+
+		valid += int64(len(line))
+		s.entities += n
+	}
+
+	if err := s.file.Truncate(valid); err != nil {
+		return err
+	}
+	_, err := s.file.Seek(valid, io.SeekStart)
+
+	return err
+}
+
+// applyMirrorRecord applies the record to the store and returns the number of entities it holds.
+func applyMirrorRecord(ctx context.Context, store *MemoryMirrorStore, record fileMirrorRecord) (int, error) {
+	switch record.Op {
+	case mirrorOpCheckpoints:
+		var checkpoints MirrorCheckpoints
+		if err := json.Unmarshal(record.Data, &checkpoints); err != nil {
+			return 0, err
+		}
+
+		return 1, store.SaveCheckpoints(ctx, checkpoints)
+	case mirrorOpDelete:
+		switch record.Entity {
+		case mirrorEntityChats:
+			return len(record.IDs), store.DeleteChats(ctx, record.IDs)
+		case mirrorEntityMessages:
+			return len(record.IDs), store.DeleteMessages(ctx, record.IDs)
+		}
+	case mirrorOpPut:
+		switch record.Entity {
+		case mirrorEntityChats:
+			return putRecord(ctx, record.Data, store.PutChats)
+		case mirrorEntityDialogs:
+			return putRecord(ctx, record.Data, store.PutDialogs)
+		case mirrorEntityMessages:
+			return putRecord(ctx, record.Data, store.PutMessages)
+		case mirrorEntityCustomers:
+			return putRecord(ctx, record.Data, store.PutCustomers)
+		}
+	}
+
+	return 0, fmt.Errorf("unknown record %s %s", record.Op, record.Entity)
+}
+
+func putRecord[T any](ctx context.Context, data json.RawMessage, put func(context.Context, []T) error) (int, error) {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return 0, err
+	}
+
+	return len(items), put(ctx, items)
+}
+
+// append writes the record to the file and then applies it to the memory store.
+func (s *FileMirrorStore) append(ctx context.Context, record fileMirrorRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if _, err = s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	n, err := applyMirrorRecord(ctx, s.MemoryMirrorStore, record)
+	s.entities += n
+	if err != nil {
+		return err
+	}
+
+	if s.entities > compactMinEntities && s.entities > 4*s.liveEntities() {
+		return s.compact()
+	}
+
+	return nil
+}
+
+func (s *FileMirrorStore) put(ctx context.Context, entity string, items any) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	return s.append(ctx, fileMirrorRecord{Op: mirrorOpPut, Entity: entity, Data: data})
+}
+
+func (s *FileMirrorStore) PutChats(ctx context.Context, chats []ChatsListResponseItem) error {
+	return s.put(ctx, mirrorEntityChats, chats)
+}
+
+func (s *FileMirrorStore) PutDialogs(ctx context.Context, dialogs []DialogListResponseItem) error {
+	return s.put(ctx, mirrorEntityDialogs, dialogs)
+}
+
+func (s *FileMirrorStore) PutMessages(ctx context.Context, messages []MessageListResponseItem) error {
+	return s.put(ctx, mirrorEntityMessages, messages)
+}
+
+func (s *FileMirrorStore) PutCustomers(ctx context.Context, customers []Customer) error {
+	return s.put(ctx, mirrorEntityCustomers, customers)
+}
+
+func (s *FileMirrorStore) DeleteChats(ctx context.Context, ids []int64) error {
+	return s.append(ctx, fileMirrorRecord{Op: mirrorOpDelete, Entity: mirrorEntityChats, IDs: ids})
+}
+
+func (s *FileMirrorStore) DeleteMessages(ctx context.Context, ids []int64) error {
+	return s.append(ctx, fileMirrorRecord{Op: mirrorOpDelete, Entity: mirrorEntityMessages, IDs: ids})
+}
+
+func (s *FileMirrorStore) SaveCheckpoints(ctx context.Context, checkpoints MirrorCheckpoints) error {
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+
+	return s.append(ctx, fileMirrorRecord{Op: mirrorOpCheckpoints, Data: data})
+}
+
+// Compact rewrites the file with the current contents of the store.
+func (s *FileMirrorStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return os.ErrClosed
+	}
+
+	return s.compact()
+}
+
+func (s *FileMirrorStore) liveEntities() int {
+	m := s.MemoryMirrorStore
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.chats) + len(m.dialogs) + len(m.messages) + len(m.customers)
+}
+
+// compact writes the contents to a temporary file, one record per entity type, and replaces the file with it.
+func (s *FileMirrorStore) compact() error {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	write := func(op, entity string, v any) {
+		if err != nil {
+			return
+		}
+
+		var data []byte
+		if data, err = json.Marshal(v); err == nil {
+			err = json.NewEncoder(&buf).Encode(fileMirrorRecord{Op: op, Entity: entity, Data: data})
+		}
+	}
+
+	m := s.MemoryMirrorStore
+	m.mu.RLock()
+	write(mirrorOpCheckpoints, "", m.checkpoints)
+	write(mirrorOpPut, mirrorEntityChats, sortedValues(m.chats, func(ChatsListResponseItem) bool { return true }))
+	write(mirrorOpPut, mirrorEntityDialogs, sortedValues(m.dialogs, func(DialogListResponseItem) bool { return true }))
+	write(mirrorOpPut, mirrorEntityMessages, sortedValues(m.messages, func(MessageListResponseItem) bool { return true }))
+	write(mirrorOpPut, mirrorEntityCustomers, sortedValues(m.customers, func(Customer) bool { return true }))
+	entities := 1 + len(m.chats) + len(m.dialogs) + len(m.messages) + len(m.customers)
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err = writeSynced(tmp, buf.Bytes()); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_ = s.file.Close()
+	s.file, s.entities = file, entities
+
+	return nil
+}
+
+// writeSynced writes the data to the file and syncs it, so it is on disk before the file replaces another.
+func writeSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	return errors.Join(err, file.Close())
+}
+
+// Close syncs and closes the file.
+func (s *FileMirrorStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := errors.Join(s.file.Sync(), s.file.Close())
+	s.file = nil
+
+	return err
+}
@@ -0,0 +1,168 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fillMirrorStore(t *testing.T, store MirrorStore) {
+	ctx := context.Background()
+
+	require.NoError(t, store.PutChats(ctx, []ChatsListResponseItem{{ID: 2}, {ID: 1}}))
+	require.NoError(t, store.PutDialogs(ctx, []DialogListResponseItem{{ID: 3, ChatID: 1}, {ID: 4, ChatID: 2}}))
+	require.NoError(t, store.PutMessages(ctx, []MessageListResponseItem{
+		{ID: 6, ChatID: 1}, {ID: 5, ChatID: 1}, {ID: 7, ChatID: 2},
+	}))
+	require.NoError(t, store.PutCustomers(ctx, []Customer{{ID: 8}}))
+	require.NoError(t, store.DeleteMessages(ctx, []int64{6}))
+	require.NoError(t, store.DeleteChats(ctx, []int64{2}))
+	require.NoError(t, store.SaveCheckpoints(ctx, MirrorCheckpoints{
+		Chats: MirrorCheckpoint{Since: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}))
+}
+
+func assertMirrorStoreContents(t *testing.T, store MirrorStore) {
+	ctx := context.Background()
+
+	chats, err := store.Chats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []ChatsListResponseItem{{ID: 1}}, chats)
+
+	dialogs, err := store.ChatDialogs(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []DialogListResponseItem{{ID: 3, ChatID: 1}}, dialogs)
+	_, ok, err := store.Dialog(ctx, 4)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	messages, err := store.ChatMessages(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []MessageListResponseItem{{ID: 5, ChatID: 1}}, messages)
+	_, ok, err = store.Message(ctx, 7)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	customer, ok, err := store.Customer(ctx, 8)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), customer.ID)
+
+	checkpoints, err := store.Checkpoints(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), checkpoints.Chats.Since.UTC())
+}
+
+func TestMemoryMirrorStore(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryMirrorStore()
+	fillMirrorStore(t, store)
+	assertMirrorStoreContents(t, store)
+}
+
+func TestFileMirrorStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("replays the log", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "mirror.jsonl")
+		store, err := OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		fillMirrorStore(t, store)
+		assertMirrorStoreContents(t, store)
+		require.NoError(t, store.Close())
+
+		require.ErrorIs(t, store.PutCustomers(context.Background(), []Customer{{ID: 9}}), os.ErrClosed)
+
+		store, err = OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+		assertMirrorStoreContents(t, store)
+	})
+
+	t.Run("discards a truncated record", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "mirror.jsonl")
+		store, err := OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		fillMirrorStore(t, store)
+		require.NoError(t, store.Close())
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"op":"put","entity":"chats","data":[{"id":`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		store, err = OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+		assertMirrorStoreContents(t, store)
+
+		require.NoError(t, store.PutCustomers(context.Background(), []Customer{{ID: 9}}))
+		reopened, err := OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		defer reopened.Close()
+		_, ok, err := reopened.Customer(context.Background(), 9)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("compacts", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "mirror.jsonl")
+		store, err := OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		fillMirrorStore(t, store)
+		for range compactMinEntities {
+			require.NoError(t, store.PutChats(context.Background(), []ChatsListResponseItem{{ID: 1}}))
+		}
+		require.NoError(t, store.Close())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Less(t, bytes.Count(data, []byte("\n")), compactMinEntities)
+
+		store, err = OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+		assertMirrorStoreContents(t, store)
+
+		require.NoError(t, store.Compact())
+		data, err = os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, 5, bytes.Count(data, []byte("\n")))
+	})
+
+	t.Run("counts the entities of every record", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "mirror.jsonl")
+		store, err := OpenFileMirrorStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+
+		page := make([]ChatsListResponseItem, 100)
+		for i := range page {
+			page[i].ID = int64(i + 1)
+		}
+		// Few records, but many times more entities than the store holds.
+		for range 12 {
+			require.NoError(t, store.PutChats(context.Background(), page))
+		}
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Less(t, bytes.Count(data, []byte("\n")), 12)
+	})
+}
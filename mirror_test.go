@@ -0,0 +1,256 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// mirrorServer serves list requests from a fixed number of entities per path, paged by since_id and limit.
+type mirrorServer struct {
+	fakeAPI
+	counts   map[string]int64
+	requests []string
+	// failAt makes the request with this index fail.
+	failAt int
+}
+
+func (s *mirrorServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		s.requests = append(s.requests, req.URL.Path+"?"+req.URL.RawQuery)
+		if len(s.requests) == s.failAt {
+			return http.StatusInternalServerError, `{"errors": ["temporary failure"]}`
+		}
+
+		query := req.URL.Query()
+		sinceID, _ := strconv.ParseInt(query.Get("since_id"), 10, 64)
+		limit, err := strconv.ParseInt(query.Get("limit"), 10, 64)
+		if !assert.NoError(t, err) {
+			return http.StatusBadRequest, nil
+		}
+
+		var items []string
+		for id := sinceID + 1; id <= s.counts[req.URL.Path] && int64(len(items)) < limit; id++ {
+			items = append(items, fmt.Sprintf(`{"id": %d, "chat_id": %d}`, id, (id+1)/2))
+		}
+
+		return http.StatusOK, "[" + strings.Join(items, ",") + "]"
+	})
+}
+
+func (s *mirrorServer) pathRequests(path string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []string
+	for _, r := range s.requests {
+		if strings.HasPrefix(r, path+"?") {
+			requests = append(requests, strings.TrimPrefix(r, path+"?"))
+		}
+	}
+
+	return requests
+}
+
+func TestMirrorSync(t *testing.T) {
+	t.Parallel()
+
+	counts := map[string]int64{"/chats": 3, "/dialogs": 4, "/messages": 5, "/customers": 1}
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("backfills and continues from the last pass", func(t *testing.T) {
+		t.Parallel()
+
+		server := mirrorServer{counts: counts}
+		store := NewMemoryMirrorStore()
+		mirror := NewMirror(server.client(t), store, WithMirrorPageSize(2))
+		mirror.now = func() time.Time { return started }
+
+		require.NoError(t, mirror.Sync(context.Background()))
+
+		chats, err := store.Chats(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, chats, 3)
+		messages, err := store.ChatMessages(context.Background(), 2)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, []int64{3, 4}, []int64{messages[0].ID, messages[1].ID})
+		_, ok, err := store.Customer(context.Background(), 1)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.Equal(t, []string{"limit=2", "limit=2&since_id=2", "limit=2&since_id=4"}, server.pathRequests("/messages"))
+
+		checkpoints, err := store.Checkpoints(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MirrorCheckpoint{Since: started}, checkpoints.Messages)
+
+		require.NoError(t, mirror.Sync(context.Background()))
+		assert.Equal(t, "limit=2&since=2024-05-01T12%3A00%3A00Z", server.pathRequests("/messages")[3])
+	})
+
+	t.Run("resumes an interrupted pass", func(t *testing.T) {
+		t.Parallel()
+
+		// The second messages request follows two chats and three dialogs requests.
+		server := mirrorServer{counts: counts, failAt: 2 + 3 + 2}
+		store := NewMemoryMirrorStore()
+		mirror := NewMirror(server.client(t), store, WithMirrorPageSize(2))
+		mirror.now = func() time.Time { return started }
+
+		require.ErrorContains(t, mirror.Sync(context.Background()), "mirror sync messages: temporary failure")
+
+		checkpoints, err := store.Checkpoints(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MirrorCheckpoint{SinceID: 2, Started: started}, checkpoints.Messages)
+		assert.Equal(t, MirrorCheckpoint{Since: started}, checkpoints.Dialogs)
+
+		restarted := NewMirror(server.client(t), store, WithMirrorPageSize(2))
+		restarted.now = func() time.Time { return started.Add(time.Hour) }
+		require.NoError(t, restarted.Sync(context.Background()))
+
+		assert.Equal(t, []string{
+			"limit=2", "limit=2&since_id=2", "limit=2&since_id=2", "limit=2&since_id=4",
+		}, server.pathRequests("/messages"))
+
+		checkpoints, err = store.Checkpoints(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MirrorCheckpoint{Since: started}, checkpoints.Messages)
+		assert.Equal(t, MirrorCheckpoint{Since: started.Add(time.Hour)}, checkpoints.Chats)
+
+		messages, err := store.ChatMessages(context.Background(), 3)
+		require.NoError(t, err)
+		assert.Len(t, messages, 1)
+	})
+
+	t.Run("backfill since", func(t *testing.T) {
+		t.Parallel()
+
+		server := mirrorServer{counts: map[string]int64{}}
+		mirror := NewMirror(server.client(t), NewMemoryMirrorStore(), WithMirrorBackfillSince(started))
+
+		require.NoError(t, mirror.Sync(context.Background()))
+		assert.Equal(t, []string{"limit=100&since=2024-05-01T12%3A00%3A00Z"}, server.pathRequests("/chats"))
+	})
+}
+
+func TestMirrorApply(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	channelID := int64(7)
+
+	store := NewMemoryMirrorStore()
+	require.NoError(t, store.PutMessages(ctx, []MessageListResponseItem{{ID: 2, ChatID: 10, ChannelID: &channelID}}))
+
+	var passed int
+	handler := NewMirror(nil, store).Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+		passed++
+
+		return nil
+	})
+	apply := func(eventType ws.EventTypeSchema, data any) {
+		require.NoError(t, handler(ctx, ws.EventMessageFromEventsChannel{
+			Payload: ws.EventSchema{Type: eventType, Data: data},
+		}))
+	}
+
+	apply(ws.EventTypeChatCrated, ws.ChatDataSchema{Chat: ws.ChatSchema{Id: 10, CreatedAt: created}})
+	require.NoError(t, handler(ctx, messageEvent(1, ws.UserTypeCustomer, "hello")))
+
+	updated := messageEvent(2, ws.UserTypeCustomer, "edited")
+	data := updated.Payload.Data.(ws.MessageDataSchema)
+	data.Message.EditedAt = &created
+	updated.Payload.Type, updated.Payload.Data = ws.EventTypeMessageUpdated, data
+	require.NoError(t, handler(ctx, updated))
+
+	message, ok, err := store.Message(ctx, 2)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "edited", *message.Content)
+	assert.Equal(t, &channelID, message.ChannelID)
+	assert.Equal(t, created, message.UpdatedAt.Time)
+
+	apply(ws.EventTypeMessageDeleted, ws.MessageDataSchema{Message: ws.MessagePropertyFromMessageDataSchema{Id: 1}})
+	_, ok, err = store.Message(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	apply(ws.EventTypeDialogAssign, ws.DialogAssignDataSchema{
+		Chat: ws.ChatSchema{Id: 10, CreatedAt: created},
+		Dialog: ws.DialogEventSchema{
+			Id: 5, CreatedAt: created, Responsible: &ws.ResponsibleSchema{Id: 3, Type: "user"},
+		},
+	})
+	closedAt := created.Add(time.Hour)
+	apply(ws.EventTypeDialogClosed, ws.DialogDataSchema{Dialog: ws.DialogEventSchema{Id: 5, ClosedAt: &closedAt}})
+
+	dialogs, err := store.ChatDialogs(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, dialogs, 1)
+	assert.False(t, dialogs[0].IsActive)
+	assert.False(t, dialogs[0].IsAssigned)
+	assert.Equal(t, created, dialogs[0].CreatedAt.Time)
+	assert.Equal(t, closedAt, dialogs[0].ClosedAt.Time)
+
+	apply(ws.EventTypeChatsDeleted, ws.ChatsDeletedDataSchema{ChatIds: []int64{10}})
+	chats, err := store.Chats(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, chats)
+	dialogs, err = store.ChatDialogs(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, dialogs)
+
+	assert.Equal(t, 7, passed)
+}
+
+func TestMirrorUpdateOrder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	store := NewMemoryMirrorStore()
+	mirror := NewMirror(nil, store)
+	renamed := func(name string, at time.Time) ws.EventSchema {
+		return ws.EventSchema{
+			Type: ws.EventTypeChatUpdated,
+			Data: ws.ChatDataSchema{Chat: ws.ChatSchema{Id: 10, CreatedAt: at, Name: &name}},
+			Meta: ws.MetaSchema{Timestamp: at.Unix()},
+		}
+	}
+	name := func() string {
+		chat, ok, err := store.Chat(ctx, 10)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		return deref(chat.Name)
+	}
+
+	require.NoError(t, mirror.Apply(ctx, renamed("event", at)))
+	assert.Equal(t, "event", name())
+
+	// A page fetched before the event does not overwrite it.
+	require.NoError(t, store.PutChats(ctx, []ChatsListResponseItem{{
+		ID: 10, Name: optional("stale page"), UpdatedAt: &DateTimeRFC3339Micro{Time: at.Add(-time.Minute)},
+	}}))
+	assert.Equal(t, "event", name())
+
+	require.NoError(t, mirror.Apply(ctx, renamed("late event", at.Add(-time.Hour))))
+	assert.Equal(t, "event", name())
+
+	require.NoError(t, store.PutChats(ctx, []ChatsListResponseItem{{
+		ID: 10, Name: optional("page"), UpdatedAt: &DateTimeRFC3339Micro{Time: at.Add(time.Minute)},
+	}}))
+	assert.Equal(t, "page", name())
+}
@@ -0,0 +1,501 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// DefaultPreflightTTL is how long resolved chat channels and channel settings are cached by Preflight.
+const DefaultPreflightTTL = 5 * time.Minute
+
+var (
+	// ErrChatNotFound is returned when the chat is not visible to the bot.
+	ErrChatNotFound = errors.New("chat not found")
+	// ErrChannelNotFound is returned when the chat's channel is not visible to the bot.
+	ErrChannelNotFound = errors.New("channel not found")
+)
+
+// ViolationCode identifies a channel capability an outgoing message does not satisfy.
+type ViolationCode string
+
+const (
+	ViolationMessageTypeUnsupported ViolationCode = "message_type_unsupported"
+	ViolationEditingUnsupported     ViolationCode = "editing_unsupported"
+	ViolationQuotingUnsupported     ViolationCode = "quoting_unsupported"
+	ViolationTextTooLong            ViolationCode = "text_too_long"
+	ViolationNoteTooLong            ViolationCode = "note_too_long"
+	ViolationTooManyItems           ViolationCode = "too_many_items"
+	ViolationItemTooLarge           ViolationCode = "item_too_large"
+	ViolationSuggestionUnsupported  ViolationCode = "suggestion_unsupported"
+)
+
+// Violation describes a single mismatch between a message and the channel settings.
+type Violation struct {
+	Code ViolationCode
+	// Field is the JSON path of the offending field, e.g. "content" or "transport_attachments.suggestions[0].type".
+	Field string
+	// Limit and Actual are set for length, count and size violations.
+	Limit  int64
+	Actual int64
+	// Message is a human-readable description of the problem.
+	Message string
+}
+
+func (v Violation) Error() string {
+	return v.Message
+}
+
+// Violations is a list of channel capability violations.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, violation := range v {
+		msgs = append(msgs, violation.Error())
+	}
+
+	return "message is not supported by the channel: " + strings.Join(msgs, "; ")
+}
+
+// Has reports whether the list contains a violation with the given code.
+func (v Violations) Has(code ViolationCode) bool {
+	for _, violation := range v {
+		if violation.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns nil when the list is empty and the list itself otherwise.
+func (v Violations) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+
+	return v
+}
+
+func (v *Violations) add(code ViolationCode, field string, limit, actual int64, format string, args ...interface{}) {
+	*v = append(*v, Violation{
+		Code:    code,
+		Field:   field,
+		Limit:   limit,
+		Actual:  actual,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// CanSend reports whether the bot may perform the operation towards the customer.
+// Unset values are treated as unsupported.
+func (f ChannelFeature) CanSend() bool {
+	return f == ChannelFeatureSend || f == ChannelFeatureBoth
+}
+
+// TextLength returns the length of a text as counted by channel limits (UTF-16 code units).
+func TextLength(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+
+	return n
+}
+
+// CheckSend validates an outgoing message against the channel settings.
+// Private messages are never delivered to the channel, so they are not checked.
+func CheckSend(settings ChannelSettings, body SendMessageRequestBody) Violations {
+	if body.Scope == MessageScopePrivate {
+		return nil
+	}
+
+	msgType := MessageTypeText
+	if body.Type != nil {
+		msgType = *body.Type
+	}
+
+	var violations Violations
+
+	creating, ok := creatingFeature(settings, msgType)
+	if !ok || !creating.CanSend() {
+		violations.add(ViolationMessageTypeUnsupported, "type", 0, 0,
+			"sending %s messages is not supported by the channel", msgType)
+
+		return violations
+	}
+
+	var items int
+	if body.Items != nil {
+		items = len(*body.Items)
+	}
+
+	checkContent(&violations, settings, msgType, messageContent{
+		content:     body.Content,
+		note:        body.Note,
+		items:       items,
+		quote:       body.QuoteMessageID,
+		attachments: body.TransportAttachments,
+	})
+
+	return violations
+}
+
+// CheckEdit validates an edit of a message of the given type against the channel settings.
+func CheckEdit(settings ChannelSettings, msgType MessageType, body EditMessageJSONRequestBody) Violations {
+	var violations Violations
+
+	editing, ok := editingFeature(settings, msgType)
+	if !ok || !editing.CanSend() {
+		violations.add(ViolationEditingUnsupported, "type", 0, 0,
+			"editing %s messages is not supported by the channel", msgType)
+
+		return violations
+	}
+
+	var items int
+	if body.Items != nil {
+		items = len(*body.Items)
+	}
+
+	checkContent(&violations, settings, msgType, messageContent{
+		content:     body.Content,
+		note:        body.Note,
+		items:       items,
+		quote:       body.QuoteMessageID,
+		attachments: body.TransportAttachments,
+	})
+
+	return violations
+}
+
+// CheckItemSize validates the size in bytes of a single attachment of the given message type.
+func CheckItemSize(settings ChannelSettings, msgType MessageType, size int64) Violations {
+	limit := itemSizeLimit(settings, msgType)
+
+	var violations Violations
+	if limit != nil && size > *limit {
+		violations.add(ViolationItemTooLarge, "items", *limit, size,
+			"%s attachment is %d bytes, the channel accepts at most %d", msgType, size, *limit)
+	}
+
+	return violations
+}
+
+// messageContent holds the parts of a send or edit request that are limited by channel settings.
+type messageContent struct {
+	content     *string
+	note        *string
+	items       int
+	quote       int64
+	attachments *MessageTransportAttachments
+}
+
+func checkContent(violations *Violations, settings ChannelSettings, msgType MessageType, msg messageContent) {
+	if msg.quote != 0 {
+		if quoting, _ := quotingFeature(settings, msgType); !quoting.CanSend() {
+			violations.add(ViolationQuotingUnsupported, "quote_message_id", 0, 0,
+				"quoting in %s messages is not supported by the channel", msgType)
+		}
+	}
+
+	if msgType == MessageTypeText && msg.content != nil && settings.Text.MaxCharsCount != nil {
+		limit, n := int64(*settings.Text.MaxCharsCount), int64(TextLength(*msg.content))
+		if n > limit {
+			violations.add(ViolationTextTooLong, "content", limit, n,
+				"text is %d characters long, the channel accepts at most %d", n, limit)
+		}
+	}
+
+	maxItems, noteMax := itemLimits(settings, msgType)
+	if maxItems != nil && int64(msg.items) > int64(*maxItems) {
+		violations.add(ViolationTooManyItems, "items", int64(*maxItems), int64(msg.items),
+			"message has %d attachments, the channel accepts at most %d", msg.items, *maxItems)
+	}
+	if noteMax != nil && msg.note != nil {
+		limit, n := int64(*noteMax), int64(TextLength(*msg.note))
+		if n > limit {
+			violations.add(ViolationNoteTooLong, "note", limit, n,
+				"note is %d characters long, the channel accepts at most %d", n, limit)
+		}
+	}
+
+	if msg.attachments != nil {
+		for i, s := range msg.attachments.Suggestions {
+			if !suggestionFeature(settings.Suggestions, s.Type).CanSend() {
+				violations.add(ViolationSuggestionUnsupported,
+					fmt.Sprintf("transport_attachments.suggestions[%d].type", i), 0, 0,
+					"suggestion type %s unsupported by the channel", s.Type)
+			}
+		}
+	}
+}
+
+func creatingFeature(settings ChannelSettings, msgType MessageType) (ChannelFeature, bool) {
+	switch msgType {
+	case MessageTypeText:
+		return settings.Text.Creating, true
+	case MessageTypeFile:
+		return settings.File.Creating, true
+	case MessageTypeImage:
+		return settings.Image.Creating, true
+	case MessageTypeAudio:
+		return settings.Audio.Creating, true
+	case MessageTypeOrder:
+		return settings.Order.Creating, true
+	case MessageTypeProduct:
+		return settings.Product.Creating, true
+	}
+
+	return ChannelFeatureNone, false
+}
+
+// editingFeature returns the editing support for the message type.
+// Audio messages cannot be edited.
+func editingFeature(settings ChannelSettings, msgType MessageType) (ChannelFeature, bool) {
+	switch msgType {
+	case MessageTypeText:
+		return settings.Text.Editing, true
+	case MessageTypeFile:
+		return settings.File.Editing, true
+	case MessageTypeImage:
+		return settings.Image.Editing, true
+	case MessageTypeOrder:
+		return settings.Order.Editing, true
+	case MessageTypeProduct:
+		return settings.Product.Editing, true
+	}
+
+	return ChannelFeatureNone, false
+}
+
+func quotingFeature(settings ChannelSettings, msgType MessageType) (ChannelFeature, bool) {
+	switch msgType {
+	case MessageTypeText:
+		return settings.Text.Quoting, true
+	case MessageTypeFile:
+		return settings.File.Quoting, true
+	case MessageTypeImage:
+		return settings.Image.Quoting, true
+	case MessageTypeAudio:
+		return settings.Audio.Quoting, true
+	case MessageTypeOrder:
+		return settings.Order.Quoting, true
+	case MessageTypeProduct:
+		return settings.Product.Quoting, true
+	}
+
+	return ChannelFeatureNone, false
+}
+
+// itemLimits returns the attachment count and note length limits for the message type.
+func itemLimits(settings ChannelSettings, msgType MessageType) (*int, *uint16) {
+	switch msgType {
+	case MessageTypeFile:
+		return settings.File.MaxItemsCount, settings.File.NoteMaxCharsCount
+	case MessageTypeImage:
+		return settings.Image.MaxItemsCount, settings.Image.NoteMaxCharsCount
+	case MessageTypeAudio:
+		return settings.Audio.MaxItemsCount, nil
+	}
+
+	return nil, nil
+}
+
+// itemSizeLimit returns the maximum attachment size in bytes for the message type.
+func itemSizeLimit(settings ChannelSettings, msgType MessageType) *int64 {
+	switch msgType {
+	case MessageTypeFile:
+		return settings.File.MaxItemSize
+	case MessageTypeImage:
+		return settings.Image.MaxItemSize
+	case MessageTypeAudio:
+		return settings.Audio.MaxItemSize
+	}
+
+	return nil
+}
+
+func suggestionFeature(s Suggestions, t SuggestionType) ChannelFeature {
+	switch t {
+	case SuggestionTypeText:
+		return s.Text
+	case SuggestionTypeEmail:
+		return s.Email
+	case SuggestionTypePhone:
+		return s.Phone
+	case SuggestionTypeUrl:
+		return s.Url
+	}
+
+	return ChannelFeatureNone
+}
+
+// Preflight checks outgoing messages against the settings of the chat's channel.
+// Chat to channel mapping and channel settings are resolved through ListChats and ListChannels
+// and cached for the configured TTL. Preflight is safe for concurrent use.
+type Preflight struct {
+	client ClientWithResponsesInterface
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	chats    map[int64]cached[int64]
+	channels map[int64]cached[ChannelListResponseItem]
+}
+
+type cached[T any] struct {
+	value   T
+	expires time.Time
+}
+
+// PreflightOption configures a Preflight.
+type PreflightOption func(*Preflight)
+
+// WithPreflightTTL sets how long resolved channels are cached.
+func WithPreflightTTL(ttl time.Duration) PreflightOption {
+	return func(p *Preflight) {
+		p.ttl = ttl
+	}
+}
+
+// NewPreflight creates a Preflight that resolves channels with the given client.
+func NewPreflight(client ClientWithResponsesInterface, opts ...PreflightOption) *Preflight {
+	p := &Preflight{
+		client:   client,
+		ttl:      DefaultPreflightTTL,
+		now:      time.Now,
+		chats:    make(map[int64]cached[int64]),
+		channels: make(map[int64]cached[ChannelListResponseItem]),
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Channel returns the channel of the chat.
+func (p *Preflight) Channel(ctx context.Context, chatID int64) (ChannelListResponseItem, error) {
+	channelID, err := p.channelID(ctx, chatID)
+	if err != nil {
+		return ChannelListResponseItem{}, err
+	}
+
+	return p.channel(ctx, channelID)
+}
+
+// ChannelSettings returns the settings of the chat's channel.
+func (p *Preflight) ChannelSettings(ctx context.Context, chatID int64) (ChannelSettings, error) {
+	channel, err := p.Channel(ctx, chatID)
+	if err != nil {
+		return ChannelSettings{}, err
+	}
+
+	return channel.Settings, nil
+}
+
+// CheckSend validates an outgoing message against the settings of its chat's channel.
+// The returned error is only set when the channel could not be resolved.
+func (p *Preflight) CheckSend(ctx context.Context, body SendMessageRequestBody) (Violations, error) {
+	if body.Scope == MessageScopePrivate {
+		return nil, nil
+	}
+
+	settings, err := p.ChannelSettings(ctx, body.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	return CheckSend(settings, body), nil
+}
+
+// CheckEdit validates an edit of a message of the given type in the chat.
+// The returned error is only set when the channel could not be resolved.
+func (p *Preflight) CheckEdit(
+	ctx context.Context, chatID int64, msgType MessageType, body EditMessageJSONRequestBody,
+) (Violations, error) {
+	settings, err := p.ChannelSettings(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	return CheckEdit(settings, msgType, body), nil
+}
+
+// InvalidateChannel drops the cached settings of the channel, e.g. after a channel_updated event.
+func (p *Preflight) InvalidateChannel(channelID int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.channels, channelID)
+}
+
+func (p *Preflight) channelID(ctx context.Context, chatID int64) (int64, error) {
+	p.mu.Lock()
+	entry, ok := p.chats[chatID]
+	p.mu.Unlock()
+
+	if ok && p.now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	id := int(chatID)
+	resp, err := p.client.ListChatsWithResponse(ctx, &ListChatsParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return 0, fmt.Errorf("list chats: %w", err)
+	}
+
+	if resp.JSON200 == nil || len(*resp.JSON200) == 0 {
+		return 0, fmt.Errorf("chat %d: %w", chatID, ErrChatNotFound)
+	}
+
+	chat := (*resp.JSON200)[0]
+	if chat.Channel == nil {
+		return 0, fmt.Errorf("chat %d: %w", chatID, ErrChannelNotFound)
+	}
+
+	p.mu.Lock()
+	p.chats[chatID] = cached[int64]{value: chat.Channel.ID, expires: p.now().Add(p.ttl)}
+	p.mu.Unlock()
+
+	return chat.Channel.ID, nil
+}
+
+func (p *Preflight) channel(ctx context.Context, channelID int64) (ChannelListResponseItem, error) {
+	p.mu.Lock()
+	entry, ok := p.channels[channelID]
+	p.mu.Unlock()
+
+	if ok && p.now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	id := int(channelID)
+	resp, err := p.client.ListChannelsWithResponse(ctx, &ListChannelsParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return ChannelListResponseItem{}, fmt.Errorf("list channels: %w", err)
+	}
+
+	if resp.JSON200 != nil {
+		for _, channel := range *resp.JSON200 {
+			if channel.ID != channelID {
+				continue
+			}
+
+			p.mu.Lock()
+			p.channels[channelID] = cached[ChannelListResponseItem]{value: channel, expires: p.now().Add(p.ttl)}
+			p.mu.Unlock()
+
+			return channel, nil
+		}
+	}
+
+	return ChannelListResponseItem{}, fmt.Errorf("channel %d: %w", channelID, ErrChannelNotFound)
+}
@@ -0,0 +1,234 @@
+package bot_api_client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSend(t *testing.T) {
+	t.Parallel()
+
+	maxChars := uint16(10)
+	maxItems := 2
+	noteMax := uint16(5)
+	settings := ChannelSettings{
+		Text: TextMessageSetting{
+			Creating:      ChannelFeatureBoth,
+			Quoting:       ChannelFeatureReceive,
+			MaxCharsCount: &maxChars,
+		},
+		Image: ImageMessageSetting{
+			Creating:          ChannelFeatureSend,
+			Quoting:           ChannelFeatureBoth,
+			MaxItemsCount:     &maxItems,
+			NoteMaxCharsCount: &noteMax,
+		},
+		Order:       OrderMessageSetting{Creating: ChannelFeatureReceive},
+		Suggestions: Suggestions{Text: ChannelFeatureBoth, Phone: ChannelFeatureNone},
+	}
+
+	t.Run("supported message", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewText(1, "hello").Build()
+		require.NoError(t, err)
+		assert.Empty(t, CheckSend(settings, body))
+	})
+
+	t.Run("text limits and quoting", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewText(1, "hello world!").
+			Quote(5).
+			Suggestions(
+				Suggestion{Type: SuggestionTypeText, Title: "Yes"},
+				Suggestion{Type: SuggestionTypePhone, Title: "Call me"},
+			).
+			Build()
+		require.NoError(t, err)
+
+		violations := CheckSend(settings, body)
+		require.Len(t, violations, 3)
+		assert.Equal(t, ViolationQuotingUnsupported, violations[0].Code)
+		assert.Equal(t, Violation{
+			Code:    ViolationTextTooLong,
+			Field:   "content",
+			Limit:   10,
+			Actual:  12,
+			Message: "text is 12 characters long, the channel accepts at most 10",
+		}, violations[1])
+		assert.Equal(t, ViolationSuggestionUnsupported, violations[2].Code)
+		assert.Equal(t, "transport_attachments.suggestions[1].type", violations[2].Field)
+		assert.Equal(t, "suggestion type phone unsupported by the channel", violations[2].Message)
+		require.Error(t, violations.Err())
+	})
+
+	t.Run("text length counts utf-16 units", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, 2, TextLength("😀"))
+		assert.Equal(t, 3, TextLength("abc"))
+
+		body, err := NewText(1, strings.Repeat("😀", 6)).Build()
+		require.NoError(t, err)
+		assert.True(t, CheckSend(settings, body).Has(ViolationTextTooLong))
+	})
+
+	t.Run("items and note", func(t *testing.T) {
+		t.Parallel()
+
+		item := NewMessageItem(uuid.New(), "photo")
+		body, err := NewImages(1, item, item, item).Note("too long note").Quote(1).Build()
+		require.NoError(t, err)
+
+		violations := CheckSend(settings, body)
+		require.Len(t, violations, 2)
+		assert.Equal(t, ViolationTooManyItems, violations[0].Code)
+		assert.Equal(t, int64(2), violations[0].Limit)
+		assert.Equal(t, int64(3), violations[0].Actual)
+		assert.Equal(t, ViolationNoteTooLong, violations[1].Code)
+	})
+
+	t.Run("unsupported message type", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewOrder(1, MessageOrder{Number: "1"}).Build()
+		require.NoError(t, err)
+
+		violations := CheckSend(settings, body)
+		require.Len(t, violations, 1)
+		assert.Equal(t, ViolationMessageTypeUnsupported, violations[0].Code)
+	})
+
+	t.Run("private messages are not checked", func(t *testing.T) {
+		t.Parallel()
+
+		body, err := NewOrder(1, MessageOrder{Number: "1"}).Private().Build()
+		require.NoError(t, err)
+		assert.Empty(t, CheckSend(settings, body))
+	})
+}
+
+func TestCheckEdit(t *testing.T) {
+	t.Parallel()
+
+	settings := ChannelSettings{
+		Text:  TextMessageSetting{Editing: ChannelFeatureBoth},
+		Audio: AudioMessageSetting{Creating: ChannelFeatureBoth},
+	}
+
+	content := "fixed"
+	assert.Empty(t, CheckEdit(settings, MessageTypeText, EditMessageJSONRequestBody{Content: &content}))
+
+	violations := CheckEdit(settings, MessageTypeAudio, EditMessageJSONRequestBody{})
+	require.Len(t, violations, 1)
+	assert.Equal(t, ViolationEditingUnsupported, violations[0].Code)
+
+	violations = CheckEdit(settings, MessageTypeImage, EditMessageJSONRequestBody{})
+	assert.True(t, violations.Has(ViolationEditingUnsupported))
+}
+
+func TestCheckItemSize(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1024)
+	settings := ChannelSettings{File: FileMessageSetting{MaxItemSize: &limit}}
+
+	assert.Empty(t, CheckItemSize(settings, MessageTypeFile, 1024))
+	assert.True(t, CheckItemSize(settings, MessageTypeFile, 1025).Has(ViolationItemTooLarge))
+	assert.Empty(t, CheckItemSize(settings, MessageTypeImage, 1<<30))
+}
+
+func TestPreflight(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, chats, channels *int32) *ClientWithResponses {
+		mockDoer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			var body string
+			switch req.URL.Path {
+			case "/chats":
+				atomic.AddInt32(chats, 1)
+				assert.Equal(t, "10", req.URL.Query().Get("id"))
+				body = `[{"id": 10, "channel": {"id": 3, "settings": {}}}]`
+			case "/channels":
+				atomic.AddInt32(channels, 1)
+				assert.Equal(t, "3", req.URL.Query().Get("id"))
+				body = `[{"id": 3, "settings": {"text": {"creating": "both", "quoting": "none"}}}]`
+			default:
+				t.Fatalf("unexpected request %s", req.URL.Path)
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		})
+
+		client, err := NewClientWithResponses("https://example.com", WithHTTPClient(mockDoer))
+		require.NoError(t, err)
+
+		return client
+	}
+
+	t.Run("resolves and caches channel settings", func(t *testing.T) {
+		t.Parallel()
+
+		var chats, channels int32
+		now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+		p := NewPreflight(newClient(t, &chats, &channels), WithPreflightTTL(time.Minute))
+		p.now = func() time.Time { return now }
+
+		body, err := NewText(10, "hi").Quote(1).Build()
+		require.NoError(t, err)
+
+		violations, err := p.CheckSend(context.Background(), body)
+		require.NoError(t, err)
+		require.Len(t, violations, 1)
+		assert.Equal(t, ViolationQuotingUnsupported, violations[0].Code)
+
+		_, err = p.CheckSend(context.Background(), body)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&chats))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&channels))
+
+		p.InvalidateChannel(3)
+		_, err = p.ChannelSettings(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&chats))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&channels))
+
+		now = now.Add(2 * time.Minute)
+		channel, err := p.Channel(context.Background(), 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), channel.ID)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&chats))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&channels))
+	})
+
+	t.Run("chat not found", func(t *testing.T) {
+		t.Parallel()
+
+		mockDoer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`[]`)),
+			}, nil
+		})
+		client, err := NewClientWithResponses("https://example.com", WithHTTPClient(mockDoer))
+		require.NoError(t, err)
+
+		_, err = NewPreflight(client).Channel(context.Background(), 10)
+		require.ErrorIs(t, err, ErrChatNotFound)
+	})
+}
@@ -0,0 +1,428 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultSendFilesConcurrency is the number of files SendFiles uploads at the same time.
+const DefaultSendFilesConcurrency = 4
+
+// OutgoingFile is a file to send with SendFiles.
+type OutgoingFile struct {
+	// Path is the file to upload. It is ignored if Reader is set.
+	Path string
+	// Reader provides the contents of the file, Size bytes long or -1 if the size is unknown.
+	Reader io.Reader
+	Size   int64
+	// Name is the file name. It defaults to the base name of Path.
+	Name string
+	// Caption is the caption of the attachment. It defaults to Name.
+	Caption string
+}
+
+// UploadedFile is a file uploaded by SendFiles.
+type UploadedFile struct {
+	Name string
+	File UploadResponse
+	// MessageType is the type of message the file is sent with.
+	MessageType MessageType
+}
+
+// SendFilesResult describes the files uploaded and the messages sent by SendFiles.
+type SendFilesResult struct {
+	// Uploaded lists the uploaded files in the order they were passed.
+	Uploaded []UploadedFile
+	Sent     []SendMessageResponse
+	// Adaptations lists how the messages were changed to fit the channel, e.g. split into several messages.
+	Adaptations []Adaptation
+}
+
+// FileError is an error of a single file passed to SendFiles.
+type FileError struct {
+	// Index is the position of the file in the list passed to SendFiles.
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// SendFilesError is returned by SendFiles when the workflow fails part way.
+// Files are checked against the channel limits before anything is uploaded and all messages are
+// prepared before the first one is sent, so Result shows how far the workflow got.
+// Uploaded files cannot be deleted through the API; they are listed in Result and may be sent later.
+type SendFilesError struct {
+	// Files lists the files that failed to upload or do not fit the channel limits.
+	Files []FileError
+	// Err is the failure not related to a single file, e.g. resolving the channel or sending a message.
+	Err    error
+	Result SendFilesResult
+}
+
+func (e *SendFilesError) Error() string {
+	parts := make([]string, 0, len(e.Files)+1)
+	for _, f := range e.Files {
+		parts = append(parts, f.Error())
+	}
+	if e.Err != nil {
+		parts = append(parts, e.Err.Error())
+	}
+
+	msg := "send files: " + strings.Join(parts, "; ")
+	if len(e.Result.Uploaded) > 0 || len(e.Result.Sent) > 0 {
+		msg += fmt.Sprintf(" (%d files uploaded, %d messages sent)", len(e.Result.Uploaded), len(e.Result.Sent))
+	}
+
+	return msg
+}
+
+func (e *SendFilesError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Files)+1)
+	for _, f := range e.Files {
+		errs = append(errs, f)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+
+	return errs
+}
+
+// SendFilesOption configures SendFiles.
+type SendFilesOption func(*sendFilesOptions)
+
+type sendFilesOptions struct {
+	concurrency int
+	note        *string
+	quote       int64
+	preflight   *Preflight
+	uploadOpts  []UploadOption
+	reqEditors  []RequestEditorFn
+}
+
+// WithSendFilesConcurrency sets the number of files uploaded at the same time.
+func WithSendFilesConcurrency(n int) SendFilesOption {
+	return func(o *sendFilesOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithSendFilesNote sets the annotation of the first message sent.
+func WithSendFilesNote(note string) SendFilesOption {
+	return func(o *sendFilesOptions) {
+		o.note = &note
+	}
+}
+
+// WithSendFilesQuote makes the first message sent a reply to the message with the given ID.
+func WithSendFilesQuote(messageID int64) SendFilesOption {
+	return func(o *sendFilesOptions) {
+		o.quote = messageID
+	}
+}
+
+// WithSendFilesPreflight resolves channel settings with the preflight, sharing its cache.
+// By default a new Preflight is created for each call.
+func WithSendFilesPreflight(p *Preflight) SendFilesOption {
+	return func(o *sendFilesOptions) {
+		o.preflight = p
+	}
+}
+
+// WithSendFilesUploadOptions applies upload options to every file.
+func WithSendFilesUploadOptions(opts ...UploadOption) SendFilesOption {
+	return func(o *sendFilesOptions) {
+		o.uploadOpts = append(o.uploadOpts, opts...)
+	}
+}
+
+// WithSendFilesRequestEditors adds request editors to the send message requests.
+func WithSendFilesRequestEditors(reqEditors ...RequestEditorFn) SendFilesOption {
+	return func(o *sendFilesOptions) {
+		o.reqEditors = append(o.reqEditors, reqEditors...)
+	}
+}
+
+// SendFiles uploads files with the client and sends them to the chat:
+//   - files larger than the channel accepts for any attachment type are rejected before uploading;
+//   - files are uploaded in parallel and the remaining uploads are cancelled after the first failure;
+//   - each file is sent in an image, audio or file message depending on the file type detected by the server,
+//     or in a file message if the channel cannot send images or audio;
+//   - consecutive files of the same type are sent together, split into several messages to fit MaxItemsCount.
+//
+// The returned error is of type *SendFilesError.
+func SendFiles(
+	ctx context.Context, client ClientWithResponsesInterface, chatID int64, files []OutgoingFile, opts ...SendFilesOption,
+) (SendFilesResult, error) {
+	o := sendFilesOptions{concurrency: DefaultSendFilesConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.preflight == nil {
+		o.preflight = NewPreflight(client)
+	}
+
+	settings, err := o.preflight.ChannelSettings(ctx, chatID)
+	if err != nil {
+		return SendFilesResult{}, &SendFilesError{Err: err}
+	}
+
+	files, fileErrs := prepareFiles(settings, files)
+	if len(fileErrs) > 0 {
+		return SendFilesResult{}, &SendFilesError{Files: fileErrs}
+	}
+
+	var result SendFilesResult
+
+	result.Uploaded, fileErrs = uploadFiles(ctx, client, files, o)
+	if len(fileErrs) > 0 {
+		return SendFilesResult{}, &SendFilesError{Files: fileErrs, Result: result}
+	}
+
+	for i, u := range result.Uploaded {
+		result.Uploaded[i].MessageType = fileMessageType(settings, u.File.Type, int64(u.File.Size))
+		for _, v := range CheckItemSize(settings, result.Uploaded[i].MessageType, int64(u.File.Size)) {
+			fileErrs = append(fileErrs, FileError{Index: i, Name: u.Name, Err: v})
+		}
+	}
+	if len(fileErrs) > 0 {
+		return SendFilesResult{}, &SendFilesError{Files: fileErrs, Result: result}
+	}
+
+	plans, err := planFileMessages(settings, chatID, files, result.Uploaded, o)
+	if err != nil {
+		return SendFilesResult{}, &SendFilesError{Err: err, Result: result}
+	}
+
+	for _, plan := range plans {
+		result.Adaptations = append(result.Adaptations, plan.Adaptations...)
+	}
+
+	for _, plan := range plans {
+		sent, err := plan.Execute(ctx, client, o.reqEditors...)
+		result.Sent = append(result.Sent, sent...)
+		if err != nil {
+			return SendFilesResult{}, &SendFilesError{Err: err, Result: result}
+		}
+	}
+
+	return result, nil
+}
+
+// prepareFiles fills in default names and captions and rejects files too large for any attachment type.
+func prepareFiles(settings ChannelSettings, files []OutgoingFile) ([]OutgoingFile, []FileError) {
+	prepared := make([]OutgoingFile, len(files))
+	maxSize, limited := maxItemSize(settings)
+
+	var errs []FileError
+	for i, f := range files {
+		if f.Name == "" {
+			f.Name = filepath.Base(f.Path)
+		}
+		if f.Caption == "" {
+			f.Caption = f.Name
+		}
+
+		if f.Reader == nil {
+			info, err := os.Stat(f.Path)
+			if err != nil {
+				errs = append(errs, FileError{Index: i, Name: f.Name, Err: err})
+
+				continue
+			}
+			f.Size = info.Size()
+		}
+
+		if limited && f.Size > maxSize {
+			var violations Violations
+			violations.add(ViolationItemTooLarge, "items", maxSize, f.Size,
+				"file is %d bytes, the channel accepts at most %d", f.Size, maxSize)
+			errs = append(errs, FileError{Index: i, Name: f.Name, Err: violations})
+		}
+
+		prepared[i] = f
+	}
+
+	return prepared, errs
+}
+
+// maxItemSize returns the largest attachment size the channel accepts in any message type it can send.
+func maxItemSize(settings ChannelSettings) (int64, bool) {
+	var (
+		maxSize int64
+		limited bool
+	)
+	for _, msgType := range []MessageType{MessageTypeFile, MessageTypeImage, MessageTypeAudio} {
+		if feature, _ := creatingFeature(settings, msgType); !feature.CanSend() {
+			continue
+		}
+
+		limit := itemSizeLimit(settings, msgType)
+		if limit == nil {
+			return 0, false
+		}
+		maxSize, limited = max(maxSize, *limit), true
+	}
+
+	return maxSize, limited
+}
+
+func uploadFiles(
+	ctx context.Context, client ClientWithResponsesInterface, files []OutgoingFile, o sendFilesOptions,
+) ([]UploadedFile, []FileError) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		errs     []FileError
+		uploaded = make([]*UploadedFile, len(files))
+		slots    = make(chan struct{}, max(o.concurrency, 1))
+	)
+
+	for i, f := range files {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			if len(errs) == 0 {
+				errs = append(errs, FileError{Index: i, Name: f.Name, Err: err})
+			}
+			mu.Unlock()
+
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			file, err := uploadOutgoingFile(ctx, client, f, o.uploadOpts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if len(errs) == 0 || !errors.Is(err, context.Canceled) {
+					errs = append(errs, FileError{Index: i, Name: f.Name, Err: err})
+				}
+				cancel()
+
+				return
+			}
+			uploaded[i] = &UploadedFile{Name: f.Name, File: file}
+		}()
+	}
+	wg.Wait()
+
+	result := make([]UploadedFile, 0, len(files))
+	for _, u := range uploaded {
+		if u != nil {
+			result = append(result, *u)
+		}
+	}
+
+	return result, errs
+}
+
+func uploadOutgoingFile(
+	ctx context.Context, client ClientWithResponsesInterface, f OutgoingFile, opts []UploadOption,
+) (UploadResponse, error) {
+	if f.Reader != nil {
+		return UploadFileFromReader(ctx, client, f.Name, f.Reader, f.Size, opts...)
+	}
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return UploadResponse{}, err
+	}
+	defer file.Close()
+
+	return UploadFileFromReader(ctx, client, f.Name, file, f.Size, opts...)
+}
+
+// fileMessageType picks the message type for a file, falling back to a file message
+// when the channel cannot send messages of the preferred type or the file exceeds their size limit.
+func fileMessageType(settings ChannelSettings, fileType FileType, size int64) MessageType {
+	msgType := MessageTypeFile
+	switch fileType {
+	case FileTypeImage:
+		msgType = MessageTypeImage
+	case FileTypeAudio:
+		msgType = MessageTypeAudio
+	}
+
+	if feature, _ := creatingFeature(settings, msgType); !feature.CanSend() {
+		return MessageTypeFile
+	}
+	if limit := itemSizeLimit(settings, msgType); limit != nil && size > *limit {
+		return MessageTypeFile
+	}
+
+	return msgType
+}
+
+// planFileMessages groups consecutive files of the same message type and adapts each group to the channel.
+func planFileMessages(
+	settings ChannelSettings, chatID int64, files []OutgoingFile, uploaded []UploadedFile, o sendFilesOptions,
+) ([]SendPlan, error) {
+	var plans []SendPlan
+
+	for start := 0; start < len(uploaded); {
+		msgType := uploaded[start].MessageType
+		end := start
+		items := make([]MessageItem, 0)
+		for ; end < len(uploaded) && uploaded[end].MessageType == msgType; end++ {
+			items = append(items, NewMessageItem(uploaded[end].File.ID, files[end].Caption))
+		}
+
+		var b *MessageBuilder
+		switch msgType {
+		case MessageTypeImage:
+			b = NewImages(chatID, items...)
+		case MessageTypeAudio:
+			b = NewAudio(chatID, items...)
+		default:
+			b = NewFiles(chatID, items...)
+		}
+		if start == 0 {
+			if o.note != nil {
+				b.Note(*o.note)
+			}
+			if o.quote != 0 {
+				b.Quote(o.quote)
+			}
+		}
+
+		body, err := b.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		plan, err := Adapt(settings, body)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+
+		start = end
+	}
+
+	return plans, nil
+}
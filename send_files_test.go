@@ -0,0 +1,258 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sendFilesServer struct {
+	fakeAPI
+	uploads  []string
+	messages []SendMessageRequestBody
+	// failUpload and failMessage make the upload of the named file or the n-th message fail.
+	failUpload  string
+	failMessage int
+}
+
+func (s *sendFilesServer) client(t *testing.T) *ClientWithResponses {
+	settings := `{
+		"file": {"creating": "both", "max_items_count": 2, "max_item_size": 100, "note_max_chars_count": 10},
+		"image": {"creating": "both", "quoting": "both", "max_items_count": 10, "max_item_size": 50},
+		"audio": {"creating": "none"}
+	}`
+
+	return s.serve(t, func(req *http.Request) (int, any) {
+		switch req.URL.Path {
+		case "/chats":
+			if req.URL.Query().Get("id") == "10" {
+				return http.StatusOK, `[{"id": 10, "channel": {"id": 3}}]`
+			}
+
+			return http.StatusOK, `[]`
+		case "/channels":
+			return http.StatusOK, `[{"id": 3, "settings": ` + settings + `}]`
+		case "/files/upload":
+			name, size, ok := readUploadedFile(t, req)
+			if !ok {
+				return http.StatusBadRequest, nil
+			}
+			s.uploads = append(s.uploads, name)
+			if name == s.failUpload {
+				return http.StatusBadRequest, `{"errors": ["invalid file"]}`
+			}
+
+			fileType := FileTypeFile
+			switch filepath.Ext(name) {
+			case ".png":
+				fileType = FileTypeImage
+			case ".mp3":
+				fileType = FileTypeAudio
+			}
+
+			return http.StatusOK, fmt.Sprintf(`{"id": %q, "size": %d, "type": %q}`, uuid.New(), size, fileType)
+		case "/messages":
+			var msg SendMessageRequestBody
+			if !decodeRequest(t, req, &msg) {
+				return http.StatusBadRequest, nil
+			}
+			s.messages = append(s.messages, msg)
+			if len(s.messages) == s.failMessage {
+				return http.StatusBadRequest, `{"errors": ["chat is closed"]}`
+			}
+
+			return http.StatusOK, fmt.Sprintf(`{"message_id": %d, "time": "2025-01-01T00:00:00Z"}`, len(s.messages))
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+// readUploadedFile returns the name and the size of the file uploaded by req. It reports the error and returns false
+// if the request is malformed.
+func readUploadedFile(t *testing.T, req *http.Request) (string, int, bool) {
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if !assert.NoError(t, err) {
+		return "", 0, false
+	}
+
+	part, err := multipart.NewReader(req.Body, params["boundary"]).NextPart()
+	if !assert.NoError(t, err) {
+		return "", 0, false
+	}
+	content, err := io.ReadAll(part)
+	if !assert.NoError(t, err) {
+		return "", 0, false
+	}
+
+	return part.FileName(), len(content), true
+}
+
+func outgoingFile(name string, size int) OutgoingFile {
+	return OutgoingFile{Name: name, Reader: strings.NewReader(strings.Repeat("x", size)), Size: int64(size)}
+}
+
+func TestSendFiles(t *testing.T) {
+	t.Parallel()
+
+	t.Run("groups files by type and splits batches", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "report.pdf")
+		require.NoError(t, os.WriteFile(path, []byte("report"), 0o600))
+
+		var server sendFilesServer
+		result, err := SendFiles(context.Background(), server.client(t), 10, []OutgoingFile{
+			outgoingFile("a.png", 10),
+			outgoingFile("b.png", 10),
+			{Path: path, Caption: "Monthly report"},
+			outgoingFile("c.txt", 10),
+			outgoingFile("d.txt", 10),
+			outgoingFile("voice.mp3", 10),
+		}, WithSendFilesNote("see files"), WithSendFilesQuote(5), WithSendFilesConcurrency(2))
+		require.NoError(t, err)
+
+		assert.Len(t, server.uploads, 6)
+		require.Len(t, result.Uploaded, 6)
+		assert.Equal(t, "report.pdf", result.Uploaded[2].Name)
+		// The channel cannot send audio messages, so the audio file is sent as a file.
+		assert.Equal(t, []MessageType{
+			MessageTypeImage, MessageTypeImage, MessageTypeFile, MessageTypeFile, MessageTypeFile, MessageTypeFile,
+		}, []MessageType{
+			result.Uploaded[0].MessageType, result.Uploaded[1].MessageType, result.Uploaded[2].MessageType,
+			result.Uploaded[3].MessageType, result.Uploaded[4].MessageType, result.Uploaded[5].MessageType,
+		})
+
+		require.Len(t, server.messages, 3)
+		assert.Len(t, result.Sent, 3)
+		assert.NotEmpty(t, result.Adaptations)
+
+		first := server.messages[0]
+		assert.Equal(t, MessageTypeImage, *first.Type)
+		require.NotNil(t, first.Items)
+		assert.Len(t, *first.Items, 2)
+		assert.Equal(t, "a.png", (*first.Items)[0].Caption)
+		require.NotNil(t, first.Note)
+		assert.Equal(t, "see files", *first.Note)
+		assert.Equal(t, int64(5), first.QuoteMessageID)
+
+		for _, msg := range server.messages[1:] {
+			assert.Equal(t, MessageTypeFile, *msg.Type)
+			require.NotNil(t, msg.Items)
+			assert.Len(t, *msg.Items, 2)
+			assert.Nil(t, msg.Note)
+			assert.Zero(t, msg.QuoteMessageID)
+		}
+		assert.Equal(t, "Monthly report", (*server.messages[1].Items)[0].Caption)
+		assert.Equal(t, result.Uploaded[2].File.ID, (*server.messages[1].Items)[0].ID)
+	})
+
+	t.Run("rejects files too large before uploading", func(t *testing.T) {
+		t.Parallel()
+
+		var server sendFilesServer
+		_, err := SendFiles(context.Background(), server.client(t), 10, []OutgoingFile{
+			outgoingFile("a.txt", 10),
+			outgoingFile("huge.bin", 101),
+		})
+
+		var sendErr *SendFilesError
+		require.ErrorAs(t, err, &sendErr)
+		require.Len(t, sendErr.Files, 1)
+		assert.Equal(t, 1, sendErr.Files[0].Index)
+
+		var violations Violations
+		require.ErrorAs(t, err, &violations)
+		assert.True(t, violations.Has(ViolationItemTooLarge))
+		assert.Empty(t, server.uploads)
+	})
+
+	t.Run("sends files too large for the detected type as files", func(t *testing.T) {
+		t.Parallel()
+
+		var server sendFilesServer
+		result, err := SendFiles(context.Background(), server.client(t), 10, []OutgoingFile{
+			outgoingFile("photo.png", 60),
+			outgoingFile("thumb.png", 50),
+		})
+		require.NoError(t, err)
+
+		// The image limit is 50 bytes and the file limit 100.
+		require.Len(t, result.Uploaded, 2)
+		assert.Equal(t, MessageTypeFile, result.Uploaded[0].MessageType)
+		assert.Equal(t, MessageTypeImage, result.Uploaded[1].MessageType)
+		require.Len(t, server.messages, 2)
+		assert.Equal(t, MessageTypeFile, *server.messages[0].Type)
+		assert.Equal(t, MessageTypeImage, *server.messages[1].Type)
+	})
+
+	t.Run("rejects a note too long before sending", func(t *testing.T) {
+		t.Parallel()
+
+		var server sendFilesServer
+		_, err := SendFiles(context.Background(), server.client(t), 10, []OutgoingFile{
+			outgoingFile("a.txt", 10),
+		}, WithSendFilesNote("a note longer than ten characters"))
+
+		var violations Violations
+		require.ErrorAs(t, err, &violations)
+		assert.True(t, violations.Has(ViolationNoteTooLong))
+		assert.Empty(t, server.messages)
+	})
+
+	t.Run("reports failed uploads", func(t *testing.T) {
+		t.Parallel()
+
+		server := sendFilesServer{failUpload: "b.txt"}
+		_, err := SendFiles(context.Background(), server.client(t), 10, []OutgoingFile{
+			outgoingFile("a.txt", 10),
+			outgoingFile("b.txt", 10),
+		}, WithSendFilesConcurrency(1))
+
+		var sendErr *SendFilesError
+		require.ErrorAs(t, err, &sendErr)
+		require.Len(t, sendErr.Files, 1)
+		assert.Equal(t, "b.txt", sendErr.Files[0].Name)
+		require.Len(t, sendErr.Result.Uploaded, 1)
+		assert.Equal(t, "a.txt", sendErr.Result.Uploaded[0].Name)
+		assert.ErrorContains(t, err, "b.txt: upload file: invalid file")
+		assert.Empty(t, server.messages)
+	})
+
+	t.Run("reports messages sent before a failure", func(t *testing.T) {
+		t.Parallel()
+
+		server := sendFilesServer{failMessage: 2}
+		_, err := SendFiles(context.Background(), server.client(t), 10, []OutgoingFile{
+			outgoingFile("a.png", 10),
+			outgoingFile("b.txt", 10),
+		})
+
+		var sendErr *SendFilesError
+		require.ErrorAs(t, err, &sendErr)
+		assert.Len(t, sendErr.Result.Uploaded, 2)
+		assert.Len(t, sendErr.Result.Sent, 1)
+		assert.ErrorContains(t, err, "send message 1 of 1: chat is closed")
+		assert.ErrorContains(t, err, "2 files uploaded, 1 messages sent")
+	})
+
+	t.Run("unknown chat", func(t *testing.T) {
+		t.Parallel()
+
+		var server sendFilesServer
+		_, err := SendFiles(context.Background(), server.client(t), 11, []OutgoingFile{outgoingFile("a.txt", 10)})
+		require.ErrorIs(t, err, ErrChatNotFound)
+		assert.Empty(t, server.uploads)
+	})
+}
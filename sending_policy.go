@@ -0,0 +1,252 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SendPermission is what may be sent to a customer under the sending policy of the channel.
+type SendPermission string
+
+const (
+	// SendFreeForm allows any message.
+	SendFreeForm SendPermission = "free_form"
+	// SendTextOnly allows only text messages.
+	SendTextOnly SendPermission = "text_only"
+	// SendTemplateOnly allows only template messages, which cannot be sent through the Bot API.
+	SendTemplateOnly SendPermission = "template_only"
+	// SendNothing allows no messages.
+	SendNothing SendPermission = "nothing"
+)
+
+// SendPolicyReason is the sending policy rule that restricts messages to a chat.
+type SendPolicyReason string
+
+const (
+	SendPolicyOutgoingRestricted SendPolicyReason = "outgoing_restricted"
+	SendPolicyNewCustomer        SendPolicyReason = "new_customer"
+	SendPolicyReplyTimeout       SendPolicyReason = "reply_timeout"
+)
+
+// SendDecision tells what may be sent to a chat and why.
+type SendDecision struct {
+	Permission SendPermission
+	// Reason is empty when free-form messages are allowed.
+	Reason SendPolicyReason
+	// Message is a human-readable description of the restriction.
+	Message string
+}
+
+// FreeForm reports whether free-form messages are allowed.
+func (d SendDecision) FreeForm() bool {
+	return d.Permission == SendFreeForm
+}
+
+// Allows reports whether the message may be sent. Messages without a type are text messages.
+func (d SendDecision) Allows(body SendMessageRequestBody) bool {
+	switch d.Permission {
+	case SendFreeForm:
+		return true
+	case SendTextOnly:
+		return body.Type == nil || *body.Type == MessageTypeText
+	default:
+		return false
+	}
+}
+
+// SendingPolicyError is returned when the sending policy of the channel does not allow the message to the chat.
+type SendingPolicyError struct {
+	ChatID   int64
+	Decision SendDecision
+}
+
+func (e *SendingPolicyError) Error() string {
+	return fmt.Sprintf("chat %d: %s", e.ChatID, e.Decision.Message)
+}
+
+// ChatPolicyState is the state of a chat the sending policy depends on.
+type ChatPolicyState struct {
+	// NewCustomer is set if the customer has not written to the chat yet.
+	NewCustomer bool
+	// ReplyDeadline is when the window for replying to the customer closes. Zero means no deadline.
+	ReplyDeadline time.Time
+}
+
+// EvaluateSendingPolicy decides what may be sent to a chat in the given state at now. Outgoing restrictions
+// take precedence over the new customer rule, which takes precedence over the reply timeout rule.
+func EvaluateSendingPolicy(policy SendingPolicy, state ChatPolicyState, now time.Time) SendDecision {
+	if policy.Outgoing == SendingPolicyOutgoingRestricted {
+		return SendDecision{
+			Permission: SendNothing,
+			Reason:     SendPolicyOutgoingRestricted,
+			Message:    "the channel does not allow outgoing messages",
+		}
+	}
+
+	if state.NewCustomer {
+		switch policy.NewCustomer {
+		case SendingPolicyNewCustomerNo:
+			return SendDecision{
+				Permission: SendNothing,
+				Reason:     SendPolicyNewCustomer,
+				Message:    "the channel does not allow messages to customers who have not written yet",
+			}
+		case SendingPolicyNewCustomerTemplate:
+			return SendDecision{
+				Permission: SendTemplateOnly,
+				Reason:     SendPolicyNewCustomer,
+				Message:    "the channel only allows templates to customers who have not written yet",
+			}
+		case SendingPolicyNewCustomerText:
+			return SendDecision{
+				Permission: SendTextOnly,
+				Reason:     SendPolicyNewCustomer,
+				Message:    "the channel only allows text messages to customers who have not written yet",
+			}
+		}
+	}
+
+	if !state.ReplyDeadline.IsZero() && !now.Before(state.ReplyDeadline) {
+		switch policy.AfterReplyTimeout {
+		case SendingPolicyAfterReplyTimeoutNo:
+			return SendDecision{
+				Permission: SendNothing,
+				Reason:     SendPolicyReplyTimeout,
+				Message:    "the reply deadline has passed and the channel does not allow messages after it",
+			}
+		case SendingPolicyAfterReplyTimeoutTemplate:
+			return SendDecision{
+				Permission: SendTemplateOnly,
+				Reason:     SendPolicyReplyTimeout,
+				Message:    "the reply deadline has passed and the channel only allows templates after it",
+			}
+		}
+	}
+
+	return SendDecision{Permission: SendFreeForm}
+}
+
+// SendingPolicyEvaluator decides whether messages may be sent to chats under the sending policy
+// of their channels. It is safe for concurrent use.
+type SendingPolicyEvaluator struct {
+	client    ClientWithResponsesInterface
+	preflight *Preflight
+	now       func() time.Time
+}
+
+// SendingPolicyOption configures a SendingPolicyEvaluator.
+type SendingPolicyOption func(*SendingPolicyEvaluator)
+
+// WithPolicyPreflight resolves channel settings with the preflight, sharing its cache.
+func WithPolicyPreflight(p *Preflight) SendingPolicyOption {
+	return func(e *SendingPolicyEvaluator) {
+		e.preflight = p
+	}
+}
+
+// NewSendingPolicyEvaluator creates a SendingPolicyEvaluator.
+func NewSendingPolicyEvaluator(
+	client ClientWithResponsesInterface, opts ...SendingPolicyOption,
+) *SendingPolicyEvaluator {
+	e := &SendingPolicyEvaluator{
+		client: client,
+		now:    time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.preflight == nil {
+		e.preflight = NewPreflight(client)
+	}
+
+	return e
+}
+
+// Evaluate decides what may be sent to the chat. Whether the customer has written to the chat
+// is only looked up if the channel restricts messages to new customers.
+func (e *SendingPolicyEvaluator) Evaluate(ctx context.Context, chat ChatsListResponseItem) (SendDecision, error) {
+	var (
+		channel ChannelListResponseItem
+		err     error
+	)
+	if chat.Channel != nil {
+		channel, err = e.preflight.channel(ctx, chat.Channel.ID)
+	} else {
+		channel, err = e.preflight.Channel(ctx, chat.ID)
+	}
+	if err != nil {
+		return SendDecision{}, fmt.Errorf("evaluate sending policy: %w", err)
+	}
+	policy := channel.Settings.SendingPolicy
+
+	var state ChatPolicyState
+	if chat.ReplyDeadline != nil {
+		state.ReplyDeadline = chat.ReplyDeadline.Time
+	}
+	if policy.Outgoing != SendingPolicyOutgoingRestricted && slices.Contains([]SendingPolicyNewCustomer{
+		SendingPolicyNewCustomerNo, SendingPolicyNewCustomerTemplate, SendingPolicyNewCustomerText,
+	}, policy.NewCustomer) {
+		if state.NewCustomer, err = e.newCustomer(ctx, chat); err != nil {
+			return SendDecision{}, fmt.Errorf("evaluate sending policy: %w", err)
+		}
+	}
+
+	return EvaluateSendingPolicy(policy, state, e.now()), nil
+}
+
+// EvaluateChat looks up the chat and decides what may be sent to it.
+func (e *SendingPolicyEvaluator) EvaluateChat(ctx context.Context, chatID int64) (SendDecision, error) {
+	id := int(chatID)
+	resp, err := e.client.ListChatsWithResponse(ctx, &ListChatsParams{ID: &id})
+	if err = ExtractError(resp, err); err != nil {
+		return SendDecision{}, fmt.Errorf("evaluate sending policy: list chats: %w", err)
+	}
+
+	for _, chat := range deref(resp.JSON200) {
+		if chat.ID == chatID {
+			return e.Evaluate(ctx, chat)
+		}
+	}
+
+	return SendDecision{}, fmt.Errorf("evaluate sending policy: chat %d: %w", chatID, ErrChatNotFound)
+}
+
+// Check returns a SendingPolicyError if the message may not be sent to its chat, e.g. if it is not a text message
+// and the customer who has not written yet may only receive text. Private messages are not sent to the customer
+// and always pass.
+func (e *SendingPolicyEvaluator) Check(ctx context.Context, body SendMessageRequestBody) error {
+	if body.Scope == MessageScopePrivate {
+		return nil
+	}
+
+	decision, err := e.EvaluateChat(ctx, body.ChatID)
+	if err != nil {
+		return err
+	}
+	if !decision.Allows(body) {
+		return &SendingPolicyError{ChatID: body.ChatID, Decision: decision}
+	}
+
+	return nil
+}
+
+// newCustomer reports whether the customer of the chat has not written to it yet.
+func (e *SendingPolicyEvaluator) newCustomer(ctx context.Context, chat ChatsListResponseItem) (bool, error) {
+	if chat.Customer == nil {
+		return false, nil
+	}
+
+	chatID, customerID, limit := int(chat.ID), int(chat.Customer.ID), 1
+	resp, err := e.client.ListMessagesWithResponse(ctx, &ListMessagesParams{
+		ChatID: &chatID, CustomerID: &customerID, Limit: &limit,
+	})
+	if err = ExtractError(resp, err); err != nil {
+		return false, fmt.Errorf("list messages: %w", err)
+	}
+
+	return len(deref(resp.JSON200)) == 0, nil
+}
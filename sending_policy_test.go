@@ -0,0 +1,268 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateSendingPolicy(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expired := ChatPolicyState{ReplyDeadline: now.Add(-time.Minute)}
+
+	tests := []struct {
+		name   string
+		policy SendingPolicy
+		state  ChatPolicyState
+		want   SendPermission
+		reason SendPolicyReason
+	}{
+		{
+			name:  "no policy",
+			state: ChatPolicyState{NewCustomer: true, ReplyDeadline: now},
+			want:  SendFreeForm,
+		},
+		{
+			name:   "outgoing restricted",
+			policy: SendingPolicy{Outgoing: SendingPolicyOutgoingRestricted, NewCustomer: SendingPolicyNewCustomerText},
+			want:   SendNothing,
+			reason: SendPolicyOutgoingRestricted,
+		},
+		{
+			name:   "new customer",
+			policy: SendingPolicy{Outgoing: SendingPolicyOutgoingAllowed, NewCustomer: SendingPolicyNewCustomerNo},
+			state:  ChatPolicyState{NewCustomer: true},
+			want:   SendNothing,
+			reason: SendPolicyNewCustomer,
+		},
+		{
+			name:   "new customer template",
+			policy: SendingPolicy{NewCustomer: SendingPolicyNewCustomerTemplate},
+			state:  ChatPolicyState{NewCustomer: true, ReplyDeadline: expired.ReplyDeadline},
+			want:   SendTemplateOnly,
+			reason: SendPolicyNewCustomer,
+		},
+		{
+			name:   "new customer text",
+			policy: SendingPolicy{NewCustomer: SendingPolicyNewCustomerText},
+			state:  ChatPolicyState{NewCustomer: true},
+			want:   SendTextOnly,
+			reason: SendPolicyNewCustomer,
+		},
+		{
+			name:   "returning customer",
+			policy: SendingPolicy{NewCustomer: SendingPolicyNewCustomerNo},
+			want:   SendFreeForm,
+		},
+		{
+			name:   "reply deadline not reached",
+			policy: SendingPolicy{AfterReplyTimeout: SendingPolicyAfterReplyTimeoutNo},
+			state:  ChatPolicyState{ReplyDeadline: now.Add(time.Minute)},
+			want:   SendFreeForm,
+		},
+		{
+			name:   "reply deadline passed",
+			policy: SendingPolicy{AfterReplyTimeout: SendingPolicyAfterReplyTimeoutNo},
+			state:  ChatPolicyState{ReplyDeadline: now},
+			want:   SendNothing,
+			reason: SendPolicyReplyTimeout,
+		},
+		{
+			name:   "reply deadline passed template",
+			policy: SendingPolicy{AfterReplyTimeout: SendingPolicyAfterReplyTimeoutTemplate},
+			state:  expired,
+			want:   SendTemplateOnly,
+			reason: SendPolicyReplyTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decision := EvaluateSendingPolicy(tt.policy, tt.state, now)
+			assert.Equal(t, tt.want, decision.Permission)
+			assert.Equal(t, tt.reason, decision.Reason)
+			assert.Equal(t, tt.want == SendFreeForm, decision.Message == "")
+		})
+	}
+}
+
+type policyServer struct {
+	fakeAPI
+	chats    []ChatsListResponseItem
+	policies map[int64]SendingPolicy
+	// written lists the customers who have written to their chat.
+	written  map[int64]bool
+	messages atomic.Int32
+	sent     []int64
+}
+
+func (s *policyServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		query := req.URL.Query()
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/messages":
+			var message SendMessageRequestBody
+			if !decodeRequest(t, req, &message) {
+				return http.StatusBadRequest, nil
+			}
+			s.sent = append(s.sent, message.ChatID)
+
+			return http.StatusOK, SendMessageResponse{MessageId: 1}
+		case req.URL.Path == "/chats":
+			chats := []ChatsListResponseItem{}
+			for _, chat := range s.chats {
+				if query.Get("id") == strconv.FormatInt(chat.ID, 10) {
+					chats = append(chats, chat)
+				}
+			}
+
+			return http.StatusOK, chats
+		case req.URL.Path == "/channels":
+			id, _ := strconv.ParseInt(query.Get("id"), 10, 64)
+
+			return http.StatusOK, []ChannelListResponseItem{{ID: id, Settings: ChannelSettings{SendingPolicy: s.policies[id]}}}
+		case req.URL.Path == "/messages":
+			s.messages.Add(1)
+			assert.Equal(t, "1", query.Get("limit"))
+			customerID, _ := strconv.ParseInt(query.Get("customer_id"), 10, 64)
+			messages := MessageListResponse{}
+			if s.written[customerID] {
+				messages = append(messages, MessageListResponseItem{ID: 1})
+			}
+
+			return http.StatusOK, messages
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func TestSendingPolicyEvaluator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	chat := func(id, channelID, customerID int64, deadline time.Time) ChatsListResponseItem {
+		item := ChatsListResponseItem{ID: id, Channel: &Channel{ID: channelID}, Customer: &Actor{ID: customerID}}
+		if !deadline.IsZero() {
+			item.ReplyDeadline = &DateTimeRFC3339{Time: deadline}
+		}
+
+		return item
+	}
+	newServer := func() *policyServer {
+		return &policyServer{
+			chats: []ChatsListResponseItem{
+				chat(11, 1, 21, time.Time{}),
+				chat(12, 1, 22, time.Time{}),
+				chat(13, 2, 23, now.Add(-time.Hour)),
+				chat(14, 3, 24, time.Time{}),
+				chat(15, 4, 25, time.Time{}),
+			},
+			policies: map[int64]SendingPolicy{
+				1: {Outgoing: SendingPolicyOutgoingAllowed, NewCustomer: SendingPolicyNewCustomerTemplate},
+				2: {AfterReplyTimeout: SendingPolicyAfterReplyTimeoutNo},
+				3: {Outgoing: SendingPolicyOutgoingRestricted, NewCustomer: SendingPolicyNewCustomerNo},
+				4: {NewCustomer: SendingPolicyNewCustomerText},
+			},
+			written: map[int64]bool{22: true},
+		}
+	}
+
+	t.Run("evaluate chat", func(t *testing.T) {
+		t.Parallel()
+
+		server := newServer()
+		evaluator := NewSendingPolicyEvaluator(server.client(t))
+		evaluator.now = func() time.Time { return now }
+
+		for chatID, want := range map[int64]SendDecision{
+			11: EvaluateSendingPolicy(server.policies[1], ChatPolicyState{NewCustomer: true}, now),
+			12: {Permission: SendFreeForm},
+			13: EvaluateSendingPolicy(server.policies[2], ChatPolicyState{ReplyDeadline: now.Add(-time.Hour)}, now),
+			14: EvaluateSendingPolicy(server.policies[3], ChatPolicyState{}, now),
+			15: EvaluateSendingPolicy(server.policies[4], ChatPolicyState{NewCustomer: true}, now),
+		} {
+			decision, err := evaluator.EvaluateChat(context.Background(), chatID)
+			require.NoError(t, err)
+			assert.Equal(t, want, decision, "chat %d", chatID)
+		}
+		// The customer is only looked up in the channels restricting messages to new customers.
+		assert.Equal(t, int32(3), server.messages.Load())
+
+		_, err := evaluator.EvaluateChat(context.Background(), 99)
+		require.ErrorIs(t, err, ErrChatNotFound)
+	})
+
+	t.Run("check", func(t *testing.T) {
+		t.Parallel()
+
+		evaluator := NewSendingPolicyEvaluator(newServer().client(t))
+		evaluator.now = func() time.Time { return now }
+
+		check := func(message *MessageBuilder) error {
+			body, err := message.Build()
+			require.NoError(t, err)
+
+			return evaluator.Check(context.Background(), body)
+		}
+
+		require.NoError(t, check(NewText(12, "Hello")))
+		require.NoError(t, check(NewText(14, "Note").Private()))
+
+		err := check(NewText(11, "Hello"))
+		var policyErr *SendingPolicyError
+		require.True(t, errors.As(err, &policyErr))
+		assert.Equal(t, int64(11), policyErr.ChatID)
+		assert.Equal(t, SendTemplateOnly, policyErr.Decision.Permission)
+		assert.EqualError(t, err, "chat 11: the channel only allows templates to customers who have not written yet")
+
+		require.NoError(t, check(NewText(15, "Hello")))
+		err = check(NewImages(15, NewMessageItem(uuid.New(), "Sale")))
+		require.True(t, errors.As(err, &policyErr))
+		assert.Equal(t, SendTextOnly, policyErr.Decision.Permission)
+		assert.EqualError(t, err, "chat 15: the channel only allows text messages to customers who have not written yet")
+	})
+
+	t.Run("campaign", func(t *testing.T) {
+		t.Parallel()
+
+		server := newServer()
+		client := server.client(t)
+		evaluator := NewSendingPolicyEvaluator(client)
+		evaluator.now = func() time.Time { return now }
+
+		recipients := CampaignRecipients{ChatIDs: []int64{11, 12, 13, 14}}
+		render := func(_ context.Context, chat ChatsListResponseItem) (*MessageBuilder, error) {
+			return NewText(chat.ID, "Sale"), nil
+		}
+		store := NewMemoryCampaignStore()
+		campaign := NewCampaign(client, store, recipients, render,
+			WithCampaignRate(1000, 10), WithCampaignSendingPolicy(evaluator))
+
+		report, err := campaign.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Sent)
+		assert.Equal(t, 3, report.Skipped)
+		assert.Equal(t, []int64{12}, server.sent)
+
+		results, err := store.Results(context.Background())
+		require.NoError(t, err)
+		for _, r := range results {
+			if r.ChatID == 14 {
+				assert.Equal(t, "the channel does not allow outgoing messages", r.Error)
+			}
+		}
+	})
+}
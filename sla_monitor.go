@@ -0,0 +1,409 @@
+package bot_api_client
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// DefaultSLACheckInterval is how often SLAMonitor.Run checks reply deadlines.
+const DefaultSLACheckInterval = 10 * time.Second
+
+// SLAEventKind is the reason an SLA hook fires.
+type SLAEventKind string
+
+const (
+	// SLAEventWarning fires when a chat enters the warning waiting level.
+	SLAEventWarning SLAEventKind = "warning"
+	// SLAEventDanger fires when a chat enters the danger waiting level.
+	SLAEventDanger SLAEventKind = "danger"
+	// SLAEventOverdue fires when the reply deadline of a chat passes.
+	SLAEventOverdue SLAEventKind = "overdue"
+)
+
+// SLAEvent describes a chat that needs attention.
+type SLAEvent struct {
+	Kind SLAEventKind
+	Chat ChatsListResponseItem
+	// PreviousLevel is the waiting level of the chat before the change, or its current level for overdue events.
+	PreviousLevel ChatsListResponseItemWaitingLevel
+	// At is the time the event was detected.
+	At time.Time
+}
+
+// SLAHook is called for SLA events. Errors are reported to the error handler of the monitor.
+type SLAHook func(ctx context.Context, event SLAEvent) error
+
+// QueueStats describe the chats waiting for a reply.
+type QueueStats struct {
+	// Waiting is the number of chats waiting for a reply.
+	Waiting int
+	// Overdue is the number of waiting chats whose reply deadline has passed.
+	Overdue int
+	// ByLevel is the number of waiting chats per waiting level.
+	ByLevel map[ChatsListResponseItemWaitingLevel]int
+	// ByChannel is the number of waiting chats per channel ID and waiting level.
+	ByChannel map[int64]map[ChatsListResponseItemWaitingLevel]int
+}
+
+// SLAMonitor tracks the waiting level and reply deadline of chats waiting for a reply and fires hooks
+// when a chat enters the warning or danger level or its deadline passes. Chats are loaded by Refresh
+// and updated from chat events; Run checks deadlines periodically. SLAMonitor is safe for concurrent use.
+type SLAMonitor struct {
+	client     ClientWithResponsesInterface
+	hooks      []slaHook
+	interval   time.Duration
+	now        func() time.Time
+	onError    func(ctx context.Context, err error)
+	reqEditors []RequestEditorFn
+
+	mu    sync.Mutex
+	chats map[int64]*slaChat
+}
+
+type slaHook struct {
+	hook  SLAHook
+	kinds []SLAEventKind
+}
+
+type slaChat struct {
+	chat ChatsListResponseItem
+	// overdue is set once the overdue event for the current deadline has fired.
+	overdue bool
+}
+
+// SLAMonitorOption configures an SLAMonitor.
+type SLAMonitorOption func(*SLAMonitor)
+
+// WithSLAHook calls the hook for events of the given kinds, or of all kinds if none are given.
+// Hooks run in the order they are added.
+func WithSLAHook(hook SLAHook, kinds ...SLAEventKind) SLAMonitorOption {
+	return func(m *SLAMonitor) {
+		if len(kinds) == 0 {
+			kinds = []SLAEventKind{SLAEventWarning, SLAEventDanger, SLAEventOverdue}
+		}
+		m.hooks = append(m.hooks, slaHook{hook: hook, kinds: kinds})
+	}
+}
+
+// WithSLACheckInterval sets how often Run checks reply deadlines.
+func WithSLACheckInterval(interval time.Duration) SLAMonitorOption {
+	return func(m *SLAMonitor) {
+		m.interval = interval
+	}
+}
+
+// WithSLAErrorHandler sets the function called with errors of hooks and of periodic refreshes.
+func WithSLAErrorHandler(onError func(ctx context.Context, err error)) SLAMonitorOption {
+	return func(m *SLAMonitor) {
+		m.onError = onError
+	}
+}
+
+// WithSLARequestEditors sets the request editors applied to the list requests.
+func WithSLARequestEditors(reqEditors ...RequestEditorFn) SLAMonitorOption {
+	return func(m *SLAMonitor) {
+		m.reqEditors = reqEditors
+	}
+}
+
+// NewSLAMonitor creates a monitor that lists chats with the given client.
+func NewSLAMonitor(client ClientWithResponsesInterface, opts ...SLAMonitorOption) *SLAMonitor {
+	m := &SLAMonitor{
+		client:   client,
+		interval: DefaultSLACheckInterval,
+		now:      time.Now,
+		onError:  func(context.Context, error) {},
+		chats:    make(map[int64]*slaChat),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Refresh lists all chats and updates the tracked ones, firing hooks for the changes found.
+// On the first refresh, hooks fire for every chat already in the warning or danger level or past its deadline.
+func (m *SLAMonitor) Refresh(ctx context.Context) error {
+	params := ListChatsParams{Limit: optional(maxListLimit)}
+	seen := make(map[int64]bool)
+	for {
+		resp, err := m.client.ListChatsWithResponse(ctx, &params, m.reqEditors...)
+		if err = ExtractError(resp, err); err != nil {
+			return fmt.Errorf("sla monitor refresh: %w", err)
+		}
+
+		page := deref(resp.JSON200)
+		for _, chat := range page {
+			seen[chat.ID] = true
+			m.Update(ctx, chat)
+		}
+		if len(page) < maxListLimit {
+			break
+		}
+		params.SinceID = &page[len(page)-1].ID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id := range m.chats {
+		if !seen[id] {
+			delete(m.chats, id)
+		}
+	}
+
+	return nil
+}
+
+// Update records the current state of a chat and fires hooks if it entered a higher waiting level
+// or its reply deadline has passed.
+func (m *SLAMonitor) Update(ctx context.Context, chat ChatsListResponseItem) {
+	now := m.now()
+
+	m.mu.Lock()
+	tracked, ok := m.chats[chat.ID]
+	if !ok {
+		tracked = &slaChat{}
+	}
+	prev := tracked.chat
+
+	var events []SLAEvent
+	prevLevel, level := waitingLevel(prev.WaitingLevel), waitingLevel(chat.WaitingLevel)
+	if waitingLevelRank(level) > waitingLevelRank(prevLevel) {
+		events = append(events, SLAEvent{Kind: SLAEventKind(level), Chat: chat, PreviousLevel: prevLevel, At: now})
+	}
+
+	if !sameTime(prev.ReplyDeadline, chat.ReplyDeadline) {
+		tracked.overdue = false
+	}
+	tracked.chat = chat
+
+	if waiting(chat) {
+		m.chats[chat.ID] = tracked
+		if event, ok := m.overdue(tracked, now); ok {
+			events = append(events, event)
+		}
+	} else {
+		delete(m.chats, chat.ID)
+	}
+	m.mu.Unlock()
+
+	m.fire(ctx, events)
+}
+
+// Remove stops tracking the chats.
+func (m *SLAMonitor) Remove(ids ...int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		delete(m.chats, id)
+	}
+}
+
+// Check fires the overdue hooks of chats whose reply deadline has passed.
+func (m *SLAMonitor) Check(ctx context.Context) {
+	now := m.now()
+
+	m.mu.Lock()
+	var events []SLAEvent
+	for _, tracked := range m.chats {
+		if event, ok := m.overdue(tracked, now); ok {
+			events = append(events, event)
+		}
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(events, func(a, b SLAEvent) int { return cmp.Compare(a.Chat.ID, b.Chat.ID) })
+	m.fire(ctx, events)
+}
+
+// overdue returns the overdue event of the chat if its deadline has passed and the event has not fired yet.
+func (m *SLAMonitor) overdue(tracked *slaChat, now time.Time) (SLAEvent, bool) {
+	deadline := tracked.chat.ReplyDeadline
+	if tracked.overdue || deadline == nil || now.Before(deadline.Time) {
+		return SLAEvent{}, false
+	}
+	tracked.overdue = true
+
+	level := waitingLevel(tracked.chat.WaitingLevel)
+
+	return SLAEvent{Kind: SLAEventOverdue, Chat: tracked.chat, PreviousLevel: level, At: now}, true
+}
+
+func (m *SLAMonitor) fire(ctx context.Context, events []SLAEvent) {
+	for _, event := range events {
+		for _, h := range m.hooks {
+			if !slices.Contains(h.kinds, event.Kind) {
+				continue
+			}
+
+			if err := h.hook(ctx, event); err != nil {
+				m.onError(ctx, fmt.Errorf("sla hook for chat %d (%s): %w", event.Chat.ID, event.Kind, err))
+			}
+		}
+	}
+}
+
+// Run refreshes the chats and then checks deadlines every interval until the context is done.
+// Refresh errors are reported to the error handler.
+func (m *SLAMonitor) Run(ctx context.Context) error {
+	if err := m.Refresh(ctx); err != nil {
+		m.onError(ctx, err)
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Stats returns the statistics of the chats waiting for a reply.
+func (m *SLAMonitor) Stats() QueueStats {
+	now := m.now()
+	stats := QueueStats{
+		ByLevel:   make(map[ChatsListResponseItemWaitingLevel]int),
+		ByChannel: make(map[int64]map[ChatsListResponseItemWaitingLevel]int),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tracked := range m.chats {
+		chat := tracked.chat
+		level := waitingLevel(chat.WaitingLevel)
+
+		stats.Waiting++
+		stats.ByLevel[level]++
+		if chat.ReplyDeadline != nil && !now.Before(chat.ReplyDeadline.Time) {
+			stats.Overdue++
+		}
+
+		var channelID int64
+		if chat.Channel != nil {
+			channelID = chat.Channel.ID
+		}
+		if stats.ByChannel[channelID] == nil {
+			stats.ByChannel[channelID] = make(map[ChatsListResponseItemWaitingLevel]int)
+		}
+		stats.ByChannel[channelID][level]++
+	}
+
+	return stats
+}
+
+// Handler returns a WebSocket event handler that updates the monitor from chat events and passes
+// every event to next, which may be nil. Hooks run before next is called.
+func (m *SLAMonitor) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		switch data := msg.Payload.Data.(type) {
+		case ws.ChatDataSchema:
+			m.mu.Lock()
+			var prev ChatsListResponseItem
+			if tracked, ok := m.chats[data.Chat.Id]; ok {
+				prev = tracked.chat
+			}
+			m.mu.Unlock()
+
+			m.Update(ctx, chatItemFromWS(prev, data.Chat))
+		case ws.ChatsDeletedDataSchema:
+			m.Remove(data.ChatIds...)
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+// NoteHook returns a hook that posts a private note with the given text to the chat.
+func NoteHook(client ClientWithResponsesInterface, text func(event SLAEvent) string) SLAHook {
+	return func(ctx context.Context, event SLAEvent) error {
+		body, err := NewText(event.Chat.ID, text(event)).Private().Build()
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.SendMessageWithResponse(ctx, body)
+
+		return ExtractError(resp, err)
+	}
+}
+
+// ReassignHook returns a hook that assigns the last dialog of the chat to the user returned by pick.
+// Nothing is done if pick returns false or the chat has no open dialog.
+func ReassignHook(
+	client ClientWithResponsesInterface, pick func(ctx context.Context, event SLAEvent) (userID int64, ok bool),
+) SLAHook {
+	return func(ctx context.Context, event SLAEvent) error {
+		dialog := event.Chat.LastDialog
+		if dialog == nil || dialog.ClosedAt != nil {
+			return nil
+		}
+
+		userID, ok := pick(ctx, event)
+		if !ok {
+			return nil
+		}
+
+		resp, err := client.AssignDialogResponsibleWithResponse(ctx, dialog.ID, AssignDialogResponsibleJSONRequestBody{
+			UserID: userID,
+		})
+		if err = ExtractError(resp, err); err != nil {
+			return fmt.Errorf("assign dialog %d to user %d: %w", dialog.ID, userID, err)
+		}
+
+		return nil
+	}
+}
+
+// waiting reports whether the chat is waiting for a reply.
+func waiting(chat ChatsListResponseItem) bool {
+	return chat.ReplyDeadline != nil || waitingLevelRank(waitingLevel(chat.WaitingLevel)) > 0
+}
+
+func waitingLevel(level *ChatsListResponseItemWaitingLevel) ChatsListResponseItemWaitingLevel {
+	if level == nil || *level == "" {
+		return ChatsListResponseItemWaitingLevelNone
+	}
+
+	return *level
+}
+
+func waitingLevelRank(level ChatsListResponseItemWaitingLevel) int {
+	switch level {
+	case ChatsListResponseItemWaitingLevelWarning:
+		return 1
+	case ChatsListResponseItemWaitingLevelDanger:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func sameTime(a, b *DateTimeRFC3339) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Time.Equal(b.Time)
+}
@@ -0,0 +1,210 @@
+package bot_api_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+type slaServer struct {
+	fakeAPI
+	chats    []ChatsListResponseItem
+	messages []SendMessageRequestBody
+	assigns  map[int64]int64
+}
+
+func (s *slaServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/chats":
+			return http.StatusOK, s.chats
+		case req.Method == http.MethodPost && req.URL.Path == "/messages":
+			var message SendMessageRequestBody
+			if !decodeRequest(t, req, &message) {
+				return http.StatusBadRequest, nil
+			}
+			s.messages = append(s.messages, message)
+
+			return http.StatusOK, map[string]any{"message_id": len(s.messages)}
+		case req.Method == http.MethodPatch && req.URL.Path == "/dialogs/7/assign":
+			var assign AssignDialogResponsibleJSONRequestBody
+			if !decodeRequest(t, req, &assign) {
+				return http.StatusBadRequest, nil
+			}
+			s.assigns[7] = assign.UserID
+
+			return http.StatusOK, DialogAssignResponse{}
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func waitingChat(id, channelID int64, level ChatsListResponseItemWaitingLevel, deadline time.Time) ChatsListResponseItem {
+	return ChatsListResponseItem{
+		ID:            id,
+		Channel:       &Channel{ID: channelID},
+		WaitingLevel:  &level,
+		ReplyDeadline: &DateTimeRFC3339{Time: deadline},
+	}
+}
+
+type slaRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *slaRecorder) hook(_ context.Context, event SLAEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, fmt.Sprintf("%d:%s:%s", event.Chat.ID, event.Kind, event.PreviousLevel))
+
+	return nil
+}
+
+func (r *slaRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := r.events
+	r.events = nil
+
+	return events
+}
+
+func TestSLAMonitor(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("fires hooks and tracks deadlines", func(t *testing.T) {
+		t.Parallel()
+
+		server := slaServer{chats: []ChatsListResponseItem{
+			waitingChat(1, 10, ChatsListResponseItemWaitingLevelNone, now.Add(time.Minute)),
+			waitingChat(2, 10, ChatsListResponseItemWaitingLevelWarning, now.Add(time.Hour)),
+			waitingChat(3, 20, ChatsListResponseItemWaitingLevelDanger, now.Add(-time.Minute)),
+			{ID: 4},
+		}}
+
+		var (
+			recorder slaRecorder
+			clock    = now
+		)
+		monitor := NewSLAMonitor(server.client(t), WithSLAHook(recorder.hook))
+		monitor.now = func() time.Time { return clock }
+
+		require.NoError(t, monitor.Refresh(context.Background()))
+		assert.Equal(t, []string{"2:warning:none", "3:danger:none", "3:overdue:danger"}, recorder.take())
+
+		assert.Equal(t, QueueStats{
+			Waiting: 3,
+			Overdue: 1,
+			ByLevel: map[ChatsListResponseItemWaitingLevel]int{
+				ChatsListResponseItemWaitingLevelNone:    1,
+				ChatsListResponseItemWaitingLevelWarning: 1,
+				ChatsListResponseItemWaitingLevelDanger:  1,
+			},
+			ByChannel: map[int64]map[ChatsListResponseItemWaitingLevel]int{
+				10: {ChatsListResponseItemWaitingLevelNone: 1, ChatsListResponseItemWaitingLevelWarning: 1},
+				20: {ChatsListResponseItemWaitingLevelDanger: 1},
+			},
+		}, monitor.Stats())
+
+		clock = now.Add(2 * time.Minute)
+		monitor.Check(context.Background())
+		monitor.Check(context.Background())
+		assert.Equal(t, []string{"1:overdue:none"}, recorder.take())
+
+		// A new deadline fires again once it passes; a lower level does not fire.
+		chat := waitingChat(3, 20, ChatsListResponseItemWaitingLevelWarning, clock.Add(time.Minute))
+		monitor.Update(context.Background(), chat)
+		clock = clock.Add(time.Minute)
+		monitor.Check(context.Background())
+		assert.Equal(t, []string{"3:overdue:warning"}, recorder.take())
+
+		monitor.Update(context.Background(), ChatsListResponseItem{ID: 1})
+		assert.Equal(t, 2, monitor.Stats().Waiting)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			recorder slaRecorder
+			passed   int
+		)
+		monitor := NewSLAMonitor(nil, WithSLAHook(recorder.hook, SLAEventDanger))
+		monitor.now = func() time.Time { return now }
+
+		handler := monitor.Handler(func(context.Context, ws.EventMessageFromEventsChannel) error {
+			passed++
+
+			return nil
+		})
+		handle := func(eventType ws.EventTypeSchema, data any) {
+			require.NoError(t, handler(context.Background(), ws.EventMessageFromEventsChannel{
+				Payload: ws.EventSchema{Type: eventType, Data: data},
+			}))
+		}
+
+		deadline := now.Add(time.Hour)
+		for _, level := range []ws.WaitingLevelSchema{ws.WaitingLevelWarning, ws.WaitingLevelDanger} {
+			handle(ws.EventTypeChatUpdated, ws.ChatDataSchema{
+				Chat: ws.ChatSchema{Id: 1, WaitingLevel: &level, ReplyDeadline: &deadline},
+			})
+		}
+		assert.Equal(t, []string{"1:danger:warning"}, recorder.take())
+		assert.Equal(t, 1, monitor.Stats().Waiting)
+
+		handle(ws.EventTypeChatsDeleted, ws.ChatsDeletedDataSchema{ChatIds: []int64{1}})
+		assert.Equal(t, 0, monitor.Stats().Waiting)
+		assert.Equal(t, 3, passed)
+	})
+
+	t.Run("note and reassign hooks", func(t *testing.T) {
+		t.Parallel()
+
+		server := slaServer{assigns: map[int64]int64{}}
+		client := server.client(t)
+
+		chat := waitingChat(5, 10, ChatsListResponseItemWaitingLevelDanger, now)
+		chat.LastDialog = &Dialog{ID: 7}
+		event := SLAEvent{Kind: SLAEventDanger, Chat: chat}
+
+		note := NoteHook(client, func(e SLAEvent) string { return "Chat is in " + string(e.Kind) })
+		require.NoError(t, note(context.Background(), event))
+		require.Len(t, server.messages, 1)
+		assert.Equal(t, "Chat is in danger", *server.messages[0].Content)
+		assert.Equal(t, MessageScopePrivate, server.messages[0].Scope)
+
+		reassign := ReassignHook(client, func(context.Context, SLAEvent) (int64, bool) { return 42, true })
+		require.NoError(t, reassign(context.Background(), event))
+		assert.Equal(t, map[int64]int64{7: 42}, server.assigns)
+	})
+
+	t.Run("hook errors", func(t *testing.T) {
+		t.Parallel()
+
+		var errs []error
+		monitor := NewSLAMonitor(nil,
+			WithSLAHook(func(context.Context, SLAEvent) error { return errors.New("pager unavailable") }),
+			WithSLAErrorHandler(func(_ context.Context, err error) { errs = append(errs, err) }),
+		)
+		monitor.now = func() time.Time { return now }
+
+		monitor.Update(context.Background(), waitingChat(1, 10, ChatsListResponseItemWaitingLevelWarning, now.Add(time.Hour)))
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "sla hook for chat 1 (warning): pager unavailable")
+	})
+}
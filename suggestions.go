@@ -0,0 +1,325 @@
+package bot_api_client
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// DefaultSuggestionTTL is how long SuggestionCorrelator waits for a reply to tracked suggestions.
+const DefaultSuggestionTTL = 24 * time.Hour
+
+// SuggestionSet builds a set of quick replies for a message.
+type SuggestionSet struct {
+	suggestions []Suggestion
+}
+
+// NewSuggestionSet starts an empty set of quick replies.
+func NewSuggestionSet() *SuggestionSet {
+	return &SuggestionSet{}
+}
+
+// Text adds a text quick reply. The payload is reported back when the customer selects it.
+func (s *SuggestionSet) Text(title, payload string) *SuggestionSet {
+	return s.add(SuggestionTypeText, title, payload)
+}
+
+// Email adds a quick reply asking the customer to share their email.
+func (s *SuggestionSet) Email(title string) *SuggestionSet {
+	return s.add(SuggestionTypeEmail, title, "")
+}
+
+// Phone adds a quick reply asking the customer to share their phone number.
+func (s *SuggestionSet) Phone(title string) *SuggestionSet {
+	return s.add(SuggestionTypePhone, title, "")
+}
+
+// URL adds a quick reply opening the URL.
+func (s *SuggestionSet) URL(title, url string) *SuggestionSet {
+	return s.add(SuggestionTypeUrl, title, url)
+}
+
+func (s *SuggestionSet) add(t SuggestionType, title, payload string) *SuggestionSet {
+	s.suggestions = append(s.suggestions, Suggestion{Type: t, Title: title, Payload: payload})
+
+	return s
+}
+
+// Suggestions returns the quick replies without validation.
+func (s *SuggestionSet) Suggestions() []Suggestion {
+	return append([]Suggestion(nil), s.suggestions...)
+}
+
+// Build validates the set and returns the quick replies.
+// Text quick replies must have distinct titles so a reply can be correlated to one of them.
+// The returned error is of type ValidationErrors.
+func (s *SuggestionSet) Build() ([]Suggestion, error) {
+	var errs ValidationErrors
+
+	titles := make(map[string]int, len(s.suggestions))
+	for i, suggestion := range s.suggestions {
+		field := fmt.Sprintf("suggestions[%d]", i)
+		validateEnum(&errs, field+".type", suggestion.Type)
+
+		if strings.TrimSpace(suggestion.Title) == "" {
+			errs.add(field+".title", "required", "", "title is required")
+
+			continue
+		}
+
+		if suggestion.Type != SuggestionTypeText {
+			continue
+		}
+
+		key := normalizeSuggestionText(suggestion.Title)
+		if key == "" {
+			key = strings.TrimSpace(suggestion.Title)
+		}
+		if j, ok := titles[key]; ok {
+			errs.add(field+".title", "unique", "", "title matches suggestions[%d]", j)
+		}
+		titles[key] = i
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return s.Suggestions(), nil
+}
+
+// Check validates the set against the quick reply types supported by the channel.
+func (s *SuggestionSet) Check(settings ChannelSettings) Violations {
+	var violations Violations
+	checkContent(&violations, settings, MessageTypeText, messageContent{
+		attachments: &MessageTransportAttachments{Suggestions: s.suggestions},
+	})
+
+	return violations
+}
+
+// SuggestionSelection is a customer reply correlated to a quick reply.
+type SuggestionSelection struct {
+	ChatID     int64
+	MessageID  int64
+	Suggestion Suggestion
+	// Text is the text of the customer message.
+	Text string
+}
+
+// Payload returns the payload of the selected quick reply.
+func (s SuggestionSelection) Payload() string {
+	return s.Suggestion.Payload
+}
+
+// SuggestionCorrelator remembers the quick replies last offered in each chat and matches
+// the next customer message in that chat against them.
+// It is safe for concurrent use.
+type SuggestionCorrelator struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending map[int64]cached[[]Suggestion]
+}
+
+// SuggestionCorrelatorOption configures a SuggestionCorrelator.
+type SuggestionCorrelatorOption func(*SuggestionCorrelator)
+
+// WithSuggestionTTL sets how long offered quick replies wait for a reply.
+func WithSuggestionTTL(ttl time.Duration) SuggestionCorrelatorOption {
+	return func(c *SuggestionCorrelator) {
+		c.ttl = ttl
+	}
+}
+
+// NewSuggestionCorrelator creates an empty correlator.
+func NewSuggestionCorrelator(opts ...SuggestionCorrelatorOption) *SuggestionCorrelator {
+	c := &SuggestionCorrelator{
+		ttl:     DefaultSuggestionTTL,
+		now:     time.Now,
+		pending: make(map[int64]cached[[]Suggestion]),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Track remembers quick replies offered in the chat, replacing previously offered ones.
+func (c *SuggestionCorrelator) Track(chatID int64, suggestions []Suggestion) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(suggestions) == 0 {
+		delete(c.pending, chatID)
+
+		return
+	}
+
+	c.pending[chatID] = cached[[]Suggestion]{
+		value:   append([]Suggestion(nil), suggestions...),
+		expires: c.now().Add(c.ttl),
+	}
+}
+
+// TrackMessage remembers the quick replies of an outgoing message.
+func (c *SuggestionCorrelator) TrackMessage(body SendMessageRequestBody) {
+	if body.TransportAttachments != nil && len(body.TransportAttachments.Suggestions) > 0 {
+		c.Track(body.ChatID, body.TransportAttachments.Suggestions)
+	}
+}
+
+// Forget drops the quick replies offered in the chat.
+func (c *SuggestionCorrelator) Forget(chatID int64) {
+	c.Track(chatID, nil)
+}
+
+// Match correlates the next customer message in the chat with the offered quick replies.
+// Text quick replies match by title, first exactly and then ignoring case, punctuation and extra spaces.
+// Email and phone quick replies match a message that looks like an email or a phone number.
+// The offered quick replies are consumed whether or not the message matches.
+func (c *SuggestionCorrelator) Match(chatID int64, text string) (Suggestion, bool) {
+	c.mu.Lock()
+	entry, ok := c.pending[chatID]
+	delete(c.pending, chatID)
+	c.mu.Unlock()
+
+	if !ok || !c.now().Before(entry.expires) {
+		return Suggestion{}, false
+	}
+
+	return matchSuggestion(entry.value, text)
+}
+
+func matchSuggestion(suggestions []Suggestion, text string) (Suggestion, bool) {
+	trimmed := strings.TrimSpace(text)
+	for _, s := range suggestions {
+		if s.Type == SuggestionTypeText && strings.TrimSpace(s.Title) == trimmed {
+			return s, true
+		}
+	}
+
+	normalized := normalizeSuggestionText(text)
+	for _, s := range suggestions {
+		if s.Type == SuggestionTypeText && normalized != "" && normalizeSuggestionText(s.Title) == normalized {
+			return s, true
+		}
+	}
+
+	for _, s := range suggestions {
+		switch {
+		case s.Type == SuggestionTypeEmail && looksLikeEmail(trimmed):
+			return s, true
+		case s.Type == SuggestionTypePhone && looksLikePhone(trimmed):
+			return s, true
+		}
+	}
+
+	return Suggestion{}, false
+}
+
+// normalizeSuggestionText lowercases text and drops everything but letters, digits and single spaces.
+func normalizeSuggestionText(text string) string {
+	var b strings.Builder
+
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			space = true
+		}
+	}
+
+	return b.String()
+}
+
+func looksLikeEmail(text string) bool {
+	addr, err := mail.ParseAddress(text)
+
+	return err == nil && addr.Address == text
+}
+
+func looksLikePhone(text string) bool {
+	digits := 0
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case strings.ContainsRune("+-() .", r):
+		default:
+			return false
+		}
+	}
+
+	return digits >= 7 && digits <= 15
+}
+
+// Handler returns a WebSocket event handler that correlates customer replies with quick replies.
+// Outgoing messages carrying quick replies are tracked automatically, so replies to messages
+// sent by any bot or operator are recognized. When a customer text message matches,
+// onSelected is called instead of next; all other events are passed to next, which may be nil.
+func (c *SuggestionCorrelator) Handler(
+	onSelected func(ctx context.Context, selection SuggestionSelection) error,
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		if selection, ok := c.handleEvent(msg.Payload); ok {
+			return onSelected(ctx, selection)
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+func (c *SuggestionCorrelator) handleEvent(event ws.EventSchema) (SuggestionSelection, bool) {
+	data, ok := event.Data.(ws.MessageDataSchema)
+	if !ok || event.Type != ws.EventTypeMessageNew || data.Message.From == nil {
+		return SuggestionSelection{}, false
+	}
+
+	message := data.Message
+	if message.From.Type != ws.UserTypeCustomer {
+		if message.TransportAttachments != nil && len(message.TransportAttachments.Suggestions) > 0 {
+			c.Track(message.ChatId, suggestionsFromWS(message.TransportAttachments.Suggestions))
+		}
+
+		return SuggestionSelection{}, false
+	}
+
+	if message.Type != ws.MessageTypeText || message.Content == nil {
+		c.Forget(message.ChatId)
+
+		return SuggestionSelection{}, false
+	}
+
+	suggestion, ok := c.Match(message.ChatId, *message.Content)
+	if !ok {
+		return SuggestionSelection{}, false
+	}
+
+	return SuggestionSelection{
+		ChatID:     message.ChatId,
+		MessageID:  message.Id,
+		Suggestion: suggestion,
+		Text:       *message.Content,
+	}, true
+}
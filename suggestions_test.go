@@ -0,0 +1,170 @@
+package bot_api_client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+func TestSuggestionSet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("build", func(t *testing.T) {
+		t.Parallel()
+
+		suggestions, err := NewSuggestionSet().
+			Text("Yes, please", "confirm").
+			Text("No", "cancel").
+			Phone("Share phone").
+			Build()
+		require.NoError(t, err)
+		assert.Equal(t, []Suggestion{
+			{Type: SuggestionTypeText, Title: "Yes, please", Payload: "confirm"},
+			{Type: SuggestionTypeText, Title: "No", Payload: "cancel"},
+			{Type: SuggestionTypePhone, Title: "Share phone"},
+		}, suggestions)
+	})
+
+	t.Run("duplicate and empty titles", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewSuggestionSet().Text("Yes", "a").Text(" yes! ", "b").Email("").Build()
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		require.Len(t, verrs, 2)
+		assert.Equal(t, "suggestions[1].title", verrs[0].Field)
+		assert.Equal(t, "unique", verrs[0].Rule)
+		assert.Equal(t, "suggestions[2].title", verrs[1].Field)
+	})
+
+	t.Run("check against channel", func(t *testing.T) {
+		t.Parallel()
+
+		settings := ChannelSettings{Suggestions: Suggestions{Text: ChannelFeatureBoth, Url: ChannelFeatureReceive}}
+		violations := NewSuggestionSet().Text("Yes", "").URL("Site", "https://example.com").Check(settings)
+		require.Len(t, violations, 1)
+		assert.Equal(t, ViolationSuggestionUnsupported, violations[0].Code)
+		assert.Equal(t, "suggestion type url unsupported by the channel", violations[0].Message)
+	})
+}
+
+func TestSuggestionCorrelator(t *testing.T) {
+	t.Parallel()
+
+	suggestions := []Suggestion{
+		{Type: SuggestionTypeText, Title: "Yes, please!", Payload: "confirm"},
+		{Type: SuggestionTypeText, Title: "Call me back", Payload: "callback"},
+		{Type: SuggestionTypeEmail, Title: "Share email"},
+	}
+
+	t.Run("match", func(t *testing.T) {
+		t.Parallel()
+
+		testCases := []struct {
+			text    string
+			payload string
+			ok      bool
+		}{
+			{text: "Yes, please!", payload: "confirm", ok: true},
+			{text: "  yes   PLEASE ", payload: "confirm", ok: true},
+			{text: "call me back.", payload: "callback", ok: true},
+			{text: "john@example.com", ok: true},
+			{text: "maybe later"},
+		}
+
+		for _, tc := range testCases {
+			c := NewSuggestionCorrelator()
+			c.Track(1, suggestions)
+
+			s, ok := c.Match(1, tc.text)
+			assert.Equal(t, tc.ok, ok, tc.text)
+			assert.Equal(t, tc.payload, s.Payload, tc.text)
+
+			_, ok = c.Match(1, tc.text)
+			assert.False(t, ok, "suggestions are consumed by the first reply")
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+		c := NewSuggestionCorrelator(WithSuggestionTTL(time.Hour))
+		c.now = func() time.Time { return now }
+
+		c.TrackMessage(SendMessageRequestBody{
+			ChatID:               1,
+			TransportAttachments: &MessageTransportAttachments{Suggestions: suggestions},
+		})
+		now = now.Add(2 * time.Hour)
+
+		_, ok := c.Match(1, "Yes, please!")
+		assert.False(t, ok)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewSuggestionCorrelator()
+
+		var (
+			selections []SuggestionSelection
+			passed     []ws.EventTypeSchema
+		)
+		handler := c.Handler(
+			func(_ context.Context, s SuggestionSelection) error {
+				selections = append(selections, s)
+				return nil
+			},
+			func(_ context.Context, msg ws.EventMessageFromEventsChannel) error {
+				passed = append(passed, msg.Payload.Type)
+				return nil
+			},
+		)
+
+		title, payload, textType := "Yes, please!", "confirm", ws.SuggestionTypeText
+		outgoing := messageEvent(1, ws.UserTypeBot, "Confirm the order?")
+		data := outgoing.Payload.Data.(ws.MessageDataSchema)
+		data.Message.TransportAttachments = &ws.MessageTransportAttachmentsSchema{
+			Suggestions: []ws.SuggestionSchema{{Type: &textType, Title: &title, Payload: &payload}},
+		}
+		outgoing.Payload.Data = data
+
+		require.NoError(t, handler(context.Background(), outgoing))
+		require.NoError(t, handler(context.Background(), messageEvent(2, ws.UserTypeCustomer, "yes please")))
+		require.NoError(t, handler(context.Background(), messageEvent(3, ws.UserTypeCustomer, "yes please")))
+
+		require.Len(t, selections, 1)
+		assert.Equal(t, SuggestionSelection{
+			ChatID:     10,
+			MessageID:  2,
+			Suggestion: Suggestion{Type: SuggestionTypeText, Title: title, Payload: payload},
+			Text:       "yes please",
+		}, selections[0])
+		assert.Equal(t, "confirm", selections[0].Payload())
+		assert.Len(t, passed, 2)
+	})
+}
+
+func messageEvent(id int64, from ws.UserTypeSchema, content string) ws.EventMessageFromEventsChannel {
+	return ws.EventMessageFromEventsChannel{
+		Payload: ws.EventSchema{
+			Type: ws.EventTypeMessageNew,
+			Data: ws.MessageDataSchema{
+				Message: ws.MessagePropertyFromMessageDataSchema{
+					Id:      id,
+					ChatId:  10,
+					Type:    ws.MessageTypeText,
+					Content: &content,
+					From:    &ws.UserRefSchema{Type: from},
+				},
+			},
+		},
+	}
+}
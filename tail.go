@@ -0,0 +1,286 @@
+package bot_api_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// TailFormat is the output format of a Tail.
+type TailFormat int
+
+const (
+	// TailFormatText prints a line with the receive time, type, chat, channel and customer of every event,
+	// followed by the decoded EventSchema as indented JSON.
+	TailFormatText TailFormat = iota
+	// TailFormatJSONL prints every event as a TailRecord line, in the format of the recordings.
+	TailFormatJSONL
+)
+
+// TailFilter selects events by type, chat, channel and customer. An empty field matches every event;
+// otherwise the event must be of one of the listed types or refer to one of the listed IDs.
+// Events that do not refer to a chat, channel or customer at all do not match a filter on it.
+type TailFilter struct {
+	Types       []ws.EventTypeSchema
+	ChatIDs     []int64
+	ChannelIDs  []int64
+	CustomerIDs []int64
+}
+
+// Match reports whether the event passes the filter.
+func (f TailFilter) Match(event ws.EventSchema) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+
+	refs := tailEventRefs(event)
+
+	return matchIDs(f.ChatIDs, refs.chats) && matchIDs(f.ChannelIDs, refs.channels) &&
+		matchIDs(f.CustomerIDs, refs.customers)
+}
+
+func matchIDs(filter, ids []int64) bool {
+	return len(filter) == 0 || slices.ContainsFunc(ids, func(id int64) bool { return slices.Contains(filter, id) })
+}
+
+// tailRefs are the chats, channels and customers an event refers to.
+type tailRefs struct {
+	chats     []int64
+	channels  []int64
+	customers []int64
+}
+
+func (r *tailRefs) addChat(chat *ws.ChatSchema) {
+	if chat == nil {
+		return
+	}
+
+	r.chats = append(r.chats, chat.Id)
+	if chat.Channel != nil {
+		r.channels = append(r.channels, chat.Channel.Id)
+	}
+	if chat.Customer != nil {
+		r.customers = append(r.customers, chat.Customer.Id)
+	}
+}
+
+func (r *tailRefs) addUser(user *ws.UserRefSchema) {
+	if user != nil && user.Type == ws.UserTypeCustomer {
+		r.customers = append(r.customers, user.Id)
+	}
+}
+
+func tailEventRefs(event ws.EventSchema) tailRefs {
+	var refs tailRefs
+
+	switch data := event.Data.(type) {
+	case ws.MessageDataSchema:
+		refs.chats = append(refs.chats, data.Message.ChatId)
+		refs.addChat(data.Message.Chat)
+		refs.addUser(data.Message.From)
+	case ws.ChatDataSchema:
+		refs.addChat(&data.Chat)
+	case ws.ChatsDeletedDataSchema:
+		refs.chats = append(refs.chats, data.ChatIds...)
+	case ws.DialogDataSchema:
+		refs.addChat(data.Dialog.Chat)
+	case ws.DialogAssignDataSchema:
+		refs.addChat(&data.Chat)
+	case ws.UserJoinedChatDataSchema:
+		refs.addChat(&data.Chat)
+		refs.addUser(&data.User)
+	case ws.UserLeftChatDataSchema:
+		if data.Chat != nil {
+			refs.chats = append(refs.chats, data.Chat.Id)
+		}
+	case ws.CustomerUpdatedDataSchema:
+		refs.customers = append(refs.customers, data.Id)
+	case ws.ChannelUpdatedDataSchema:
+		if data.Channel != nil {
+			refs.channels = append(refs.channels, data.Channel.Id)
+		}
+	}
+
+	return refs
+}
+
+// Tail prints and records the WebSocket events passing its filter, for debugging.
+// It is safe for concurrent use.
+type Tail struct {
+	out      io.Writer
+	format   TailFormat
+	filter   TailFilter
+	recorder *TailRecorder
+	now      func() time.Time
+
+	mu sync.Mutex
+}
+
+// TailOption configures a Tail.
+type TailOption func(t *Tail)
+
+// WithTailFormat sets the output format, TailFormatText by default.
+func WithTailFormat(format TailFormat) TailOption {
+	return func(t *Tail) {
+		t.format = format
+	}
+}
+
+// WithTailFilter sets the filter of the printed and recorded events. By default all events pass.
+func WithTailFilter(filter TailFilter) TailOption {
+	return func(t *Tail) {
+		t.filter = filter
+	}
+}
+
+// WithTailRecorder records the events passing the filter with the frames as received. The recorder is not
+// closed by the tail.
+func WithTailRecorder(recorder *TailRecorder) TailOption {
+	return func(t *Tail) {
+		t.recorder = recorder
+	}
+}
+
+// NewTail creates a tail printing events to out, which may be nil to only record them.
+func NewTail(out io.Writer, opts ...TailOption) *Tail {
+	t := &Tail{out: out, now: time.Now}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Subscribe subscribes the tail to the events and options of params, e.g. ws.EventsChannelParameters{Events:
+// "message_new,dialog_opened"}. Events are handled until the subscription is cancelled.
+func (t *Tail) Subscribe(ctx context.Context, controller *ws.AppController, params ws.EventsChannelParameters) error {
+	return controller.SubscribeToReceiveEventsOperation(ctx, params, t.Handler(nil))
+}
+
+// Handler returns a WebSocket event handler that prints and records the events passing the filter and then
+// passes every event to next, which may be nil. Errors of the output and the recorder are returned
+// without calling next.
+//
+// The frame is taken from ws.RawEventFromContext; without it, the decoded event is encoded again.
+func (t *Tail) Handler(
+	next func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+		if t.filter.Match(msg.Payload) {
+			if err := t.handle(ctx, msg.Payload); err != nil {
+				return err
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(ctx, msg)
+	}
+}
+
+func (t *Tail) handle(ctx context.Context, event ws.EventSchema) error {
+	record := TailRecord{ReceivedAt: t.now()}
+	if receivedAt, ok := ctx.Value(tailReceivedAtKey{}).(time.Time); ok {
+		record.ReceivedAt = receivedAt
+	}
+	if raw, ok := ws.RawEventFromContext(ctx); ok {
+		record.Frame = raw.Raw
+	} else {
+		frame, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("tail %s event: %w", event.Type, err)
+		}
+		record.Frame = frame
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.recorder != nil {
+		if err := t.recorder.Record(record); err != nil {
+			return fmt.Errorf("record %s event: %w", event.Type, err)
+		}
+	}
+	if t.out == nil {
+		return nil
+	}
+
+	var out []byte
+	switch t.format {
+	case TailFormatJSONL:
+		out = record.appendJSON(nil)
+	default:
+		text, err := formatTailEvent(record.ReceivedAt, event)
+		if err != nil {
+			return fmt.Errorf("tail %s event: %w", event.Type, err)
+		}
+		out = []byte(text)
+	}
+	if _, err := t.out.Write(out); err != nil {
+		return fmt.Errorf("tail %s event: %w", event.Type, err)
+	}
+
+	return nil
+}
+
+// formatTailEvent formats an event in TailFormatText.
+func formatTailEvent(receivedAt time.Time, event ws.EventSchema) (string, error) {
+	data, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(receivedAt.Local().Format("2006-01-02 15:04:05.000"))
+	b.WriteString(" " + string(event.Type))
+
+	refs := tailEventRefs(event)
+	for _, ref := range []struct {
+		name string
+		ids  []int64
+	}{{"chat", refs.chats}, {"channel", refs.channels}, {"customer", refs.customers}} {
+		ids := slices.Compact(slices.Sorted(slices.Values(ref.ids)))
+		for _, id := range ids {
+			fmt.Fprintf(&b, " %s=%d", ref.name, id)
+		}
+	}
+
+	b.WriteString("\n")
+	b.Write(data)
+	b.WriteString("\n")
+
+	return b.String(), nil
+}
+
+type tailReceivedAtKey struct{}
+
+// ReplayTail passes the events of a recording to handler in order, with the frames available from
+// ws.RawEventFromContext as for received events. A Tail handler prints and records them with their original
+// receive times. Replay stops at the first error of the handler.
+func ReplayTail(
+	ctx context.Context, r io.Reader, handler func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error,
+) error {
+	return ReadTailRecords(r, func(record TailRecord) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		event, err := record.Event()
+		if err != nil {
+			return fmt.Errorf("event received at %s: %w", record.ReceivedAt.Format(time.RFC3339Nano), err)
+		}
+
+		eventCtx := context.WithValue(ws.ContextWithRawEvent(ctx, record.Frame), tailReceivedAtKey{}, record.ReceivedAt)
+
+		return handler(eventCtx, ws.EventMessageFromEventsChannel{Payload: event})
+	})
+}
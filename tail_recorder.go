@@ -0,0 +1,229 @@
+package bot_api_client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// TailRecord is a WebSocket event as received by a Tail.
+//
+// Records are stored as JSON lines with the receive time and the frame: {"received_at":"…","event":{…}}.
+// Frames that are not valid JSON or span several lines are stored base64-encoded as "frame" instead of "event",
+// so that every frame is read back byte for byte.
+type TailRecord struct {
+	// ReceivedAt is the time the frame was received.
+	ReceivedAt time.Time
+	// Frame is the payload of the WebSocket frame as received.
+	Frame []byte
+}
+
+// Event decodes the frame as the controller does for event handlers.
+func (r TailRecord) Event() (ws.EventSchema, error) {
+	var event ws.EventSchema
+	err := json.Unmarshal(r.Frame, &event)
+
+	return event, err
+}
+
+// appendJSON appends the record as a JSON line. It is not encoded with encoding/json, which
+// would compact and escape the frame.
+func (r TailRecord) appendJSON(b []byte) []byte {
+	b = append(b, `{"received_at":"`...)
+	b = r.ReceivedAt.AppendFormat(b, time.RFC3339Nano)
+	if json.Valid(r.Frame) && !bytes.ContainsAny(r.Frame, "\r\n") {
+		b = append(b, `","event":`...)
+		b = append(b, r.Frame...)
+	} else {
+		b = append(b, `","frame":"`...)
+		b = base64.StdEncoding.AppendEncode(b, r.Frame)
+		b = append(b, '"')
+	}
+
+	return append(b, "}\n"...)
+}
+
+func parseTailRecord(line []byte) (TailRecord, error) {
+	var record struct {
+		ReceivedAt time.Time       `json:"received_at"`
+		Event      json.RawMessage `json:"event"`
+		Frame      []byte          `json:"frame"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return TailRecord{}, err
+	}
+
+	frame := record.Frame
+	if record.Event != nil {
+		frame = record.Event
+	}
+	if frame == nil {
+		return TailRecord{}, errors.New("no event")
+	}
+
+	return TailRecord{ReceivedAt: record.ReceivedAt, Frame: frame}, nil
+}
+
+// ReadTailRecords calls fn for every record of a recording in order and stops at the first error.
+// A line without the trailing newline is being written or was cut short, and is skipped.
+func ReadTailRecords(r io.Reader, fn func(record TailRecord) error) error {
+	reader := bufio.NewReader(r)
+
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		record, err := parseTailRecord(line)
+		if err != nil {
+			return fmt.Errorf("record at offset %d: %w", offset, err)
+		}
+		if err = fn(record); err != nil {
+			return err
+		}
+
+		offset += int64(len(line))
+	}
+}
+
+// DefaultTailRecorderMaxSize is the size at which a TailRecorder starts a new file by default.
+const DefaultTailRecorderMaxSize = 64 << 20
+
+// TailRecorder writes TailRecords to files in a directory, starting a new file when the current one
+// reaches the maximum size or age. Files are named after the prefix and the receive time of their first record,
+// e.g. events-20240501T120000.000000000Z.jsonl, so that they sort in the order of the records.
+// It is safe for concurrent use.
+type TailRecorder struct {
+	dir      string
+	prefix   string
+	maxSize  int64
+	interval time.Duration
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// TailRecorderOption configures a TailRecorder.
+type TailRecorderOption func(r *TailRecorder)
+
+// WithTailRecorderMaxSize sets the size in bytes at which a new file is started. Zero disables the limit.
+// A record larger than the limit is written to a file of its own.
+func WithTailRecorderMaxSize(size int64) TailRecorderOption {
+	return func(r *TailRecorder) {
+		r.maxSize = size
+	}
+}
+
+// WithTailRecorderInterval starts a new file for records received the given time after the first record
+// of the current file, e.g. hourly. By default files are rotated by size only.
+func WithTailRecorderInterval(interval time.Duration) TailRecorderOption {
+	return func(r *TailRecorder) {
+		r.interval = interval
+	}
+}
+
+// WithTailRecorderPrefix sets the prefix of the file names, "events" by default.
+func WithTailRecorderPrefix(prefix string) TailRecorderOption {
+	return func(r *TailRecorder) {
+		r.prefix = prefix
+	}
+}
+
+// NewTailRecorder creates a recorder writing to dir, creating the directory if it does not exist.
+// No file is created until the first record.
+func NewTailRecorder(dir string, opts ...TailRecorderOption) (*TailRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	r := &TailRecorder{dir: dir, prefix: "events", maxSize: DefaultTailRecorderMaxSize}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
+}
+
+// Record appends the record to the current file, starting a new file first if the record does not fit.
+func (r *TailRecorder) Record(record TailRecord) error {
+	line := record.appendJSON(nil)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil && r.full(record.ReceivedAt, int64(len(line))) {
+		if err := r.closeFile(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		name := fmt.Sprintf("%s-%s.jsonl", r.prefix, record.ReceivedAt.UTC().Format("20060102T150405.000000000Z"))
+		file, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		r.file, r.size, r.opened = file, 0, record.ReceivedAt
+	}
+
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+
+	return err
+}
+
+// full reports whether a record of the given size received at the given time starts a new file.
+func (r *TailRecorder) full(receivedAt time.Time, size int64) bool {
+	if r.maxSize > 0 && r.size > 0 && r.size+size > r.maxSize {
+		return true
+	}
+
+	return r.interval > 0 && !receivedAt.Before(r.opened.Add(r.interval))
+}
+
+// Path returns the path of the file being written, or an empty string before the first record.
+func (r *TailRecorder) Path() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return ""
+	}
+
+	return r.file.Name()
+}
+
+// Close closes the current file. A later record starts a new file.
+func (r *TailRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	return r.closeFile()
+}
+
+func (r *TailRecorder) closeFile() error {
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
@@ -0,0 +1,150 @@
+package bot_api_client
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTailRecords(t *testing.T, path string) []TailRecord {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var records []TailRecord
+	require.NoError(t, ReadTailRecords(file, func(record TailRecord) error {
+		records = append(records, record)
+		return nil
+	}))
+
+	return records
+}
+
+func TestTailRecord(t *testing.T) {
+	t.Parallel()
+
+	receivedAt := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	frames := []string{
+		`{"type":"message_new", "meta":{"timestamp":1}, "data":{"message":{"content":"<b>&</b>"}}}`,
+		"{\n  \"type\": \"dialog_closed\"\n}",
+		"not json",
+		"",
+	}
+
+	var data []byte
+	for _, frame := range frames {
+		data = TailRecord{ReceivedAt: receivedAt, Frame: []byte(frame)}.appendJSON(data)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, len(frames))
+	assert.Equal(t, `{"received_at":"2024-05-01T12:00:00.123456789Z","event":`+frames[0]+`}`, lines[0])
+	assert.Contains(t, lines[1], `"frame":"`)
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	// The last record is cut short.
+	require.NoError(t, os.WriteFile(path, append(data, `{"received_at":"2024-05-01T12:00:01Z","ev`...), 0o600))
+
+	records := readTailRecords(t, path)
+	require.Len(t, records, len(frames))
+	for i, frame := range frames {
+		assert.True(t, receivedAt.Equal(records[i].ReceivedAt))
+		assert.Equal(t, frame, string(records[i].Frame))
+	}
+
+	event, err := records[0].Event()
+	require.NoError(t, err)
+	assert.Equal(t, "message_new", string(event.Type))
+
+	err = ReadTailRecords(strings.NewReader(lines[0]+"\n{}\n"), func(TailRecord) error { return nil })
+	require.EqualError(t, err, fmt.Sprintf("record at offset %d: no event", len(lines[0])+1))
+}
+
+func TestTailRecorder(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	frame := []byte(`{"type":"message_new","meta":{"timestamp":1},"data":{}}`)
+	size := int64(len(TailRecord{ReceivedAt: start, Frame: frame}.appendJSON(nil)))
+
+	record := func(t *testing.T, recorder *TailRecorder, offsets ...time.Duration) {
+		t.Helper()
+
+		for _, offset := range offsets {
+			require.NoError(t, recorder.Record(TailRecord{ReceivedAt: start.Add(offset), Frame: frame}))
+		}
+	}
+	files := func(t *testing.T, dir string) map[string]int {
+		t.Helper()
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		counts := make(map[string]int, len(entries))
+		for _, entry := range entries {
+			counts[entry.Name()] = len(readTailRecords(t, filepath.Join(dir, entry.Name())))
+		}
+
+		return counts
+	}
+
+	t.Run("rotates by size", func(t *testing.T) {
+		t.Parallel()
+
+		dir := filepath.Join(t.TempDir(), "recordings")
+		recorder, err := NewTailRecorder(dir, WithTailRecorderMaxSize(2*size))
+		require.NoError(t, err)
+		assert.Empty(t, recorder.Path())
+
+		record(t, recorder, 0, time.Second, 2*time.Second, 3*time.Second, 4*time.Second)
+		assert.Equal(t, filepath.Join(dir, "events-20240501T120004.000000000Z.jsonl"), recorder.Path())
+		require.NoError(t, recorder.Close())
+		require.NoError(t, recorder.Close())
+
+		assert.Equal(t, map[string]int{
+			"events-20240501T120000.000000000Z.jsonl": 2,
+			"events-20240501T120002.000000000Z.jsonl": 2,
+			"events-20240501T120004.000000000Z.jsonl": 1,
+		}, files(t, dir))
+	})
+
+	t.Run("rotates by interval", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		recorder, err := NewTailRecorder(dir, WithTailRecorderInterval(time.Hour), WithTailRecorderPrefix("bot"),
+			WithTailRecorderMaxSize(0))
+		require.NoError(t, err)
+
+		record(t, recorder, 0, 59*time.Minute, time.Hour, 2*time.Hour+time.Minute)
+		require.NoError(t, recorder.Close())
+
+		assert.Equal(t, map[string]int{
+			"bot-20240501T120000.000000000Z.jsonl": 2,
+			"bot-20240501T130000.000000000Z.jsonl": 1,
+			"bot-20240501T140100.000000000Z.jsonl": 1,
+		}, files(t, dir))
+	})
+
+	t.Run("record larger than the limit", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		recorder, err := NewTailRecorder(dir, WithTailRecorderMaxSize(size/2))
+		require.NoError(t, err)
+
+		record(t, recorder, 0, time.Second)
+		require.NoError(t, recorder.Close())
+
+		assert.Equal(t, map[string]int{
+			"events-20240501T120000.000000000Z.jsonl": 1,
+			"events-20240501T120001.000000000Z.jsonl": 1,
+		}, files(t, dir))
+	})
+}
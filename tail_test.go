@@ -0,0 +1,205 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+var tailFrames = []string{
+	`{"type":"message_new","meta":{"timestamp":1714564800},` +
+		`"data":{"message":{"id":1,"chat_id":11,"type":"text","scope":"public","status":"received",` +
+		`"time":"2024-05-01T12:00:00Z","content":"Hi",` +
+		`"from":{"id":7,"type":"customer","name":"Ann","external_id":"a"},` +
+		`"chat":{"id":11,"channel":{"id":3,"type":"telegram","is_active":true,"transport_id":1},` +
+		`"created_at":"2024-05-01T12:00:00Z","last_activity":"2024-05-01T12:00:00Z"}}}}`,
+	`{"type":"dialog_opened", "meta":{"timestamp":1714564801}, "data":{"dialog":{"id":5,` +
+		`"created_at":"2024-05-01T12:00:01Z","chat":{"id":12,"channel":{"id":4,"type":"whatsapp",` +
+		`"is_active":true,"transport_id":1},"customer":{"id":8,"type":"customer","name":"Bob","external_id":"b"},` +
+		`"created_at":"2024-05-01T12:00:00Z","last_activity":"2024-05-01T12:00:00Z"}}}}`,
+	`{"type":"chats_deleted","meta":{"timestamp":1714564802},"data":{"chat_ids":[11,13]}}`,
+	`{"type":"customer_updated","meta":{"timestamp":1714564803},` +
+		`"data":{"id":7,"type":"customer","name":"Ann Lee","external_id":"a"}}`,
+	`{"type":"channel_updated","meta":{"timestamp":1714564804},` +
+		`"data":{"channel":{"id":3,"type":"telegram","is_active":false,"transport_id":1}}}`,
+	`{"type":"reaction_added","meta":{"timestamp":1714564805},"data":{"message_id":1}}`,
+}
+
+func tailEvent(t *testing.T, frame string) ws.EventSchema {
+	t.Helper()
+
+	var event ws.EventSchema
+	require.NoError(t, json.Unmarshal([]byte(frame), &event))
+
+	return event
+}
+
+func TestTailFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		filter  TailFilter
+		matches []int
+	}{
+		{name: "empty", matches: []int{0, 1, 2, 3, 4, 5}},
+		{
+			name:    "types",
+			filter:  TailFilter{Types: []ws.EventTypeSchema{ws.EventTypeMessageNew, "reaction_added"}},
+			matches: []int{0, 5},
+		},
+		{name: "chat", filter: TailFilter{ChatIDs: []int64{11}}, matches: []int{0, 2}},
+		{name: "channel", filter: TailFilter{ChannelIDs: []int64{3, 4}}, matches: []int{0, 1, 4}},
+		{name: "customer", filter: TailFilter{CustomerIDs: []int64{7}}, matches: []int{0, 3}},
+		{
+			name:    "all fields",
+			filter:  TailFilter{Types: []ws.EventTypeSchema{ws.EventTypeMessageNew}, ChatIDs: []int64{11, 12}},
+			matches: []int{0},
+		},
+		{name: "no match", filter: TailFilter{ChatIDs: []int64{11}, ChannelIDs: []int64{4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var matches []int
+			for i, frame := range tailFrames {
+				if tt.filter.Match(tailEvent(t, frame)) {
+					matches = append(matches, i)
+				}
+			}
+			assert.Equal(t, tt.matches, matches)
+		})
+	}
+}
+
+func TestTail(t *testing.T) {
+	t.Parallel()
+
+	receivedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	handle := func(t *testing.T, handler func(context.Context, ws.EventMessageFromEventsChannel) error) {
+		t.Helper()
+
+		for _, frame := range tailFrames {
+			ctx := ws.ContextWithRawEvent(context.Background(), []byte(frame))
+			require.NoError(t, handler(ctx, ws.EventMessageFromEventsChannel{Payload: tailEvent(t, frame)}))
+		}
+	}
+	newTail := func(out *bytes.Buffer, opts ...TailOption) *Tail {
+		tail := NewTail(out, opts...)
+		tail.now = func() time.Time { return receivedAt }
+
+		return tail
+	}
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+
+		var out bytes.Buffer
+		var next []ws.EventTypeSchema
+		tail := newTail(&out, WithTailFilter(TailFilter{ChatIDs: []int64{11, 12}}))
+		handle(t, tail.Handler(func(_ context.Context, msg ws.EventMessageFromEventsChannel) error {
+			next = append(next, msg.Payload.Type)
+			return nil
+		}))
+
+		assert.Len(t, next, len(tailFrames))
+
+		prefix := receivedAt.Local().Format("2006-01-02 15:04:05.000")
+		assert.True(t, strings.HasPrefix(out.String(),
+			prefix+" message_new chat=11 channel=3 customer=7\n{\n  \"data\": {\n"), out.String())
+		assert.Contains(t, out.String(), "\n"+prefix+" dialog_opened chat=12 channel=4 customer=8\n{\n")
+		assert.Contains(t, out.String(), "\n"+prefix+" chats_deleted chat=11 chat=13\n{\n")
+		assert.NotContains(t, out.String(), "customer_updated")
+	})
+
+	t.Run("jsonl keeps frames", func(t *testing.T) {
+		t.Parallel()
+
+		var out bytes.Buffer
+		handle(t, newTail(&out, WithTailFormat(TailFormatJSONL)).Handler(nil))
+
+		lines := strings.SplitAfter(out.String(), "\n")
+		require.Len(t, lines, len(tailFrames)+1)
+		for i, frame := range tailFrames {
+			assert.Equal(t, `{"received_at":"2024-05-01T12:00:00Z","event":`+frame+"}\n", lines[i])
+		}
+	})
+
+	t.Run("without raw event", func(t *testing.T) {
+		t.Parallel()
+
+		var out bytes.Buffer
+		tail := newTail(&out, WithTailFormat(TailFormatJSONL))
+		msg := ws.EventMessageFromEventsChannel{Payload: tailEvent(t, tailFrames[3])}
+		require.NoError(t, tail.Handler(nil)(context.Background(), msg))
+
+		var records []TailRecord
+		require.NoError(t, ReadTailRecords(&out, func(record TailRecord) error {
+			records = append(records, record)
+			return nil
+		}))
+		require.Len(t, records, 1)
+		assert.JSONEq(t, tailFrames[3], string(records[0].Frame))
+	})
+
+	t.Run("record and replay", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		recorder, err := NewTailRecorder(dir)
+		require.NoError(t, err)
+		filter := TailFilter{Types: []ws.EventTypeSchema{ws.EventTypeMessageNew, ws.EventTypeChannelUpdated}}
+		recording := NewTail(nil, WithTailRecorder(recorder), WithTailFilter(filter))
+		recording.now = func() time.Time { return receivedAt }
+		handle(t, recording.Handler(nil))
+		path := recorder.Path()
+		require.NoError(t, recorder.Close())
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		tail := NewTail(&out, WithTailFormat(TailFormatJSONL))
+		require.NoError(t, ReplayTail(context.Background(), bytes.NewReader(data), tail.Handler(nil)))
+		assert.Equal(t, string(data), out.String())
+		assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+	})
+
+	t.Run("replay stops at handler error", func(t *testing.T) {
+		t.Parallel()
+
+		var recording bytes.Buffer
+		for _, frame := range tailFrames {
+			recording.Write(TailRecord{ReceivedAt: receivedAt, Frame: []byte(frame)}.appendJSON(nil))
+		}
+
+		errStop := errors.New("stop")
+		var replayed []ws.EventTypeSchema
+		handler := func(ctx context.Context, msg ws.EventMessageFromEventsChannel) error {
+			raw, ok := ws.RawEventFromContext(ctx)
+			require.True(t, ok)
+			assert.Equal(t, tailFrames[len(replayed)], string(raw.Raw))
+
+			replayed = append(replayed, msg.Payload.Type)
+			if len(replayed) == 2 {
+				return errStop
+			}
+
+			return nil
+		}
+		require.ErrorIs(t, ReplayTail(context.Background(), &recording, handler), errStop)
+		assert.Equal(t, []ws.EventTypeSchema{ws.EventTypeMessageNew, ws.EventTypeDialogOpened}, replayed)
+	})
+}
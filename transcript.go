@@ -0,0 +1,332 @@
+package bot_api_client
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranscriptEntryKind distinguishes customer-visible messages, private notes and system actions in a transcript.
+type TranscriptEntryKind string
+
+const (
+	TranscriptEntryMessage TranscriptEntryKind = "message"
+	TranscriptEntryNote    TranscriptEntryKind = "note"
+	TranscriptEntrySystem  TranscriptEntryKind = "system"
+)
+
+// Transcript is the exported conversation of a dialog or a chat.
+type Transcript struct {
+	DialogID int64             `json:"dialog_id,omitempty"`
+	ChatID   int64             `json:"chat_id,omitempty"`
+	Since    time.Time         `json:"since,omitzero"`
+	Until    time.Time         `json:"until,omitzero"`
+	Entries  []TranscriptEntry `json:"entries"`
+}
+
+// TranscriptEntry is a single message of a transcript.
+type TranscriptEntry struct {
+	ID         int64               `json:"id"`
+	Time       time.Time           `json:"time"`
+	Kind       TranscriptEntryKind `json:"kind"`
+	Type       MessageType         `json:"type"`
+	Author     string              `json:"author,omitempty"`
+	AuthorType ActorType           `json:"author_type,omitempty"`
+	Text       string              `json:"text,omitempty"`
+	// Action is set for system messages.
+	Action      SystemAction           `json:"action,omitempty"`
+	Quote       *TranscriptQuote       `json:"quote,omitempty"`
+	Attachments []TranscriptAttachment `json:"attachments,omitempty"`
+}
+
+// TranscriptQuote is the message quoted by a transcript entry.
+type TranscriptQuote struct {
+	ID     int64  `json:"id"`
+	Author string `json:"author,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+// TranscriptAttachment is a file attached to a transcript entry. URL is a temporary download link.
+type TranscriptAttachment struct {
+	ID            string   `json:"id"`
+	Kind          FileType `json:"kind,omitempty"`
+	Caption       string   `json:"caption,omitempty"`
+	Size          int      `json:"size,omitempty"`
+	URL           string   `json:"url,omitempty"`
+	Transcription string   `json:"transcription,omitempty"`
+}
+
+// TranscriptQuery selects the messages of a transcript. DialogID, ChatID or both must be set.
+// Since and Until limit the creation time of the messages, both inclusive; zero times leave the range open.
+type TranscriptQuery struct {
+	DialogID int64
+	ChatID   int64
+	Since    time.Time
+	Until    time.Time
+}
+
+var (
+	piiEmail = regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`)
+	piiPhone = regexp.MustCompile(`\+?\(?\d[\d\s().-]{5,}\d`)
+)
+
+// RedactPII masks email addresses, payment card numbers and phone numbers in s.
+func RedactPII(s string) string {
+	s = piiEmail.ReplaceAllString(s, "[email]")
+
+	return piiPhone.ReplaceAllStringFunc(s, func(match string) string {
+		var digits []byte
+		for i := range len(match) {
+			if match[i] >= '0' && match[i] <= '9' {
+				digits = append(digits, match[i])
+			}
+		}
+
+		switch {
+		case len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits):
+			return "[card]"
+		case len(digits) >= 10, strings.HasPrefix(match, "+") && len(digits) >= 7:
+			return "[phone]"
+		default:
+			return match
+		}
+	})
+}
+
+func luhnValid(digits []byte) bool {
+	var sum int
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return sum%10 == 0
+}
+
+// TranscriptExporter exports dialog and chat transcripts, resolving actor names and attachment URLs.
+type TranscriptExporter struct {
+	client     ClientWithResponsesInterface
+	downloader *Downloader
+	pageSize   int
+	redact     func(string) string
+}
+
+// TranscriptOption configures a TranscriptExporter.
+type TranscriptOption func(*TranscriptExporter)
+
+// WithTranscriptDownloader resolves attachment URLs with the downloader, sharing its cache.
+func WithTranscriptDownloader(d *Downloader) TranscriptOption {
+	return func(e *TranscriptExporter) {
+		e.downloader = d
+	}
+}
+
+// WithTranscriptPageSize sets how many messages are requested per page, from 1 to 1000, the most the API allows.
+// Sizes out of the range are clamped to it.
+func WithTranscriptPageSize(n int) TranscriptOption {
+	return func(e *TranscriptExporter) {
+		e.pageSize = min(max(n, 1), maxListLimit)
+	}
+}
+
+// WithTranscriptRedaction passes texts, captions and transcriptions through redact, e.g. RedactPII,
+// and replaces customer names with their IDs.
+func WithTranscriptRedaction(redact func(string) string) TranscriptOption {
+	return func(e *TranscriptExporter) {
+		e.redact = redact
+	}
+}
+
+// NewTranscriptExporter creates a TranscriptExporter.
+func NewTranscriptExporter(client ClientWithResponsesInterface, opts ...TranscriptOption) *TranscriptExporter {
+	e := &TranscriptExporter{
+		client:   client,
+		pageSize: maxListLimit,
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.downloader == nil {
+		e.downloader = NewDownloader(client)
+	}
+
+	return e
+}
+
+// Export pages through the messages of the query and returns them in order as a transcript.
+func (e *TranscriptExporter) Export(ctx context.Context, q TranscriptQuery) (Transcript, error) {
+	if q.DialogID == 0 && q.ChatID == 0 {
+		return Transcript{}, errors.New("export transcript: dialog or chat ID is required")
+	}
+
+	transcript := Transcript{DialogID: q.DialogID, ChatID: q.ChatID, Since: q.Since, Until: q.Until}
+	names := make(map[string]string)
+
+	// The API filters by the time of the last update, which is never before the creation time, so only the lower
+	// bound narrows the pages down; the range is checked against the creation time of every message.
+	params := ListMessagesParams{Since: optional(q.Since), Limit: &e.pageSize}
+	if q.DialogID != 0 {
+		params.DialogID = &q.DialogID
+	}
+	if q.ChatID != 0 {
+		params.ChatID = optional(int(q.ChatID))
+	}
+
+	for {
+		resp, err := e.client.ListMessagesWithResponse(ctx, &params)
+		if err = ExtractError(resp, err); err != nil {
+			return Transcript{}, fmt.Errorf("export transcript: list messages: %w", err)
+		}
+
+		page := deref(resp.JSON200)
+		for _, m := range page {
+			if m.CreatedAt.Time.Before(q.Since) || (!q.Until.IsZero() && m.CreatedAt.Time.After(q.Until)) {
+				continue
+			}
+
+			entry, err := e.entry(ctx, m, names)
+			if err != nil {
+				return Transcript{}, fmt.Errorf("export transcript: message %d: %w", m.ID, err)
+			}
+			transcript.Entries = append(transcript.Entries, entry)
+		}
+		if len(page) == 0 || len(page) < e.pageSize {
+			break
+		}
+		params.SinceID = &page[len(page)-1].ID
+	}
+
+	slices.SortFunc(transcript.Entries, func(a, b TranscriptEntry) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return transcript, nil
+}
+
+func (e *TranscriptExporter) entry(
+	ctx context.Context, m MessageListResponseItem, names map[string]string,
+) (TranscriptEntry, error) {
+	entry := TranscriptEntry{
+		ID:     m.ID,
+		Time:   m.CreatedAt.Time,
+		Kind:   TranscriptEntryMessage,
+		Type:   m.Type,
+		Text:   e.text(deref(m.Content)),
+		Action: deref(m.Action),
+	}
+	switch {
+	case m.Type == MessageTypeSystem || m.Action != nil:
+		entry.Kind = TranscriptEntrySystem
+	case m.Scope == MessageScopePrivate:
+		entry.Kind = TranscriptEntryNote
+	}
+
+	var err error
+	if m.From != nil {
+		entry.AuthorType = m.From.Type
+		if entry.Author, err = e.actorName(ctx, *m.From, names); err != nil {
+			return TranscriptEntry{}, err
+		}
+	}
+
+	if m.Quote != nil {
+		entry.Quote = &TranscriptQuote{ID: m.Quote.ID, Text: e.text(m.Quote.Content)}
+		if m.Quote.From != nil {
+			if entry.Quote.Author, err = e.actorName(ctx, *m.Quote.From, names); err != nil {
+				return TranscriptEntry{}, err
+			}
+		}
+	}
+
+	for _, item := range m.Items {
+		attachment := TranscriptAttachment{
+			ID:            item.ID.String(),
+			Kind:          item.Kind,
+			Caption:       e.text(item.Caption),
+			Size:          item.Size,
+			Transcription: e.text(item.Transcription),
+		}
+		file, err := e.downloader.FileURL(ctx, item.ID)
+		if err != nil {
+			return TranscriptEntry{}, err
+		}
+		attachment.URL = file.Url
+		entry.Attachments = append(entry.Attachments, attachment)
+	}
+
+	return entry, nil
+}
+
+func (e *TranscriptExporter) text(s string) string {
+	if e.redact == nil || s == "" {
+		return s
+	}
+
+	return e.redact(s)
+}
+
+// actorName returns the name of the actor, looking up users and customers whose name is not set.
+// Names are cached in names for the duration of an export.
+func (e *TranscriptExporter) actorName(ctx context.Context, actor Actor, names map[string]string) (string, error) {
+	if actor.Type == ActorTypeCustomer && e.redact != nil {
+		return "Customer " + strconv.FormatInt(actor.ID, 10), nil
+	}
+	if actor.Name != "" {
+		return actor.Name, nil
+	}
+
+	key := string(actor.Type) + ":" + strconv.FormatInt(actor.ID, 10)
+	if name, ok := names[key]; ok {
+		return name, nil
+	}
+
+	var name string
+	id := int(actor.ID)
+	switch actor.Type {
+	case ActorTypeUser:
+		resp, err := e.client.ListUsersWithResponse(ctx, &ListUsersParams{ID: &id})
+		if err = ExtractError(resp, err); err != nil {
+			return "", fmt.Errorf("get user %d: %w", actor.ID, err)
+		}
+		for _, u := range deref(resp.JSON200) {
+			if u.ID == actor.ID {
+				name = fullName(deref(u.FirstName), deref(u.LastName))
+			}
+		}
+	case ActorTypeCustomer:
+		resp, err := e.client.ListCustomersWithResponse(ctx, &ListCustomersParams{ID: &id})
+		if err = ExtractError(resp, err); err != nil {
+			return "", fmt.Errorf("get customer %d: %w", actor.ID, err)
+		}
+		for _, c := range deref(resp.JSON200) {
+			if c.ID == actor.ID {
+				name = cmp.Or(fullName(deref(c.FirstName), deref(c.LastName)), deref(c.Username))
+			}
+		}
+	}
+
+	if name == "" {
+		name = cmp.Or(fullName(actor.FirstName, actor.LastName), key)
+	}
+	names[key] = name
+
+	return name, nil
+}
+
+func fullName(first, last string) string {
+	return strings.TrimSpace(first + " " + last)
+}
@@ -0,0 +1,166 @@
+package bot_api_client
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranscriptFormat is an output format of a transcript.
+type TranscriptFormat string
+
+const (
+	TranscriptFormatJSON TranscriptFormat = "json"
+	TranscriptFormatCSV  TranscriptFormat = "csv"
+	TranscriptFormatText TranscriptFormat = "text"
+	TranscriptFormatHTML TranscriptFormat = "html"
+)
+
+// Render writes the transcript to w in the format. The HTML page has no external dependencies.
+func (t Transcript) Render(w io.Writer, format TranscriptFormat) error {
+	switch format {
+	case TranscriptFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(t)
+	case TranscriptFormatCSV:
+		return t.renderCSV(w)
+	case TranscriptFormatText:
+		return t.renderText(w)
+	case TranscriptFormatHTML:
+		return transcriptHTML.Execute(w, t)
+	default:
+		return fmt.Errorf("unknown transcript format %q", format)
+	}
+}
+
+func (t Transcript) renderCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{
+		"id", "time", "kind", "type", "author", "author_type", "text", "action", "quote_id", "quote_text", "attachments",
+	})
+
+	for _, e := range t.Entries {
+		var quoteID, quoteText string
+		if e.Quote != nil {
+			quoteID, quoteText = strconv.FormatInt(e.Quote.ID, 10), e.Quote.Text
+		}
+
+		urls := make([]string, 0, len(e.Attachments))
+		for _, a := range e.Attachments {
+			urls = append(urls, a.URL)
+		}
+
+		_ = cw.Write([]string{
+			strconv.FormatInt(e.ID, 10), e.Time.Format(time.RFC3339), string(e.Kind), string(e.Type),
+			e.Author, string(e.AuthorType), e.Text, string(e.Action), quoteID, quoteText, strings.Join(urls, " "),
+		})
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func (t Transcript) renderText(w io.Writer) error {
+	var b strings.Builder
+	for _, e := range t.Entries {
+		at := e.Time.Format(time.DateTime)
+		switch e.Kind {
+		case TranscriptEntrySystem:
+			fmt.Fprintf(&b, "[%s] * %s", at, transcriptAction(e))
+		case TranscriptEntryNote:
+			fmt.Fprintf(&b, "[%s] (note) %s: %s", at, e.Author, e.Text)
+		default:
+			fmt.Fprintf(&b, "[%s] %s: %s", at, e.Author, e.Text)
+		}
+		b.WriteByte('\n')
+
+		if e.Quote != nil {
+			fmt.Fprintf(&b, "    > %s: %s\n", e.Quote.Author, e.Quote.Text)
+		}
+		for _, a := range e.Attachments {
+			fmt.Fprintf(&b, "    [%s] %s\n", a.Kind, strings.Join(nonEmpty(a.Caption, a.URL), " "))
+			if a.Transcription != "" {
+				fmt.Fprintf(&b, "    transcription: %s\n", a.Transcription)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// transcriptAction describes a system message, e.g. "dialog_assign by John Smith".
+func transcriptAction(e TranscriptEntry) string {
+	return strings.Join(nonEmpty(string(e.Action), e.Text, by(e.Author)), " ")
+}
+
+func by(author string) string {
+	if author == "" {
+		return ""
+	}
+
+	return "by " + author
+}
+
+func nonEmpty(values ...string) []string {
+	result := values[:0]
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"time":   func(t time.Time) string { return t.Format(time.DateTime) },
+	"action": transcriptAction,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transcript{{if .DialogID}} of dialog {{.DialogID}}{{else}} of chat {{.ChatID}}{{end}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; color: #222; }
+.entry { margin: 0.75em 0; padding: 0.5em 0.75em; border-radius: 6px; background: #f3f4f6; }
+.entry.customer { background: #e8f0fe; }
+.entry.note { background: #fff7d6; border-left: 4px solid #e0b000; }
+.entry.system { background: none; color: #777; font-size: 0.9em; text-align: center; }
+.meta { color: #666; font-size: 0.85em; }
+.text { white-space: pre-wrap; }
+blockquote { margin: 0.25em 0; padding-left: 0.75em; border-left: 3px solid #bbb; color: #555; }
+</style>
+</head>
+<body>
+<h1>Transcript{{if .DialogID}} of dialog {{.DialogID}}{{else}} of chat {{.ChatID}}{{end}}</h1>
+{{- range .Entries}}
+{{- if eq .Kind "system"}}
+<div class="entry system" id="m{{.ID}}">{{time .Time}} · {{action .}}</div>
+{{- else}}
+<div class="entry {{.Kind}} {{.AuthorType}}" id="m{{.ID}}">
+<div class="meta">{{if eq .Kind "note"}}Private note · {{end}}{{.Author}} · {{time .Time}}</div>
+{{- with .Quote}}
+<blockquote><a href="#m{{.ID}}">{{.Author}}</a>: {{.Text}}</blockquote>
+{{- end}}
+{{- if .Text}}
+<div class="text">{{.Text}}</div>
+{{- end}}
+{{- range .Attachments}}
+<div class="attachment">{{.Kind}}: <a href="{{.URL}}">{{or .Caption .ID}}</a>
+{{- with .Transcription}}<div class="text">{{.}}</div>{{end}}</div>
+{{- end}}
+</div>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,106 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderedTranscript() Transcript {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	return Transcript{DialogID: 7, Entries: []TranscriptEntry{
+		{
+			ID: 1, Time: at, Kind: TranscriptEntryMessage, Type: MessageTypeImage, Author: "Jane Doe",
+			AuthorType: ActorTypeCustomer, Text: "Hi <there>",
+			Attachments: []TranscriptAttachment{{
+				ID: "f1", Kind: FileTypeImage, Caption: "Broken box", URL: "https://storage.example.com/photo.jpg",
+			}},
+		},
+		{
+			ID: 2, Time: at.Add(time.Minute), Kind: TranscriptEntrySystem, Type: MessageTypeSystem,
+			Author: "John Smith", AuthorType: ActorTypeUser, Action: SystemActionDialogAssign,
+		},
+		{
+			ID: 3, Time: at.Add(2 * time.Minute), Kind: TranscriptEntryNote, Type: MessageTypeText,
+			Author: "John Smith", AuthorType: ActorTypeUser, Text: "Refund approved",
+		},
+		{
+			ID: 4, Time: at.Add(3 * time.Minute), Kind: TranscriptEntryMessage, Type: MessageTypeText,
+			Author: "John Smith", AuthorType: ActorTypeUser, Text: "Done",
+			Quote: &TranscriptQuote{ID: 1, Author: "Jane Doe", Text: "Hi <there>"},
+		},
+	}}
+}
+
+func TestTranscriptRender(t *testing.T) {
+	t.Parallel()
+
+	transcript := renderedTranscript()
+	render := func(format TranscriptFormat) string {
+		var buf bytes.Buffer
+		require.NoError(t, transcript.Render(&buf, format))
+
+		return buf.String()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		var decoded Transcript
+		require.NoError(t, json.Unmarshal([]byte(render(TranscriptFormatJSON)), &decoded))
+		assert.Equal(t, transcript, decoded)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		t.Parallel()
+
+		records, err := csv.NewReader(bytes.NewBufferString(render(TranscriptFormatCSV))).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 5)
+		assert.Equal(t, []string{
+			"1", "2024-05-01T12:00:00Z", "message", "image", "Jane Doe", "customer", "Hi <there>", "", "", "",
+			"https://storage.example.com/photo.jpg",
+		}, records[1])
+		assert.Equal(t, "dialog_assign", records[2][7])
+		assert.Equal(t, "note", records[3][2])
+		assert.Equal(t, []string{"1", "Hi <there>"}, records[4][8:10])
+	})
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, `[2024-05-01 12:00:00] Jane Doe: Hi <there>
+    [image] Broken box https://storage.example.com/photo.jpg
+[2024-05-01 12:01:00] * dialog_assign by John Smith
+[2024-05-01 12:02:00] (note) John Smith: Refund approved
+[2024-05-01 12:03:00] John Smith: Done
+    > Jane Doe: Hi <there>
+`, render(TranscriptFormatText))
+	})
+
+	t.Run("html", func(t *testing.T) {
+		t.Parallel()
+
+		page := render(TranscriptFormatHTML)
+		assert.Contains(t, page, "<title>Transcript of dialog 7</title>")
+		assert.Contains(t, page, `<div class="text">Hi &lt;there&gt;</div>`)
+		assert.Contains(t, page, `<a href="https://storage.example.com/photo.jpg">Broken box</a>`)
+		assert.Contains(t, page, `<div class="entry system" id="m2">2024-05-01 12:01:00 · dialog_assign by John Smith</div>`)
+		assert.Contains(t, page, `<div class="entry note user" id="m3">`)
+		assert.Contains(t, page, `<blockquote><a href="#m1">Jane Doe</a>: Hi &lt;there&gt;</blockquote>`)
+		assert.NotContains(t, page, "<script")
+		assert.NotContains(t, page, "<link")
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		t.Parallel()
+
+		require.EqualError(t, transcript.Render(&bytes.Buffer{}, "pdf"), `unknown transcript format "pdf"`)
+	})
+}
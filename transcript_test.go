@@ -0,0 +1,229 @@
+package bot_api_client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var transcriptFileID = uuid.MustParse("5b1b5b6e-7c3a-4a57-9a0e-2f1f3b7c9d10")
+
+type transcriptServer struct {
+	fakeAPI
+	messages []MessageListResponseItem
+	// updated is the time of the last update of edited messages, used by the since and until filters.
+	updated  map[int64]time.Time
+	requests []string
+}
+
+func (s *transcriptServer) client(t *testing.T) *ClientWithResponses {
+	return s.serve(t, func(req *http.Request) (int, any) {
+		query := req.URL.Query()
+		switch {
+		case req.URL.Path == "/messages":
+			s.requests = append(s.requests, "messages?"+req.URL.RawQuery)
+
+			sinceID, _ := strconv.ParseInt(query.Get("since_id"), 10, 64)
+			limit, err := strconv.Atoi(query.Get("limit"))
+			if !assert.NoError(t, err) {
+				return http.StatusBadRequest, nil
+			}
+			since, _ := time.Parse(time.RFC3339, query.Get("since"))
+			until, _ := time.Parse(time.RFC3339, query.Get("until"))
+
+			page := []MessageListResponseItem{}
+			for _, m := range s.messages {
+				updated, ok := s.updated[m.ID]
+				if !ok {
+					updated = m.CreatedAt.Time
+				}
+				if updated.Before(since) || (!until.IsZero() && updated.After(until)) {
+					continue
+				}
+				if m.ID > sinceID && len(page) < limit {
+					page = append(page, m)
+				}
+			}
+
+			return http.StatusOK, page
+		case req.URL.Path == "/users":
+			s.requests = append(s.requests, "users?"+req.URL.RawQuery)
+
+			return http.StatusOK, []map[string]any{{"id": 2, "first_name": "John", "last_name": "Smith"}}
+		case req.URL.Path == "/customers":
+			s.requests = append(s.requests, "customers?"+req.URL.RawQuery)
+
+			return http.StatusOK, []map[string]any{{"id": 5, "first_name": "Jane", "last_name": "Doe"}}
+		case strings.HasPrefix(req.URL.Path, "/files/"):
+			s.requests = append(s.requests, "files")
+
+			return http.StatusOK, FileWithUrl{ID: transcriptFileID, Url: "https://storage.example.com/photo.jpg"}
+		default:
+			return unexpectedRequest(t, req)
+		}
+	})
+}
+
+func transcriptMessages(start time.Time) []MessageListResponseItem {
+	customer := &Actor{ID: 5, Type: ActorTypeCustomer}
+	user := &Actor{ID: 2, Type: ActorTypeUser}
+	assign := SystemActionDialogAssign
+	at := func(minutes int) DateTimeRFC3339Micro {
+		return DateTimeRFC3339Micro{Time: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	return []MessageListResponseItem{
+		{
+			ID: 1, ChatID: 10, CreatedAt: at(0), Type: MessageTypeImage, Scope: MessageScopePublic, From: customer,
+			Content: optional("Hi, write to jane@example.com"),
+			Items:   []MessageFile{{ID: transcriptFileID, Kind: FileTypeImage, Caption: "Broken box", Size: 1024}},
+		},
+		{ID: 2, ChatID: 10, CreatedAt: at(1), Type: MessageTypeSystem, Action: &assign, From: user},
+		{
+			ID: 3, ChatID: 10, CreatedAt: at(2), Type: MessageTypeText, Scope: MessageScopePrivate, From: user,
+			Content: optional("Refund approved"),
+		},
+		{
+			ID: 4, ChatID: 10, CreatedAt: at(3), Type: MessageTypeText, Scope: MessageScopePublic,
+			From:    &Actor{ID: 2, Type: ActorTypeUser, Name: "John"},
+			Content: optional("We will call you at +1 555 123 4567"),
+			Quote:   &QuoteMessage{ID: 1, Content: "Hi, write to jane@example.com", From: customer},
+		},
+	}
+}
+
+func TestTranscriptExporter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("export", func(t *testing.T) {
+		t.Parallel()
+
+		server := transcriptServer{messages: transcriptMessages(start)}
+		exporter := NewTranscriptExporter(server.client(t), WithTranscriptPageSize(2))
+
+		transcript, err := exporter.Export(context.Background(), TranscriptQuery{DialogID: 7, Since: start})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"messages?dialog_id=7&limit=2&since=2024-05-01T12%3A00%3A00Z",
+			"customers?id=5",
+			"files",
+			"users?id=2",
+			"messages?dialog_id=7&limit=2&since=2024-05-01T12%3A00%3A00Z&since_id=2",
+			"messages?dialog_id=7&limit=2&since=2024-05-01T12%3A00%3A00Z&since_id=4",
+		}, server.requests)
+
+		require.Len(t, transcript.Entries, 4)
+		assert.Equal(t, TranscriptEntry{
+			ID: 1, Time: start, Kind: TranscriptEntryMessage, Type: MessageTypeImage,
+			Author: "Jane Doe", AuthorType: ActorTypeCustomer, Text: "Hi, write to jane@example.com",
+			Attachments: []TranscriptAttachment{{
+				ID: transcriptFileID.String(), Kind: FileTypeImage, Caption: "Broken box", Size: 1024,
+				URL: "https://storage.example.com/photo.jpg",
+			}},
+		}, transcript.Entries[0])
+		assert.Equal(t, TranscriptEntrySystem, transcript.Entries[1].Kind)
+		assert.Equal(t, SystemActionDialogAssign, transcript.Entries[1].Action)
+		assert.Equal(t, "John Smith", transcript.Entries[1].Author)
+		assert.Equal(t, TranscriptEntryNote, transcript.Entries[2].Kind)
+		assert.Equal(t, "John", transcript.Entries[3].Author)
+		assert.Equal(t, &TranscriptQuote{ID: 1, Author: "Jane Doe", Text: "Hi, write to jane@example.com"},
+			transcript.Entries[3].Quote)
+	})
+
+	t.Run("redaction", func(t *testing.T) {
+		t.Parallel()
+
+		server := transcriptServer{messages: transcriptMessages(start)}
+		exporter := NewTranscriptExporter(server.client(t), WithTranscriptRedaction(RedactPII))
+
+		transcript, err := exporter.Export(context.Background(), TranscriptQuery{ChatID: 10})
+		require.NoError(t, err)
+		require.Len(t, transcript.Entries, 4)
+
+		assert.Equal(t, "Customer 5", transcript.Entries[0].Author)
+		assert.Equal(t, "Hi, write to [email]", transcript.Entries[0].Text)
+		assert.Equal(t, "We will call you at [phone]", transcript.Entries[3].Text)
+		assert.Equal(t, &TranscriptQuote{ID: 1, Author: "Customer 5", Text: "Hi, write to [email]"},
+			transcript.Entries[3].Quote)
+		assert.NotContains(t, server.requests, "customers?id=5")
+	})
+
+	t.Run("range of creation times", func(t *testing.T) {
+		t.Parallel()
+
+		text := func(id int64, createdAt time.Time) MessageListResponseItem {
+			return MessageListResponseItem{
+				ID: id, ChatID: 10, CreatedAt: DateTimeRFC3339Micro{Time: createdAt}, Type: MessageTypeText,
+				Scope: MessageScopePublic, Content: optional("message " + strconv.FormatInt(id, 10)),
+			}
+		}
+		server := transcriptServer{
+			messages: []MessageListResponseItem{
+				text(1, start.Add(-time.Hour)), text(2, start.Add(5*time.Minute)), text(3, start.Add(20*time.Minute)),
+				text(4, start.Add(2*time.Hour)),
+			},
+			// Message 1 was edited within the range and message 2 after it.
+			updated: map[int64]time.Time{1: start.Add(10 * time.Minute), 2: start.Add(3 * time.Hour)},
+		}
+		exporter := NewTranscriptExporter(server.client(t))
+
+		transcript, err := exporter.Export(context.Background(), TranscriptQuery{
+			ChatID: 10, Since: start, Until: start.Add(time.Hour),
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"messages?chat_id=10&limit=1000&since=2024-05-01T12%3A00%3A00Z"}, server.requests)
+		require.Len(t, transcript.Entries, 2)
+		assert.Equal(t, int64(2), transcript.Entries[0].ID)
+		assert.Equal(t, int64(3), transcript.Entries[1].ID)
+	})
+
+	t.Run("page size is clamped", func(t *testing.T) {
+		t.Parallel()
+
+		for size, want := range map[int]string{0: "limit=1", -5: "limit=1", 5000: "limit=1000"} {
+			server := transcriptServer{messages: transcriptMessages(start)[1:3]}
+			exporter := NewTranscriptExporter(server.client(t), WithTranscriptPageSize(size))
+
+			transcript, err := exporter.Export(context.Background(), TranscriptQuery{ChatID: 10})
+			require.NoError(t, err)
+			assert.Len(t, transcript.Entries, 2, "size %d", size)
+			for _, r := range server.requests {
+				if strings.HasPrefix(r, "messages?") {
+					assert.Contains(t, r, want, "size %d", size)
+				}
+			}
+		}
+	})
+
+	t.Run("requires dialog or chat", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewTranscriptExporter(nil).Export(context.Background(), TranscriptQuery{})
+		require.EqualError(t, err, "export transcript: dialog or chat ID is required")
+	})
+}
+
+func TestRedactPII(t *testing.T) {
+	t.Parallel()
+
+	for input, expected := range map[string]string{
+		"mail me at john.smith+shop@mail.example.org": "mail me at [email]",
+		"call +7 (912) 345-67-89 after 6":             "call [phone] after 6",
+		"my number is 8 912 345 6789":                 "my number is [phone]",
+		"card 4111 1111 1111 1111, exp 12/27":         "card [card], exp 12/27",
+		"order 123456 shipped on 2024-05-01":          "order 123456 shipped on 2024-05-01",
+	} {
+		assert.Equal(t, expected, RedactPII(input), input)
+	}
+}
@@ -0,0 +1,276 @@
+package bot_api_client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType looks at.
+const sniffLen = 512
+
+// UploadProgressFunc is called as the file is sent. Total is -1 when the size is unknown.
+type UploadProgressFunc func(sent, total int64)
+
+// UploadOption configures a file upload.
+type UploadOption func(*uploadOptions)
+
+type uploadOptions struct {
+	contentType string
+	progress    UploadProgressFunc
+	digest      *string
+	reqEditors  []RequestEditorFn
+	image       *ImageOptions
+	imageReport func(ImageReport)
+}
+
+// WithUploadContentType sets the content type of the file instead of detecting it.
+func WithUploadContentType(contentType string) UploadOption {
+	return func(o *uploadOptions) {
+		o.contentType = contentType
+	}
+}
+
+// WithUploadProgress reports the number of file bytes sent.
+func WithUploadProgress(fn UploadProgressFunc) UploadOption {
+	return func(o *uploadOptions) {
+		o.progress = fn
+	}
+}
+
+// WithUploadSHA256 computes the SHA-256 digest of the file while it is sent
+// and stores it hex-encoded in digest once the file has been read completely.
+func WithUploadSHA256(digest *string) UploadOption {
+	return func(o *uploadOptions) {
+		o.digest = digest
+	}
+}
+
+// WithUploadRequestEditors adds request editors to the upload request.
+func WithUploadRequestEditors(reqEditors ...RequestEditorFn) UploadOption {
+	return func(o *uploadOptions) {
+		o.reqEditors = append(o.reqEditors, reqEditors...)
+	}
+}
+
+// UploadFileFromPath uploads a file from disk with the client. The file name sent is the base name of the path.
+func UploadFileFromPath(
+	ctx context.Context, client ClientWithResponsesInterface, path string, opts ...UploadOption,
+) (UploadResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return UploadResponse{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return UploadResponse{}, err
+	}
+
+	return UploadFileFromReader(ctx, client, filepath.Base(path), f, info.Size(), opts...)
+}
+
+// UploadFileFromReader uploads a file with the client as multipart/form-data, streaming it from r
+// without buffering it in memory.
+// Size is the number of bytes r provides; it is used for Content-Length and progress reporting.
+// Pass -1 if it is unknown and the request body is sent chunked.
+// Unless set with WithUploadContentType, the content type of the file is detected from its first bytes,
+// falling back to the file name extension.
+func UploadFileFromReader(
+	ctx context.Context, client ClientWithResponsesInterface, name string, r io.Reader, size int64, opts ...UploadOption,
+) (UploadResponse, error) {
+	var o uploadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	br := bufio.NewReaderSize(r, sniffLen)
+	if o.contentType == "" || o.image != nil {
+		head, err := br.Peek(sniffLen)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return UploadResponse{}, fmt.Errorf("read file: %w", err)
+		}
+
+		if detected := http.DetectContentType(head); o.image != nil && (detected == "image/jpeg" || detected == "image/png") {
+			processed, processedSize, err := preprocessUpload(name, br, *o.image, o.imageReport)
+			if err != nil {
+				return UploadResponse{}, fmt.Errorf("upload file: %w", err)
+			}
+			br, size = bufio.NewReaderSize(processed, sniffLen), processedSize
+		}
+
+		if o.contentType == "" {
+			o.contentType = detectContentType(name, head)
+		}
+	}
+
+	form, err := newUploadForm(name, o.contentType)
+	if err != nil {
+		return UploadResponse{}, err
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := form.write(pw, &uploadReader{ctx: ctx, r: br, total: size, progress: o.progress}, size, o.digest)
+		_ = pw.CloseWithError(err)
+		done <- err
+	}()
+
+	length := int64(-1)
+	if size >= 0 {
+		length = form.length(size)
+	}
+	reqEditors := append(o.reqEditors, func(_ context.Context, req *http.Request) error {
+		req.ContentLength = length
+
+		return nil
+	})
+
+	resp, err := client.UploadFileWithBodyWithResponse(ctx, form.contentType(), pr, reqEditors...)
+	_ = pr.Close()
+	if writeErr := <-done; writeErr != nil && !errors.Is(writeErr, io.ErrClosedPipe) {
+		return UploadResponse{}, fmt.Errorf("upload file: %w", writeErr)
+	}
+	if err = ExtractError(resp, err); err != nil {
+		return UploadResponse{}, fmt.Errorf("upload file: %w", err)
+	}
+	if resp.JSON200 == nil {
+		return UploadResponse{}, fmt.Errorf("upload file: unexpected response %s", resp.Status())
+	}
+
+	return *resp.JSON200, nil
+}
+
+func detectContentType(name string, head []byte) string {
+	detected := http.DetectContentType(head)
+	if detected != "application/octet-stream" {
+		return detected
+	}
+
+	if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+		return byExt
+	}
+
+	return detected
+}
+
+// uploadForm is a multipart form with a single file part. The parts before and after the file
+// are rendered in advance, so the length of the body is known before the file is read.
+type uploadForm struct {
+	boundary string
+	head     []byte
+	tail     []byte
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func newUploadForm(name, contentType string) (uploadForm, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, quoteEscaper.Replace(name)))
+	header.Set("Content-Type", contentType)
+	if _, err := mw.CreatePart(header); err != nil {
+		return uploadForm{}, err
+	}
+
+	head := append([]byte(nil), buf.Bytes()...)
+	if err := mw.Close(); err != nil {
+		return uploadForm{}, err
+	}
+
+	return uploadForm{boundary: mw.Boundary(), head: head, tail: buf.Bytes()[len(head):]}, nil
+}
+
+func (f uploadForm) contentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+func (f uploadForm) length(size int64) int64 {
+	return int64(len(f.head)) + size + int64(len(f.tail))
+}
+
+// write writes the form with the file read from r, which must provide exactly size bytes unless size is -1.
+func (f uploadForm) write(w io.Writer, r io.Reader, size int64, digest *string) error {
+	if _, err := w.Write(f.head); err != nil {
+		return err
+	}
+
+	var h hash.Hash
+	if digest != nil {
+		h = sha256.New()
+		r = io.TeeReader(r, h)
+	}
+
+	if size < 0 {
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+	} else {
+		n, err := io.CopyN(w, r, size)
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("file is %d bytes, expected %d", n, size)
+		}
+		if err != nil {
+			return err
+		}
+
+		var extra [1]byte
+		if _, err := io.ReadFull(r, extra[:]); err == nil {
+			return fmt.Errorf("file is larger than %d bytes", size)
+		} else if !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	if _, err := w.Write(f.tail); err != nil {
+		return err
+	}
+
+	if h != nil {
+		*digest = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return nil
+}
+
+// uploadReader reports progress and stops reading once the context is done.
+type uploadReader struct {
+	ctx      context.Context
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress UploadProgressFunc
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	if err := u.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := u.r.Read(p)
+	if n > 0 {
+		u.sent += int64(n)
+		if u.progress != nil {
+			u.progress(u.sent, u.total)
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,179 @@
+package bot_api_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedUpload struct {
+	contentLength int64
+	fileName      string
+	contentType   string
+	content       []byte
+}
+
+func newUploadClient(t *testing.T, received *receivedUpload) *ClientWithResponses {
+	mockDoer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.True(t, strings.HasSuffix(req.URL.Path, "/files/upload"))
+
+		received.contentLength = req.ContentLength
+
+		mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		assert.Equal(t, "multipart/form-data", mediaType)
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if req.ContentLength >= 0 {
+			assert.Equal(t, req.ContentLength, int64(len(body)))
+		}
+
+		part, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).NextPart()
+		require.NoError(t, err)
+		assert.Equal(t, "file", part.FormName())
+		received.fileName = part.FileName()
+		received.contentType = part.Header.Get("Content-Type")
+		received.content, err = io.ReadAll(part)
+		require.NoError(t, err)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body: io.NopCloser(strings.NewReader(
+				`{"id": "1d2bc0d5-31e1-4a4e-9bda-1d0c5a6f9e2b", "size": 5, "type": "image"}`,
+			)),
+		}, nil
+	})
+
+	client, err := NewClientWithResponses("https://example.com/api/bot/v1/", WithHTTPClient(mockDoer))
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestUploadFileFromReader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streams the file", func(t *testing.T) {
+		t.Parallel()
+
+		png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{1}, 100_000)...)
+
+		var (
+			received receivedUpload
+			digest   string
+			progress []int64
+		)
+		client := newUploadClient(t, &received)
+		resp, err := UploadFileFromReader(
+			context.Background(), client, `photo "1".png`, bytes.NewReader(png), int64(len(png)),
+			WithUploadSHA256(&digest),
+			WithUploadProgress(func(sent, total int64) {
+				assert.Equal(t, int64(len(png)), total)
+				progress = append(progress, sent)
+			}),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, FileType("image"), resp.Type)
+		assert.Equal(t, `photo "1".png`, received.fileName)
+		assert.Equal(t, "image/png", received.contentType)
+		assert.Equal(t, png, received.content)
+		assert.Greater(t, received.contentLength, int64(len(png)))
+
+		sum := sha256.Sum256(png)
+		assert.Equal(t, hex.EncodeToString(sum[:]), digest)
+
+		require.NotEmpty(t, progress)
+		assert.Equal(t, int64(len(png)), progress[len(progress)-1])
+		assert.IsIncreasing(t, progress)
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		t.Parallel()
+
+		var received receivedUpload
+		client := newUploadClient(t, &received)
+		_, err := UploadFileFromReader(
+			context.Background(), client, "data", io.MultiReader(strings.NewReader(`{"a":`), strings.NewReader(`1}`)), -1,
+			WithUploadContentType("application/json"),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(-1), received.contentLength)
+		assert.Equal(t, "application/json", received.contentType)
+		assert.Equal(t, `{"a":1}`, string(received.content))
+	})
+
+	t.Run("content type by extension", func(t *testing.T) {
+		t.Parallel()
+
+		var received receivedUpload
+		client := newUploadClient(t, &received)
+		_, err := UploadFileFromReader(
+			context.Background(), client, "data.json", bytes.NewReader([]byte{0, 1, 2}), 3,
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, "application/json", received.contentType)
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		var received receivedUpload
+		client := newUploadClient(t, &received)
+
+		_, err := UploadFileFromReader(context.Background(), client, "a.txt", strings.NewReader("hello"), 10)
+		require.ErrorContains(t, err, "file is 5 bytes, expected 10")
+
+		_, err = UploadFileFromReader(context.Background(), client, "a.txt", strings.NewReader("hello"), 3)
+		require.ErrorContains(t, err, "file is larger than 3 bytes")
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var received receivedUpload
+		client := newUploadClient(t, &received)
+		_, err := UploadFileFromReader(
+			ctx, client, "big.bin", bytes.NewReader(make([]byte, 1<<20)), 1<<20,
+			WithUploadProgress(func(_, _ int64) { cancel() }),
+		)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestUploadFileFromPath(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(path, []byte("plain text notes"), 0o600))
+
+	var received receivedUpload
+	_, err := UploadFileFromPath(context.Background(), newUploadClient(t, &received), path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "notes.txt", received.fileName)
+	assert.Equal(t, "text/plain; charset=utf-8", received.contentType)
+	assert.Equal(t, "plain text notes", string(received.content))
+}
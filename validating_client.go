@@ -0,0 +1,318 @@
+package bot_api_client
+
+import (
+	"context"
+	"io"
+)
+
+// ValidatingClient validates request bodies and query parameters against the API constraints
+// before the requests are made by the wrapped client. Invalid requests fail with ValidationErrors
+// and never reach the server. Raw bodies, passed to the WithBody methods, are not checked.
+type ValidatingClient struct {
+	client ClientWithResponsesInterface
+}
+
+var _ ClientWithResponsesInterface = (*ValidatingClient)(nil)
+
+// NewValidatingClient returns a client validating the requests before client makes them.
+func NewValidatingClient(client ClientWithResponsesInterface) *ValidatingClient {
+	return &ValidatingClient{client: client}
+}
+
+func (c *ValidatingClient) ListBotsWithResponse(
+	ctx context.Context, params *ListBotsParams, reqEditors ...RequestEditorFn,
+) (*ListBotsResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListBotsWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) ListChannelsWithResponse(
+	ctx context.Context, params *ListChannelsParams, reqEditors ...RequestEditorFn,
+) (*ListChannelsResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListChannelsWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) ListChatsWithResponse(
+	ctx context.Context, params *ListChatsParams, reqEditors ...RequestEditorFn,
+) (*ListChatsResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListChatsWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) CreateDialogWithBodyWithResponse(
+	ctx context.Context, chatIDPath ChatIDPath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*CreateDialogResp, error) {
+	return c.client.CreateDialogWithBodyWithResponse(ctx, chatIDPath, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) CreateDialogWithResponse(
+	ctx context.Context, chatIDPath ChatIDPath, body CreateDialogJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*CreateDialogResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.CreateDialogWithResponse(ctx, chatIDPath, body, reqEditors...)
+}
+
+func (c *ValidatingClient) ListCustomersWithResponse(
+	ctx context.Context, params *ListCustomersParams, reqEditors ...RequestEditorFn,
+) (*ListCustomersResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListCustomersWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) ListDialogsWithResponse(
+	ctx context.Context, params *ListDialogsParams, reqEditors ...RequestEditorFn,
+) (*ListDialogsResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListDialogsWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) AssignDialogResponsibleWithBodyWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*AssignDialogResponsibleResp, error) {
+	return c.client.AssignDialogResponsibleWithBodyWithResponse(ctx, dialogIDPath, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) AssignDialogResponsibleWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, body AssignDialogResponsibleJSONRequestBody,
+	reqEditors ...RequestEditorFn,
+) (*AssignDialogResponsibleResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.AssignDialogResponsibleWithResponse(ctx, dialogIDPath, body, reqEditors...)
+}
+
+func (c *ValidatingClient) CloseDialogWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, reqEditors ...RequestEditorFn,
+) (*CloseDialogResp, error) {
+	return c.client.CloseDialogWithResponse(ctx, dialogIDPath, reqEditors...)
+}
+
+func (c *ValidatingClient) DialogAddTagsWithBodyWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*DialogAddTagsResp, error) {
+	return c.client.DialogAddTagsWithBodyWithResponse(ctx, dialogIDPath, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) DialogAddTagsWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, body DialogAddTagsJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*DialogAddTagsResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.DialogAddTagsWithResponse(ctx, dialogIDPath, body, reqEditors...)
+}
+
+func (c *ValidatingClient) DialogDeleteTagsWithBodyWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*DialogDeleteTagsResp, error) {
+	return c.client.DialogDeleteTagsWithBodyWithResponse(ctx, dialogIDPath, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) DialogDeleteTagsWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, body DialogDeleteTagsJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*DialogDeleteTagsResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.DialogDeleteTagsWithResponse(ctx, dialogIDPath, body, reqEditors...)
+}
+
+func (c *ValidatingClient) UnassignDialogResponsibleWithResponse(
+	ctx context.Context, dialogIDPath DialogIDPath, reqEditors ...RequestEditorFn,
+) (*UnassignDialogResponsibleResp, error) {
+	return c.client.UnassignDialogResponsibleWithResponse(ctx, dialogIDPath, reqEditors...)
+}
+
+func (c *ValidatingClient) UploadFileWithBodyWithResponse(
+	ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*UploadFileResp, error) {
+	return c.client.UploadFileWithBodyWithResponse(ctx, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) UploadFileByUrlWithBodyWithResponse(
+	ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*UploadFileByUrlResp, error) {
+	return c.client.UploadFileByUrlWithBodyWithResponse(ctx, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) UploadFileByUrlWithResponse(
+	ctx context.Context, body UploadFileByUrlJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*UploadFileByUrlResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.UploadFileByUrlWithResponse(ctx, body, reqEditors...)
+}
+
+func (c *ValidatingClient) GetFileUrlWithResponse(
+	ctx context.Context, fileIDPath FileIDPath, reqEditors ...RequestEditorFn,
+) (*GetFileUrlResp, error) {
+	return c.client.GetFileUrlWithResponse(ctx, fileIDPath, reqEditors...)
+}
+
+func (c *ValidatingClient) UpdateFileMetadataWithBodyWithResponse(
+	ctx context.Context, fileIDPath FileIDPath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*UpdateFileMetadataResp, error) {
+	return c.client.UpdateFileMetadataWithBodyWithResponse(ctx, fileIDPath, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) UpdateFileMetadataWithResponse(
+	ctx context.Context, fileIDPath FileIDPath, body UpdateFileMetadataJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*UpdateFileMetadataResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.UpdateFileMetadataWithResponse(ctx, fileIDPath, body, reqEditors...)
+}
+
+func (c *ValidatingClient) ListMembersWithResponse(
+	ctx context.Context, params *ListMembersParams, reqEditors ...RequestEditorFn,
+) (*ListMembersResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListMembersWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) ListMessagesWithResponse(
+	ctx context.Context, params *ListMessagesParams, reqEditors ...RequestEditorFn,
+) (*ListMessagesResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListMessagesWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) SendMessageWithBodyWithResponse(
+	ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*SendMessageResp, error) {
+	return c.client.SendMessageWithBodyWithResponse(ctx, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) SendMessageWithResponse(
+	ctx context.Context, body SendMessageJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*SendMessageResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.SendMessageWithResponse(ctx, body, reqEditors...)
+}
+
+func (c *ValidatingClient) DeleteMessageWithResponse(
+	ctx context.Context, messageIDPath MessageIDPath, reqEditors ...RequestEditorFn,
+) (*DeleteMessageResp, error) {
+	return c.client.DeleteMessageWithResponse(ctx, messageIDPath, reqEditors...)
+}
+
+func (c *ValidatingClient) EditMessageWithBodyWithResponse(
+	ctx context.Context, messageIDPath MessageIDPath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*EditMessageResp, error) {
+	return c.client.EditMessageWithBodyWithResponse(ctx, messageIDPath, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) EditMessageWithResponse(
+	ctx context.Context, messageIDPath MessageIDPath, body EditMessageJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*EditMessageResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.EditMessageWithResponse(ctx, messageIDPath, body, reqEditors...)
+}
+
+func (c *ValidatingClient) ListCommandsWithResponse(
+	ctx context.Context, params *ListCommandsParams, reqEditors ...RequestEditorFn,
+) (*ListCommandsResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListCommandsWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) DeleteCommandWithResponse(
+	ctx context.Context, commandName CommandNamePath, reqEditors ...RequestEditorFn,
+) (*DeleteCommandResp, error) {
+	return c.client.DeleteCommandWithResponse(ctx, commandName, reqEditors...)
+}
+
+func (c *ValidatingClient) CreateOrUpdateCommandWithBodyWithResponse(
+	ctx context.Context, commandName CommandNamePath, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*CreateOrUpdateCommandResp, error) {
+	return c.client.CreateOrUpdateCommandWithBodyWithResponse(ctx, commandName, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) CreateOrUpdateCommandWithResponse(
+	ctx context.Context, commandName CommandNamePath, body CreateOrUpdateCommandJSONRequestBody,
+	reqEditors ...RequestEditorFn,
+) (*CreateOrUpdateCommandResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.CreateOrUpdateCommandWithResponse(ctx, commandName, body, reqEditors...)
+}
+
+func (c *ValidatingClient) UpdateBotWithBodyWithResponse(
+	ctx context.Context, contentType string, body io.Reader, reqEditors ...RequestEditorFn,
+) (*UpdateBotResp, error) {
+	return c.client.UpdateBotWithBodyWithResponse(ctx, contentType, body, reqEditors...)
+}
+
+func (c *ValidatingClient) UpdateBotWithResponse(
+	ctx context.Context, body UpdateBotJSONRequestBody, reqEditors ...RequestEditorFn,
+) (*UpdateBotResp, error) {
+	if err := Validate(body); err != nil {
+		return nil, err
+	}
+
+	return c.client.UpdateBotWithResponse(ctx, body, reqEditors...)
+}
+
+func (c *ValidatingClient) ListUsersWithResponse(
+	ctx context.Context, params *ListUsersParams, reqEditors ...RequestEditorFn,
+) (*ListUsersResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.ListUsersWithResponse(ctx, params, reqEditors...)
+}
+
+func (c *ValidatingClient) WebSocketConnectionWithResponse(
+	ctx context.Context, params *WebSocketConnectionParams, reqEditors ...RequestEditorFn,
+) (*WebSocketConnectionResp, error) {
+	if err := Validate(params); err != nil {
+		return nil, err
+	}
+
+	return c.client.WebSocketConnectionWithResponse(ctx, params, reqEditors...)
+}
@@ -0,0 +1,104 @@
+package bot_api_client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatingClient(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, calls *int) *ValidatingClient {
+		mockDoer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			*calls++
+
+			payload := `[]`
+			if req.Method == http.MethodPost {
+				body, err := io.ReadAll(req.Body)
+				if assert.NoError(t, err) {
+					assert.Contains(t, string(body), `"chat_id":1`)
+				}
+				payload = `{"message_id": 1}`
+			}
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(payload)),
+			}, nil
+		})
+
+		client, err := NewClientWithResponses("https://example.com/api/bot/v1/", WithHTTPClient(mockDoer))
+		require.NoError(t, err)
+
+		return NewValidatingClient(client)
+	}
+
+	t.Run("invalid params are not sent", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int
+		limit := 5000
+		state := ListMembersParamsState("gone")
+
+		_, err := newClient(t, &calls).ListMembersWithResponse(context.Background(), &ListMembersParams{
+			Limit: &limit,
+			State: &state,
+		})
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, []string{"limit", "state"}, fieldNames(verrs))
+		assert.Zero(t, calls)
+	})
+
+	t.Run("invalid body is not sent", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int
+		_, err := newClient(t, &calls).DialogAddTagsWithResponse(context.Background(), 1, DialogAddTagsJSONRequestBody{})
+
+		var verrs ValidationErrors
+		require.ErrorAs(t, err, &verrs)
+		assert.Equal(t, []string{"tags"}, fieldNames(verrs))
+		assert.Zero(t, calls)
+	})
+
+	t.Run("valid requests are sent", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int
+		client := newClient(t, &calls)
+
+		limit := 10
+		_, err := client.ListChatsWithResponse(context.Background(), &ListChatsParams{Limit: &limit})
+		require.NoError(t, err)
+		_, err = client.ListChatsWithResponse(context.Background(), nil)
+		require.NoError(t, err)
+
+		content := "hi"
+		_, err = client.SendMessageWithResponse(context.Background(), SendMessageRequestBody{
+			ChatID:  1,
+			Content: &content,
+			Scope:   MessageScopePublic,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("raw bodies are sent unchecked", func(t *testing.T) {
+		t.Parallel()
+
+		var calls int
+		_, err := newClient(t, &calls).SendMessageWithBodyWithResponse(context.Background(), "application/json",
+			strings.NewReader(`{"chat_id":1}`))
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+}
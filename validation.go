@@ -0,0 +1,329 @@
+package bot_api_client
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes a single field that does not satisfy an API constraint.
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. "items[0].caption".
+	Field string
+	// Rule is the name of the violated constraint, e.g. "max" or "required".
+	Rule string
+	// Param is the constraint parameter, e.g. "1024" for max=1024.
+	Param string
+	// Message is a human-readable description of the problem.
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors is a list of field errors returned by client-side validation.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// add appends a field error with a formatted message.
+func (e *ValidationErrors) add(field, rule, param, format string, args ...interface{}) {
+	*e = append(*e, FieldError{
+		Field:   field,
+		Rule:    rule,
+		Param:   param,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// err returns nil when no errors were collected.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Validate checks a request body or params struct against the binding tags of its fields,
+// the same constraints the server enforces. Nested structs, including slice elements, are validated too.
+// It returns nil or ValidationErrors with one entry per invalid field.
+func Validate(v interface{}) error {
+	return validate(v).err()
+}
+
+func validate(v interface{}) ValidationErrors {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	var errs ValidationErrors
+	if rv.Kind() == reflect.Struct {
+		validateStruct(&errs, "", rv)
+	}
+
+	return errs
+}
+
+type bindingRule struct {
+	name  string
+	param string
+}
+
+func parseBindingRules(tag string) []bindingRule {
+	if tag == "" {
+		return nil
+	}
+
+	parts := strings.Split(tag, ",")
+	rules := make([]bindingRule, 0, len(parts))
+	for _, part := range parts {
+		name, param, _ := strings.Cut(part, "=")
+		rules = append(rules, bindingRule{name: name, param: param})
+	}
+
+	return rules
+}
+
+func validateStruct(errs *ValidationErrors, path string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+			continue
+		case f.Anonymous && name == "":
+			validateNested(errs, path, v.Field(i))
+
+			continue
+		case name == "":
+			name = f.Name
+		}
+
+		if path != "" {
+			name = path + "." + name
+		}
+
+		trim := strings.Contains(f.Tag.Get("mod"), "trim")
+		validateField(errs, name, v.Field(i), parseBindingRules(f.Tag.Get("binding")), trim)
+	}
+}
+
+func validateField(errs *ValidationErrors, path string, v reflect.Value, rules []bindingRule, trim bool) {
+	for i, rule := range rules {
+		switch rule.name {
+		case "omitempty":
+			if !hasValue(v) {
+				return
+			}
+		case "required":
+			if !hasValue(v) {
+				errs.add(path, rule.name, "", "is required")
+
+				return
+			}
+		case "dive":
+			elems := indirect(v)
+			if elems.Kind() != reflect.Slice && elems.Kind() != reflect.Array {
+				return
+			}
+			for j := 0; j < elems.Len(); j++ {
+				validateField(errs, fmt.Sprintf("%s[%d]", path, j), elems.Index(j), rules[i+1:], trim)
+			}
+
+			return
+		default:
+			ev := indirect(v)
+			if !ev.IsValid() {
+				// Rules of unset optional fields are not checked.
+				return
+			}
+			if !checkBindingRule(errs, path, ev, rule, trim) {
+				return
+			}
+		}
+	}
+
+	validateNested(errs, path, v)
+}
+
+// validateNested validates struct fields and struct elements of slices.
+func validateNested(errs *ValidationErrors, path string, v reflect.Value) {
+	v = indirect(v)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		validateStruct(errs, path, v)
+	case reflect.Slice, reflect.Array:
+		for j := 0; j < v.Len(); j++ {
+			if elem := indirect(v.Index(j)); elem.Kind() == reflect.Struct {
+				validateStruct(errs, fmt.Sprintf("%s[%d]", path, j), elem)
+			}
+		}
+	}
+}
+
+// checkBindingRule reports whether the value satisfies the rule and records an error otherwise.
+// Unknown rules are ignored.
+func checkBindingRule(errs *ValidationErrors, path string, v reflect.Value, rule bindingRule, trim bool) bool {
+	switch rule.name {
+	case "min", "max", "gte", "lte", "gt", "lt", "len":
+		return checkBound(errs, path, v, rule, trim)
+	case "oneof":
+		options := strings.Fields(rule.param)
+		if slices.Contains(options, fmt.Sprint(v.Interface())) {
+			return true
+		}
+		errs.add(path, rule.name, rule.param, "must be one of [%s]", strings.Join(options, " "))
+	case "enum-valid":
+		return checkEnum(errs, path, v)
+	case "currency":
+		if v.Kind() != reflect.String || isCurrencyCode(v.String()) {
+			return true
+		}
+		errs.add(path, rule.name, "", "%q is not an ISO 4217 currency code", v.String())
+	case "url", "Url":
+		if u, err := url.Parse(strings.TrimSpace(v.String())); err == nil && u.Scheme != "" {
+			return true
+		}
+		errs.add(path, "url", "", "must be a valid URL")
+	case "web_url":
+		u, err := url.Parse(strings.TrimSpace(v.String()))
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			return true
+		}
+		errs.add(path, rule.name, "", "must be an http or https URL")
+	case "command_name":
+		if commandNamePattern.MatchString(v.String()) {
+			return true
+		}
+		errs.add(path, rule.name, "", "must contain only latin letters, digits and underscores")
+	default:
+		return true
+	}
+
+	return false
+}
+
+var commandNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func checkBound(errs *ValidationErrors, path string, v reflect.Value, rule bindingRule, trim bool) bool {
+	limit, err := strconv.ParseFloat(rule.param, 64)
+	if err != nil {
+		return true
+	}
+
+	var (
+		value float64
+		unit  string
+	)
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if trim {
+			s = strings.TrimSpace(s)
+		}
+		value, unit = float64(utf8.RuneCountInString(s)), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		value, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		value = v.Float()
+	default:
+		return true
+	}
+
+	var ok bool
+	var format string
+	switch rule.name {
+	case "min", "gte":
+		ok, format = value >= limit, "must be at least %s%s"
+	case "max", "lte":
+		ok, format = value <= limit, "must be at most %s%s"
+	case "gt":
+		ok, format = value > limit, "must be greater than %s%s"
+	case "lt":
+		ok, format = value < limit, "must be less than %s%s"
+	case "len":
+		ok, format = value == limit, "must be exactly %s%s"
+	}
+
+	if !ok {
+		errs.add(path, rule.name, rule.param, format, rule.param, unit)
+	}
+
+	return ok
+}
+
+func checkEnum(errs *ValidationErrors, path string, v reflect.Value) bool {
+	if e, ok := v.Interface().(enumValidator); ok {
+		if err := e.ValidateEnum(); err != nil {
+			errs.add(path, "enum-valid", "", "%v", err)
+
+			return false
+		}
+
+		return true
+	}
+
+	valid := true
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for j := 0; j < v.Len(); j++ {
+			valid = checkEnum(errs, fmt.Sprintf("%s[%d]", path, j), indirect(v.Index(j))) && valid
+		}
+	}
+
+	return valid
+}
+
+// hasValue reports whether a field is set: non-nil for pointers, slices and maps, non-zero otherwise.
+func hasValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return !v.IsNil()
+	case reflect.Invalid:
+		return false
+	}
+
+	return !v.IsZero()
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
@@ -0,0 +1,107 @@
+package bot_api_client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	limit := 5000
+	zero := 0
+	active := Boolean("maybe")
+	name := "bad-name!"
+	badStatus := FileTranscriptionStatus("done")
+	avatar := "not a url"
+
+	testCases := []struct {
+		name     string
+		value    interface{}
+		expected []FieldError
+	}{
+		{
+			name:  "valid params",
+			value: &ListChatsParams{Limit: func() *int { l := 100; return &l }()},
+		},
+		{
+			name:  "nil params",
+			value: (*ListChatsParams)(nil),
+		},
+		{
+			name:  "numeric bounds and enums",
+			value: ListChannelsParams{ID: &zero, Limit: &limit, Active: &active, Types: &ChannelTypeQuery{"telegram", "pigeon"}},
+			expected: []FieldError{
+				{Field: "id", Rule: "min", Param: "1", Message: "must be at least 1"},
+				{Field: "types[1]", Rule: "enum-valid", Message: "invalid value for ChannelType: pigeon"},
+				{Field: "active", Rule: "enum-valid", Message: "invalid value for Boolean: maybe"},
+				{Field: "limit", Rule: "max", Param: "1000", Message: "must be at most 1000"},
+			},
+		},
+		{
+			name: "dive into slices",
+			value: DialogAddTagsJSONRequestBody{Tags: []struct {
+				ColorCode *ColorCode `binding:"omitempty,enum-valid" json:"color_code,omitempty"`
+				Name      string     `binding:"required,min=1,max=255" json:"name" mod:"trim,escape"`
+			}{{Name: "vip"}, {Name: strings.Repeat("x", 256)}, {}}},
+			expected: []FieldError{
+				{Field: "tags[1].name", Rule: "max", Param: "255", Message: "must be at most 255 characters"},
+				{Field: "tags[2].name", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name:  "required slice",
+			value: DialogDeleteTagsJSONRequestBody{},
+			expected: []FieldError{
+				{Field: "tags", Rule: "required", Message: "is required"},
+			},
+		},
+		{
+			name: "oneof, url and command name",
+			value: struct {
+				UpdateFileMetadataJSONRequestBody
+				Bot      UpdateBotJSONRequestBody `json:"bot"`
+				Commands ListCommandsParams       `json:"commands"`
+				Upload   UploadFileByUrlJSONRequestBody
+			}{
+				UpdateFileMetadataJSONRequestBody: UpdateFileMetadataJSONRequestBody{TranscriptionStatus: &badStatus},
+				Bot:                               UpdateBotJSONRequestBody{AvatarUrl: &avatar},
+				Commands:                          ListCommandsParams{Name: &name},
+				Upload:                            UploadFileByUrlJSONRequestBody{Url: "ftp://example.com/file"},
+			},
+			expected: []FieldError{
+				{Field: "transcription_status", Rule: "oneof", Param: "in_progress ready error", Message: "must be one of [in_progress ready error]"},
+				{Field: "bot.avatar_url", Rule: "url", Message: "must be a valid URL"},
+				{Field: "commands.name", Rule: "command_name", Message: "must contain only latin letters, digits and underscores"},
+				{Field: "Upload.url", Rule: "web_url", Message: "must be an http or https URL"},
+			},
+		},
+		{
+			name:  "trimmed length",
+			value: NewMessageItem([16]byte{1}, "   "),
+			expected: []FieldError{
+				{Field: "caption", Rule: "min", Param: "1", Message: "must be at least 1 characters"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Validate(tc.value)
+			if tc.expected == nil {
+				require.NoError(t, err)
+
+				return
+			}
+
+			var verrs ValidationErrors
+			require.ErrorAs(t, err, &verrs)
+			assert.Equal(t, ValidationErrors(tc.expected), verrs)
+		})
+	}
+}
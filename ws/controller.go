@@ -6,12 +6,20 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/lerenn/asyncapi-codegen/pkg/extensions"
 	"net/http"
+	"strconv"
+	"sync"
 )
 
 type Controller struct {
 	url     string
 	headers http.Header
 	options []ControllerOption
+	// middlewares are added by options and attached to the app controller as a single chain.
+	middlewares []extensions.Middleware
+	// onDisconnect is called when the connection of a subscription is lost.
+	onDisconnect func(channel string, err error)
+	// handled tracks the received messages until the app controller has handled them.
+	handled *handledMessages
 }
 
 type Option func(controller *Controller) error
@@ -23,7 +31,9 @@ func NewController(url, token string, options ...Option) (*AppController, error)
 			"X-Bot-Token": []string{token},
 		},
 		options: make([]ControllerOption, 0),
+		handled: &handledMessages{pending: make(map[uint64]chan struct{})},
 	}
+	ctrl.middlewares = []extensions.Middleware{ctrl.handled.track, rawEvents}
 
 	for _, option := range options {
 		if err := option(ctrl); err != nil {
@@ -31,6 +41,8 @@ func NewController(url, token string, options ...Option) (*AppController, error)
 		}
 	}
 
+	ctrl.options = append(ctrl.options, WithMiddlewares(ctrl.middlewares...))
+
 	appController, err := NewAppController(ctrl, ctrl.options...)
 	if err != nil {
 		panic(err)
@@ -54,34 +66,151 @@ func (c Controller) Subscribe(ctx context.Context, channel string) (extensions.B
 
 	messages := make(chan extensions.AcknowledgeableBrokerMessage)
 	cancel := make(chan any)
+	// closing is closed when the subscription is cancelled, read once the reader has stopped.
+	closing := make(chan struct{})
+	read := make(chan struct{})
+	var readErr error
+
+	// last is the channel closed once the last message passed to the listener has been handled.
+	var last chan struct{}
 
 	go func() {
-		defer close(cancel)
-		defer close(messages)
+		defer close(read)
 
 		for {
-			_, message, readErr := conn.ReadMessage()
+			_, message, err := conn.ReadMessage()
+
+			if err != nil {
+				readErr = err
 
-			if readErr != nil {
 				return
 			}
 
-			messages <- extensions.NewAcknowledgeableBrokerMessage(
-				extensions.BrokerMessage{Payload: message},
-				NoopAcknowledgementHandler{},
-			)
+			id, done := c.handled.add()
+			msg := extensions.BrokerMessage{Payload: message}
+			if done != nil {
+				msg.Headers = map[string][]byte{handledHeader: []byte(strconv.FormatUint(id, 10))}
+			}
+
+			select {
+			case messages <- extensions.NewAcknowledgeableBrokerMessage(msg, NoopAcknowledgementHandler{}):
+				last = done
+			case <-closing:
+				c.handled.done(id)
+
+				return
+			}
 		}
 	}()
 
-	sub := extensions.NewBrokerChannelSubscription(messages, cancel)
-
-	sub.WaitForCancellationAsync(func() {
-		if err := conn.Close(); err != nil {
-			panic(err)
+	// The subscription ends when it is cancelled or the connection fails. Either way messages is closed, which stops
+	// the listener. cancel is closed only once the cancellation is requested, as Cancel sends on it and then waits
+	// for it to be closed.
+	go func() {
+		select {
+		case <-cancel:
+			close(closing)
+			// The connection may already be broken; there is nothing to do about an error closing it.
+			_ = conn.Close()
+			<-read
+			close(messages)
+		case <-read:
+			_ = conn.Close()
+			close(messages)
+			// The listener handles the messages in order, so the last one is handled after all the others.
+			// The handler may cancel the subscription meanwhile, which is then not reported.
+			if last != nil {
+				select {
+				case <-last:
+				case <-cancel:
+					close(cancel)
+
+					return
+				}
+			}
+			if c.onDisconnect != nil {
+				c.onDisconnect(channel, readErr)
+			}
+			<-cancel
 		}
-	})
+		close(cancel)
+	}()
+
+	return extensions.NewBrokerChannelSubscription(messages, cancel), nil
+}
+
+// WithDisconnectHandler sets a handler called with the error when the connection of a subscription fails
+// or is closed by the server. The subscription has ended by then: the middlewares and the handler have returned for
+// every received event and receive no more. It is to be unsubscribed before subscribing to the channel again.
+// Cancelled subscriptions are not reported.
+func WithDisconnectHandler(handler func(channel string, err error)) Option {
+	return func(controller *Controller) error {
+		controller.onDisconnect = handler
+
+		return nil
+	}
+}
+
+// handledHeader carries the ID given to a message by the reader. It is removed by the outermost middleware,
+// so other middlewares and handlers do not see it.
+const handledHeader = "X-Handled-Message"
+
+// handledMessages tracks the messages passed to the app controller until its middlewares have returned, whether or
+// not they passed the message on to the handler and whatever they did with its acknowledgement.
+type handledMessages struct {
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]chan struct{}
+}
+
+// add registers a message and returns its ID and a channel closed once it has been handled. The channel is nil
+// when h is, i.e. the controller was not created by NewController and has no middleware to close it.
+func (h *handledMessages) add() (uint64, chan struct{}) {
+	if h == nil {
+		return 0, nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.next++
+	done := make(chan struct{})
+	h.pending[h.next] = done
+
+	return h.next, done
+}
+
+// done closes the channel of the message with id, if it is pending.
+func (h *handledMessages) done(id uint64) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if done, ok := h.pending[id]; ok {
+		delete(h.pending, id)
+		close(done)
+	}
+}
+
+// track is the outermost middleware. It marks the message as handled once the other middlewares have returned.
+func (h *handledMessages) track(
+	ctx context.Context, msg *extensions.BrokerMessage, next extensions.NextMiddleware,
+) error {
+	header, ok := msg.Headers[handledHeader]
+	if !ok {
+		return next(ctx)
+	}
+	delete(msg.Headers, handledHeader)
+
+	id, err := strconv.ParseUint(string(header), 10, 64)
+	if err == nil {
+		defer h.done(id)
+	}
 
-	return sub, nil
+	return next(ctx)
 }
 
 type NoopAcknowledgementHandler struct {
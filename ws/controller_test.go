@@ -0,0 +1,234 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lerenn/asyncapi-codegen/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	disconnected := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		defer close(disconnected)
+
+		frame := []byte(`{"type":"dialog_closed","meta":{"timestamp":1700000000},"data":{"dialog":{"id":1}}}`)
+		for {
+			if err = conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	controller, err := NewController("ws"+strings.TrimPrefix(srv.URL, "http"), "token")
+	require.NoError(t, err)
+
+	params := EventsChannelParameters{Events: string(EventTypeDialogClosed)}
+	received := make(chan EventTypeSchema, 1)
+	err = controller.SubscribeToReceiveEventsOperation(context.Background(), params,
+		func(_ context.Context, msg EventMessageFromEventsChannel) error {
+			select {
+			case received <- msg.Payload.Type:
+			default:
+			}
+
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, EventTypeDialogClosed, <-received)
+
+	// Events keep arriving while the subscription is cancelled.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	controller.UnsubscribeFromReceiveEventsOperation(ctx, params)
+	require.NoError(t, ctx.Err())
+
+	select {
+	case <-disconnected:
+	case <-ctx.Done():
+		t.Fatal("connection not closed")
+	}
+}
+
+func TestControllerDisconnect(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		frame := []byte(`{"type":"dialog_closed","meta":{"timestamp":1700000000},"data":{"dialog":{"id":1}}}`)
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+		assert.NoError(t, conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "restart")))
+		_ = conn.Close()
+	}))
+	defer srv.Close()
+
+	type disconnect struct {
+		channel string
+		err     error
+	}
+	disconnected := make(chan disconnect, 1)
+	controller, err := NewController("ws"+strings.TrimPrefix(srv.URL, "http"), "token",
+		WithDisconnectHandler(func(channel string, err error) {
+			disconnected <- disconnect{channel, err}
+		}))
+	require.NoError(t, err)
+
+	params := EventsChannelParameters{Events: string(EventTypeDialogClosed)}
+	received := make(chan EventTypeSchema, 2)
+	err = controller.SubscribeToReceiveEventsOperation(context.Background(), params,
+		func(_ context.Context, msg EventMessageFromEventsChannel) error {
+			received <- msg.Payload.Type
+			return nil
+		})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	select {
+	case d := <-disconnected:
+		assert.Equal(t, "events=dialog_closed&options=", d.channel)
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, d.err, &closeErr)
+		assert.Equal(t, websocket.CloseGoingAway, closeErr.Code)
+	case <-ctx.Done():
+		t.Fatal("disconnect not reported")
+	}
+	assert.Equal(t, EventTypeDialogClosed, <-received)
+
+	// The subscription has ended, so it can be cancelled and made again.
+	controller.UnsubscribeFromReceiveEventsOperation(ctx, params)
+	require.NoError(t, ctx.Err())
+	assert.Empty(t, received)
+
+	t.Run("handler loop exits", func(t *testing.T) {
+		sub, err := Controller{url: "ws" + strings.TrimPrefix(srv.URL, "http")}.Subscribe(ctx, "events=dialog_closed")
+		require.NoError(t, err)
+
+		// The handler loop stops when the messages channel is closed.
+		var messages int
+		for {
+			select {
+			case msg, open := <-sub.MessagesChannel():
+				if open {
+					messages++
+					msg.Ack()
+
+					continue
+				}
+			case <-ctx.Done():
+				t.Fatal("messages channel not closed")
+			}
+
+			break
+		}
+		assert.Equal(t, 1, messages)
+
+		sub.Cancel(ctx)
+		require.NoError(t, ctx.Err())
+	})
+}
+
+func TestControllerFilteringMiddleware(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for id := 1; id <= 4; id++ {
+			frame := `{"type":"dialog_closed","meta":{"timestamp":1700000000},"data":{"dialog":{"id":` +
+				strconv.Itoa(id) + `}}}`
+			assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(frame)))
+		}
+		assert.NoError(t, conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "restart")))
+		_ = conn.Close()
+	}))
+	defer srv.Close()
+
+	// The middleware drops the events of even dialogs. Returning nil would still pass them on, so it returns an error,
+	// which the app controller passes to the error handler instead of the handler.
+	errDropped := errors.New("dropped")
+	filter := func(ctx context.Context, msg *extensions.BrokerMessage, next extensions.NextMiddleware) error {
+		assert.NotContains(t, msg.Headers, handledHeader)
+		var event EventSchema
+		if !assert.NoError(t, json.Unmarshal(msg.Payload, &event)) {
+			return nil
+		}
+		if event.Data.(DialogDataSchema).Dialog.Id%2 == 0 {
+			return errDropped
+		}
+
+		return next(ctx)
+	}
+
+	var (
+		mu       sync.Mutex
+		received []int64
+	)
+	disconnected := make(chan []int64, 1)
+	controller, err := NewController("ws"+strings.TrimPrefix(srv.URL, "http"), "token",
+		func(controller *Controller) error {
+			controller.middlewares = append(controller.middlewares, filter)
+
+			return nil
+		},
+		WithDisconnectHandler(func(string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			disconnected <- received
+		}))
+	require.NoError(t, err)
+
+	params := EventsChannelParameters{Events: string(EventTypeDialogClosed)}
+	err = controller.SubscribeToReceiveEventsOperation(context.Background(), params,
+		func(_ context.Context, msg EventMessageFromEventsChannel) error {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, msg.Payload.Data.(DialogDataSchema).Dialog.Id)
+
+			return nil
+		})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Dropped events do not stop the subscription, and the disconnect is reported once all events are handled.
+	select {
+	case ids := <-disconnected:
+		assert.Equal(t, []int64{1, 3}, ids)
+	case <-ctx.Done():
+		t.Fatal("disconnect not reported")
+	}
+
+	controller.UnsubscribeFromReceiveEventsOperation(ctx, params)
+	require.NoError(t, ctx.Err())
+}
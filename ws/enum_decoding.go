@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lerenn/asyncapi-codegen/pkg/extensions"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// EnumDecodingMode controls how events carrying enum values unknown to the client are handled.
+type EnumDecodingMode int
+
+const (
+	// EnumDecodingLenient accepts unknown enum values and reports them to the hook.
+	EnumDecodingLenient EnumDecodingMode = iota
+	// EnumDecodingStrict rejects the event with UnknownEnumError, which is passed to the error handler.
+	EnumDecodingStrict
+)
+
+// UnknownEnumValue is a value not listed in the oneof constraint of its field.
+type UnknownEnumValue struct {
+	// Type is the name of the field type, e.g. ChannelTypeSchema.
+	Type string
+	// Value is the value received from the server.
+	Value string
+	// Path is the JSON path of the value within the event, e.g. data.message.type.
+	Path string
+}
+
+// UnknownEnumHook receives unknown enum values found in events.
+type UnknownEnumHook func(ctx context.Context, value UnknownEnumValue)
+
+// UnknownEnumError is returned in strict mode when an event contains unknown enum values.
+type UnknownEnumError struct {
+	Values []UnknownEnumValue
+}
+
+func (e *UnknownEnumError) Error() string {
+	parts := make([]string, 0, len(e.Values))
+	for _, v := range e.Values {
+		parts = append(parts, fmt.Sprintf("%s: unknown %s value %q", v.Path, v.Type, v.Value))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// WithEnumDecoding checks enum values of received events against the oneof constraints of the schema.
+// In lenient mode unknown values are passed to the hook and the event is handled as usual.
+// In strict mode they are passed to the hook too, and the event is rejected. The hook may be nil.
+// Events of unknown types are not checked, see UnknownEventHandler.
+func WithEnumDecoding(mode EnumDecodingMode, hook UnknownEnumHook) Option {
+	return func(controller *Controller) error {
+		controller.middlewares = append(controller.middlewares, EnumDecoding(mode, hook))
+
+		return nil
+	}
+}
+
+// EnumDecoding is a middleware checking enum values of received events, see WithEnumDecoding.
+func EnumDecoding(mode EnumDecodingMode, hook UnknownEnumHook) extensions.Middleware {
+	return func(ctx context.Context, msg *extensions.BrokerMessage, next extensions.NextMiddleware) error {
+		var event EventSchema
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			// Malformed events are reported when the payload is decoded for the handler.
+			return next(ctx)
+		}
+		if _, ok := event.Data.(UnknownEventData); ok {
+			// Events of unknown types are passed on as UnknownEventData.
+			return next(ctx)
+		}
+
+		unknown := UnknownEnums(event)
+		if hook != nil {
+			for _, v := range unknown {
+				hook(ctx, v)
+			}
+		}
+
+		if mode == EnumDecodingStrict && len(unknown) > 0 {
+			return &UnknownEnumError{Values: unknown}
+		}
+
+		return next(ctx)
+	}
+}
+
+// UnknownEnums walks a decoded event or schema and returns the values failing oneof constraints.
+// Empty values are treated as missing and not reported.
+func UnknownEnums(v interface{}) []UnknownEnumValue {
+	var unknown []UnknownEnumValue
+	findUnknownEnums(&unknown, "", reflect.ValueOf(v))
+
+	return unknown
+}
+
+func findUnknownEnums(unknown *[]UnknownEnumValue, path string, v reflect.Value) {
+	v = indirect(v)
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			switch {
+			case name == "-":
+				continue
+			case f.Anonymous && name == "":
+				findUnknownEnums(unknown, path, v.Field(i))
+
+				continue
+			case name == "":
+				name = f.Name
+			}
+
+			if path != "" {
+				name = path + "." + name
+			}
+
+			field := indirect(v.Field(i))
+			if allowed := oneOf(f.Tag.Get("validate")); allowed != nil && field.Kind() == reflect.String {
+				if value := field.String(); value != "" && !slices.Contains(allowed, value) {
+					*unknown = append(*unknown, UnknownEnumValue{Type: field.Type().Name(), Value: value, Path: name})
+				}
+
+				continue
+			}
+
+			findUnknownEnums(unknown, name, field)
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			findUnknownEnums(unknown, fmt.Sprintf("%s[%d]", path, i), v.Index(i))
+		}
+	}
+}
+
+var oneOfValue = regexp.MustCompile(`'([^']*)'|(\S+)`)
+
+// oneOf returns the values of the oneof constraint in a validate tag, or nil if there is none.
+func oneOf(tag string) []string {
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name != "oneof" {
+			continue
+		}
+
+		values := make([]string, 0)
+		for _, m := range oneOfValue.FindAllStringSubmatch(param, -1) {
+			values = append(values, m[1]+m[2])
+		}
+
+		return values
+	}
+
+	return nil
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lerenn/asyncapi-codegen/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnumDecoding(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{
+		"type": "message_new",
+		"meta": {"timestamp": 1},
+		"data": {"message": {"id": 1, "chat_id": 2, "type": "sticker", "scope": "public", "from": {"id": 3, "type": "customer"}}}
+	}`)
+
+	expected := []UnknownEnumValue{{Type: "MessageTypeSchema", Value: "sticker", Path: "data.message.type"}}
+
+	testCases := []struct {
+		name    string
+		mode    EnumDecodingMode
+		handled bool
+	}{
+		{name: "lenient", mode: EnumDecodingLenient, handled: true},
+		{name: "strict", mode: EnumDecodingStrict, handled: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var reported []UnknownEnumValue
+			mw := EnumDecoding(tc.mode, func(_ context.Context, v UnknownEnumValue) {
+				reported = append(reported, v)
+			})
+
+			handled := false
+			err := mw(context.Background(), &extensions.BrokerMessage{Payload: payload}, func(context.Context) error {
+				handled = true
+				return nil
+			})
+
+			assert.Equal(t, tc.handled, handled)
+			assert.Equal(t, expected, reported)
+			if tc.mode == EnumDecodingStrict {
+				var enumErr *UnknownEnumError
+				require.ErrorAs(t, err, &enumErr)
+				assert.Equal(t, expected, enumErr.Values)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("controller option", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := NewController("wss://example.com/ws", "token", WithEnumDecoding(EnumDecodingStrict, nil))
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lerenn/asyncapi-codegen/pkg/extensions"
+)
+
+// UnknownEventData is the data of an event type the client does not know about.
+// The data is kept undecoded so that handlers can process events added to the API later.
+type UnknownEventData struct {
+	// Type is the event type as received.
+	Type string
+	// Data is the raw JSON of the event data.
+	Data json.RawMessage
+}
+
+// MarshalJSON encodes the data as received, so that an event with unknown data can be re-encoded unchanged.
+func (d UnknownEventData) MarshalJSON() ([]byte, error) {
+	if len(d.Data) == 0 {
+		return []byte("null"), nil
+	}
+
+	return d.Data, nil
+}
+
+// RawEvent is an event with undecoded data.
+type RawEvent struct {
+	Type EventTypeSchema
+	Meta MetaSchema
+	// Data is the raw JSON of the event data.
+	Data json.RawMessage
+	// Raw is the full JSON of the event as received, if available.
+	Raw json.RawMessage
+}
+
+// ParseRawEvent decodes the type and metadata of an event and keeps its data undecoded.
+func ParseRawEvent(payload []byte) (RawEvent, error) {
+	var event struct {
+		Data json.RawMessage `json:"data"`
+		Meta MetaSchema      `json:"meta"`
+		Type EventTypeSchema `json:"type"`
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return RawEvent{}, err
+	}
+
+	return RawEvent{
+		Type: event.Type,
+		Meta: event.Meta,
+		Data: event.Data,
+		Raw:  append(json.RawMessage(nil), payload...),
+	}, nil
+}
+
+type rawEventKey struct{}
+
+// rawEvents is a middleware keeping the received payload in the handler context.
+func rawEvents(ctx context.Context, msg *extensions.BrokerMessage, next extensions.NextMiddleware) error {
+	return next(ContextWithRawEvent(ctx, msg.Payload))
+}
+
+// ContextWithRawEvent returns a copy of ctx carrying payload as the event being handled, as handlers of
+// a controller created by NewController receive it. It lets recorded events be replayed to the same handlers.
+func ContextWithRawEvent(ctx context.Context, payload []byte) context.Context {
+	return context.WithValue(ctx, rawEventKey{}, payload)
+}
+
+// RawEventFromContext returns the event being handled as received from the server,
+// e.g. to store it for auditing. It is available in handlers of subscriptions
+// made with a controller created by NewController.
+func RawEventFromContext(ctx context.Context) (RawEvent, bool) {
+	payload, ok := ctx.Value(rawEventKey{}).([]byte)
+	if !ok {
+		return RawEvent{}, false
+	}
+
+	event, err := ParseRawEvent(payload)
+
+	return event, err == nil
+}
+
+// UnknownEventHandler returns an event handler passing events of unknown types to onUnknown
+// and all other events to next, which may be nil.
+func UnknownEventHandler(
+	onUnknown func(ctx context.Context, event RawEvent) error,
+	next func(ctx context.Context, msg EventMessageFromEventsChannel) error,
+) func(ctx context.Context, msg EventMessageFromEventsChannel) error {
+	return func(ctx context.Context, msg EventMessageFromEventsChannel) error {
+		data, ok := msg.Payload.Data.(UnknownEventData)
+		if !ok {
+			if next == nil {
+				return nil
+			}
+
+			return next(ctx, msg)
+		}
+
+		event, ok := RawEventFromContext(ctx)
+		if !ok {
+			event = RawEvent{Type: msg.Payload.Type, Meta: msg.Payload.Meta, Data: data.Data}
+		}
+
+		return onUnknown(ctx, event)
+	}
+}
@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/lerenn/asyncapi-codegen/pkg/extensions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownEvents(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"type":"reaction_added","meta":{"timestamp":1700000000},"data":{"message_id":1,"emoji":"+1"}}`)
+
+	t.Run("decoded as unknown event data", func(t *testing.T) {
+		t.Parallel()
+
+		var event EventSchema
+		require.NoError(t, json.Unmarshal(payload, &event))
+		assert.Equal(t, EventTypeSchema("reaction_added"), event.Type)
+		assert.Equal(t, UnknownEventData{
+			Type: "reaction_added",
+			Data: json.RawMessage(`{"message_id":1,"emoji":"+1"}`),
+		}, event.Data)
+
+		encoded, err := json.Marshal(event)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(payload), string(encoded))
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			unknown []RawEvent
+			known   []EventTypeSchema
+		)
+		handler := UnknownEventHandler(
+			func(_ context.Context, event RawEvent) error {
+				unknown = append(unknown, event)
+				return nil
+			},
+			func(_ context.Context, msg EventMessageFromEventsChannel) error {
+				known = append(known, msg.Payload.Type)
+				return nil
+			},
+		)
+
+		handle := func(payload []byte) {
+			err := rawEvents(context.Background(), &extensions.BrokerMessage{Payload: payload}, func(ctx context.Context) error {
+				msg, err := brokerMessageToEventMessageFromEventsChannel(extensions.BrokerMessage{Payload: payload})
+				require.NoError(t, err)
+
+				return handler(ctx, msg)
+			})
+			require.NoError(t, err)
+		}
+
+		handle(payload)
+		handle([]byte(`{"type":"dialog_closed","meta":{"timestamp":1700000000},"data":{"dialog":{"id":1}}}`))
+
+		require.Len(t, unknown, 1)
+		assert.Equal(t, EventTypeSchema("reaction_added"), unknown[0].Type)
+		assert.Equal(t, int64(1700000000), unknown[0].Meta.Timestamp)
+		assert.JSONEq(t, `{"message_id":1,"emoji":"+1"}`, string(unknown[0].Data))
+		assert.JSONEq(t, string(payload), string(unknown[0].Raw))
+		assert.Equal(t, []EventTypeSchema{EventTypeDialogClosed}, known)
+	})
+
+	t.Run("handler without raw payload in context", func(t *testing.T) {
+		t.Parallel()
+
+		var event RawEvent
+		handler := UnknownEventHandler(func(_ context.Context, e RawEvent) error {
+			event = e
+			return nil
+		}, nil)
+
+		msg, err := brokerMessageToEventMessageFromEventsChannel(extensions.BrokerMessage{Payload: payload})
+		require.NoError(t, err)
+		require.NoError(t, handler(context.Background(), msg))
+		assert.Equal(t, EventTypeSchema("reaction_added"), event.Type)
+		assert.Nil(t, event.Raw)
+	})
+}
@@ -297,7 +297,7 @@ func (e *EventSchema) UnmarshalJSON(data []byte) error {
 		e.Data = body
 
 	default:
-		return fmt.Errorf("unknown event type: %s", e.Type)
+		e.Data = UnknownEventData{Type: raw.Type, Data: raw.Data}
 	}
 
 	return nil
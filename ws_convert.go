@@ -0,0 +1,902 @@
+package bot_api_client
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+// The functions below convert WebSocket event schemas to REST models and back.
+// Optional event fields that are not set convert to zero values of the REST fields and zero values
+// convert back to unset fields, matching how both are encoded to JSON. Converting a REST model to
+// an event schema and back therefore yields the same value.
+// Fields existing on one side only are listed in the doc comments and are left empty.
+
+// MessageFromWS converts a message of a message event to the REST model.
+// The event fields Chat, CreatedAt, EditedAt and Reactions have no REST counterpart.
+func MessageFromWS(m ws.MessagePropertyFromMessageDataSchema) Message {
+	var actions []MessageAction
+	if m.Actions != nil {
+		actions = make([]MessageAction, 0, len(m.Actions))
+		for _, a := range m.Actions {
+			actions = append(actions, MessageAction(a))
+		}
+	}
+
+	msg := Message{
+		Action:               convertEnum[SystemAction](m.Action),
+		Actions:              actions,
+		ChatID:               m.ChatId,
+		Content:              m.Content,
+		Error:                messageErrorFromWS(m.Error),
+		From:                 actorFromWS(m.From),
+		ID:                   m.Id,
+		IsEdit:               m.IsEdit,
+		IsRead:               m.IsRead,
+		Items:                messageFilesFromWS(m.Items),
+		Note:                 deref(m.Note),
+		Order:                orderFromWS(m.Order),
+		Product:              productFromWS(m.Product),
+		Quote:                quoteFromWS(m.Quote),
+		Responsible:          actorFromWS(m.Responsible),
+		Scope:                MessageScope(m.Scope),
+		Status:               MessageStatus(m.Status),
+		TemplateCode:         m.TemplateCode,
+		Time:                 DateTimeRFC3339{Time: m.Time},
+		TransportAttachments: transportAttachmentsFromWS(m.TransportAttachments),
+		Type:                 MessageType(m.Type),
+	}
+	if m.Dialog != nil {
+		msg.Dialog = &MessageDialog{ID: deref(m.Dialog.Id)}
+	}
+
+	return msg
+}
+
+// MessageToWS converts a REST message to the message of a message event.
+// The REST field User has no event counterpart.
+func MessageToWS(m Message) ws.MessagePropertyFromMessageDataSchema {
+	var actions []string
+	if m.Actions != nil {
+		actions = make([]string, 0, len(m.Actions))
+		for _, a := range m.Actions {
+			actions = append(actions, string(a))
+		}
+	}
+
+	msg := ws.MessagePropertyFromMessageDataSchema{
+		Action:               convertEnum[ws.MessageActionSchema](m.Action),
+		Actions:              actions,
+		ChatId:               m.ChatID,
+		Content:              m.Content,
+		Error:                messageErrorToWS(m.Error),
+		From:                 actorToWS(m.From),
+		Id:                   m.ID,
+		IsEdit:               m.IsEdit,
+		IsRead:               m.IsRead,
+		Items:                messageFilesToWS(m.Items),
+		Note:                 optional(m.Note),
+		Order:                orderToWS(m.Order),
+		Product:              productToWS(m.Product),
+		Quote:                quoteToWS(m.Quote),
+		Responsible:          actorToWS(m.Responsible),
+		Scope:                ws.MessageScopeSchema(m.Scope),
+		Status:               ws.MessageStatusSchema(m.Status),
+		TemplateCode:         m.TemplateCode,
+		Time:                 m.Time.Time,
+		TransportAttachments: transportAttachmentsToWS(m.TransportAttachments),
+		Type:                 ws.MessageTypeSchema(m.Type),
+	}
+	if m.Dialog != nil {
+		msg.Dialog = &ws.MessageDialogSchema{Id: optional(m.Dialog.ID)}
+	}
+
+	return msg
+}
+
+// ChatFromWS converts a chat of a chat event to the REST model.
+// The event fields CreatedAt and Members have no REST counterpart,
+// nor has TemplateCode of the last message.
+func ChatFromWS(c ws.ChatSchema) Chat {
+	chat := Chat{
+		AuthorID:                   c.AuthorId,
+		Avatar:                     c.Avatar,
+		Customer:                   actorFromWS(c.Customer),
+		ID:                         c.Id,
+		LastActivity:               timeFromWS(optional(c.LastActivity)),
+		Name:                       c.Name,
+		NotReadMessagesCount:       deref(c.NotReadMessagesCount),
+		ReplyDeadline:              timeFromWS(c.ReplyDeadline),
+		Unread:                     deref(c.Unread),
+		WaitingLevel:               convertEnum[ChatWaitingLevel](c.WaitingLevel),
+		WaitingLevelTransitionTime: timeFromWS(c.WaitingLevelTransitionTime),
+	}
+	if c.Channel != nil {
+		channel := ChannelFromWS(*c.Channel)
+		chat.Channel = &channel
+	}
+	if c.LastDialog != nil {
+		dialog := DialogFromWS(*c.LastDialog)
+		chat.LastDialog = &dialog
+	}
+	if c.LastMessage != nil {
+		message := lastMessageFromWS(*c.LastMessage)
+		chat.LastMessage = &message
+	}
+	if c.LastUserMessage != nil {
+		chat.LastUserMessage = &LastUserMessage{ID: c.LastUserMessage.Id}
+	}
+
+	return chat
+}
+
+// ChatToWS converts a REST chat to the chat of a chat event.
+// The TemplateCode of the last message has no event counterpart.
+func ChatToWS(c Chat) ws.ChatSchema {
+	chat := ws.ChatSchema{
+		AuthorId:                   c.AuthorID,
+		Avatar:                     c.Avatar,
+		Customer:                   actorToWS(c.Customer),
+		Id:                         c.ID,
+		LastActivity:               deref(timeToWS(c.LastActivity)),
+		Name:                       c.Name,
+		NotReadMessagesCount:       optional(c.NotReadMessagesCount),
+		ReplyDeadline:              timeToWS(c.ReplyDeadline),
+		Unread:                     optional(c.Unread),
+		WaitingLevel:               convertEnum[ws.WaitingLevelSchema](c.WaitingLevel),
+		WaitingLevelTransitionTime: timeToWS(c.WaitingLevelTransitionTime),
+	}
+	if c.Channel != nil {
+		channel := ChannelToWS(*c.Channel)
+		chat.Channel = &channel
+	}
+	if c.LastDialog != nil {
+		dialog := DialogToWS(*c.LastDialog)
+		chat.LastDialog = &dialog
+	}
+	if c.LastMessage != nil {
+		message := lastMessageToWS(*c.LastMessage)
+		chat.LastMessage = &message
+	}
+	if c.LastUserMessage != nil {
+		chat.LastUserMessage = &ws.MessageIDSchema{Id: c.LastUserMessage.ID}
+	}
+
+	return chat
+}
+
+// ChannelFromWS converts a channel of an event to the REST model.
+func ChannelFromWS(c ws.ChannelSchema) Channel {
+	channel := Channel{
+		Avatar:      deref(c.Avatar),
+		ID:          c.Id,
+		IsActive:    c.IsActive,
+		Name:        c.Name,
+		TransportID: c.TransportId,
+		Type:        ChannelType(c.Type),
+	}
+	if c.Settings != nil {
+		channel.Settings = ChannelSettingsFromWS(*c.Settings)
+	}
+
+	return channel
+}
+
+// ChannelToWS converts a REST channel to the channel of an event.
+func ChannelToWS(c Channel) ws.ChannelSchema {
+	return ws.ChannelSchema{
+		Avatar:      optional(c.Avatar),
+		Id:          c.ID,
+		IsActive:    c.IsActive,
+		Name:        c.Name,
+		Settings:    optional(ChannelSettingsToWS(c.Settings)),
+		TransportId: c.TransportID,
+		Type:        ws.ChannelTypeSchema(c.Type),
+	}
+}
+
+// ChannelSettingsFromWS converts channel settings of an event to the REST model.
+func ChannelSettingsFromWS(s ws.ChannelSettingsSchema) ChannelSettings {
+	var settings ChannelSettings
+
+	if a := s.Audio; a != nil {
+		settings.Audio = AudioMessageSetting{
+			Creating:      convertEnumValue[ChannelFeature](a.Creating),
+			Deleting:      convertEnumValue[ChannelFeature](a.Deleting),
+			MaxItemSize:   a.MaxItemSize,
+			MaxItemsCount: a.MaxItemsCount,
+			Quoting:       convertEnumValue[ChannelFeature](a.Quoting),
+			Reaction:      convertEnumValue[ChannelFeature](a.Reaction),
+		}
+	}
+	settings.CustomerExternalID = convertEnumValue[CustomerExternalID](s.CustomerExternalId)
+	if f := s.File; f != nil {
+		settings.File = FileMessageSetting{
+			Creating:          convertEnumValue[ChannelFeature](f.Creating),
+			Deleting:          convertEnumValue[ChannelFeature](f.Deleting),
+			Editing:           convertEnumValue[ChannelFeature](f.Editing),
+			MaxItemSize:       f.MaxItemSize,
+			MaxItemsCount:     f.MaxItemsCount,
+			NoteMaxCharsCount: f.NoteMaxCharsCount,
+			Quoting:           convertEnumValue[ChannelFeature](f.Quoting),
+			Reaction:          convertEnumValue[ChannelFeature](f.Reaction),
+		}
+	}
+	if i := s.Image; i != nil {
+		settings.Image = ImageMessageSetting{
+			Creating:          convertEnumValue[ChannelFeature](i.Creating),
+			Deleting:          convertEnumValue[ChannelFeature](i.Deleting),
+			Editing:           convertEnumValue[ChannelFeature](i.Editing),
+			MaxItemSize:       i.MaxItemSize,
+			MaxItemsCount:     i.MaxItemsCount,
+			NoteMaxCharsCount: i.NoteMaxCharsCount,
+			Quoting:           convertEnumValue[ChannelFeature](i.Quoting),
+			Reaction:          convertEnumValue[ChannelFeature](i.Reaction),
+		}
+	}
+	if o := s.Order; o != nil {
+		settings.Order = OrderMessageSetting{
+			Creating: convertEnumValue[ChannelFeature](o.Creating),
+			Deleting: convertEnumValue[ChannelFeature](o.Deleting),
+			Editing:  convertEnumValue[ChannelFeature](o.Editing),
+			Quoting:  convertEnumValue[ChannelFeature](o.Quoting),
+			Reaction: convertEnumValue[ChannelFeature](o.Reaction),
+		}
+	}
+	if p := s.Product; p != nil {
+		settings.Product = ProductMessageSetting{
+			Creating: convertEnumValue[ChannelFeature](p.Creating),
+			Deleting: convertEnumValue[ChannelFeature](p.Deleting),
+			Editing:  convertEnumValue[ChannelFeature](p.Editing),
+			Quoting:  convertEnumValue[ChannelFeature](p.Quoting),
+			Reaction: convertEnumValue[ChannelFeature](p.Reaction),
+		}
+	}
+	if r := s.Reactions; r != nil {
+		settings.Reactions = Reactions{Dictionary: r.Dictionary, MaxCount: r.MaxCount}
+	}
+	if p := s.SendingPolicy; p != nil {
+		settings.SendingPolicy = SendingPolicy{
+			AfterReplyTimeout: convertEnumValue[SendingPolicyAfterReplyTimeout](p.AfterReplyTimeout),
+			NewCustomer:       convertEnumValue[SendingPolicyNewCustomer](p.NewCustomer),
+			Outgoing:          convertEnumValue[SendingPolicyOutgoing](p.Outgoing),
+		}
+	}
+	if st := s.Status; st != nil {
+		settings.Status = StatusSetting{
+			Delivered: convertEnumValue[ChannelFeature](st.Delivered),
+			Read:      convertEnumValue[ChannelFeature](st.Read),
+		}
+	}
+	if sg := s.Suggestions; sg != nil {
+		settings.Suggestions = Suggestions{
+			Email: convertEnumValue[ChannelFeature](sg.Email),
+			Phone: convertEnumValue[ChannelFeature](sg.Phone),
+			Text:  convertEnumValue[ChannelFeature](sg.Text),
+			Url:   convertEnumValue[ChannelFeature](sg.Url),
+		}
+	}
+	if t := s.Template; t != nil {
+		settings.Template = TemplateSetting{Creation: deref(t.Creation)}
+	}
+	if t := s.Text; t != nil {
+		settings.Text = TextMessageSetting{
+			Creating:      convertEnumValue[ChannelFeature](t.Creating),
+			Deleting:      convertEnumValue[ChannelFeature](t.Deleting),
+			Editing:       convertEnumValue[ChannelFeature](t.Editing),
+			MarkupFormats: convertEnums[MarkupFormat](t.MarkupFormats),
+			MaxCharsCount: t.MaxCharsCount,
+			Quoting:       convertEnumValue[ChannelFeature](t.Quoting),
+			Reaction:      convertEnumValue[ChannelFeature](t.Reaction),
+		}
+	}
+	if w := s.Whatsapp; w != nil {
+		settings.Whatsapp = &WAChannelProperties{
+			ChannelQuality: convertEnum[WAChannelQuality](w.ChannelQuality),
+			ChannelStatus:  convertEnum[WAChannelStatus](w.ChannelStatus),
+			Tier:           w.Tier,
+		}
+	}
+
+	return settings
+}
+
+// ChannelSettingsToWS converts REST channel settings to channel settings of an event.
+func ChannelSettingsToWS(s ChannelSettings) ws.ChannelSettingsSchema {
+	settings := ws.ChannelSettingsSchema{
+		Audio: optional(ws.AudioMessageSettingSchema{
+			Creating:      enumPtr[ws.ChannelFeatureSchema](s.Audio.Creating),
+			Deleting:      enumPtr[ws.ChannelFeatureSchema](s.Audio.Deleting),
+			MaxItemSize:   s.Audio.MaxItemSize,
+			MaxItemsCount: s.Audio.MaxItemsCount,
+			Quoting:       enumPtr[ws.ChannelFeatureSchema](s.Audio.Quoting),
+			Reaction:      enumPtr[ws.ChannelFeatureSchema](s.Audio.Reaction),
+		}),
+		CustomerExternalId: enumPtr[ws.CustomerExternalIdSchema](s.CustomerExternalID),
+		File: optional(ws.FileMessageSettingSchema{
+			Creating:          enumPtr[ws.ChannelFeatureSchema](s.File.Creating),
+			Deleting:          enumPtr[ws.ChannelFeatureSchema](s.File.Deleting),
+			Editing:           enumPtr[ws.ChannelFeatureSchema](s.File.Editing),
+			MaxItemSize:       s.File.MaxItemSize,
+			MaxItemsCount:     s.File.MaxItemsCount,
+			NoteMaxCharsCount: s.File.NoteMaxCharsCount,
+			Quoting:           enumPtr[ws.ChannelFeatureSchema](s.File.Quoting),
+			Reaction:          enumPtr[ws.ChannelFeatureSchema](s.File.Reaction),
+		}),
+		Image: optional(ws.ImageMessageSettingSchema{
+			Creating:          enumPtr[ws.ChannelFeatureSchema](s.Image.Creating),
+			Deleting:          enumPtr[ws.ChannelFeatureSchema](s.Image.Deleting),
+			Editing:           enumPtr[ws.ChannelFeatureSchema](s.Image.Editing),
+			MaxItemSize:       s.Image.MaxItemSize,
+			MaxItemsCount:     s.Image.MaxItemsCount,
+			NoteMaxCharsCount: s.Image.NoteMaxCharsCount,
+			Quoting:           enumPtr[ws.ChannelFeatureSchema](s.Image.Quoting),
+			Reaction:          enumPtr[ws.ChannelFeatureSchema](s.Image.Reaction),
+		}),
+		Order: optional(ws.OrderMessageSettingSchema{
+			Creating: enumPtr[ws.ChannelFeatureSchema](s.Order.Creating),
+			Deleting: enumPtr[ws.ChannelFeatureSchema](s.Order.Deleting),
+			Editing:  enumPtr[ws.ChannelFeatureSchema](s.Order.Editing),
+			Quoting:  enumPtr[ws.ChannelFeatureSchema](s.Order.Quoting),
+			Reaction: enumPtr[ws.ChannelFeatureSchema](s.Order.Reaction),
+		}),
+		Product: optional(ws.ProductMessageSettingSchema{
+			Creating: enumPtr[ws.ChannelFeatureSchema](s.Product.Creating),
+			Deleting: enumPtr[ws.ChannelFeatureSchema](s.Product.Deleting),
+			Editing:  enumPtr[ws.ChannelFeatureSchema](s.Product.Editing),
+			Quoting:  enumPtr[ws.ChannelFeatureSchema](s.Product.Quoting),
+			Reaction: enumPtr[ws.ChannelFeatureSchema](s.Product.Reaction),
+		}),
+		Reactions: optional(ws.ReactionsSchema{Dictionary: s.Reactions.Dictionary, MaxCount: s.Reactions.MaxCount}),
+		SendingPolicy: optional(ws.SendingPolicySchema{
+			AfterReplyTimeout: enumPtr[ws.SendingPolicyAfterReplyTimeoutSchema](s.SendingPolicy.AfterReplyTimeout),
+			NewCustomer:       enumPtr[ws.SendingPolicyNewCustomerSchema](s.SendingPolicy.NewCustomer),
+			Outgoing:          enumPtr[ws.SendingPolicyOutgoingSchema](s.SendingPolicy.Outgoing),
+		}),
+		Status: optional(ws.StatusSettingSchema{
+			Delivered: enumPtr[ws.ChannelFeatureSchema](s.Status.Delivered),
+			Read:      enumPtr[ws.ChannelFeatureSchema](s.Status.Read),
+		}),
+		Suggestions: optional(ws.SuggestionsSchema{
+			Email: enumPtr[ws.ChannelFeatureSchema](s.Suggestions.Email),
+			Phone: enumPtr[ws.ChannelFeatureSchema](s.Suggestions.Phone),
+			Text:  enumPtr[ws.ChannelFeatureSchema](s.Suggestions.Text),
+			Url:   enumPtr[ws.ChannelFeatureSchema](s.Suggestions.Url),
+		}),
+		Template: optional(ws.TemplateSettingSchema{Creation: optional(s.Template.Creation)}),
+		Text: optional(ws.TextMessageSettingSchema{
+			Creating:      enumPtr[ws.ChannelFeatureSchema](s.Text.Creating),
+			Deleting:      enumPtr[ws.ChannelFeatureSchema](s.Text.Deleting),
+			Editing:       enumPtr[ws.ChannelFeatureSchema](s.Text.Editing),
+			MarkupFormats: convertEnums[ws.MarkupFormatSchema](s.Text.MarkupFormats),
+			MaxCharsCount: s.Text.MaxCharsCount,
+			Quoting:       enumPtr[ws.ChannelFeatureSchema](s.Text.Quoting),
+			Reaction:      enumPtr[ws.ChannelFeatureSchema](s.Text.Reaction),
+		}),
+	}
+	if w := s.Whatsapp; w != nil {
+		settings.Whatsapp = &ws.WAChannelPropertiesSchema{
+			ChannelQuality: convertEnum[ws.WAChannelQualitySchema](w.ChannelQuality),
+			ChannelStatus:  convertEnum[ws.WAChannelStatusSchema](w.ChannelStatus),
+			Tier:           w.Tier,
+		}
+	}
+
+	return settings
+}
+
+// ActorFromWS converts a user reference of an event to the REST model.
+func ActorFromWS(u ws.UserRefSchema) Actor {
+	return Actor{
+		Available:          deref(u.Available),
+		Avatar:             deref(u.Avatar),
+		Email:              deref(u.Email),
+		ExternalID:         u.ExternalId,
+		FirstName:          deref(u.FirstName),
+		ID:                 u.Id,
+		IsBlocked:          deref(u.IsBlocked),
+		IsSystem:           deref(u.IsSystem),
+		IsTechnicalAccount: deref(u.IsTechnicalAccount),
+		LastName:           deref(u.LastName),
+		Name:               u.Name,
+		Phone:              deref(u.Phone),
+		Type:               ActorType(u.Type),
+		Username:           deref(u.Username),
+	}
+}
+
+// ActorToWS converts a REST actor to a user reference of an event.
+func ActorToWS(a Actor) ws.UserRefSchema {
+	return ws.UserRefSchema{
+		Available:          optional(a.Available),
+		Avatar:             optional(a.Avatar),
+		Email:              optional(a.Email),
+		ExternalId:         a.ExternalID,
+		FirstName:          optional(a.FirstName),
+		Id:                 a.ID,
+		IsBlocked:          optional(a.IsBlocked),
+		IsSystem:           optional(a.IsSystem),
+		IsTechnicalAccount: optional(a.IsTechnicalAccount),
+		LastName:           optional(a.LastName),
+		Name:               a.Name,
+		Phone:              optional(a.Phone),
+		Type:               ws.UserTypeSchema(a.Type),
+		Username:           optional(a.Username),
+	}
+}
+
+// DialogFromWS converts a dialog of an event to the REST model.
+func DialogFromWS(d ws.DialogSchema) Dialog {
+	dialog := Dialog{
+		AssignedAt:  timeFromWS(d.AssignedAt),
+		ClosedAt:    timeFromWS(d.ClosedAt),
+		CreatedAt:   DateTimeRFC3339{Time: d.CreatedAt},
+		ID:          d.Id,
+		Responsible: actorFromWS(d.Responsible),
+	}
+	if u := d.Utm; u != nil {
+		dialog.Utm = &Utm{Campaign: u.Campaign, Content: u.Content, Medium: u.Medium, Source: u.Source, Term: u.Term}
+	}
+
+	return dialog
+}
+
+// DialogToWS converts a REST dialog to the dialog of an event.
+func DialogToWS(d Dialog) ws.DialogSchema {
+	dialog := ws.DialogSchema{
+		AssignedAt:  timeToWS(d.AssignedAt),
+		ClosedAt:    timeToWS(d.ClosedAt),
+		CreatedAt:   d.CreatedAt.Time,
+		Id:          d.ID,
+		Responsible: actorToWS(d.Responsible),
+	}
+	if u := d.Utm; u != nil {
+		dialog.Utm = &ws.UtmSchema{Campaign: u.Campaign, Content: u.Content, Medium: u.Medium, Source: u.Source, Term: u.Term}
+	}
+
+	return dialog
+}
+
+// lastMessageFromWS converts the last message of a chat event, which lacks a few fields of the message of a message event.
+func lastMessageFromWS(m ws.MessageSchema) Message {
+	return MessageFromWS(ws.MessagePropertyFromMessageDataSchema{
+		Action:               m.Action,
+		Actions:              m.Actions,
+		ChatId:               m.ChatId,
+		Content:              m.Content,
+		Dialog:               m.Dialog,
+		Error:                m.Error,
+		From:                 m.From,
+		Id:                   m.Id,
+		IsEdit:               m.IsEdit,
+		IsRead:               m.IsRead,
+		Items:                m.Items,
+		Note:                 m.Note,
+		Order:                m.Order,
+		Product:              m.Product,
+		Quote:                m.Quote,
+		Reactions:            m.Reactions,
+		Responsible:          m.Responsible,
+		Scope:                m.Scope,
+		Status:               m.Status,
+		Time:                 m.Time,
+		TransportAttachments: m.TransportAttachments,
+		Type:                 m.Type,
+	})
+}
+
+func lastMessageToWS(m Message) ws.MessageSchema {
+	msg := MessageToWS(m)
+
+	return ws.MessageSchema{
+		Action:               msg.Action,
+		Actions:              msg.Actions,
+		ChatId:               msg.ChatId,
+		Content:              msg.Content,
+		Dialog:               msg.Dialog,
+		Error:                msg.Error,
+		From:                 msg.From,
+		Id:                   msg.Id,
+		IsEdit:               msg.IsEdit,
+		IsRead:               msg.IsRead,
+		Items:                msg.Items,
+		Note:                 msg.Note,
+		Order:                msg.Order,
+		Product:              msg.Product,
+		Quote:                msg.Quote,
+		Reactions:            msg.Reactions,
+		Responsible:          msg.Responsible,
+		Scope:                msg.Scope,
+		Status:               msg.Status,
+		Time:                 msg.Time,
+		TransportAttachments: msg.TransportAttachments,
+		Type:                 msg.Type,
+	}
+}
+
+func actorFromWS(u *ws.UserRefSchema) *Actor {
+	if u == nil {
+		return nil
+	}
+	actor := ActorFromWS(*u)
+
+	return &actor
+}
+
+func actorToWS(a *Actor) *ws.UserRefSchema {
+	if a == nil {
+		return nil
+	}
+	user := ActorToWS(*a)
+
+	return &user
+}
+
+func messageErrorFromWS(e *ws.MessageErrorSchema) *MessageError {
+	if e == nil {
+		return nil
+	}
+
+	return &MessageError{Code: convertEnumValue[MessageErrorCode](e.Code), Message: deref(e.Message)}
+}
+
+func messageErrorToWS(e *MessageError) *ws.MessageErrorSchema {
+	if e == nil {
+		return nil
+	}
+
+	return &ws.MessageErrorSchema{Code: enumPtr[ws.MessageErrorCodeSchema](e.Code), Message: optional(e.Message)}
+}
+
+func messageFilesFromWS(items []ws.MessageFileSchema) []MessageFile {
+	if items == nil {
+		return nil
+	}
+
+	files := make([]MessageFile, 0, len(items))
+	for _, f := range items {
+		files = append(files, MessageFile{
+			Caption:       deref(f.Caption),
+			Duration:      deref(f.Duration),
+			Height:        deref(f.Height),
+			Histogram:     f.Histogram,
+			ID:            f.Id,
+			Kind:          FileType(f.Kind),
+			PreviewURL:    deref(f.PreviewUrl),
+			Size:          deref(f.Size),
+			Transcription: deref(f.Transcription),
+			Type:          deref(f.Type),
+			Width:         deref(f.Width),
+		})
+	}
+
+	return files
+}
+
+func messageFilesToWS(items []MessageFile) []ws.MessageFileSchema {
+	if items == nil {
+		return nil
+	}
+
+	files := make([]ws.MessageFileSchema, 0, len(items))
+	for _, f := range items {
+		files = append(files, ws.MessageFileSchema{
+			Caption:       optional(f.Caption),
+			Duration:      optional(f.Duration),
+			Height:        optional(f.Height),
+			Histogram:     f.Histogram,
+			Id:            f.ID,
+			Kind:          ws.MessageFileKindSchema(f.Kind),
+			PreviewUrl:    optional(f.PreviewURL),
+			Size:          optional(f.Size),
+			Transcription: optional(f.Transcription),
+			Type:          optional(f.Type),
+			Width:         optional(f.Width),
+		})
+	}
+
+	return files
+}
+
+func costFromWS(c *ws.CostSchema) *Cost {
+	if c == nil {
+		return nil
+	}
+
+	return &Cost{Currency: deref(c.Currency), Value: deref(c.Value)}
+}
+
+func costToWS(c *Cost) *ws.CostSchema {
+	if c == nil {
+		return nil
+	}
+
+	return &ws.CostSchema{Currency: optional(c.Currency), Value: optional(c.Value)}
+}
+
+func orderFromWS(o *ws.MessageOrderSchema) *MessageOrder {
+	if o == nil {
+		return nil
+	}
+
+	order := &MessageOrder{
+		Cost:       costFromWS(o.Cost),
+		Date:       o.Date,
+		Discount:   costFromWS(o.Discount),
+		ExternalID: deref(o.ExternalId),
+		Number:     deref(o.Number),
+		Url:        deref(o.Url),
+	}
+	if d := o.Delivery; d != nil {
+		order.Delivery = &MessageOrderDelivery{
+			Address: deref(d.Address),
+			Comment: deref(d.Comment),
+			Name:    deref(d.Name),
+			Price:   costFromWS(d.Price),
+		}
+	}
+	if o.Items != nil {
+		order.Items = make([]MessageOrderItem, 0, len(o.Items))
+		for _, i := range o.Items {
+			item := MessageOrderItem{
+				ExternalID: deref(i.ExternalId),
+				Img:        deref(i.Img),
+				Name:       deref(i.Name),
+				Price:      costFromWS(i.Price),
+				Url:        deref(i.Url),
+			}
+			if q := i.Quantity; q != nil {
+				item.Quantity = Quantity{Unit: deref(q.Unit), Value: deref(q.Value)}
+			}
+			order.Items = append(order.Items, item)
+		}
+	}
+	if o.Payments != nil {
+		order.Payments = make([]MessageOrderPayment, 0, len(o.Payments))
+		for _, p := range o.Payments {
+			payment := MessageOrderPayment{Amount: costFromWS(p.Amount), Name: deref(p.Name)}
+			if s := p.Status; s != nil {
+				payment.Status = &MessageOrderPaymentStatus{Name: deref(s.Name), Payed: s.Payed}
+			}
+			order.Payments = append(order.Payments, payment)
+		}
+	}
+	if s := o.Status; s != nil {
+		order.Status = &MessageOrderStatus{Code: convertEnumValue[MessageOrderStatusCode](s.Code), Name: deref(s.Name)}
+	}
+
+	return order
+}
+
+func orderToWS(o *MessageOrder) *ws.MessageOrderSchema {
+	if o == nil {
+		return nil
+	}
+
+	order := &ws.MessageOrderSchema{
+		Cost:       costToWS(o.Cost),
+		Date:       o.Date,
+		Discount:   costToWS(o.Discount),
+		ExternalId: optional(o.ExternalID),
+		Number:     optional(o.Number),
+		Url:        optional(o.Url),
+	}
+	if d := o.Delivery; d != nil {
+		order.Delivery = &ws.MessageOrderDeliverySchema{
+			Address: optional(d.Address),
+			Comment: optional(d.Comment),
+			Name:    optional(d.Name),
+			Price:   costToWS(d.Price),
+		}
+	}
+	if o.Items != nil {
+		order.Items = make([]ws.MessageOrderItemSchema, 0, len(o.Items))
+		for _, i := range o.Items {
+			order.Items = append(order.Items, ws.MessageOrderItemSchema{
+				ExternalId: optional(i.ExternalID),
+				Img:        optional(i.Img),
+				Name:       optional(i.Name),
+				Price:      costToWS(i.Price),
+				Quantity:   optional(ws.QuantitySchema{Unit: optional(i.Quantity.Unit), Value: optional(i.Quantity.Value)}),
+				Url:        optional(i.Url),
+			})
+		}
+	}
+	if o.Payments != nil {
+		order.Payments = make([]ws.MessageOrderPaymentSchema, 0, len(o.Payments))
+		for _, p := range o.Payments {
+			payment := ws.MessageOrderPaymentSchema{Amount: costToWS(p.Amount), Name: optional(p.Name)}
+			if s := p.Status; s != nil {
+				payment.Status = &ws.MessageOrderPaymentStatusSchema{Name: optional(s.Name), Payed: s.Payed}
+			}
+			order.Payments = append(order.Payments, payment)
+		}
+	}
+	if s := o.Status; s != nil {
+		order.Status = &ws.MessageOrderStatusSchema{
+			Code: enumPtr[ws.MessageOrderStatusCodeSchema](s.Code),
+			Name: optional(s.Name),
+		}
+	}
+
+	return order
+}
+
+// productFromWS converts a product of a message. RevokedAt has no REST counterpart.
+func productFromWS(p *ws.MessageProductSchema) *MessageProduct {
+	if p == nil {
+		return nil
+	}
+
+	return &MessageProduct{
+		Article: deref(p.Article),
+		Cost:    costFromWS(p.Cost),
+		ID:      p.Id,
+		Img:     deref(p.Img),
+		Name:    p.Name,
+		Unit:    deref(p.Unit),
+		Url:     deref(p.Url),
+	}
+}
+
+func productToWS(p *MessageProduct) *ws.MessageProductSchema {
+	if p == nil {
+		return nil
+	}
+
+	return &ws.MessageProductSchema{
+		Article: optional(p.Article),
+		Cost:    costToWS(p.Cost),
+		Id:      p.ID,
+		Img:     optional(p.Img),
+		Name:    p.Name,
+		Unit:    optional(p.Unit),
+		Url:     optional(p.Url),
+	}
+}
+
+func quoteFromWS(q *ws.MessageQuoteSchema) *QuoteMessage {
+	if q == nil {
+		return nil
+	}
+
+	quote := &QuoteMessage{
+		Content: deref(q.Content),
+		From:    actorFromWS(q.From),
+		ID:      deref(q.Id),
+		Items:   messageFilesFromWS(q.Items),
+		Type:    convertEnumValue[MessageType](q.Type),
+	}
+	if q.Time != nil {
+		quote.Time = DateTimeRFC3339{Time: *q.Time}
+	}
+
+	return quote
+}
+
+func quoteToWS(q *QuoteMessage) *ws.MessageQuoteSchema {
+	if q == nil {
+		return nil
+	}
+
+	return &ws.MessageQuoteSchema{
+		Content: optional(q.Content),
+		From:    actorToWS(q.From),
+		Id:      optional(q.ID),
+		Items:   messageFilesToWS(q.Items),
+		Time:    optional(q.Time.Time),
+		Type:    enumPtr[ws.MessageTypeSchema](q.Type),
+	}
+}
+
+func transportAttachmentsFromWS(a *ws.MessageTransportAttachmentsSchema) *MessageTransportAttachments {
+	if a == nil {
+		return nil
+	}
+
+	return &MessageTransportAttachments{Suggestions: suggestionsFromWS(a.Suggestions)}
+}
+
+func transportAttachmentsToWS(a *MessageTransportAttachments) *ws.MessageTransportAttachmentsSchema {
+	if a == nil {
+		return nil
+	}
+
+	var suggestions []ws.SuggestionSchema
+	if a.Suggestions != nil {
+		suggestions = make([]ws.SuggestionSchema, 0, len(a.Suggestions))
+		for _, s := range a.Suggestions {
+			suggestions = append(suggestions, ws.SuggestionSchema{
+				Payload: optional(s.Payload),
+				Title:   optional(s.Title),
+				Type:    enumPtr[ws.SuggestionTypeSchema](s.Type),
+			})
+		}
+	}
+
+	return &ws.MessageTransportAttachmentsSchema{Suggestions: suggestions}
+}
+
+func suggestionsFromWS(schemas []ws.SuggestionSchema) []Suggestion {
+	if schemas == nil {
+		return nil
+	}
+
+	suggestions := make([]Suggestion, 0, len(schemas))
+	for _, s := range schemas {
+		suggestions = append(suggestions, Suggestion{
+			Payload: deref(s.Payload),
+			Title:   deref(s.Title),
+			Type:    convertEnumValue[SuggestionType](s.Type),
+		})
+	}
+
+	return suggestions
+}
+
+func timeFromWS(t *time.Time) *DateTimeRFC3339 {
+	if t == nil {
+		return nil
+	}
+
+	return &DateTimeRFC3339{Time: *t}
+}
+
+func timeToWS(t *DateTimeRFC3339) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// optional returns a pointer to v, or nil if v is the zero value.
+func optional[T any](v T) *T {
+	if reflect.ValueOf(&v).Elem().IsZero() {
+		return nil
+	}
+
+	return &v
+}
+
+// deref returns the value p points to, or the zero value if p is nil.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+
+		return zero
+	}
+
+	return *p
+}
+
+func convertEnum[T, S ~string](p *S) *T {
+	if p == nil {
+		return nil
+	}
+	v := T(*p)
+
+	return &v
+}
+
+func convertEnumValue[T, S ~string](p *S) T {
+	return T(deref(p))
+}
+
+func enumPtr[T, S ~string](v S) *T {
+	return optional(T(v))
+}
+
+func convertEnums[T, S ~string](values []S) []T {
+	if values == nil {
+		return nil
+	}
+
+	converted := make([]T, 0, len(values))
+	for _, v := range values {
+		converted = append(converted, T(v))
+	}
+
+	return converted
+}
@@ -0,0 +1,152 @@
+package bot_api_client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/retailcrm/bot-api-client-go/ws"
+)
+
+func TestWSConversionRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("from REST models", func(t *testing.T) {
+		t.Parallel()
+
+		var message Message
+		fillAll(reflect.ValueOf(&message).Elem())
+		// Fields without an event counterpart.
+		message.User = nil
+		assert.Equal(t, message, MessageFromWS(MessageToWS(message)))
+
+		var chat Chat
+		fillAll(reflect.ValueOf(&chat).Elem())
+		chat.LastMessage.User = nil
+		chat.LastMessage.TemplateCode = nil
+		assert.Equal(t, chat, ChatFromWS(ChatToWS(chat)))
+
+		var settings ChannelSettings
+		fillAll(reflect.ValueOf(&settings).Elem())
+		assert.Equal(t, settings, ChannelSettingsFromWS(ChannelSettingsToWS(settings)))
+
+		var actor Actor
+		fillAll(reflect.ValueOf(&actor).Elem())
+		assert.Equal(t, actor, ActorFromWS(ActorToWS(actor)))
+
+		var dialog Dialog
+		fillAll(reflect.ValueOf(&dialog).Elem())
+		assert.Equal(t, dialog, DialogFromWS(DialogToWS(dialog)))
+	})
+
+	t.Run("from event schemas", func(t *testing.T) {
+		t.Parallel()
+
+		var message ws.MessagePropertyFromMessageDataSchema
+		fillAll(reflect.ValueOf(&message).Elem())
+		// Fields without a REST counterpart.
+		message.Chat, message.CreatedAt, message.EditedAt, message.Reactions = nil, time.Time{}, nil, nil
+		message.Product.RevokedAt = nil
+		assert.Equal(t, message, MessageToWS(MessageFromWS(message)))
+
+		var chat ws.ChatSchema
+		fillAll(reflect.ValueOf(&chat).Elem())
+		chat.CreatedAt, chat.Members = time.Time{}, nil
+		chat.LastMessage.Reactions = nil
+		chat.LastMessage.Product.RevokedAt = nil
+		assert.Equal(t, chat, ChatToWS(ChatFromWS(chat)))
+
+		var settings ws.ChannelSettingsSchema
+		fillAll(reflect.ValueOf(&settings).Elem())
+		assert.Equal(t, settings, ChannelSettingsToWS(ChannelSettingsFromWS(settings)))
+
+		var user ws.UserRefSchema
+		fillAll(reflect.ValueOf(&user).Elem())
+		assert.Equal(t, user, ActorToWS(ActorFromWS(user)))
+
+		var dialog ws.DialogSchema
+		fillAll(reflect.ValueOf(&dialog).Elem())
+		assert.Equal(t, dialog, DialogToWS(DialogFromWS(dialog)))
+	})
+
+	t.Run("unset optional fields", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Equal(t, ws.ChannelSchema{Id: 1}, ChannelToWS(ChannelFromWS(ws.ChannelSchema{Id: 1})))
+		assert.Equal(t, ws.UserRefSchema{Id: 1}, ActorToWS(ActorFromWS(ws.UserRefSchema{Id: 1})))
+		assert.Equal(t, Chat{ID: 1}, ChatFromWS(ChatToWS(Chat{ID: 1})))
+	})
+}
+
+func TestMessageFromWS(t *testing.T) {
+	t.Parallel()
+
+	content, note := "Hello", "checked"
+	sent := time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	message := MessageFromWS(ws.MessagePropertyFromMessageDataSchema{
+		Id:      10,
+		ChatId:  20,
+		Content: &content,
+		Note:    &note,
+		From:    &ws.UserRefSchema{Id: 30, Type: ws.UserTypeCustomer, Name: "John"},
+		Scope:   ws.MessageScopePublic,
+		Status:  ws.MessageStatusReceived,
+		Time:    sent,
+		Type:    ws.MessageTypeText,
+	})
+
+	require.NotNil(t, message.From)
+	assert.Equal(t, Actor{ID: 30, Type: ActorTypeCustomer, Name: "John"}, *message.From)
+	assert.Equal(t, int64(10), message.ID)
+	assert.Equal(t, int64(20), message.ChatID)
+	assert.Equal(t, "checked", message.Note)
+	assert.Equal(t, MessageScopePublic, message.Scope)
+	assert.Equal(t, MessageStatusReceived, message.Status)
+	assert.Equal(t, MessageTypeText, message.Type)
+	assert.True(t, sent.Equal(message.Time.Time))
+}
+
+var fillTime = time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+// fillAll sets every field reachable from v to a non-zero value, so that round trip tests
+// notice fields a conversion forgets.
+func fillAll(v reflect.Value) {
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		v.Set(reflect.ValueOf(fillTime))
+
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillAll(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fillAll(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fillAll(v.Index(0))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fillAll(v.Index(i))
+		}
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	}
+}